@@ -11,6 +11,7 @@ import (
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pro100kartochki/mozgoemka/internal/config"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
 	"github.com/pro100kartochki/mozgoemka/internal/handler"
 	"github.com/pro100kartochki/mozgoemka/internal/middleware"
 	"github.com/pro100kartochki/mozgoemka/internal/repository"
@@ -60,6 +61,11 @@ func main() {
 	tagRepo := repository.NewTagRepository(db)
 	deckRepo := repository.NewDeckRepository(db)
 	cardRepo := repository.NewCardRepository(db)
+	subRepo := repository.NewSubscriptionRepository(db)
+	progressRepo := repository.NewCardProgressRepository(db)
+	revisionRepo := repository.NewRevisionRepository(db)
+	trashRepo := repository.NewTrashRepository(db)
+	jobRepo := repository.NewJobRepository(db)
 
 	jwtManager := jwt.NewManager(jwt.Config{
 		AccessSecret:  cfg.JWT.AccessSecret,
@@ -70,13 +76,29 @@ func main() {
 
 	v := validator.New()
 
+	jobSvc := service.NewJobService(jobRepo, logger)
+	jobSvc.SetConfig(cfg.Jobs.Dir, cfg.BaseURL, cfg.Jobs.Retention)
 	authSvc := service.NewAuthService(userRepo, tokenRepo, jwtManager)
-	userSvc := service.NewUserService(userRepo, deckRepo, cardRepo)
-	userSvc.SetUploadConfig(cfg.UploadPath, cfg.BaseURL)
 	categorySvc := service.NewCategoryService(categoryRepo)
 	tagSvc := service.NewTagService(tagRepo)
 	deckSvc := service.NewDeckService(deckRepo, cardRepo, userRepo, categoryRepo, tagRepo)
 	cardSvc := service.NewCardService(cardRepo, deckRepo, categoryRepo, tagRepo)
+	subscriptionSvc := service.NewSubscriptionService(subRepo, deckRepo, cardRepo, userRepo)
+	studySvc := service.NewStudyService(progressRepo, cardRepo, deckRepo, subRepo)
+	revisionSvc := service.NewRevisionService(revisionRepo, deckRepo)
+	revisionSvc.SetRetention(cfg.Revisions.Keep, cfg.Revisions.MaxAge)
+	trashSvc := service.NewTrashService(trashRepo, cfg.Trash.Retention)
+	importSvc := service.NewImportService(deckRepo, cardRepo, progressRepo, categoryRepo, tagSvc, cardSvc)
+	importSvc.SetUploadConfig(cfg.UploadPath, cfg.BaseURL)
+	exportSvc := service.NewExportService(deckRepo, cardRepo, categoryRepo, tagRepo)
+	exportSvc.SetUploadConfig(cfg.UploadPath, cfg.BaseURL)
+	userSvc := service.NewUserService(userRepo, deckRepo, cardRepo, tagRepo, progressRepo, subRepo, exportSvc, importSvc)
+	userSvc.SetUploadConfig(cfg.UploadPath, cfg.BaseURL)
+	importSvc.RegisterJobs(jobSvc)
+	exportSvc.RegisterJobs(jobSvc)
+	userSvc.RegisterJobs(jobSvc)
+	revisionSvc.RegisterJobs(jobSvc)
+	trashSvc.RegisterJobs(jobSvc)
 
 	authHandler := handler.NewAuthHandler(authSvc, v)
 	userHandler := handler.NewUserHandler(userSvc, v)
@@ -84,6 +106,13 @@ func main() {
 	tagHandler := handler.NewTagHandler(tagSvc, v)
 	deckHandler := handler.NewDeckHandler(deckSvc, v)
 	cardHandler := handler.NewCardHandler(cardSvc, v)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionSvc)
+	studyHandler := handler.NewStudyHandler(studySvc, v)
+	revisionHandler := handler.NewRevisionHandler(revisionSvc)
+	trashHandler := handler.NewTrashHandler(trashSvc)
+	importHandler := handler.NewImportHandler(importSvc, v)
+	exportHandler := handler.NewExportHandler(exportSvc)
+	jobHandler := handler.NewJobHandler(jobSvc)
 
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
@@ -103,7 +132,7 @@ func main() {
 		api.POST("/auth/forgot-password", authHandler.ForgotPassword)
 
 		api.GET("/categories", categoryHandler.List)
-		api.GET("/tags", tagHandler.List)
+		api.GET("/tags", middleware.OptionalAuth(jwtManager), tagHandler.List)
 		api.GET("/public/decks", deckHandler.ListPublicPaginated)
 		api.GET("/public/decks/:id", deckHandler.GetPublicByID)
 
@@ -114,26 +143,92 @@ func main() {
 			auth.GET("/users/me", userHandler.GetProfile)
 			auth.PUT("/users/me", userHandler.UpdateProfile)
 			auth.POST("/users/me/avatar", userHandler.UploadAvatar)
+			auth.POST("/users/me/export", userHandler.StartExport)
+			auth.GET("/users/me/export/:id", userHandler.ExportStatus)
+			auth.GET("/users/me/export/:id/download", userHandler.DownloadExport)
+			auth.POST("/users/me/import", userHandler.ImportAccount)
 
-			auth.POST("/categories", categoryHandler.Create)
+			moderated := auth.Group("", middleware.RequireRole(domain.RoleModerator, domain.RoleAdmin))
+			moderated.POST("/categories", categoryHandler.Create)
+			moderated.PUT("/categories/:id", categoryHandler.Update)
+			moderated.POST("/categories/:id/move", categoryHandler.Move)
+			moderated.DELETE("/categories/:id", categoryHandler.Delete)
 			auth.POST("/tags", tagHandler.Create)
+			auth.POST("/tags/suggest", tagHandler.Suggest)
+			auth.PUT("/tags/:id", tagHandler.Update)
+			auth.DELETE("/tags/:id", tagHandler.Delete)
+			auth.POST("/tags/:id/merge", tagHandler.Merge)
+
+			auth.POST("/public/decks/:id/fork", deckHandler.Fork)
+			auth.POST("/public/decks/:id/subscription", subscriptionHandler.Subscribe)
+			auth.DELETE("/public/decks/:id/subscription", subscriptionHandler.Unsubscribe)
+			auth.GET("/subscriptions", subscriptionHandler.List)
+
+			auth.GET("/study/queue", studyHandler.Queue)
+			auth.POST("/study/cards/:id/review", studyHandler.Review)
 
 			auth.GET("/decks", deckHandler.ListMine)
 			auth.POST("/decks", deckHandler.Create)
 			auth.GET("/decks/:id", deckHandler.GetByID)
 			auth.PUT("/decks/:id", deckHandler.Update)
 			auth.DELETE("/decks/:id", deckHandler.Delete)
+			auth.POST("/decks/:id/move", deckHandler.Move)
+			auth.POST("/decks/:id/merge", deckHandler.Merge)
+			auth.POST("/decks/:id/split", deckHandler.Split)
+			auth.GET("/decks/:id/export", exportHandler.ExportDeck)
+			auth.POST("/decks/:id/export", exportHandler.StartExport)
+			auth.GET("/decks/:id/print.pdf", exportHandler.PrintDeck)
+			auth.POST("/decks/:id/print", exportHandler.StartPrint)
+			auth.GET("/decks/:id/upstream", deckHandler.UpstreamDiff)
+			auth.POST("/decks/:id/upstream/apply", deckHandler.ApplyUpstream)
+			auth.GET("/trash", trashHandler.List)
+			auth.DELETE("/trash", trashHandler.Empty)
+			auth.POST("/trash/decks/:id/restore", trashHandler.RestoreDeck)
+			auth.DELETE("/trash/decks/:id", trashHandler.PurgeDeck)
+			auth.POST("/trash/cards/:id/restore", trashHandler.RestoreCard)
+			auth.DELETE("/trash/cards/:id", trashHandler.PurgeCard)
+			auth.GET("/decks/:id/revisions", revisionHandler.List)
+			auth.GET("/decks/:id/revisions/diff", revisionHandler.Diff)
+			auth.GET("/decks/:id/revisions/:rev", revisionHandler.Get)
+			auth.POST("/decks/:id/revisions/:rev/rollback", revisionHandler.Rollback)
+
+			auth.POST("/import/anki", importHandler.ImportAnki)
+			auth.POST("/import/json", importHandler.ImportJSON)
+			auth.GET("/import/jobs/:id", jobHandler.Get)
+			auth.POST("/import/csv", importHandler.UploadCSV)
+			auth.GET("/import/csv/:upload_id", importHandler.PreviewCSV)
+			auth.POST("/import/csv/:upload_id", importHandler.ImportCSV)
+			auth.POST("/import/text", importHandler.ImportText)
+			auth.POST("/import/markdown", importHandler.ImportMarkdown)
 
 			auth.GET("/cards", cardHandler.List)
 			auth.POST("/cards", cardHandler.Create)
-			auth.GET("/decks/:deck_id/cards", cardHandler.ListByDeck)
-			auth.POST("/decks/:deck_id/cards", cardHandler.Create)
+			auth.GET("/decks/:id/cards", cardHandler.ListByDeck)
+			auth.POST("/decks/:id/cards", cardHandler.Create)
+			auth.POST("/decks/:id/cards/bulk", cardHandler.BulkCreate)
+			auth.PUT("/decks/:id/cards/bulk", cardHandler.BulkUpdate)
+			auth.POST("/decks/:id/cards/bulk/delete", cardHandler.BulkDelete)
+			auth.GET("/decks/:id/duplicates", cardHandler.Duplicates)
+			auth.POST("/decks/:id/duplicates/merge", cardHandler.MergeDuplicates)
+			auth.POST("/cards/move", cardHandler.MoveMany)
+			auth.POST("/cards/copy", cardHandler.CopyMany)
 			auth.GET("/cards/:id", cardHandler.GetByID)
 			auth.PUT("/cards/:id", cardHandler.Update)
 			auth.DELETE("/cards/:id", cardHandler.Delete)
+			auth.POST("/cards/:id/move", cardHandler.Move)
+			auth.POST("/cards/:id/copy", cardHandler.Copy)
+
+			auth.GET("/jobs", jobHandler.List)
+			auth.GET("/jobs/:id", jobHandler.Get)
+			auth.POST("/jobs/:id/cancel", jobHandler.Cancel)
+			auth.GET("/jobs/:id/download", jobHandler.Download)
 		}
 	}
 
+	if err := jobSvc.Start(cfg.Jobs.Workers); err != nil {
+		logger.Fatal("jobs", zap.Error(err))
+	}
+
 	srv := &http.Server{
 		Addr:    ":" + cfg.Server.Port,
 		Handler: r,
@@ -154,5 +249,12 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("server shutdown", zap.Error(err))
 	}
+	// выполняемые задачи дорабатывают; не успевшие за JOBS_DRAIN_TIMEOUT прерываются и при
+	// оставшихся попытках возвращаются в очередь
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Jobs.DrainTimeout)
+	defer cancelDrain()
+	if err := jobSvc.Shutdown(drainCtx); err != nil {
+		logger.Warn("jobs interrupted", zap.Error(err))
+	}
 	logger.Info("server stopped")
 }
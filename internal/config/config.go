@@ -4,17 +4,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server    Server
-	Database  Database
-	JWT       JWT
+	Server     Server
+	Database   Database
+	JWT        JWT
+	Revisions  Revisions
+	Trash      Trash
+	Jobs       Jobs
 	UploadPath string
-	BaseURL   string
+	BaseURL    string
 }
 
 type Server struct {
@@ -25,6 +29,25 @@ type Database struct {
 	DSN string
 }
 
+// Revisions — ограничения хранения истории изменений наборов.
+type Revisions struct {
+	Keep   int           // сколько последних ревизий хранить на набор (0 — без ограничения)
+	MaxAge time.Duration // сколько хранить ревизии (0 — без ограничения)
+}
+
+// Trash — хранение удалённых наборов и карточек в корзине.
+type Trash struct {
+	Retention time.Duration // через сколько удалённое окончательно стирается
+}
+
+// Jobs — фоновые задачи (импорт, выгрузки, очистка).
+type Jobs struct {
+	Workers      int           // воркеров в этом процессе (0 — задачи выполняют другие экземпляры)
+	Retention    time.Duration // сколько хранятся завершённые задачи и их файлы
+	Dir          string        // каталог файлов задач
+	DrainTimeout time.Duration // сколько ждать выполняемые задачи при остановке
+}
+
 type JWT struct {
 	AccessSecret  string
 	RefreshSecret string
@@ -59,6 +82,47 @@ func Load() *Config {
 		dsn = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, pass, host, port, dbname, sslmode)
 	}
 
+	revisionsKeep := 500
+	if v := os.Getenv("REVISIONS_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			revisionsKeep = n
+		}
+	}
+	var revisionsMaxAge time.Duration
+	if v := os.Getenv("REVISIONS_MAX_AGE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			revisionsMaxAge = d
+		}
+	}
+
+	trashRetention := 30 * 24 * time.Hour // 30 дней
+	if v := os.Getenv("TRASH_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			trashRetention = d
+		}
+	}
+
+	jobWorkers := 4
+	if v := os.Getenv("JOBS_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			jobWorkers = n
+		}
+	}
+	jobRetention := 24 * time.Hour
+	if v := os.Getenv("JOBS_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			jobRetention = d
+		}
+	}
+	jobDrain := 30 * time.Second
+	if v := os.Getenv("JOBS_DRAIN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			jobDrain = d
+		}
+	}
+	jobDir := getEnv("JOBS_DIR", filepath.Join(os.TempDir(), "mozgoemka-jobs"))
+	jobDir, _ = filepath.Abs(jobDir)
+
 	uploadPath := getEnv("UPLOAD_PATH", "./uploads")
 	uploadPath, _ = filepath.Abs(uploadPath)
 	baseURL := getEnv("SERVER_BASE_URL", "http://localhost:8080")
@@ -78,6 +142,17 @@ func Load() *Config {
 			AccessTTL:     accessTTL,
 			RefreshTTL:    refreshTTL,
 		},
+		Revisions: Revisions{
+			Keep:   revisionsKeep,
+			MaxAge: revisionsMaxAge,
+		},
+		Trash: Trash{Retention: trashRetention},
+		Jobs: Jobs{
+			Workers:      jobWorkers,
+			Retention:    jobRetention,
+			Dir:          jobDir,
+			DrainTimeout: jobDrain,
+		},
 		UploadPath: uploadPath,
 		BaseURL:    baseURL,
 	}
@@ -0,0 +1,74 @@
+package domain
+
+import "time"
+
+// Архив данных аккаунта (POST /api/users/me/export) — zip со следующими файлами:
+//
+//	manifest.json   AccountManifest
+//	profile.json    AccountProfile
+//	settings.json   AccountSettings
+//	tags.json       личные теги — полные пути, ["lang", "lang::de"]
+//	decks.json      []AccountDeck
+//	cards.jsonl     AccountCard, по одной на строку
+//	progress.jsonl  CardProgress, по одному на строку
+//	reviews.jsonl   ReviewLog, по одной записи на строку
+//	media/...       файлы из загрузок, на которые ссылаются карточки, и аватар
+//
+// ID наборов и карточек — из исходного аккаунта: при восстановлении (POST /api/users/me/import)
+// создаются новые, ссылки прогресса и журнала пересчитываются. Прогресс по карточкам чужих
+// публичных наборов сохраняет их ID и восстанавливается вместе с подпиской. Версия растёт
+// только при несовместимых изменениях, как у резервной копии набора.
+const (
+	AccountArchiveFormat  = "mozgoemka.account"
+	AccountArchiveVersion = 1
+)
+
+type AccountManifest struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Counts     AccountCounts     `json:"counts"`
+	Media      map[string]string `json:"media"` // файл архива → исходный URL
+}
+
+type AccountCounts struct {
+	Decks    int `json:"decks"`
+	Cards    int `json:"cards"`
+	Progress int `json:"progress"`
+	Reviews  int `json:"reviews"`
+	Media    int `json:"media"`
+}
+
+type AccountProfile struct {
+	Email     string    `json:"email"`
+	Username  *string   `json:"username"`
+	Avatar    string    `json:"avatar,omitempty"` // файл архива
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AccountSettings — настройки аккаунта; пока это подписки на публичные наборы.
+type AccountSettings struct {
+	Subscriptions []int `json:"subscriptions"`
+}
+
+type AccountDeck struct {
+	ID          int             `json:"id"`
+	ParentID    *int            `json:"parent_id"`
+	Title       string          `json:"title"`
+	Description *string         `json:"description"`
+	IsPublic    bool            `json:"is_public"`
+	Category    *BackupCategory `json:"category"`
+	Tags        []string        `json:"tags"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type AccountCard struct {
+	ID        int             `json:"id"`
+	DeckID    int             `json:"deck_id"`
+	Question  string          `json:"question"`
+	Answer    string          `json:"answer"`
+	Category  *BackupCategory `json:"category"`
+	Tags      []string        `json:"tags"`
+	CreatedAt time.Time       `json:"created_at"`
+}
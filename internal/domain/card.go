@@ -3,13 +3,18 @@ package domain
 import "time"
 
 type Card struct {
-	ID         int        `json:"id"`
-	DeckID     int        `json:"deck_id"`
-	Question   string     `json:"question"`
-	Answer     string     `json:"answer"`
-	CategoryID *int       `json:"category_id,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	Category   *Category  `json:"category,omitempty"`
-	Tags       []Tag      `json:"tags,omitempty"`
+	ID           int       `json:"id"`
+	DeckID       int       `json:"deck_id"`
+	Question     string    `json:"question"`
+	Answer       string    `json:"answer"`
+	CategoryID   *int      `json:"category_id,omitempty"`
+	OriginCardID *int      `json:"origin_card_id,omitempty"` // карточка исходного набора, из которой скопирована карточка форка
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Category     *Category `json:"category,omitempty"`
+	Tags         []Tag     `json:"tags,omitempty"`
+
+	SuggestedTags    []TagSuggestion   `json:"suggested_tags,omitempty"`    // подсказки тегов при создании (suggest_tags)
+	Match            *SearchMatch      `json:"match,omitempty"`             // совпадение при поиске (search)
+	DuplicateWarning *DuplicateWarning `json:"duplicate_warning,omitempty"` // похожие карточки при массовом создании и изменении
 }
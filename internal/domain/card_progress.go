@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// CardProgress — состояние интервальных повторений карточки для пользователя (SM-2).
+type CardProgress struct {
+	UserID         int        `json:"user_id"`
+	CardID         int        `json:"card_id"`
+	EaseFactor     float64    `json:"ease_factor"`
+	IntervalDays   int        `json:"interval_days"`
+	Repetitions    int        `json:"repetitions"`
+	DueAt          time.Time  `json:"due_at"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at,omitempty"`
+}
+
+// ReviewLog — запись журнала ответов: оценка и состояние повторений после ответа.
+type ReviewLog struct {
+	ID           int64     `json:"id"`
+	UserID       int       `json:"user_id"`
+	CardID       int       `json:"card_id"`
+	Grade        int       `json:"grade"`
+	IntervalDays int       `json:"interval_days"`
+	EaseFactor   float64   `json:"ease_factor"`
+	DurationMs   int       `json:"duration_ms"`
+	ReviewedAt   time.Time `json:"reviewed_at"`
+}
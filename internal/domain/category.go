@@ -2,8 +2,13 @@ package domain
 
 import "time"
 
+// Category — категория в дереве категорий; управляют категориями moderator и admin.
 type Category struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	ParentID  *int      `json:"parent_id,omitempty"` // родительская категория; nil — верхний уровень
+	SortOrder int       `json:"sort_order"`
+	Icon      string    `json:"icon,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
@@ -3,16 +3,24 @@ package domain
 import "time"
 
 type Deck struct {
-	ID          int        `json:"id"`
-	UserID      int        `json:"user_id"`
-	Title       string     `json:"title"`
-	Description *string    `json:"description,omitempty"`
-	CategoryID  *int       `json:"category_id,omitempty"`
-	IsPublic    bool       `json:"is_public"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	Category    *Category  `json:"category,omitempty"`
-	Tags        []Tag      `json:"tags,omitempty"`
-	CardsCount  int        `json:"cards_count,omitempty"`
-	Cards       []Card     `json:"cards,omitempty"`
+	ID                int       `json:"id"`
+	UserID            int       `json:"user_id"`
+	Title             string    `json:"title"`
+	Description       *string   `json:"description,omitempty"`
+	CategoryID        *int      `json:"category_id,omitempty"`
+	IsPublic          bool      `json:"is_public"`
+	Version           int       `json:"version"`
+	ForkedFromDeckID  *int      `json:"forked_from_deck_id,omitempty"` // исходный набор форка
+	ForkedFromVersion *int      `json:"forked_from_version,omitempty"` // версия исходного набора на момент форка
+	ParentID          *int      `json:"parent_id,omitempty"`           // родительский набор
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Category          *Category `json:"category,omitempty"`
+	Tags              []Tag     `json:"tags,omitempty"`
+	CardsCount        int       `json:"cards_count,omitempty"`
+	TotalCardsCount   int       `json:"total_cards_count,omitempty"` // вместе с вложенными наборами
+	ForksCount        int       `json:"forks_count,omitempty"`
+	Cards             []Card    `json:"cards,omitempty"`
+
+	Match *SearchMatch `json:"match,omitempty"` // совпадение при поиске (search)
 }
@@ -87,14 +87,33 @@ type DecksListResponse struct {
 }
 
 type DeckListItem struct {
-	ID          int        `json:"id"`
-	Title       string     `json:"title"`
-	Description *string    `json:"description,omitempty"`
-	Category    *Category `json:"category,omitempty"`
-	Tags        []Tag      `json:"tags,omitempty"`
-	IsPublic    bool       `json:"is_public"`
-	CardsCount  int        `json:"cards_count"`
-	CreatedAt   string     `json:"created_at"`
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	Description     *string   `json:"description,omitempty"`
+	Category        *Category `json:"category,omitempty"`
+	Tags            []Tag     `json:"tags,omitempty"`
+	IsPublic        bool      `json:"is_public"`
+	ParentID        *int      `json:"parent_id,omitempty"`
+	CardsCount      int       `json:"cards_count"`
+	TotalCardsCount int       `json:"total_cards_count"` // вместе с вложенными наборами
+	CreatedAt       string    `json:"created_at"`
+
+	Match *SearchMatch `json:"match,omitempty"` // совпадение при поиске (search)
+}
+
+// DeckTreeResponse (200) — GET /api/decks?view=tree
+type DeckTreeResponse struct {
+	Decks []DeckTreeNode `json:"decks"`
+}
+
+type DeckTreeNode struct {
+	DeckListItem
+	Children []DeckTreeNode `json:"children"`
+}
+
+// MoveDeckRequest — POST /api/decks/:id/move; parent_id = null переносит набор на верхний уровень
+type MoveDeckRequest struct {
+	ParentID *int `json:"parent_id"`
 }
 
 type Pagination struct {
@@ -109,6 +128,7 @@ type CreateDeckRequest struct {
 	CategoryID  *int    `json:"category_id,omitempty"`
 	IsPublic    bool    `json:"is_public"`
 	TagIDs      []int   `json:"tag_ids,omitempty"`
+	ParentID    *int    `json:"parent_id,omitempty"`
 }
 
 type UpdateDeckRequest struct {
@@ -120,22 +140,28 @@ type UpdateDeckRequest struct {
 }
 
 type CreateCardRequest struct {
-	DeckID     *int   `json:"deck_id,omitempty"` // обязателен для POST /api/cards
-	Question   string `json:"question" binding:"required"`
-	Answer     string `json:"answer" binding:"required"`
-	CategoryID *int   `json:"category_id,omitempty"`
-	TagIDs     []int  `json:"tag_ids,omitempty"`
+	DeckID      *int   `json:"deck_id,omitempty"` // обязателен для POST /api/cards
+	Question    string `json:"question" binding:"required,max=10000" validate:"required,max=10000"`
+	Answer      string `json:"answer" binding:"required,max=10000" validate:"required,max=10000"`
+	CategoryID  *int   `json:"category_id,omitempty"`
+	TagIDs      []int  `json:"tag_ids,omitempty"`
+	SuggestTags bool   `json:"suggest_tags,omitempty"` // вернуть suggested_tags по тексту карточки
+
+	NewTags []string `json:"-"` // личные теги по имени, создаются вместе с карточкой (импорт таблиц)
 }
 
 // CardListItem — элемент списка GET /api/cards
 type CardListItem struct {
-	ID        int        `json:"id"`
-	Question  string     `json:"question"`
-	Answer    string     `json:"answer"`
-	Deck      DeckBrief  `json:"deck"`
-	Category  *Category  `json:"category,omitempty"`
-	Tags      []Tag      `json:"tags,omitempty"`
-	CreatedAt string     `json:"created_at"`
+	ID               int               `json:"id"`
+	Question         string            `json:"question"`
+	Answer           string            `json:"answer"`
+	Deck             DeckBrief         `json:"deck"`
+	Category         *Category         `json:"category,omitempty"`
+	Tags             []Tag             `json:"tags,omitempty"`
+	CreatedAt        string            `json:"created_at"`
+	DuplicateWarning *DuplicateWarning `json:"duplicate_warning,omitempty"` // только в ответе на создание и изменение
+	SuggestedTags    []TagSuggestion   `json:"suggested_tags,omitempty"`    // только в ответе на создание с suggest_tags
+	Match            *SearchMatch      `json:"match,omitempty"`             // совпадение при поиске (search)
 }
 
 type DeckBrief struct {
@@ -161,19 +187,96 @@ type CategoriesResponse struct {
 	Categories []Category `json:"categories"`
 }
 
+// CategoryTreeResponse (200) — GET /api/categories?view=tree
+type CategoryTreeResponse struct {
+	Categories []CategoryTreeNode `json:"categories"`
+}
+
+type CategoryTreeNode struct {
+	Category
+	Children []CategoryTreeNode `json:"children"`
+}
+
+// CreateCategoryRequest — POST /api/categories; slug по умолчанию строится из названия
 type CreateCategoryRequest struct {
-	Name string `json:"name" binding:"required,max=100"`
+	Name      string `json:"name" binding:"required,max=100"`
+	Slug      string `json:"slug" binding:"max=120"`
+	ParentID  *int   `json:"parent_id,omitempty"`
+	SortOrder int    `json:"sort_order"`
+	Icon      string `json:"icon" binding:"max=64"`
+}
+
+// UpdateCategoryRequest — PUT /api/categories/:id; переданные поля заменяются
+type UpdateCategoryRequest struct {
+	Name      *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Slug      *string `json:"slug,omitempty" binding:"omitempty,max=120"`
+	SortOrder *int    `json:"sort_order,omitempty"`
+	Icon      *string `json:"icon,omitempty" binding:"omitempty,max=64"`
+}
+
+// MoveCategoryRequest — POST /api/categories/:id/move; parent_id = null переносит категорию на верхний уровень
+type MoveCategoryRequest struct {
+	ParentID  *int `json:"parent_id"`
+	SortOrder *int `json:"sort_order,omitempty"`
+}
+
+// DeleteCategoryResponse (200) — DELETE /api/categories/:id?reassign_to=: наборы и карточки
+// удалённой категории переходят в reassign_to (по умолчанию — в родительскую категорию),
+// подкатегории — в родительскую категорию
+type DeleteCategoryResponse struct {
+	ReassignedTo    *int `json:"reassigned_to"`
+	DecksReassigned int  `json:"decks_reassigned"`
+	CardsReassigned int  `json:"cards_reassigned"`
+	ChildrenMoved   int  `json:"children_moved"`
 }
 
 // TagsResponse (200) — GET /api/tags
 type TagsResponse struct {
-	Tags []Tag `json:"tags"`
+	Tags []TagListItem `json:"tags"`
+}
+
+// TagListItem — тег с количеством наборов и карточек, к которым он привязан
+type TagListItem struct {
+	Tag
+	DecksCount int `json:"decks_count"`
+	CardsCount int `json:"cards_count"`
 }
 
 type CreateTagRequest struct {
+	Name   string `json:"name" binding:"required,max=100"`
+	Global bool   `json:"global"` // общий тег (moderator, admin); по умолчанию тег личный
+}
+
+// UpdateTagRequest — PUT /api/tags/:id
+type UpdateTagRequest struct {
 	Name string `json:"name" binding:"required,max=100"`
 }
 
+// SuggestTagsRequest — POST /api/tags/suggest: черновик карточки или набора
+type SuggestTagsRequest struct {
+	Question      string `json:"question" binding:"max=10000"`
+	Answer        string `json:"answer" binding:"max=10000"`
+	Text          string `json:"text" binding:"max=10000"` // произвольный текст, например название и описание набора
+	ExcludeTagIDs []int  `json:"exclude_tag_ids,omitempty"` // уже выбранные теги
+	Limit         int    `json:"limit" binding:"omitempty,min=1,max=20"`
+}
+
+// TagSuggestionsResponse (200) — POST /api/tags/suggest
+type TagSuggestionsResponse struct {
+	Suggestions []TagSuggestion `json:"suggestions"`
+}
+
+// TagSuggestion — предлагаемый тег; score от 0 до 1, чем больше, тем увереннее
+type TagSuggestion struct {
+	Tag   Tag     `json:"tag"`
+	Score float64 `json:"score"`
+}
+
+// MergeTagRequest — POST /api/tags/:id/merge: тег :id заменяется тегом target_tag_id и удаляется
+type MergeTagRequest struct {
+	TargetTagID int `json:"target_tag_id" binding:"required"`
+}
+
 // PublicDeckListItem — элемент GET /api/public/decks
 type PublicDeckListItem struct {
 	ID          int        `json:"id"`
@@ -182,8 +285,11 @@ type PublicDeckListItem struct {
 	Category    *Category  `json:"category,omitempty"`
 	Tags        []Tag      `json:"tags,omitempty"`
 	CardsCount  int        `json:"cards_count"`
+	ForksCount  int        `json:"forks_count"`
 	Author      DeckAuthor `json:"author"`
 	CreatedAt   string     `json:"created_at"`
+
+	Match *SearchMatch `json:"match,omitempty"` // совпадение при поиске (search)
 }
 
 type DeckAuthor struct {
@@ -200,6 +306,8 @@ type PublicDeckDetail struct {
 	Category    *Category       `json:"category,omitempty"`
 	Tags        []Tag           `json:"tags,omitempty"`
 	CardsCount  int             `json:"cards_count"`
+	ForksCount  int             `json:"forks_count"`
+	Version     int             `json:"version"`
 	Author      DeckAuthor      `json:"author"`
 	Cards       []PublicCardItem `json:"cards"`
 }
@@ -215,3 +323,440 @@ type PublicDecksListResponse struct {
 	Decks      []PublicDeckListItem `json:"decks"`
 	Pagination Pagination           `json:"pagination"`
 }
+
+// Режимы массовых операций с карточками
+const (
+	BulkModeAllOrNothing = "all_or_nothing" // при любой ошибке ничего не сохраняется
+	BulkModeBestEffort   = "best_effort"    // сохраняются корректные элементы, ошибочные возвращаются в errors
+)
+
+// BulkCreateCardsRequest — POST /api/decks/:id/cards/bulk
+type BulkCreateCardsRequest struct {
+	Cards []CreateCardRequest `json:"cards" binding:"required,min=1,max=1000"`
+	Mode  string              `json:"mode,omitempty" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+type BulkUpdateCardItem struct {
+	ID         int     `json:"id" binding:"required"`
+	Question   *string `json:"question,omitempty" binding:"omitempty,min=1,max=10000" validate:"omitempty,min=1,max=10000"`
+	Answer     *string `json:"answer,omitempty" binding:"omitempty,min=1,max=10000" validate:"omitempty,min=1,max=10000"`
+	CategoryID *int    `json:"category_id,omitempty"`
+	TagIDs     []int   `json:"tag_ids,omitempty"`
+}
+
+// BulkUpdateCardsRequest — PUT /api/decks/:id/cards/bulk
+type BulkUpdateCardsRequest struct {
+	Cards []BulkUpdateCardItem `json:"cards" binding:"required,min=1,max=1000"`
+	Mode  string               `json:"mode,omitempty" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+// BulkDeleteCardsRequest — POST /api/decks/:id/cards/bulk/delete
+type BulkDeleteCardsRequest struct {
+	IDs  []int  `json:"ids" binding:"required,min=1,max=1000"`
+	Mode string `json:"mode,omitempty" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+// BulkItemError — ошибки валидации элемента по его индексу в запросе
+type BulkItemError struct {
+	Index  int               `json:"index"`
+	Errors map[string]string `json:"errors"`
+}
+
+// BulkCardsResponse — результат массовой операции
+type BulkCardsResponse struct {
+	Mode       string          `json:"mode"`
+	Cards      []Card          `json:"cards,omitempty"`
+	DeletedIDs []int           `json:"deleted_ids,omitempty"`
+	Errors     []BulkItemError `json:"errors,omitempty"`
+}
+
+// TransferCardRequest — POST /api/cards/:id/move, POST /api/cards/:id/copy
+type TransferCardRequest struct {
+	TargetDeckID  int  `json:"target_deck_id" binding:"required,min=1" validate:"required,min=1"`
+	ResetProgress bool `json:"reset_progress"` // true — сбросить состояние повторений, false — сохранить
+}
+
+// TransferCardsRequest — POST /api/cards/move, POST /api/cards/copy
+type TransferCardsRequest struct {
+	CardIDs       []int `json:"card_ids" binding:"required,min=1,max=1000,dive,min=1" validate:"required,min=1,max=1000,dive,min=1"`
+	TargetDeckID  int   `json:"target_deck_id" binding:"required,min=1" validate:"required,min=1"`
+	ResetProgress bool  `json:"reset_progress"`
+}
+
+// TransferCardsResponse — карточки в целевом наборе после переноса/копирования
+type TransferCardsResponse struct {
+	TargetDeckID int    `json:"target_deck_id"`
+	Cards        []Card `json:"cards"`
+}
+
+// UpstreamDiffResponse — GET /api/decks/:id/upstream: отличия форка от исходного набора
+type UpstreamDiffResponse struct {
+	UpstreamDeckID    int                  `json:"upstream_deck_id"`
+	UpstreamVersion   int                  `json:"upstream_version"`
+	ForkedFromVersion int                  `json:"forked_from_version"`
+	Added             []UpstreamCardChange `json:"added"`     // новые карточки исходного набора
+	Changed           []UpstreamCardChange `json:"changed"`   // изменены только в исходном наборе
+	Removed           []UpstreamCardChange `json:"removed"`   // удалены из исходного набора, в форке не менялись
+	Conflicts         []UpstreamCardChange `json:"conflicts"` // изменены и в исходном наборе, и в форке
+}
+
+// UpstreamCardChange — изменение одной карточки; связь по ID исходной карточки.
+// Theirs == nil — карточка удалена из исходного набора, Mine == nil — её нет в форке.
+type UpstreamCardChange struct {
+	OriginCardID int          `json:"origin_card_id"`
+	CardID       *int         `json:"card_id,omitempty"`
+	Base         *CardContent `json:"base,omitempty"`
+	Mine         *CardContent `json:"mine,omitempty"`
+	Theirs       *CardContent `json:"theirs,omitempty"`
+}
+
+// ApplyUpstreamRequest — POST /api/decks/:id/upstream/apply
+type ApplyUpstreamRequest struct {
+	Apply    []int `json:"apply"`     // origin_card_id изменений, которые нужно принять
+	KeepMine []int `json:"keep_mine"` // origin_card_id конфликтов, в которых остаётся версия форка
+}
+
+// SubscriptionsResponse (200) — GET /api/subscriptions
+type SubscriptionsResponse struct {
+	Subscriptions []SubscriptionItem `json:"subscriptions"`
+}
+
+// SubscriptionItem — набор, на который подписан пользователь.
+// Available == false, если автор сделал набор приватным: карточки не попадают в очередь изучения.
+type SubscriptionItem struct {
+	DeckID       int        `json:"deck_id"`
+	Title        string     `json:"title"`
+	Author       DeckAuthor `json:"author"`
+	CardsCount   int        `json:"cards_count"`
+	Available    bool       `json:"available"`
+	SubscribedAt string     `json:"subscribed_at"`
+}
+
+// StudyQueueResponse (200) — GET /api/study/queue
+type StudyQueueResponse struct {
+	Cards []StudyCard `json:"cards"`
+}
+
+// StudyCard — карточка в очереди изучения (сначала просроченные повторения, затем новые).
+type StudyCard struct {
+	ID         int           `json:"id"`
+	Question   string        `json:"question"`
+	Answer     string        `json:"answer"`
+	Deck       DeckBrief     `json:"deck"`
+	Subscribed bool          `json:"subscribed"` // карточка из набора по подписке
+	Progress   *CardProgress `json:"progress,omitempty"`
+}
+
+// ReviewCardRequest — POST /api/study/cards/:id/review; grade — оценка ответа по SM-2 (0–5)
+type ReviewCardRequest struct {
+	Grade *int `json:"grade" binding:"required,min=0,max=5"`
+}
+
+// DeckRevisionsResponse (200) — GET /api/decks/:id/revisions
+type DeckRevisionsResponse struct {
+	Revisions  []DeckRevision `json:"revisions"`
+	Pagination Pagination     `json:"pagination"`
+}
+
+// DeckAtRevision — GET /api/decks/:id/revisions/:rev: набор в состоянии на момент ревизии
+type DeckAtRevision struct {
+	RevisionID int            `json:"revision_id"`
+	Deck       DeckSnapshot   `json:"deck"`
+	Cards      []CardSnapshot `json:"cards"`
+}
+
+// RevisionDiffResponse — GET /api/decks/:id/revisions/diff?from=&to=
+type RevisionDiffResponse struct {
+	From    int                    `json:"from"`
+	To      int                    `json:"to"`
+	Deck    map[string]FieldChange `json:"deck,omitempty"`
+	Added   []CardSnapshot         `json:"added"`
+	Removed []CardSnapshot         `json:"removed"`
+	Changed []CardRevisionChange   `json:"changed"`
+}
+
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+type CardRevisionChange struct {
+	CardID int          `json:"card_id"`
+	Fields []string     `json:"fields"`
+	From   CardSnapshot `json:"from"`
+	To     CardSnapshot `json:"to"`
+}
+
+// RollbackRequest — POST /api/decks/:id/revisions/:rev/rollback; card_id — откатить только одну карточку
+type RollbackRequest struct {
+	CardID *int `json:"card_id,omitempty"`
+}
+
+// TrashResponse (200) — GET /api/trash
+type TrashResponse struct {
+	Decks []TrashDeckItem `json:"decks"`
+	Cards []TrashCardItem `json:"cards"`
+}
+
+type TrashDeckItem struct {
+	ID         int    `json:"id"`
+	Title      string `json:"title"`
+	CardsCount int    `json:"cards_count"`
+	DeletedAt  string `json:"deleted_at"`
+	PurgeAt    string `json:"purge_at"` // когда набор будет удалён окончательно
+}
+
+type TrashCardItem struct {
+	ID        int       `json:"id"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Deck      DeckBrief `json:"deck"`
+	DeletedAt string    `json:"deleted_at"`
+	PurgeAt   string    `json:"purge_at"`
+}
+
+// MergeDecksRequest — POST /api/decks/:id/merge: карточки source_deck_ids переносятся в набор :id
+type MergeDecksRequest struct {
+	SourceDeckIDs []int `json:"source_deck_ids" binding:"required,min=1,max=50"`
+	KeepSources   bool  `json:"keep_sources"` // не перемещать опустевшие наборы в корзину
+}
+
+// MergeDecksResponse (200) — отчёт об объединении
+type MergeDecksResponse struct {
+	Deck           DeckBrief         `json:"deck"`
+	Moved          []CardMove        `json:"moved"`
+	Duplicates     []MergedDuplicate `json:"duplicates"`
+	TagsAdded      []Tag             `json:"tags_added"`
+	DeletedDeckIDs []int             `json:"deleted_deck_ids"`
+}
+
+type CardMove struct {
+	CardID     int `json:"card_id"`
+	FromDeckID int `json:"from_deck_id"`
+	ToDeckID   int `json:"to_deck_id"`
+}
+
+// MergedDuplicate — карточка, совпавшая с уже имеющейся; она перемещена в корзину
+type MergedDuplicate struct {
+	CardID     int `json:"card_id"`
+	FromDeckID int `json:"from_deck_id"`
+	KeptCardID int `json:"kept_card_id"`
+}
+
+// Способы разделения набора
+const (
+	SplitByTag      = "tag"
+	SplitByCategory = "category"
+)
+
+// SplitDeckRequest — POST /api/decks/:id/split
+type SplitDeckRequest struct {
+	By       string `json:"by" binding:"required,oneof=tag category"`
+	TagIDs   []int  `json:"tag_ids,omitempty"` // для by=tag: какие теги выделять и в каком порядке (по умолчанию все)
+	SubDecks bool   `json:"sub_decks"`         // создать новые наборы вложенными в исходный
+}
+
+// SplitDeckResponse (200) — отчёт о разделении
+type SplitDeckResponse struct {
+	SourceDeckID   int             `json:"source_deck_id"`
+	Decks          []SplitDeckItem `json:"decks"`
+	RemainingCards int             `json:"remaining_cards"`
+}
+
+type SplitDeckItem struct {
+	Deck       DeckBrief `json:"deck"`
+	TagID      *int      `json:"tag_id,omitempty"`
+	CategoryID *int      `json:"category_id,omitempty"`
+	CardIDs    []int     `json:"card_ids"`
+}
+
+// Область поиска дубликатов (параметр duplicates при создании и изменении карточки, scope в отчёте)
+const (
+	DuplicatesDeck = "deck" // в том же наборе
+	DuplicatesAll  = "all"  // во всех наборах пользователя
+	DuplicatesNone = "none" // не проверять
+)
+
+// DuplicateCandidate — карточка, похожая на проверяемую
+type DuplicateCandidate struct {
+	ID         int       `json:"id"`
+	Question   string    `json:"question"`
+	Answer     string    `json:"answer"`
+	Deck       DeckBrief `json:"deck"`
+	Similarity float64   `json:"similarity"` // похожесть вопросов, 0..1
+	Exact      bool      `json:"exact"`      // вопросы совпадают без учёта регистра и пробелов
+}
+
+// DuplicateWarning — предупреждение о возможных дубликатах; карточка при этом сохраняется
+type DuplicateWarning struct {
+	Message    string               `json:"message"`
+	Candidates []DuplicateCandidate `json:"candidates"`
+}
+
+// DeckDuplicatesResponse (200) — GET /api/decks/:id/duplicates
+type DeckDuplicatesResponse struct {
+	Groups []DuplicateGroup `json:"groups"`
+}
+
+// DuplicateGroup — группа похожих карточек; similarity карточки — наибольшая похожесть на другую карточку группы
+type DuplicateGroup struct {
+	Exact bool                 `json:"exact"`
+	Cards []DuplicateCandidate `json:"cards"`
+}
+
+// MergeDuplicatesRequest — POST /api/decks/:id/duplicates/merge
+type MergeDuplicatesRequest struct {
+	KeepCardID int   `json:"keep_card_id" binding:"required"`
+	CardIDs    []int `json:"card_ids" binding:"required,min=1,max=100"`
+}
+
+// MergeDuplicatesResponse (200)
+type MergeDuplicatesResponse struct {
+	KeptCardID     int   `json:"kept_card_id"`
+	RemovedCardIDs []int `json:"removed_card_ids"`
+}
+
+// Кодировки таблиц при импорте CSV/TSV
+const (
+	EncodingUTF8   = "utf-8"
+	EncodingCP1251 = "cp1251"
+)
+
+// CSVParseOptions — параметры разбора таблицы; пустые поля определяются автоматически
+type CSVParseOptions struct {
+	Delimiter string `json:"delimiter,omitempty" form:"delimiter" binding:"omitempty,max=3"` // один символ или tab
+	Encoding  string `json:"encoding,omitempty" form:"encoding" binding:"omitempty,oneof=utf-8 cp1251"`
+	HasHeader *bool  `json:"has_header,omitempty" form:"has_header"`
+}
+
+// CSVMapping — номера колонок таблицы (с 0) для полей карточки
+type CSVMapping struct {
+	Question int  `json:"question" binding:"min=0"`
+	Answer   int  `json:"answer" binding:"min=0"`
+	Tags     *int `json:"tags,omitempty" binding:"omitempty,min=0"`     // имена тегов через tag_separator
+	Category *int `json:"category,omitempty" binding:"omitempty,min=0"` // название или slug категории
+}
+
+// CSVPreviewResponse — POST /api/import/csv, GET /api/import/csv/:upload_id
+type CSVPreviewResponse struct {
+	UploadID  string     `json:"upload_id"`
+	Delimiter string     `json:"delimiter"`
+	Encoding  string     `json:"encoding"`
+	HasHeader bool       `json:"has_header"`
+	Columns   []string   `json:"columns"`
+	Rows      [][]string `json:"rows"` // первые строки данных
+	TotalRows int        `json:"total_rows"`
+	Mapping   CSVMapping `json:"mapping"` // предложенное соответствие колонок
+}
+
+// CSVImportRequest — POST /api/import/csv/:upload_id
+type CSVImportRequest struct {
+	CSVParseOptions
+	DeckID       int        `json:"deck_id" binding:"required"`
+	Mapping      CSVMapping `json:"mapping"`
+	TagSeparator string     `json:"tag_separator,omitempty" binding:"omitempty,max=3"` // по умолчанию запятая
+	Mode         string     `json:"mode,omitempty" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+// CSVRow — карточка из строки таблицы; Row — номер строки в файле (с 1, включая заголовок)
+type CSVRow struct {
+	Row    int
+	Card   CreateCardRequest
+	Errors map[string]string
+}
+
+// CSVRowError — ошибки строки таблицы
+type CSVRowError struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
+
+// CSVImportResponse (201)
+type CSVImportResponse struct {
+	Mode    string        `json:"mode"`
+	Created int           `json:"created"`
+	Errors  []CSVRowError `json:"errors,omitempty"`
+}
+
+// TextImportRequest — POST /api/import/text: вставленный текст вида «термин<TAB>определение» (как копирует Quizlet).
+// Разделители — tab, comma, dash (« - »), newline, semicolon, blank_line (пустая строка) или произвольная строка;
+// \t и \n в произвольной строке означают табуляцию и перевод строки.
+type TextImportRequest struct {
+	DeckID        int    `json:"deck_id" binding:"required"`
+	Text          string `json:"text" binding:"required,max=1000000"`
+	TermSeparator string `json:"term_separator,omitempty" binding:"omitempty,max=10"` // между термином и определением, по умолчанию tab
+	CardSeparator string `json:"card_separator,omitempty" binding:"omitempty,max=10"` // между карточками, по умолчанию newline
+	Preview       bool   `json:"preview,omitempty"`                                   // только разобрать, карточки не создавать
+	Mode          string `json:"mode,omitempty" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+// TextCard — карточка из вставленного текста; Line — строка текста, с которой она начинается (с 1)
+type TextCard struct {
+	Line     int               `json:"line"`
+	Question string            `json:"question"`
+	Answer   string            `json:"answer"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// TextPreviewResponse — предпросмотр (preview=true)
+type TextPreviewResponse struct {
+	Cards   []TextCard `json:"cards"`
+	Total   int        `json:"total"`
+	Invalid int        `json:"invalid"`
+}
+
+// TextImportResponse (201)
+type TextImportResponse struct {
+	Mode    string     `json:"mode"`
+	Created int        `json:"created"`
+	Errors  []TextCard `json:"errors,omitempty"`
+}
+
+// MarkdownImportRequest — POST /api/import/markdown: конспект в Markdown.
+// Заголовки становятся наборами и вложенными наборами внутри deck_id (или на верхнем уровне);
+// карточки до первого заголовка попадают в deck_id.
+type MarkdownImportRequest struct {
+	DeckID *int   `json:"deck_id,omitempty"`
+	Text   string `json:"text" binding:"required,max=2000000"`
+	DryRun bool   `json:"dry_run,omitempty"` // только показать, что будет создано и обновлено
+}
+
+// Действия с карточкой при импорте конспекта
+const (
+	MarkdownCreate    = "create"
+	MarkdownUpdate    = "update"
+	MarkdownUnchanged = "unchanged"
+	MarkdownSkip      = "skip"
+)
+
+// MarkdownDeckItem — набор из заголовка; ID = nil в dry_run у набора, который будет создан
+type MarkdownDeckItem struct {
+	Path    string `json:"path"` // Лекция 1 / Термины
+	ID      *int   `json:"id"`
+	Created bool   `json:"created"`
+}
+
+// MarkdownCardItem — карточка конспекта. Kind — qa (Q:/A:), definition (список определений),
+// inline (термин :: определение), cloze (==выделение==)
+type MarkdownCardItem struct {
+	Line     int    `json:"line"`
+	Deck     string `json:"deck"`
+	Kind     string `json:"kind"`
+	Anchor   string `json:"anchor"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Action   string `json:"action"`
+	CardID   *int   `json:"card_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MarkdownImportResponse (200 в dry_run, 201)
+type MarkdownImportResponse struct {
+	DryRun    bool               `json:"dry_run"`
+	Decks     []MarkdownDeckItem `json:"decks"`
+	Cards     []MarkdownCardItem `json:"cards"`
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Skipped   int                `json:"skipped"`
+}
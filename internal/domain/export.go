@@ -0,0 +1,75 @@
+package domain
+
+import "time"
+
+// Форматы выгрузки набора (GET /decks/:id/export?format=).
+const (
+	ExportAPKG     = "apkg" // пакет Anki
+	ExportCSV      = "csv"  // таблица: question, answer, tags, category, deck
+	ExportJSON     = "json" // резервная копия DeckBackup, восстанавливается POST /import/json
+	ExportMarkdown = "md"   // конспект: заголовки наборов, пары Q:/A:
+	ExportPDF      = "pdf"  // карточки для печати (GET /decks/:id/print.pdf, POST /decks/:id/print)
+)
+
+// Резервная копия набора в JSON. Версия растёт только при несовместимых изменениях схемы:
+// новые необязательные поля добавляются без смены версии, импорт поддерживает все версии до текущей.
+const (
+	BackupFormat  = "mozgoemka.deck"
+	BackupVersion = 1
+)
+
+// DeckBackup — корень JSON-выгрузки набора (версия 1):
+//
+//	{
+//	  "format": "mozgoemka.deck",
+//	  "version": 1,
+//	  "exported_at": "2024-05-01T12:00:00Z",
+//	  "deck": {
+//	    "title": "Немецкий", "description": "...", "is_public": false,
+//	    "category": {"slug": "yazyki", "name": "Языки"},
+//	    "tags": ["lang::de"],
+//	    "cards": [{"question": "Hund", "answer": "собака", "tags": ["lang::de::nouns"], "category": null}],
+//	    "sub_decks": [{"title": "Глаголы", ...}]
+//	  }
+//	}
+//
+// Теги записываются полными путями и при восстановлении находятся по имени или создаются личными;
+// категории находятся по slug, затем по названию. Карточки идут в порядке создания.
+type DeckBackup struct {
+	Format     string     `json:"format"`
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Deck       BackupDeck `json:"deck"`
+}
+
+type BackupDeck struct {
+	Title       string          `json:"title"`
+	Description *string         `json:"description"`
+	IsPublic    bool            `json:"is_public"`
+	Category    *BackupCategory `json:"category"`
+	Tags        []string        `json:"tags"`
+	Cards       []BackupCard    `json:"cards"`
+	SubDecks    []BackupDeck    `json:"sub_decks"`
+}
+
+type BackupCard struct {
+	Question string          `json:"question"`
+	Answer   string          `json:"answer"`
+	Tags     []string        `json:"tags"`
+	Category *BackupCategory `json:"category"`
+}
+
+type BackupCategory struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// PrintOptions — параметры печатной версии набора (GET /decks/:id/print.pdf, POST /decks/:id/print).
+type PrintOptions struct {
+	Paper    string `json:"paper"` // "a4" или "letter"
+	Cols     int    `json:"cols"`
+	Rows     int    `json:"rows"`
+	CutLines bool   `json:"cut_lines"` // линии реза
+	Mirror   bool   `json:"mirror"`    // обороты зеркально для двусторонней печати
+	Images   bool   `json:"images"`    // выводить картинки из карточек
+}
@@ -0,0 +1,15 @@
+package domain
+
+// CardContent — содержимое карточки, которое сравнивается при синхронизации форка.
+type CardContent struct {
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+	CategoryID *int   `json:"category_id,omitempty"`
+}
+
+// ForkCard — карточка форка вместе с базой: содержимым исходной карточки
+// на момент форка или последней синхронизации.
+type ForkCard struct {
+	Card
+	Base CardContent
+}
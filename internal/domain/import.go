@@ -0,0 +1,20 @@
+package domain
+
+// AnkiImportOptions — поля формы POST /api/import/anki
+type AnkiImportOptions struct {
+	ParentID   *int // набор, внутри которого воссоздаются колоды Anki; nil — верхний уровень
+	Scheduling bool // перенести состояние повторений (интервал, лёгкость, срок)
+	History    bool // перенести журнал ответов (revlog)
+}
+
+// ImportResult — итог импорта набора из файла
+type ImportResult struct {
+	Decks        []DeckBrief `json:"decks"`
+	CardsCreated int         `json:"cards_created"`
+	CardsSkipped int         `json:"cards_skipped"` // пустой вопрос или ответ, у Anki — неизвестный тип заметки
+	TagsUsed     int         `json:"tags_used"`
+	MediaCopied  int         `json:"media_copied"`
+	Scheduled    int         `json:"scheduled"`        // карточек с перенесённым состоянием повторений
+	Reviews      int         `json:"reviews_imported"` // записей журнала ответов
+	Warnings     []string    `json:"warnings,omitempty"`
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Статусы фоновой задачи.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// Виды фоновых задач.
+const (
+	JobImportAnki     = "anki"
+	JobImportJSON     = "json"
+	JobImportAccount  = "account" // восстановление аккаунта из архива POST /api/users/me/import
+	JobExportAccount  = "account_export"
+	JobExportDeck     = "deck_export" // выгрузка набора в файл, в том числе PDF для печати
+	JobPruneRevisions = "revisions_prune"
+	JobPurgeTrash     = "trash_purge"
+	JobCleanup        = "jobs_cleanup"        // удаление завершённых задач и их файлов
+	JobCleanupCSV     = "csv_uploads_cleanup" // удаление устаревших таблиц импорта CSV
+)
+
+// Job (202, 200) — GET /api/jobs/:id: состояние фоновой задачи. Result зависит от вида задачи:
+// ImportResult у импорта, JobFile у задач, которые готовят файл (его отдаёт download_url).
+type Job struct {
+	ID              string          `json:"id"`
+	UserID          int             `json:"-"` // 0 — служебная задача
+	Kind            string          `json:"kind"`
+	Status          string          `json:"status"`
+	Payload         json.RawMessage `json:"-"`
+	Processed       int             `json:"processed"`
+	Total           int             `json:"total"`
+	Result          json.RawMessage `json:"result,omitempty" swaggertype:"object"`
+	Error           string          `json:"error,omitempty"`
+	Attempts        int             `json:"attempts"`
+	MaxAttempts     int             `json:"max_attempts"`
+	RunAt           time.Time       `json:"run_at"` // следующая попытка для задачи в очереди
+	CancelRequested bool            `json:"cancel_requested,omitempty"`
+	DownloadURL     string          `json:"download_url,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"` // когда завершённая задача и её файл удаляются
+}
+
+// Finished сообщает, что задача больше не выполняется и не будет повторена.
+func (j *Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// JobFile — результат задачи, которая готовит файл для скачивания.
+type JobFile struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// JobListResponse (200) — GET /api/jobs
+type JobListResponse struct {
+	Jobs []Job `json:"jobs"`
+}
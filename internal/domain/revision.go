@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type revisionAuthorKey struct{}
+
+// WithRevisionAuthor возвращает контекст, изменения в котором записываются в историю наборов
+// от имени пользователя userID (deck_revisions.user_id). Без автора — владелец набора.
+func WithRevisionAuthor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, revisionAuthorKey{}, userID)
+}
+
+// RevisionAuthor возвращает автора изменений из контекста (см. WithRevisionAuthor).
+func RevisionAuthor(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(revisionAuthorKey{}).(int)
+	return id, ok && id != 0
+}
+
+// Объекты и действия ревизий
+const (
+	RevisionEntityDeck = "deck"
+	RevisionEntityCard = "card"
+
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"  // карточка восстановлена из корзины
+	RevisionMoveIn  = "move_in"  // карточка перенесена в набор
+	RevisionMoveOut = "move_out" // карточка перенесена из набора
+)
+
+// DeckRevision — неизменяемая запись об изменении набора или его карточки.
+type DeckRevision struct {
+	ID            int             `json:"id"`
+	DeckID        int             `json:"deck_id"`
+	UserID        *int            `json:"user_id,omitempty"`
+	Entity        string          `json:"entity"`
+	EntityID      int             `json:"entity_id"`
+	Action        string          `json:"action"`
+	ChangedFields []string        `json:"changed_fields"`
+	Snapshot      json.RawMessage `json:"snapshot"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// DeckSnapshot — поля набора, которые хранятся в ревизиях.
+type DeckSnapshot struct {
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+	CategoryID  *int    `json:"category_id"`
+	IsPublic    bool    `json:"is_public"`
+}
+
+// CardSnapshot — поля карточки, которые хранятся в ревизиях.
+type CardSnapshot struct {
+	ID         int    `json:"id"`
+	DeckID     int    `json:"deck_id"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+	CategoryID *int   `json:"category_id"`
+}
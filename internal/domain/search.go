@@ -0,0 +1,9 @@
+package domain
+
+// SearchMatch — совпадение полнотекстового поиска (параметр search в списках наборов и карточек).
+// Highlights — фрагменты полей (title, description или question, answer) с найденными словами
+// в <mark>…</mark>; остальной текст экранирован как HTML.
+type SearchMatch struct {
+	Rank       float32           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
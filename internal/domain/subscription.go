@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// DeckSubscription — подписка пользователя на чужой публичный набор.
+type DeckSubscription struct {
+	UserID    int       `json:"user_id"`
+	DeckID    int       `json:"deck_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
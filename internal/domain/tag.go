@@ -1,9 +1,47 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
+// TagPathSeparator разделяет уровни иерархического тега: lang::de::verbs.
+const TagPathSeparator = "::"
+
+// Tag — тег; Name хранит полный путь от корня, ParentID — родительский тег (nil у корневого).
 type Tag struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
+	UserID    *int      `json:"user_id,omitempty"`   // владелец личного тега; nil — общий тег
+	ParentID  *int      `json:"parent_id,omitempty"` // родительский тег; nil — корневой тег
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// IsGlobal — тег общий для всех пользователей.
+func (t Tag) IsGlobal() bool {
+	return t.UserID == nil
+}
+
+// VisibleTo — тег общий или принадлежит пользователю userID.
+func (t Tag) VisibleTo(userID int) bool {
+	return t.UserID == nil || *t.UserID == userID
+}
+
+// ParseTagPath разбивает путь тега на уровни, обрезая пробелы вокруг каждого.
+// Пустой уровень (lang::::verbs, ::de) — ошибка, ok = false.
+func ParseTagPath(name string) (segments []string, ok bool) {
+	parts := strings.Split(name, TagPathSeparator)
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			return nil, false
+		}
+		segments = append(segments, p)
+	}
+	return segments, true
+}
+
+// JoinTagPath собирает полный путь тега из уровней.
+func JoinTagPath(segments []string) string {
+	return strings.Join(segments, TagPathSeparator)
+}
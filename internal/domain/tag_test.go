@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTagPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		segments []string
+		ok       bool
+	}{
+		{"single", "verbs", []string{"verbs"}, true},
+		{"nested", "lang::de::verbs", []string{"lang", "de", "verbs"}, true},
+		{"spaces trimmed", "  lang :: de  ", []string{"lang", "de"}, true},
+		{"spaces inside segment", "rus lang::verbs of motion", []string{"rus lang", "verbs of motion"}, true},
+		{"single colon", "c:d::e", []string{"c:d", "e"}, true},
+		{"empty", "", nil, false},
+		{"blank", "   ", nil, false},
+		{"leading separator", "::de", nil, false},
+		{"trailing separator", "lang::", nil, false},
+		{"empty middle", "lang::::verbs", nil, false},
+		{"blank middle", "lang:: ::verbs", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, ok := ParseTagPath(tt.path)
+			if ok != tt.ok || !reflect.DeepEqual(segments, tt.segments) {
+				t.Errorf("ParseTagPath(%q) = %q, %v; want %q, %v", tt.path, segments, ok, tt.segments, tt.ok)
+			}
+			if ok {
+				if again, _ := ParseTagPath(JoinTagPath(segments)); !reflect.DeepEqual(again, segments) {
+					t.Errorf("JoinTagPath(%q) does not parse back: %q", segments, again)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
@@ -57,10 +58,10 @@ func (h *CardHandler) List(c *gin.Context) {
 	JSON(c, resp)
 }
 
-// Create создаёт карточку (POST /api/cards — deck_id в body; или POST /api/decks/:deck_id/cards)
+// Create создаёт карточку (POST /api/cards — deck_id в body; или POST /api/decks/:id/cards)
 func (h *CardHandler) Create(c *gin.Context) {
 	deckID := 0
-	if idStr := c.Param("deck_id"); idStr != "" {
+	if idStr := c.Param("id"); idStr != "" {
 		if n, err := strconv.Atoi(idStr); err == nil {
 			deckID = n
 		}
@@ -91,6 +92,8 @@ func (h *CardHandler) Create(c *gin.Context) {
 	// Ответ в формате карточки с deck/category/tags
 	item, _ := h.cardService.GetByIDForAPI(c.Request.Context(), card.ID, userID)
 	if item != nil {
+		h.duplicateWarning(c, item, userID, card)
+		item.SuggestedTags = card.SuggestedTags
 		c.JSON(http.StatusCreated, item)
 		return
 	}
@@ -121,9 +124,9 @@ func (h *CardHandler) GetByID(c *gin.Context) {
 	JSON(c, item)
 }
 
-// ListByDeck карточки набора (GET /api/decks/:deck_id/cards)
+// ListByDeck карточки набора (GET /api/decks/:id/cards)
 func (h *CardHandler) ListByDeck(c *gin.Context) {
-	deckID, err := strconv.Atoi(c.Param("deck_id"))
+	deckID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		BadRequestSimple(c, "неверный ID набора")
 		return
@@ -173,12 +176,24 @@ func (h *CardHandler) Update(c *gin.Context) {
 	}
 	item, _ := h.cardService.GetByIDForAPI(c.Request.Context(), card.ID, userID)
 	if item != nil {
+		h.duplicateWarning(c, item, userID, card)
 		JSON(c, item)
 		return
 	}
 	JSON(c, card)
 }
 
+// duplicateWarning заполняет предупреждение о похожих карточках. Карточка уже сохранена,
+// поэтому ошибка поиска не прерывает ответ, а попадает в лог запроса.
+func (h *CardHandler) duplicateWarning(c *gin.Context, item *domain.CardListItem, userID int, card *domain.Card) {
+	warning, err := h.cardService.FindDuplicates(c.Request.Context(), userID, card, c.Query("duplicates"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	item.DuplicateWarning = warning
+}
+
 // Delete удаляет карточку (200 + message)
 func (h *CardHandler) Delete(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -201,3 +216,212 @@ func (h *CardHandler) Delete(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Card deleted successfully"})
 }
+
+// BulkCreate создаёт карточки пачкой (POST /api/decks/:id/cards/bulk)
+func (h *CardHandler) BulkCreate(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID набора")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	var req domain.BulkCreateCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	invalid := make(map[int]map[string]string)
+	for i := range req.Cards {
+		if errs := h.validator.Validate(&req.Cards[i]); errs != nil {
+			invalid[i] = errs
+		}
+	}
+	resp, err := h.cardService.BulkCreate(c.Request.Context(), deckID, userID, req, invalid, c.Query("duplicates"))
+	if err != nil {
+		h.bulkError(c, resp, err, "ошибка создания карточек")
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// BulkUpdate обновляет выбранные карточки набора (PUT /api/decks/:id/cards/bulk)
+func (h *CardHandler) BulkUpdate(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID набора")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	var req domain.BulkUpdateCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	invalid := make(map[int]map[string]string)
+	for i := range req.Cards {
+		if errs := h.validator.Validate(&req.Cards[i]); errs != nil {
+			invalid[i] = errs
+		}
+	}
+	resp, err := h.cardService.BulkUpdate(c.Request.Context(), deckID, userID, req, invalid, c.Query("duplicates"))
+	if err != nil {
+		h.bulkError(c, resp, err, "ошибка обновления карточек")
+		return
+	}
+	JSON(c, resp)
+}
+
+// BulkDelete удаляет выбранные карточки набора (POST /api/decks/:id/cards/bulk/delete)
+func (h *CardHandler) BulkDelete(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID набора")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	var req domain.BulkDeleteCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	resp, err := h.cardService.BulkDelete(c.Request.Context(), deckID, userID, req)
+	if err != nil {
+		h.bulkError(c, resp, err, "ошибка удаления карточек")
+		return
+	}
+	JSON(c, resp)
+}
+
+func (h *CardHandler) bulkError(c *gin.Context, resp *domain.BulkCardsResponse, err error, message string) {
+	switch err {
+	case service.ErrBulkInvalid:
+		BadRequest(c, "ошибка валидации", resp.Errors)
+	case service.ErrCardForbidden:
+		Forbidden(c, err.Error())
+	default:
+		InternalError(c, message)
+	}
+}
+
+// Move переносит карточку в другой набор (POST /api/cards/:id/move)
+func (h *CardHandler) Move(c *gin.Context) {
+	h.transferOne(c, h.cardService.MoveCards, "ошибка переноса карточки")
+}
+
+// Copy копирует карточку в другой набор (POST /api/cards/:id/copy)
+func (h *CardHandler) Copy(c *gin.Context) {
+	h.transferOne(c, h.cardService.CopyCards, "ошибка копирования карточки")
+}
+
+// MoveMany переносит выбранные карточки в другой набор (POST /api/cards/move)
+func (h *CardHandler) MoveMany(c *gin.Context) {
+	h.transferMany(c, h.cardService.MoveCards, "ошибка переноса карточек")
+}
+
+// CopyMany копирует выбранные карточки в другой набор (POST /api/cards/copy)
+func (h *CardHandler) CopyMany(c *gin.Context) {
+	h.transferMany(c, h.cardService.CopyCards, "ошибка копирования карточек")
+}
+
+type transferFunc func(ctx context.Context, userID int, cardIDs []int, targetDeckID int, resetProgress bool) (*domain.TransferCardsResponse, error)
+
+func (h *CardHandler) transferOne(c *gin.Context, fn transferFunc, message string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	var req domain.TransferCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := fn(c.Request.Context(), middleware.GetUserID(c), []int{id}, req.TargetDeckID, req.ResetProgress)
+	h.transferResult(c, resp, err, message)
+}
+
+func (h *CardHandler) transferMany(c *gin.Context, fn transferFunc, message string) {
+	var req domain.TransferCardsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := fn(c.Request.Context(), middleware.GetUserID(c), req.CardIDs, req.TargetDeckID, req.ResetProgress)
+	h.transferResult(c, resp, err, message)
+}
+
+func (h *CardHandler) transferResult(c *gin.Context, resp *domain.TransferCardsResponse, err error, message string) {
+	if err != nil {
+		if err == service.ErrCardNotFound {
+			NotFound(c, err.Error())
+			return
+		}
+		if err == service.ErrCardForbidden {
+			Forbidden(c, err.Error())
+			return
+		}
+		InternalError(c, message)
+		return
+	}
+	JSON(c, resp)
+}
+
+// Duplicates — GET /api/decks/:id/duplicates?scope=deck|all
+func (h *CardHandler) Duplicates(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	resp, err := h.cardService.DeckDuplicates(c.Request.Context(), deckID, middleware.GetUserID(c), c.Query("scope") == domain.DuplicatesAll)
+	if err != nil {
+		if err == service.ErrCardForbidden {
+			Forbidden(c, err.Error())
+			return
+		}
+		InternalError(c, "ошибка поиска дубликатов")
+		return
+	}
+	JSON(c, resp)
+}
+
+// MergeDuplicates — POST /api/decks/:id/duplicates/merge
+func (h *CardHandler) MergeDuplicates(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	var req domain.MergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := h.cardService.MergeDuplicates(c.Request.Context(), deckID, middleware.GetUserID(c), req)
+	if err != nil {
+		switch err {
+		case service.ErrCardNotFound:
+			NotFound(c, err.Error())
+		case service.ErrCardForbidden:
+			Forbidden(c, err.Error())
+		case service.ErrMergeNothing:
+			BadRequestSimple(c, err.Error())
+		default:
+			InternalError(c, "ошибка объединения дубликатов")
+		}
+		return
+	}
+	JSON(c, resp)
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 	"github.com/pro100kartochki/mozgoemka/internal/service"
@@ -17,12 +19,22 @@ func NewCategoryHandler(categoryService *service.CategoryService, v *validator.V
 }
 
 // List godoc
-// @Summary      Список категорий
+// @Summary      Список категорий (view=tree — деревом)
 // @Tags         categories
 // @Produce      json
-// @Success      200   {array}   domain.Category
+// @Param        view  query  string  false  "tree"
+// @Success      200   {object}  domain.CategoriesResponse
 // @Router       /categories [get]
 func (h *CategoryHandler) List(c *gin.Context) {
+	if c.Query("view") == "tree" {
+		resp, err := h.categoryService.ListTree(c.Request.Context())
+		if err != nil {
+			InternalError(c, "ошибка загрузки категорий")
+			return
+		}
+		JSON(c, resp)
+		return
+	}
 	list, err := h.categoryService.List(c.Request.Context())
 	if err != nil {
 		InternalError(c, "ошибка загрузки категорий")
@@ -32,7 +44,7 @@ func (h *CategoryHandler) List(c *gin.Context) {
 }
 
 // Create godoc
-// @Summary      Создать категорию
+// @Summary      Создать категорию (moderator, admin)
 // @Tags         categories
 // @Accept       json
 // @Produce      json
@@ -53,12 +65,118 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 	}
 	cat, err := h.categoryService.Create(c.Request.Context(), req)
 	if err != nil {
-		if err == service.ErrCategoryExists {
-			Conflict(c, err.Error())
+		h.categoryError(c, err, "ошибка создания категории")
+		return
+	}
+	Created(c, cat)
+}
+
+// Update godoc
+// @Summary      Переименовать категорию, изменить slug, порядок или иконку (moderator, admin)
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path  int  true  "ID категории"
+// @Param        body  body  domain.UpdateCategoryRequest  true  "Изменения"
+// @Success      200   {object}  domain.Category
+// @Failure      404   {object}  map[string]string
+// @Router       /categories/{id} [put]
+func (h *CategoryHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var req domain.UpdateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	cat, err := h.categoryService.Update(c.Request.Context(), id, req)
+	if err != nil {
+		h.categoryError(c, err, "ошибка обновления категории")
+		return
+	}
+	JSON(c, cat)
+}
+
+// Move godoc
+// @Summary      Перенести категорию с подкатегориями (moderator, admin)
+// @Tags         categories
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path  int  true  "ID категории"
+// @Param        body  body  domain.MoveCategoryRequest  true  "Новый родитель"
+// @Success      200   {object}  domain.Category
+// @Failure      400   {object}  map[string]string
+// @Router       /categories/{id}/move [post]
+func (h *CategoryHandler) Move(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var req domain.MoveCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	cat, err := h.categoryService.Move(c.Request.Context(), id, req)
+	if err != nil {
+		h.categoryError(c, err, "ошибка переноса категории")
+		return
+	}
+	JSON(c, cat)
+}
+
+// Delete godoc
+// @Summary      Удалить категорию с переносом наборов и карточек (moderator, admin)
+// @Tags         categories
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id           path   int  true   "ID категории"
+// @Param        reassign_to  query  int  false  "Категория для наборов и карточек (по умолчанию — родительская)"
+// @Success      200   {object}  domain.DeleteCategoryResponse
+// @Failure      404   {object}  map[string]string
+// @Router       /categories/{id} [delete]
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var reassignTo *int
+	if v := c.Query("reassign_to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			BadRequest(c, "неверный reassign_to", nil)
 			return
 		}
-		InternalError(c, "ошибка создания категории")
+		reassignTo = &n
+	}
+	resp, err := h.categoryService.Delete(c.Request.Context(), id, reassignTo)
+	if err != nil {
+		h.categoryError(c, err, "ошибка удаления категории")
 		return
 	}
-	Created(c, cat)
+	JSON(c, resp)
+}
+
+func (h *CategoryHandler) categoryError(c *gin.Context, err error, message string) {
+	switch err {
+	case service.ErrCategoryNotFound:
+		NotFound(c, err.Error())
+	case service.ErrCategoryExists, service.ErrCategorySlugUsed:
+		Conflict(c, err.Error())
+	case service.ErrCategoryParent, service.ErrCategoryCycle, service.ErrCategorySlug, service.ErrCategoryReassign:
+		BadRequestSimple(c, err.Error())
+	default:
+		InternalError(c, message)
+	}
 }
@@ -33,6 +33,10 @@ func (h *DeckHandler) Create(c *gin.Context) {
 	}
 	deck, err := h.deckService.Create(c.Request.Context(), userID, req)
 	if err != nil {
+		if err == service.ErrParentDeck {
+			BadRequestSimple(c, err.Error())
+			return
+		}
 		InternalError(c, "ошибка создания набора")
 		return
 	}
@@ -62,8 +66,18 @@ func (h *DeckHandler) GetByID(c *gin.Context) {
 	JSON(c, deck)
 }
 
+// ListMine — GET /api/decks; view=tree возвращает все наборы деревом вместо страницы плоского списка.
 func (h *DeckHandler) ListMine(c *gin.Context) {
 	userID := middleware.GetUserID(c)
+	if c.Query("view") == "tree" {
+		resp, err := h.deckService.ListTree(c.Request.Context(), userID)
+		if err != nil {
+			InternalError(c, "ошибка загрузки наборов")
+			return
+		}
+		JSON(c, resp)
+		return
+	}
 	page, limit := 1, 20
 	if p := c.Query("page"); p != "" {
 		if n, err := strconv.Atoi(p); err == nil && n > 0 {
@@ -78,14 +92,19 @@ func (h *DeckHandler) ListMine(c *gin.Context) {
 	if limit > 100 {
 		limit = 100
 	}
-	var categoryID *int
+	var categoryID, tagID *int
 	if cid := c.Query("category_id"); cid != "" {
 		if n, err := strconv.Atoi(cid); err == nil {
 			categoryID = &n
 		}
 	}
+	if tid := c.Query("tag_id"); tid != "" {
+		if n, err := strconv.Atoi(tid); err == nil {
+			tagID = &n
+		}
+	}
 	search := c.Query("search")
-	resp, err := h.deckService.ListByUserPaginated(c.Request.Context(), userID, page, limit, categoryID, search)
+	resp, err := h.deckService.ListByUserPaginated(c.Request.Context(), userID, page, limit, categoryID, tagID, search)
 	if err != nil {
 		InternalError(c, "ошибка загрузки наборов")
 		return
@@ -93,6 +112,96 @@ func (h *DeckHandler) ListMine(c *gin.Context) {
 	JSON(c, resp)
 }
 
+// Move — POST /api/decks/:id/move
+func (h *DeckHandler) Move(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var req domain.MoveDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	deck, err := h.deckService.Move(c.Request.Context(), id, middleware.GetUserID(c), req.ParentID)
+	if err != nil {
+		switch err {
+		case service.ErrDeckNotFound:
+			NotFound(c, err.Error())
+		case service.ErrDeckForbidden:
+			Forbidden(c, err.Error())
+		case service.ErrParentDeck, service.ErrDeckCycle:
+			BadRequestSimple(c, err.Error())
+		default:
+			InternalError(c, "ошибка перемещения набора")
+		}
+		return
+	}
+	JSON(c, deck)
+}
+
+// Merge — POST /api/decks/:id/merge
+func (h *DeckHandler) Merge(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var req domain.MergeDecksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := h.deckService.Merge(c.Request.Context(), id, middleware.GetUserID(c), req)
+	if err != nil {
+		h.restructureError(c, err, "ошибка объединения наборов")
+		return
+	}
+	JSON(c, resp)
+}
+
+// Split — POST /api/decks/:id/split
+func (h *DeckHandler) Split(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	var req domain.SplitDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := h.deckService.Split(c.Request.Context(), id, middleware.GetUserID(c), req)
+	if err != nil {
+		h.restructureError(c, err, "ошибка разделения набора")
+		return
+	}
+	JSON(c, resp)
+}
+
+func (h *DeckHandler) restructureError(c *gin.Context, err error, message string) {
+	switch err {
+	case service.ErrDeckNotFound:
+		NotFound(c, err.Error())
+	case service.ErrDeckForbidden:
+		Forbidden(c, err.Error())
+	case service.ErrMergeSelf, service.ErrMergeAncestor, service.ErrSplitEmpty:
+		BadRequestSimple(c, err.Error())
+	default:
+		InternalError(c, message)
+	}
+}
+
 func (h *DeckHandler) ListPublic(c *gin.Context) {
 	limit := 20
 	offset := 0
@@ -184,15 +293,20 @@ func (h *DeckHandler) ListPublicPaginated(c *gin.Context) {
 	if limit > 100 {
 		limit = 100
 	}
-	var categoryID *int
+	var categoryID, tagID *int
 	if cid := c.Query("category_id"); cid != "" {
 		if n, err := strconv.Atoi(cid); err == nil {
 			categoryID = &n
 		}
 	}
+	if tid := c.Query("tag_id"); tid != "" {
+		if n, err := strconv.Atoi(tid); err == nil {
+			tagID = &n
+		}
+	}
 	search := c.Query("search")
-	sortBy := c.DefaultQuery("sort_by", "recent") // popular, recent, cards_count
-	resp, err := h.deckService.ListPublicPaginated(c.Request.Context(), page, limit, categoryID, search, sortBy)
+	sortBy := c.Query("sort_by") // popular, recent, cards_count, forks, relevance; по умолчанию relevance с search, иначе recent
+	resp, err := h.deckService.ListPublicPaginated(c.Request.Context(), page, limit, categoryID, tagID, search, sortBy)
 	if err != nil {
 		InternalError(c, "ошибка загрузки наборов")
 		return
@@ -218,3 +332,71 @@ func (h *DeckHandler) GetPublicByID(c *gin.Context) {
 	}
 	JSON(c, deck)
 }
+
+// Fork — POST /api/public/decks/:id/fork: копия публичного набора в аккаунт пользователя
+func (h *DeckHandler) Fork(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	deck, err := h.deckService.Fork(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrDeckNotFound {
+			NotFound(c, err.Error())
+			return
+		}
+		InternalError(c, "ошибка копирования набора")
+		return
+	}
+	c.JSON(http.StatusCreated, deck)
+}
+
+// UpstreamDiff — GET /api/decks/:id/upstream: отличия форка от исходного набора
+func (h *DeckHandler) UpstreamDiff(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	diff, err := h.deckService.UpstreamDiff(c.Request.Context(), id, userID)
+	if err != nil {
+		h.upstreamError(c, err)
+		return
+	}
+	JSON(c, diff)
+}
+
+// ApplyUpstream — POST /api/decks/:id/upstream/apply: принять выбранные изменения исходного набора
+func (h *DeckHandler) ApplyUpstream(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	var req domain.ApplyUpstreamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	diff, err := h.deckService.ApplyUpstream(c.Request.Context(), id, userID, req)
+	if err != nil {
+		h.upstreamError(c, err)
+		return
+	}
+	JSON(c, diff)
+}
+
+func (h *DeckHandler) upstreamError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrDeckNotFound, service.ErrNoUpstream:
+		NotFound(c, err.Error())
+	case service.ErrDeckForbidden:
+		Forbidden(c, err.Error())
+	default:
+		InternalError(c, "ошибка синхронизации с исходным набором")
+	}
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+)
+
+type ExportHandler struct {
+	exportService *service.ExportService
+}
+
+func NewExportHandler(exportService *service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// ExportDeck godoc
+// @Summary      Выгрузка набора
+// @Description  Выгружает набор с вложенными наборами потоком. apkg — пакет Anki с тегами, медиафайлами и (scheduling=true) состоянием повторений, в запросе — до 1000 карточек (больше — POST /decks/{id}/export); csv — таблица для импорта CSV; json — резервная копия (схема domain.DeckBackup) для POST /import/json; md — конспект.
+// @Tags         export
+// @Produce      octet-stream
+// @Security     BearerAuth
+// @Param        id          path   int     true   "ID набора"
+// @Param        format      query  string  true   "Формат: apkg | csv | json | md"
+// @Param        scheduling  query  bool    false  "Добавить состояние повторений"
+// @Success      200
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /decks/{id}/export [get]
+func (h *ExportHandler) ExportDeck(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	scheduling, _ := strconv.ParseBool(c.Query("scheduling"))
+	exp, err := h.exportService.ExportDeck(c.Request.Context(), id, middleware.GetUserID(c), c.Query("format"), scheduling)
+	if err == nil {
+		err = h.exportService.CheckInline(c.Request.Context(), exp)
+	}
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.Header("Content-Type", exp.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": exp.Filename}))
+	c.Status(http.StatusOK)
+	if err := exp.Write(c.Request.Context(), c.Writer); err != nil {
+		// заголовки уже отправлены: ответ обрывается, ошибка попадает в лог запроса
+		_ = c.Error(err)
+	}
+}
+
+// PrintDeck godoc
+// @Summary      Печать карточек
+// @Description  PDF для печати карточек набора с вложенными наборами: сетка карточек на A4 или Letter, лицевые стороны и обороты на чередующихся страницах (mirror=true — обороты зеркально для двусторонней печати), пунктир линий реза, картинки из загрузок (images=true). Шрифт с кириллицей встроен в файл. В запросе — до 500 карточек, больше — POST /decks/{id}/print.
+// @Tags         export
+// @Produce      application/pdf
+// @Security     BearerAuth
+// @Param        id         path   int     true   "ID набора"
+// @Param        paper      query  string  false  "Формат бумаги: a4 | letter (по умолчанию a4)"
+// @Param        grid       query  string  false  "Сетка колонки×строки, например 2x4 (по умолчанию)"
+// @Param        mirror     query  bool    false  "Зеркалить обороты (по умолчанию true)"
+// @Param        cut_lines  query  bool    false  "Линии реза (по умолчанию true)"
+// @Param        images     query  bool    false  "Картинки карточек"
+// @Success      200
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /decks/{id}/print.pdf [get]
+func (h *ExportHandler) PrintDeck(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	opts, ok := printOptions(c)
+	if !ok {
+		return
+	}
+	exp, err := h.exportService.PrintDeck(c.Request.Context(), id, middleware.GetUserID(c), opts)
+	if err == nil {
+		err = h.exportService.CheckInline(c.Request.Context(), exp)
+	}
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	c.Header("Content-Type", exp.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": exp.Filename}))
+	c.Status(http.StatusOK)
+	if err := exp.Write(c.Request.Context(), c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// StartExport godoc
+// @Summary      Фоновая выгрузка набора
+// @Description  То же, что GET /decks/{id}/export, но файл готовится фоновой задачей — для больших наборов. Ход — GET /jobs/{id}, в статусе done появляется download_url.
+// @Tags         export
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id          path   int     true   "ID набора"
+// @Param        format      query  string  true   "Формат: apkg | csv | json | md"
+// @Param        scheduling  query  bool    false  "Добавить состояние повторений"
+// @Success      202  {object}  domain.Job
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /decks/{id}/export [post]
+func (h *ExportHandler) StartExport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	scheduling, _ := strconv.ParseBool(c.Query("scheduling"))
+	job, err := h.exportService.StartDeckExport(c.Request.Context(), id, middleware.GetUserID(c), c.Query("format"), scheduling, nil)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	Accepted(c, job)
+}
+
+// StartPrint godoc
+// @Summary      Фоновая печать карточек
+// @Description  То же, что GET /decks/{id}/print.pdf, но PDF готовится фоновой задачей. Ход — GET /jobs/{id}, в статусе done появляется download_url.
+// @Tags         export
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path   int     true   "ID набора"
+// @Param        paper      query  string  false  "Формат бумаги: a4 | letter (по умолчанию a4)"
+// @Param        grid       query  string  false  "Сетка колонки×строки, например 2x4 (по умолчанию)"
+// @Param        mirror     query  bool    false  "Зеркалить обороты (по умолчанию true)"
+// @Param        cut_lines  query  bool    false  "Линии реза (по умолчанию true)"
+// @Param        images     query  bool    false  "Картинки карточек"
+// @Success      202  {object}  domain.Job
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /decks/{id}/print [post]
+func (h *ExportHandler) StartPrint(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequest(c, "неверный ID", nil)
+		return
+	}
+	opts, ok := printOptions(c)
+	if !ok {
+		return
+	}
+	job, err := h.exportService.StartDeckExport(c.Request.Context(), id, middleware.GetUserID(c), domain.ExportPDF, false, &opts)
+	if err != nil {
+		exportError(c, err)
+		return
+	}
+	Accepted(c, job)
+}
+
+// printOptions читает параметры печати из запроса; false — ответ с ошибкой уже отправлен.
+func printOptions(c *gin.Context) (domain.PrintOptions, bool) {
+	opts := domain.PrintOptions{Paper: c.DefaultQuery("paper", "a4")}
+	if opts.Paper != "a4" && opts.Paper != "letter" {
+		BadRequestSimple(c, "неизвестный формат бумаги: допустимы a4, letter")
+		return opts, false
+	}
+	if _, err := fmt.Sscanf(strings.ToLower(c.DefaultQuery("grid", "2x4")), "%dx%d", &opts.Cols, &opts.Rows); err != nil {
+		BadRequestSimple(c, "неверная сетка: ожидается колонки×строки, например 2x4")
+		return opts, false
+	}
+	opts.Mirror = queryBool(c, "mirror", true)
+	opts.CutLines = queryBool(c, "cut_lines", true)
+	opts.Images = queryBool(c, "images", false)
+	return opts, true
+}
+
+func exportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrExportFormat):
+		BadRequestSimple(c, "неизвестный формат: допустимы apkg, csv, json, md")
+	case errors.Is(err, service.ErrPrintLayout), errors.Is(err, service.ErrExportTooLarge):
+		BadRequestSimple(c, err.Error())
+	case errors.Is(err, service.ErrDeckNotFound):
+		NotFound(c, "набор не найден")
+	case errors.Is(err, service.ErrDeckForbidden):
+		Forbidden(c, "нет доступа к набору")
+	default:
+		InternalError(c, "ошибка выгрузки")
+	}
+}
+
+// queryBool читает булев параметр запроса; отсутствующий или нечитаемый — def.
+func queryBool(c *gin.Context, name string, def bool) bool {
+	if v, err := strconv.ParseBool(c.Query(name)); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,410 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+	"github.com/pro100kartochki/mozgoemka/pkg/validator"
+)
+
+const (
+	maxAnkiSize = 500 << 20 // 500MB
+	maxCSVSize  = 10 << 20  // 10MB
+	maxJSONSize = 100 << 20 // 100MB
+)
+
+type ImportHandler struct {
+	importService *service.ImportService
+	validator     *validator.Validator
+}
+
+func NewImportHandler(importService *service.ImportService, v *validator.Validator) *ImportHandler {
+	return &ImportHandler{importService: importService, validator: v}
+}
+
+// ImportAnki godoc
+// @Summary      Импорт пакета Anki
+// @Description  Импортирует .apkg фоновой задачей: колоды становятся наборами, теги заметок — личными тегами, медиафайлы копируются в загрузки. Ход задачи — GET /jobs/{id}.
+// @Tags         import
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file        formData  file    true   "Пакет .apkg"
+// @Param        parent_id   formData  int     false  "Набор, внутрь которого импортировать колоды"
+// @Param        scheduling  formData  bool    false  "Перенести состояние повторений"
+// @Param        history     formData  bool    false  "Перенести журнал повторений"
+// @Success      202  {object}  domain.Job
+// @Failure      400  {object}  map[string]string
+// @Router       /import/anki [post]
+func (h *ImportHandler) ImportAnki(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		BadRequest(c, "требуется файл file (.apkg)", nil)
+		return
+	}
+	if file.Size > maxAnkiSize {
+		BadRequestSimple(c, "файл слишком большой (макс. 500MB)")
+		return
+	}
+	var opts domain.AnkiImportOptions
+	if v := c.PostForm("parent_id"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			BadRequest(c, "неверный parent_id", nil)
+			return
+		}
+		opts.ParentID = &n
+	}
+	opts.Scheduling, _ = strconv.ParseBool(c.PostForm("scheduling"))
+	opts.History, _ = strconv.ParseBool(c.PostForm("history"))
+
+	path, err := saveTemp(file, "import-*.apkg", h.importService.SaveUpload)
+	if err != nil {
+		InternalError(c, "ошибка чтения файла")
+		return
+	}
+	job, err := h.importService.ImportAnki(c.Request.Context(), middleware.GetUserID(c), path, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDeckNotFound):
+			NotFound(c, "набор не найден")
+		case errors.Is(err, service.ErrDeckForbidden):
+			Forbidden(c, "нет доступа к набору")
+		default:
+			InternalError(c, "ошибка импорта")
+		}
+		return
+	}
+	Accepted(c, job)
+}
+
+// ImportJSON godoc
+// @Summary      Восстановление набора из резервной копии
+// @Description  Восстанавливает набор с вложенными наборами из JSON-выгрузки (GET /decks/{id}/export?format=json) фоновой задачей. Ход задачи — GET /jobs/{id}.
+// @Tags         import
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file       formData  file  true   "Резервная копия .json"
+// @Param        parent_id  formData  int   false  "Набор, внутрь которого восстановить копию"
+// @Success      202  {object}  domain.Job
+// @Failure      400  {object}  map[string]string
+// @Router       /import/json [post]
+func (h *ImportHandler) ImportJSON(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		BadRequest(c, "требуется файл file (.json)", nil)
+		return
+	}
+	if file.Size > maxJSONSize {
+		BadRequestSimple(c, "файл слишком большой (макс. 100MB)")
+		return
+	}
+	var parentID *int
+	if v := c.PostForm("parent_id"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			BadRequest(c, "неверный parent_id", nil)
+			return
+		}
+		parentID = &n
+	}
+	path, err := saveTemp(file, "import-*.json", h.importService.SaveUpload)
+	if err != nil {
+		InternalError(c, "ошибка чтения файла")
+		return
+	}
+	job, err := h.importService.ImportJSON(c.Request.Context(), middleware.GetUserID(c), path, parentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBackupFormat), errors.Is(err, service.ErrBackupVersion):
+			BadRequestSimple(c, err.Error())
+		case errors.Is(err, service.ErrDeckNotFound):
+			NotFound(c, "набор не найден")
+		case errors.Is(err, service.ErrDeckForbidden):
+			Forbidden(c, "нет доступа к набору")
+		default:
+			InternalError(c, "ошибка импорта")
+		}
+		return
+	}
+	Accepted(c, job)
+}
+
+// UploadCSV godoc
+// @Summary      Загрузка таблицы CSV/TSV
+// @Description  Первый шаг импорта: таблица сохраняется, в ответе — определённые разделитель, кодировка (utf-8 или cp1251), заголовок, первые строки и предложенное соответствие колонок.
+// @Tags         import
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file        formData  file    true   "Таблица .csv или .tsv"
+// @Param        delimiter   formData  string  false  "Разделитель (tab — табуляция)"
+// @Param        encoding    formData  string  false  "utf-8 | cp1251"
+// @Param        has_header  formData  bool    false  "Первая строка — заголовок"
+// @Success      201  {object}  domain.CSVPreviewResponse
+// @Failure      400  {object}  map[string]string
+// @Router       /import/csv [post]
+func (h *ImportHandler) UploadCSV(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		BadRequest(c, "требуется файл file (.csv, .tsv)", nil)
+		return
+	}
+	if file.Size > maxCSVSize {
+		BadRequestSimple(c, "файл слишком большой (макс. 10MB)")
+		return
+	}
+	var opts domain.CSVParseOptions
+	if !h.bindCSVOptions(c, &opts) {
+		return
+	}
+	path, err := saveTemp(file, "import-*.csv", h.importService.SaveUpload)
+	if err != nil {
+		InternalError(c, "ошибка чтения файла")
+		return
+	}
+	resp, err := h.importService.UploadCSV(c.Request.Context(), middleware.GetUserID(c), path, opts)
+	if err != nil {
+		h.csvError(c, nil, err)
+		return
+	}
+	Created(c, resp)
+}
+
+// PreviewCSV godoc
+// @Summary      Предпросмотр таблицы с другими параметрами разбора
+// @Tags         import
+// @Produce      json
+// @Security     BearerAuth
+// @Param        upload_id   path   string  true   "ID загрузки"
+// @Param        delimiter   query  string  false  "Разделитель (tab — табуляция)"
+// @Param        encoding    query  string  false  "utf-8 | cp1251"
+// @Param        has_header  query  bool    false  "Первая строка — заголовок"
+// @Success      200  {object}  domain.CSVPreviewResponse
+// @Failure      404  {object}  map[string]string
+// @Router       /import/csv/{upload_id} [get]
+func (h *ImportHandler) PreviewCSV(c *gin.Context) {
+	var opts domain.CSVParseOptions
+	if !h.bindCSVOptions(c, &opts) {
+		return
+	}
+	resp, err := h.importService.PreviewCSV(c.Request.Context(), middleware.GetUserID(c), c.Param("upload_id"), opts)
+	if err != nil {
+		h.csvError(c, nil, err)
+		return
+	}
+	JSON(c, resp)
+}
+
+// ImportCSV godoc
+// @Summary      Импорт карточек из загруженной таблицы
+// @Description  Второй шаг импорта: карточки создаются в наборе deck_id по соответствию колонок с той же проверкой, что и при создании карточки. Ошибки возвращаются по номерам строк файла.
+// @Tags         import
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        upload_id  path  string                   true  "ID загрузки"
+// @Param        body       body  domain.CSVImportRequest  true  "Соответствие колонок"
+// @Success      201  {object}  domain.CSVImportResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /import/csv/{upload_id} [post]
+func (h *ImportHandler) ImportCSV(c *gin.Context) {
+	var req domain.CSVImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	userID := middleware.GetUserID(c)
+	uploadID := c.Param("upload_id")
+	rows, err := h.importService.CSVRows(c.Request.Context(), userID, uploadID, req)
+	if err != nil {
+		h.csvError(c, nil, err)
+		return
+	}
+	for i := range rows {
+		for field, msg := range h.validator.Validate(&rows[i].Card) {
+			if rows[i].Errors == nil {
+				rows[i].Errors = make(map[string]string)
+			}
+			rows[i].Errors[field] = msg
+		}
+	}
+	resp, err := h.importService.ImportCSV(c.Request.Context(), userID, uploadID, req, rows)
+	if err != nil {
+		h.csvError(c, resp, err)
+		return
+	}
+	Created(c, resp)
+}
+
+// ImportText godoc
+// @Summary      Импорт вставленного текста
+// @Description  Разбирает текст, скопированный из Quizlet или таблицы: «термин<TAB>определение» по строке на карточку либо с заданными разделителями (tab, comma, dash, newline, semicolon, blank_line или своя строка). Поля в двойных кавычках могут содержать разделители и переводы строк; строка без разделителя продолжает определение предыдущей карточки. preview=true возвращает разобранные карточки с ошибками, не создавая их.
+// @Tags         import
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        body  body  domain.TextImportRequest  true  "Текст и разделители"
+// @Success      200  {object}  domain.TextPreviewResponse
+// @Success      201  {object}  domain.TextImportResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /import/text [post]
+func (h *ImportHandler) ImportText(c *gin.Context) {
+	var req domain.TextImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	userID := middleware.GetUserID(c)
+	cards, err := h.importService.TextCards(c.Request.Context(), userID, req)
+	if err != nil {
+		h.textError(c, nil, err)
+		return
+	}
+	invalid := 0
+	for i := range cards {
+		card := domain.CreateCardRequest{Question: cards[i].Question, Answer: cards[i].Answer}
+		for field, msg := range h.validator.Validate(&card) {
+			if _, ok := cards[i].Errors[field]; ok {
+				continue
+			}
+			if cards[i].Errors == nil {
+				cards[i].Errors = make(map[string]string)
+			}
+			cards[i].Errors[field] = msg
+		}
+		if len(cards[i].Errors) > 0 {
+			invalid++
+		}
+	}
+	if req.Preview {
+		JSON(c, domain.TextPreviewResponse{Cards: cards, Total: len(cards), Invalid: invalid})
+		return
+	}
+	resp, err := h.importService.ImportText(c.Request.Context(), userID, req, cards)
+	if err != nil {
+		h.textError(c, resp, err)
+		return
+	}
+	Created(c, resp)
+}
+
+func (h *ImportHandler) textError(c *gin.Context, resp *domain.TextImportResponse, err error) {
+	switch {
+	case errors.Is(err, service.ErrBulkInvalid):
+		BadRequest(c, "ошибки в карточках", resp.Errors)
+	case errors.Is(err, service.ErrTextSeparator):
+		BadRequestSimple(c, err.Error())
+	case errors.Is(err, service.ErrTextTooManyCards):
+		BadRequestSimple(c, "слишком много карточек (макс. 10000)")
+	case errors.Is(err, service.ErrDeckNotFound):
+		NotFound(c, "набор не найден")
+	case errors.Is(err, service.ErrDeckForbidden), errors.Is(err, service.ErrCardForbidden):
+		Forbidden(c, "нет доступа к набору")
+	default:
+		InternalError(c, "ошибка импорта текста")
+	}
+}
+
+// ImportMarkdown godoc
+// @Summary      Импорт конспекта в Markdown
+// @Description  Превращает конспект в карточки: заголовки — наборы и вложенные наборы, пары «Q:»/«A:», списки определений («термин», затем «: определение»), строки «термин :: определение» и ==выделения== (карточка-пропуск на каждое выделение). Повторный импорт обновляет карточки с тем же якорем: явным « ^id» в конце карточки или вычисленным из набора и вопроса. dry_run=true показывает, что будет создано и обновлено, ничего не сохраняя.
+// @Tags         import
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        body  body  domain.MarkdownImportRequest  true  "Конспект"
+// @Success      200  {object}  domain.MarkdownImportResponse
+// @Success      201  {object}  domain.MarkdownImportResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /import/markdown [post]
+func (h *ImportHandler) ImportMarkdown(c *gin.Context) {
+	var req domain.MarkdownImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequestSimple(c, "неверный формат запроса")
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	resp, err := h.importService.ImportMarkdown(c.Request.Context(), middleware.GetUserID(c), req,
+		func(card *domain.CreateCardRequest) map[string]string { return h.validator.Validate(card) })
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMarkdownEmpty):
+			BadRequestSimple(c, err.Error())
+		case errors.Is(err, service.ErrDeckNotFound):
+			NotFound(c, "набор не найден")
+		case errors.Is(err, service.ErrDeckForbidden):
+			Forbidden(c, "нет доступа к набору")
+		default:
+			InternalError(c, "ошибка импорта конспекта")
+		}
+		return
+	}
+	if req.DryRun {
+		JSON(c, resp)
+		return
+	}
+	Created(c, resp)
+}
+
+func (h *ImportHandler) bindCSVOptions(c *gin.Context, opts *domain.CSVParseOptions) bool {
+	if err := c.ShouldBind(opts); err != nil {
+		BadRequestSimple(c, "неверные параметры разбора")
+		return false
+	}
+	if errs := h.validator.Validate(opts); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return false
+	}
+	return true
+}
+
+func (h *ImportHandler) csvError(c *gin.Context, resp *domain.CSVImportResponse, err error) {
+	switch {
+	case errors.Is(err, service.ErrBulkInvalid):
+		BadRequest(c, "ошибки в строках таблицы", resp.Errors)
+	case errors.Is(err, service.ErrCSVUploadNotFound):
+		NotFound(c, err.Error())
+	case errors.Is(err, service.ErrCSVInvalid), errors.Is(err, service.ErrCSVMapping):
+		BadRequestSimple(c, err.Error())
+	case errors.Is(err, service.ErrCSVTooManyRows):
+		BadRequestSimple(c, "слишком много строк (макс. 10000)")
+	case errors.Is(err, service.ErrDeckNotFound):
+		NotFound(c, "набор не найден")
+	case errors.Is(err, service.ErrDeckForbidden), errors.Is(err, service.ErrCardForbidden):
+		Forbidden(c, "нет доступа к набору")
+	default:
+		InternalError(c, "ошибка импорта таблицы")
+	}
+}
+
+// saveTemp копирует загруженный файл через save (входной файл фоновой задачи): задача переживает запрос.
+func saveTemp(file *multipart.FileHeader, pattern string, save func(r io.Reader, pattern string) (string, error)) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return save(src, pattern)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+)
+
+type JobHandler struct {
+	jobService *service.JobService
+}
+
+func NewJobHandler(jobService *service.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// List godoc
+// @Summary      Фоновые задачи пользователя
+// @Description  Последние 50 задач пользователя (импорт, выгрузки, печать), новые сверху. Завершённые задачи хранятся JOBS_RETENTION (по умолчанию сутки).
+// @Tags         jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status  query  string  false  "queued | running | done | failed | canceled"
+// @Success      200  {object}  domain.JobListResponse
+// @Router       /jobs [get]
+func (h *JobHandler) List(c *gin.Context) {
+	jobs, err := h.jobService.List(c.Request.Context(), middleware.GetUserID(c), c.Query("status"))
+	if err != nil {
+		InternalError(c, "ошибка загрузки задач")
+		return
+	}
+	JSON(c, domain.JobListResponse{Jobs: jobs})
+}
+
+// Get godoc
+// @Summary      Состояние фоновой задачи
+// @Description  Статус (queued, running, done, failed, canceled), прогресс processed/total, попытки и результат. У задач, которые готовят файл, в статусе done появляется download_url.
+// @Tags         jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID задачи"
+// @Success      200  {object}  domain.Job
+// @Failure      404  {object}  map[string]string
+// @Router       /jobs/{id} [get]
+func (h *JobHandler) Get(c *gin.Context) {
+	job, err := h.jobService.Get(c.Request.Context(), c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	JSON(c, job)
+}
+
+// Cancel godoc
+// @Summary      Отмена фоновой задачи
+// @Description  Задача в очереди отменяется сразу, выполняемая — в течение нескольких секунд. Сделанное до отмены (например, уже импортированные наборы) не откатывается.
+// @Tags         jobs
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID задачи"
+// @Success      200  {object}  domain.Job
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /jobs/{id}/cancel [post]
+func (h *JobHandler) Cancel(c *gin.Context) {
+	job, err := h.jobService.Cancel(c.Request.Context(), c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	JSON(c, job)
+}
+
+// Download godoc
+// @Summary      Файл результата задачи
+// @Tags         jobs
+// @Produce      octet-stream
+// @Security     BearerAuth
+// @Param        id   path  string  true  "ID задачи"
+// @Success      200
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /jobs/{id}/download [get]
+func (h *JobHandler) Download(c *gin.Context) {
+	path, f, err := h.jobService.File(c.Request.Context(), c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		h.error(c, err)
+		return
+	}
+	c.Header("Content-Type", f.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Filename}))
+	c.File(path)
+}
+
+func (h *JobHandler) error(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		NotFound(c, "задача не найдена")
+	case errors.Is(err, service.ErrJobFinished), errors.Is(err, service.ErrJobNotReady):
+		Conflict(c, err.Error())
+	default:
+		InternalError(c, "ошибка загрузки задачи")
+	}
+}
@@ -15,6 +15,7 @@ const (
 	CodeForbidden         = "FORBIDDEN"
 	CodeNotFound          = "NOT_FOUND"
 	CodeAlreadyExists     = "ALREADY_EXISTS"
+	CodeConflict          = "CONFLICT"
 	CodeInternalError     = "INTERNAL_SERVER_ERROR"
 )
 
@@ -37,6 +38,10 @@ func Created(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusCreated, data)
 }
 
+func Accepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, data)
+}
+
 func NoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
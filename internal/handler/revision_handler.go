@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+)
+
+type RevisionHandler struct {
+	revisionService *service.RevisionService
+}
+
+func NewRevisionHandler(revisionService *service.RevisionService) *RevisionHandler {
+	return &RevisionHandler{revisionService: revisionService}
+}
+
+// List — GET /api/decks/:id/revisions?card_id=&page=&limit=
+func (h *RevisionHandler) List(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	page, limit := 1, 20
+	if p := c.Query("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 {
+			page = n
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	var cardID *int
+	if cid := c.Query("card_id"); cid != "" {
+		if n, err := strconv.Atoi(cid); err == nil {
+			cardID = &n
+		}
+	}
+	resp, err := h.revisionService.List(c.Request.Context(), deckID, middleware.GetUserID(c), cardID, page, limit)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+	JSON(c, resp)
+}
+
+// Get — GET /api/decks/:id/revisions/:rev: набор на момент ревизии
+func (h *RevisionHandler) Get(c *gin.Context) {
+	deckID, revID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+	resp, err := h.revisionService.StateAt(c.Request.Context(), deckID, middleware.GetUserID(c), revID)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+	JSON(c, resp)
+}
+
+// Diff — GET /api/decks/:id/revisions/diff?from=&to=
+func (h *RevisionHandler) Diff(c *gin.Context) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		BadRequestSimple(c, "параметры from и to обязательны")
+		return
+	}
+	resp, err := h.revisionService.Diff(c.Request.Context(), deckID, middleware.GetUserID(c), from, to)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+	JSON(c, resp)
+}
+
+// Rollback — POST /api/decks/:id/revisions/:rev/rollback
+func (h *RevisionHandler) Rollback(c *gin.Context) {
+	deckID, revID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+	var req domain.RollbackRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequestSimple(c, "неверный формат запроса")
+			return
+		}
+	}
+	resp, err := h.revisionService.Rollback(c.Request.Context(), deckID, middleware.GetUserID(c), revID, req.CardID)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+	JSON(c, resp)
+}
+
+func revisionParams(c *gin.Context) (deckID, revID int, ok bool) {
+	deckID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return 0, 0, false
+	}
+	revID, err = strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID ревизии")
+		return 0, 0, false
+	}
+	return deckID, revID, true
+}
+
+func (h *RevisionHandler) revisionError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrDeckNotFound, service.ErrRevisionNotFound:
+		NotFound(c, err.Error())
+	case service.ErrDeckForbidden:
+		Forbidden(c, err.Error())
+	default:
+		InternalError(c, "ошибка загрузки истории набора")
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+	"github.com/pro100kartochki/mozgoemka/pkg/validator"
+)
+
+type StudyHandler struct {
+	studyService *service.StudyService
+	validator    *validator.Validator
+}
+
+func NewStudyHandler(studyService *service.StudyService, v *validator.Validator) *StudyHandler {
+	return &StudyHandler{studyService: studyService, validator: v}
+}
+
+// Queue — GET /api/study/queue?deck_id=&limit=
+func (h *StudyHandler) Queue(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	var deckID *int
+	if did := c.Query("deck_id"); did != "" {
+		if n, err := strconv.Atoi(did); err == nil {
+			deckID = &n
+		}
+	}
+	resp, err := h.studyService.Queue(c.Request.Context(), userID, deckID, limit)
+	if err != nil {
+		InternalError(c, "ошибка загрузки очереди изучения")
+		return
+	}
+	JSON(c, resp)
+}
+
+// Review — POST /api/study/cards/:id/review
+func (h *StudyHandler) Review(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	var req domain.ReviewCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	progress, err := h.studyService.Review(c.Request.Context(), id, userID, *req.Grade)
+	if err != nil {
+		if err == service.ErrCardNotFound {
+			NotFound(c, err.Error())
+			return
+		}
+		if err == service.ErrCardForbidden {
+			Forbidden(c, err.Error())
+			return
+		}
+		InternalError(c, "ошибка сохранения ответа")
+		return
+	}
+	JSON(c, progress)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+)
+
+type SubscriptionHandler struct {
+	subscriptionService *service.SubscriptionService
+}
+
+func NewSubscriptionHandler(subscriptionService *service.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{subscriptionService: subscriptionService}
+}
+
+// Subscribe — POST /api/public/decks/:id/subscription
+func (h *SubscriptionHandler) Subscribe(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	sub, err := h.subscriptionService.Subscribe(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrDeckNotFound {
+			NotFound(c, err.Error())
+			return
+		}
+		if err == service.ErrSubscribeOwnDeck {
+			BadRequestSimple(c, err.Error())
+			return
+		}
+		InternalError(c, "ошибка подписки на набор")
+		return
+	}
+	Created(c, sub)
+}
+
+// Unsubscribe — DELETE /api/public/decks/:id/subscription
+func (h *SubscriptionHandler) Unsubscribe(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	userID := middleware.GetUserID(c)
+	if err := h.subscriptionService.Unsubscribe(c.Request.Context(), id, userID); err != nil {
+		InternalError(c, "ошибка отмены подписки")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully"})
+}
+
+// List — GET /api/subscriptions
+func (h *SubscriptionHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	resp, err := h.subscriptionService.List(c.Request.Context(), userID)
+	if err != nil {
+		InternalError(c, "ошибка загрузки подписок")
+		return
+	}
+	JSON(c, resp)
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"net/http"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
 	"github.com/pro100kartochki/mozgoemka/internal/service"
 	"github.com/pro100kartochki/mozgoemka/pkg/validator"
 )
@@ -17,14 +22,14 @@ func NewTagHandler(tagService *service.TagService, v *validator.Validator) *TagH
 }
 
 // List godoc
-// @Summary      Список тегов
+// @Summary      Список тегов: общие и, для авторизованного пользователя, его личные
 // @Tags         tags
 // @Produce      json
-// @Success      200   {array}   domain.Tag
+// @Success      200   {object}  domain.TagsResponse
 // @Router       /tags [get]
 func (h *TagHandler) List(c *gin.Context) {
 	search := c.Query("search")
-	list, err := h.tagService.ListWithSearch(c.Request.Context(), search)
+	list, err := h.tagService.ListWithUsage(c.Request.Context(), middleware.GetUserID(c), search)
 	if err != nil {
 		InternalError(c, "ошибка загрузки тегов")
 		return
@@ -33,7 +38,7 @@ func (h *TagHandler) List(c *gin.Context) {
 }
 
 // Create godoc
-// @Summary      Создать тег
+// @Summary      Создать личный тег (global — общий, moderator и admin); путь lang::de::verbs создаёт родительские теги
 // @Tags         tags
 // @Accept       json
 // @Produce      json
@@ -52,14 +57,160 @@ func (h *TagHandler) Create(c *gin.Context) {
 		BadRequest(c, "ошибка валидации", errs)
 		return
 	}
-	tag, err := h.tagService.Create(c.Request.Context(), req)
+	tag, err := h.tagService.Create(c.Request.Context(), middleware.GetUserID(c), middleware.GetUserRole(c), req)
 	if err != nil {
 		if err == service.ErrTagExists {
 			Conflict(c, err.Error())
 			return
 		}
+		if err == service.ErrTagForbidden {
+			Forbidden(c, err.Error())
+			return
+		}
+		if err == service.ErrTagPath {
+			BadRequestSimple(c, err.Error())
+			return
+		}
 		InternalError(c, "ошибка создания тега")
 		return
 	}
 	Created(c, tag)
 }
+
+// Suggest godoc
+// @Summary      Подсказать теги для черновика карточки или набора (TF-IDF по разметке пользователя)
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        body  body  domain.SuggestTagsRequest  true  "Текст черновика"
+// @Success      200   {object}  domain.TagSuggestionsResponse
+// @Failure      400   {object}  map[string]string
+// @Router       /tags/suggest [post]
+func (h *TagHandler) Suggest(c *gin.Context) {
+	var req domain.SuggestTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	list, err := h.tagService.Suggest(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		InternalError(c, "ошибка подбора тегов")
+		return
+	}
+	JSON(c, domain.TagSuggestionsResponse{Suggestions: list})
+}
+
+// Update godoc
+// @Summary      Переименовать тег (общий — moderator, admin; личный — владелец)
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path  int  true  "ID тега"
+// @Param        body  body  domain.UpdateTagRequest  true  "Новое название"
+// @Success      200   {object}  domain.Tag
+// @Router       /tags/{id} [put]
+func (h *TagHandler) Update(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	var req domain.UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	tag, err := h.tagService.Rename(c.Request.Context(), id, middleware.GetUserID(c), middleware.GetUserRole(c), req)
+	if err != nil {
+		h.tagError(c, err, "ошибка переименования тега")
+		return
+	}
+	JSON(c, tag)
+}
+
+// Merge godoc
+// @Summary      Объединить тег с другим тегом (общий — moderator, admin; личный — владелец)
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path  int  true  "ID тега, который будет удалён"
+// @Param        body  body  domain.MergeTagRequest  true  "Тег, который останется"
+// @Success      200   {object}  domain.Tag
+// @Router       /tags/{id}/merge [post]
+func (h *TagHandler) Merge(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	var req domain.MergeTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "неверный формат запроса", nil)
+		return
+	}
+	if errs := h.validator.Validate(&req); errs != nil {
+		BadRequest(c, "ошибка валидации", errs)
+		return
+	}
+	tag, err := h.tagService.Merge(c.Request.Context(), id, middleware.GetUserID(c), middleware.GetUserRole(c), req)
+	if err != nil {
+		h.tagError(c, err, "ошибка объединения тегов")
+		return
+	}
+	JSON(c, tag)
+}
+
+// Delete godoc
+// @Summary      Удалить тег (общий — moderator, admin; личный — владелец)
+// @Tags         tags
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id     path   int   true   "ID тега"
+// @Param        force  query  bool  false  "удалить, даже если тег используется"
+// @Success      200   {object}  map[string]string
+// @Failure      409   {object}  ErrorPayload
+// @Router       /tags/{id} [delete]
+func (h *TagHandler) Delete(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	force := c.Query("force") == "true"
+	if err := h.tagService.Delete(c.Request.Context(), id, middleware.GetUserID(c), middleware.GetUserRole(c), force); err != nil {
+		h.tagError(c, err, "ошибка удаления тега")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+func (h *TagHandler) tagError(c *gin.Context, err error, message string) {
+	var inUse *service.TagInUseError
+	switch {
+	case errors.As(err, &inUse):
+		errorResponse(c, http.StatusConflict, CodeConflict, err.Error(), inUse)
+	case err == service.ErrTagNotFound:
+		NotFound(c, err.Error())
+	case err == service.ErrTagExists:
+		Conflict(c, err.Error())
+	case err == service.ErrTagForbidden:
+		Forbidden(c, err.Error())
+	case err == service.ErrTagMergeSelf, err == service.ErrTagMergeScope, err == service.ErrTagPath, err == service.ErrTagCycle:
+		BadRequestSimple(c, err.Error())
+	case err == service.ErrTagHasChildren:
+		Conflict(c, err.Error())
+	default:
+		InternalError(c, message)
+	}
+}
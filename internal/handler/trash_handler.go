@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/middleware"
+	"github.com/pro100kartochki/mozgoemka/internal/service"
+)
+
+type TrashHandler struct {
+	trashService *service.TrashService
+}
+
+func NewTrashHandler(trashService *service.TrashService) *TrashHandler {
+	return &TrashHandler{trashService: trashService}
+}
+
+// List — GET /api/trash
+func (h *TrashHandler) List(c *gin.Context) {
+	resp, err := h.trashService.List(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		InternalError(c, "ошибка загрузки корзины")
+		return
+	}
+	JSON(c, resp)
+}
+
+// Empty — DELETE /api/trash
+func (h *TrashHandler) Empty(c *gin.Context) {
+	if err := h.trashService.Empty(c.Request.Context(), middleware.GetUserID(c)); err != nil {
+		InternalError(c, "ошибка очистки корзины")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied successfully"})
+}
+
+// RestoreDeck — POST /api/trash/decks/:id/restore
+func (h *TrashHandler) RestoreDeck(c *gin.Context) {
+	h.do(c, h.trashService.RestoreDeck, "Deck restored successfully")
+}
+
+// RestoreCard — POST /api/trash/cards/:id/restore
+func (h *TrashHandler) RestoreCard(c *gin.Context) {
+	h.do(c, h.trashService.RestoreCard, "Card restored successfully")
+}
+
+// PurgeDeck — DELETE /api/trash/decks/:id
+func (h *TrashHandler) PurgeDeck(c *gin.Context) {
+	h.do(c, h.trashService.PurgeDeck, "Deck deleted permanently")
+}
+
+// PurgeCard — DELETE /api/trash/cards/:id
+func (h *TrashHandler) PurgeCard(c *gin.Context) {
+	h.do(c, h.trashService.PurgeCard, "Card deleted permanently")
+}
+
+func (h *TrashHandler) do(c *gin.Context, fn func(ctx context.Context, id int, userID int) error, message string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		BadRequestSimple(c, "неверный ID")
+		return
+	}
+	if err := fn(c.Request.Context(), id, middleware.GetUserID(c)); err != nil {
+		switch err {
+		case service.ErrTrashNotFound:
+			NotFound(c, err.Error())
+		case service.ErrDeckForbidden, service.ErrCardForbidden:
+			Forbidden(c, err.Error())
+		case service.ErrDeckInTrash, service.ErrParentInTrash:
+			Conflict(c, err.Error())
+		default:
+			InternalError(c, "ошибка операции с корзиной")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
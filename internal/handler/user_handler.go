@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"io"
 	"net/http"
 
@@ -90,3 +91,105 @@ func (h *UserHandler) UploadAvatar(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"avatar_url": avatarURL})
 }
+
+const maxAccountArchiveSize = 2 << 30 // 2GB
+
+// StartExport godoc
+// @Summary      Выгрузка всех данных аккаунта
+// @Description  Ставит в очередь выгрузку профиля, наборов, карточек, тегов, медиафайлов, журнала повторений и подписок в zip (схема — domain.AccountManifest). Ход — GET /users/me/export/{id} или GET /jobs/{id}; в статусе done появляется download_url, архив хранится до expires_at.
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  domain.Job
+// @Router       /users/me/export [post]
+func (h *UserHandler) StartExport(c *gin.Context) {
+	e, err := h.userService.StartExport(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		InternalError(c, "ошибка выгрузки")
+		return
+	}
+	Accepted(c, e)
+}
+
+// ExportStatus godoc
+// @Summary      Состояние выгрузки аккаунта
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path  string  true  "ID выгрузки"
+// @Success      200  {object}  domain.Job
+// @Failure      404  {object}  map[string]string
+// @Router       /users/me/export/{id} [get]
+func (h *UserHandler) ExportStatus(c *gin.Context) {
+	e, err := h.userService.Export(c.Request.Context(), c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		NotFound(c, err.Error())
+		return
+	}
+	JSON(c, e)
+}
+
+// DownloadExport godoc
+// @Summary      Скачивание архива аккаунта
+// @Tags         users
+// @Produce      application/zip
+// @Security     BearerAuth
+// @Param        id   path  string  true  "ID выгрузки"
+// @Success      200
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /users/me/export/{id}/download [get]
+func (h *UserHandler) DownloadExport(c *gin.Context) {
+	path, f, err := h.userService.ExportFile(c.Request.Context(), c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrAccountExportNotReady) {
+			Conflict(c, err.Error())
+			return
+		}
+		NotFound(c, err.Error())
+		return
+	}
+	c.FileAttachment(path, f.Filename)
+}
+
+// ImportAccount godoc
+// @Summary      Восстановление аккаунта из архива
+// @Description  Восстанавливает архив POST /users/me/export в аккаунт без наборов фоновой задачей импорта: наборы, карточки, теги, медиафайлы, прогресс, журнал повторений, подписки, имя и аватар. Ход задачи — GET /jobs/{id}.
+// @Tags         users
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        file  formData  file  true  "Архив .zip"
+// @Success      202  {object}  domain.Job
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /users/me/import [post]
+func (h *UserHandler) ImportAccount(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		BadRequest(c, "требуется файл file (.zip)", nil)
+		return
+	}
+	if file.Size > maxAccountArchiveSize {
+		BadRequestSimple(c, "файл слишком большой (макс. 2GB)")
+		return
+	}
+	path, err := saveTemp(file, "import-*.zip", h.userService.SaveUpload)
+	if err != nil {
+		InternalError(c, "ошибка чтения файла")
+		return
+	}
+	job, err := h.userService.ImportAccount(c.Request.Context(), middleware.GetUserID(c), path)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAccountArchive), errors.Is(err, service.ErrAccountArchiveVersion):
+			BadRequestSimple(c, err.Error())
+		case errors.Is(err, service.ErrAccountNotEmpty):
+			Conflict(c, err.Error())
+		default:
+			InternalError(c, "ошибка импорта")
+		}
+		return
+	}
+	Accepted(c, job)
+}
@@ -5,13 +5,15 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
 	"github.com/pro100kartochki/mozgoemka/pkg/jwt"
 )
 
 const UserIDKey = "user_id"
 const UserRoleKey = "user_role"
 
-// Auth извлекает JWT из заголовка Authorization и кладёт user_id, user_role в контекст.
+// Auth извлекает JWT из заголовка Authorization и кладёт user_id, user_role в контекст;
+// пользователь становится автором изменений наборов в истории (domain.WithRevisionAuthor).
 func Auth(jwtManager *jwt.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
@@ -31,6 +33,23 @@ func Auth(jwtManager *jwt.Manager) gin.HandlerFunc {
 		}
 		c.Set(UserIDKey, claims.UserID)
 		c.Set(UserRoleKey, claims.Role)
+		c.Request = c.Request.WithContext(domain.WithRevisionAuthor(c.Request.Context(), claims.UserID))
+		c.Next()
+	}
+}
+
+// OptionalAuth кладёт user_id, user_role в контекст, если передан действительный JWT;
+// без токена запрос обрабатывается как анонимный.
+func OptionalAuth(jwtManager *jwt.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := jwtManager.ParseAccessToken(parts[1]); err == nil {
+				c.Set(UserIDKey, claims.UserID)
+				c.Set(UserRoleKey, claims.Role)
+				c.Request = c.Request.WithContext(domain.WithRevisionAuthor(c.Request.Context(), claims.UserID))
+			}
+		}
 		c.Next()
 	}
 }
@@ -43,3 +62,27 @@ func GetUserID(c *gin.Context) int {
 	}
 	return id.(int)
 }
+
+// GetUserRole возвращает роль пользователя из контекста (после Auth middleware).
+func GetUserRole(c *gin.Context) domain.UserRole {
+	role, _ := c.Get(UserRoleKey)
+	if role == nil {
+		return ""
+	}
+	return domain.UserRole(role.(string))
+}
+
+// RequireRole пропускает только пользователей с одной из ролей (после Auth middleware).
+func RequireRole(roles ...domain.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetUserRole(c)
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		// та же форма, что у handler.ErrorPayload: middleware не может импортировать handler
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "FORBIDDEN", "message": "недостаточно прав"}})
+	}
+}
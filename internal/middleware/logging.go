@@ -17,12 +17,17 @@ func Logging(logger *zap.Logger) gin.HandlerFunc {
 		c.Next()
 		latency := time.Since(start)
 		status := c.Writer.Status()
-		logger.Info("request",
+		fields := []zap.Field{
 			zap.String("method", method),
 			zap.String("path", path),
 			zap.Int("status", status),
 			zap.Duration("latency", latency),
 			zap.String("client_ip", clientIP),
-		)
+		}
+		// ошибки, случившиеся после отправки заголовков (например, при потоковой выгрузке)
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("error", c.Errors.String()))
+		}
+		logger.Info("request", fields...)
 	}
 }
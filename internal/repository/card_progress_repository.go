@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+type CardProgressRepository struct {
+	db *DB
+}
+
+func NewCardProgressRepository(db *DB) *CardProgressRepository {
+	return &CardProgressRepository{db: db}
+}
+
+func (r *CardProgressRepository) Get(ctx context.Context, userID, cardID int) (*domain.CardProgress, error) {
+	query := `SELECT user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at
+		FROM card_progress WHERE user_id = $1 AND card_id = $2`
+	var p domain.CardProgress
+	err := r.db.Pool.QueryRow(ctx, query, userID, cardID).Scan(
+		&p.UserID, &p.CardID, &p.EaseFactor, &p.IntervalDays, &p.Repetitions, &p.DueAt, &p.LastReviewedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *CardProgressRepository) Upsert(ctx context.Context, p *domain.CardProgress) error {
+	query := `INSERT INTO card_progress (user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, card_id) DO UPDATE SET ease_factor = EXCLUDED.ease_factor, interval_days = EXCLUDED.interval_days,
+			repetitions = EXCLUDED.repetitions, due_at = EXCLUDED.due_at, last_reviewed_at = EXCLUDED.last_reviewed_at`
+	_, err := r.db.Pool.Exec(ctx, query, p.UserID, p.CardID, p.EaseFactor, p.IntervalDays, p.Repetitions, p.DueAt, p.LastReviewedAt)
+	return err
+}
+
+// StudyItem — карточка очереди изучения с прогрессом пользователя (nil — новая карточка).
+type StudyItem struct {
+	Card       domain.Card
+	DeckTitle  string
+	Subscribed bool
+	Progress   *domain.CardProgress
+}
+
+// ListDue возвращает очередь изучения пользователя: карточки его наборов и публичных наборов
+// по подписке, у которых подошёл срок повторения, затем новые. deckID ограничивает очередь набором
+// и вложенными в него наборами.
+func (r *CardProgressRepository) ListDue(ctx context.Context, userID int, deckID *int, now time.Time, limit int) ([]StudyItem, error) {
+	query := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at,
+			d.title, d.user_id IS DISTINCT FROM $1, p.ease_factor, p.interval_days, p.repetitions, p.due_at, p.last_reviewed_at
+		FROM cards c
+		INNER JOIN decks d ON d.id = c.deck_id
+		LEFT JOIN card_progress p ON p.card_id = c.id AND p.user_id = $1
+		WHERE c.deleted_at IS NULL AND d.deleted_at IS NULL
+			AND (d.user_id = $1 OR (d.is_public = true AND EXISTS (
+				SELECT 1 FROM deck_subscriptions s WHERE s.deck_id = d.id AND s.user_id = $1)))
+			AND (p.due_at IS NULL OR p.due_at <= $2)`
+	args := []interface{}{userID, now}
+	pos := 3
+	if deckID != nil {
+		query += ` AND c.deck_id IN (WITH RECURSIVE tree AS (` + deckSubtree("$"+strconv.Itoa(pos)) + `) SELECT id FROM tree)`
+		args = append(args, *deckID)
+		pos++
+	}
+	query += ` ORDER BY p.due_at NULLS LAST, c.created_at, c.id LIMIT $` + strconv.Itoa(pos)
+	args = append(args, limit)
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []StudyItem
+	for rows.Next() {
+		var it StudyItem
+		var ease *float64
+		var interval, reps *int
+		var dueAt, reviewedAt *time.Time
+		if err := scanCard(rows, &it.Card, &it.DeckTitle, &it.Subscribed, &ease, &interval, &reps, &dueAt, &reviewedAt); err != nil {
+			return nil, err
+		}
+		if dueAt != nil {
+			it.Progress = &domain.CardProgress{
+				UserID: userID, CardID: it.Card.ID, EaseFactor: *ease, IntervalDays: *interval,
+				Repetitions: *reps, DueAt: *dueAt, LastReviewedAt: reviewedAt,
+			}
+		}
+		list = append(list, it)
+	}
+	return list, rows.Err()
+}
+
+// UpsertBatch сохраняет состояние повторений нескольких карточек одной транзакцией.
+func (r *CardProgressRepository) UpsertBatch(ctx context.Context, list []domain.CardProgress) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		batch := &pgx.Batch{}
+		for _, p := range list {
+			batch.Queue(`INSERT INTO card_progress (user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (user_id, card_id) DO UPDATE SET ease_factor = EXCLUDED.ease_factor, interval_days = EXCLUDED.interval_days,
+					repetitions = EXCLUDED.repetitions, due_at = EXCLUDED.due_at, last_reviewed_at = EXCLUDED.last_reviewed_at`,
+				p.UserID, p.CardID, p.EaseFactor, p.IntervalDays, p.Repetitions, p.DueAt, p.LastReviewedAt)
+		}
+		return t.SendBatch(ctx, batch).Close()
+	})
+}
+
+// LogReview добавляет ответ в журнал повторений.
+func (r *CardProgressRepository) LogReview(ctx context.Context, l *domain.ReviewLog) error {
+	query := `INSERT INTO review_log (user_id, card_id, grade, interval_days, ease_factor, duration_ms, reviewed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	return r.db.Pool.QueryRow(ctx, query, l.UserID, l.CardID, l.Grade, l.IntervalDays, l.EaseFactor, l.DurationMs, l.ReviewedAt).Scan(&l.ID)
+}
+
+// LogReviewsBatch загружает записи журнала повторений через COPY (импорт истории).
+func (r *CardProgressRepository) LogReviewsBatch(ctx context.Context, list []domain.ReviewLog) error {
+	if len(list) == 0 {
+		return nil
+	}
+	rows := make([][]interface{}, len(list))
+	for i, l := range list {
+		rows[i] = []interface{}{l.UserID, l.CardID, l.Grade, l.IntervalDays, l.EaseFactor, l.DurationMs, l.ReviewedAt}
+	}
+	_, err := r.db.Pool.CopyFrom(ctx, pgx.Identifier{"review_log"},
+		[]string{"user_id", "card_id", "grade", "interval_days", "ease_factor", "duration_ms", "reviewed_at"}, pgx.CopyFromRows(rows))
+	return err
+}
+
+// EachByUserID передаёт fn состояние повторений всех карточек пользователя по одной (выгрузка аккаунта).
+func (r *CardProgressRepository) EachByUserID(ctx context.Context, userID int, fn func(*domain.CardProgress) error) error {
+	query := `SELECT user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at
+		FROM card_progress WHERE user_id = $1 ORDER BY card_id`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p domain.CardProgress
+		if err := rows.Scan(&p.UserID, &p.CardID, &p.EaseFactor, &p.IntervalDays, &p.Repetitions, &p.DueAt, &p.LastReviewedAt); err != nil {
+			return err
+		}
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// EachReview передаёт fn журнал ответов пользователя по одной записи в порядке времени.
+func (r *CardProgressRepository) EachReview(ctx context.Context, userID int, fn func(*domain.ReviewLog) error) error {
+	query := `SELECT id, user_id, card_id, grade, interval_days, ease_factor, duration_ms, reviewed_at
+		FROM review_log WHERE user_id = $1 ORDER BY reviewed_at, id`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l domain.ReviewLog
+		if err := rows.Scan(&l.ID, &l.UserID, &l.CardID, &l.Grade, &l.IntervalDays, &l.EaseFactor, &l.DurationMs, &l.ReviewedAt); err != nil {
+			return err
+		}
+		if err := fn(&l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
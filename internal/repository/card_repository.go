@@ -3,11 +3,15 @@ package repository
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 )
 
+// cardColumns — колонки cards в порядке scanCard.
+const cardColumns = `id, deck_id, question, answer, category_id, origin_card_id, created_at, updated_at`
+
 type CardRepository struct {
 	db *DB
 }
@@ -19,15 +23,13 @@ func NewCardRepository(db *DB) *CardRepository {
 func (r *CardRepository) Create(ctx context.Context, c *domain.Card) error {
 	query := `INSERT INTO cards (deck_id, question, answer, category_id)
 		VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
-	return r.db.Pool.QueryRow(ctx, query, c.DeckID, c.Question, c.Answer, c.CategoryID).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	return r.db.queryRow(ctx, query, c.DeckID, c.Question, c.Answer, c.CategoryID).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
 }
 
 func (r *CardRepository) GetByID(ctx context.Context, id int) (*domain.Card, error) {
-	query := `SELECT id, deck_id, question, answer, category_id, created_at, updated_at FROM cards WHERE id = $1`
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id = $1 AND deleted_at IS NULL`
 	var c domain.Card
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&c.ID, &c.DeckID, &c.Question, &c.Answer, &c.CategoryID, &c.CreatedAt, &c.UpdatedAt,
-	)
+	err := scanCard(r.db.Pool.QueryRow(ctx, query, id), &c)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -38,8 +40,7 @@ func (r *CardRepository) GetByID(ctx context.Context, id int) (*domain.Card, err
 }
 
 func (r *CardRepository) ListByDeckID(ctx context.Context, deckID int) ([]domain.Card, error) {
-	query := `SELECT id, deck_id, question, answer, category_id, created_at, updated_at
-		FROM cards WHERE deck_id = $1 ORDER BY created_at`
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE deck_id = $1 AND deleted_at IS NULL ORDER BY created_at`
 	rows, err := r.db.Pool.Query(ctx, query, deckID)
 	if err != nil {
 		return nil, err
@@ -50,20 +51,22 @@ func (r *CardRepository) ListByDeckID(ctx context.Context, deckID int) ([]domain
 
 func (r *CardRepository) CountByDeckID(ctx context.Context, deckID int) (int, error) {
 	var n int
-	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM cards WHERE deck_id = $1`, deckID).Scan(&n)
+	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM cards WHERE deck_id = $1 AND deleted_at IS NULL`, deckID).Scan(&n)
 	return n, err
 }
 
 // CountByUserID возвращает общее количество карточек во всех наборах пользователя.
 func (r *CardRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
 	var n int
-	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM cards c INNER JOIN decks d ON c.deck_id = d.id WHERE d.user_id = $1`, userID).Scan(&n)
+	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM cards c INNER JOIN decks d ON c.deck_id = d.id
+		WHERE d.user_id = $1 AND c.deleted_at IS NULL AND d.deleted_at IS NULL`, userID).Scan(&n)
 	return n, err
 }
 
-// ListByUserIDWithFilters возвращает карточки пользователя с пагинацией и фильтрами.
+// ListByUserIDWithFilters возвращает карточки пользователя с пагинацией и фильтрами; tag_id учитывает и дочерние теги.
+// С search карточки ищутся полнотекстовым поиском, сортируются по рангу и получают Match.
 func (r *CardRepository) ListByUserIDWithFilters(ctx context.Context, userID int, page, limit int, categoryID *int, tagID *int, search string) ([]domain.Card, int, error) {
-	baseCond := ` FROM cards c INNER JOIN decks d ON c.deck_id = d.id WHERE d.user_id = $1`
+	baseCond := ` FROM cards c INNER JOIN decks d ON c.deck_id = d.id WHERE d.user_id = $1 AND c.deleted_at IS NULL AND d.deleted_at IS NULL`
 	args := []interface{}{userID}
 	pos := 2
 	if categoryID != nil {
@@ -72,14 +75,18 @@ func (r *CardRepository) ListByUserIDWithFilters(ctx context.Context, userID int
 		pos++
 	}
 	if tagID != nil {
-		baseCond += ` AND EXISTS (SELECT 1 FROM card_tags ct WHERE ct.card_id = c.id AND ct.tag_id = $` + strconv.Itoa(pos) + `)`
+		baseCond += ` AND EXISTS (SELECT 1 FROM card_tags ct WHERE ct.card_id = c.id AND ct.tag_id IN (` + tagSubtreeIDs("$"+strconv.Itoa(pos), "$1") + `))`
 		args = append(args, *tagID)
 		pos++
 	}
+	searchCols, orderBy := ``, ` ORDER BY c.created_at DESC`
 	if search != "" {
-		baseCond += ` AND (c.question ILIKE $` + strconv.Itoa(pos) + ` OR c.answer ILIKE $` + strconv.Itoa(pos) + `)`
-		args = append(args, "%"+search+"%")
-		pos++
+		q, pattern := "$"+strconv.Itoa(pos), "$"+strconv.Itoa(pos+1)
+		baseCond += searchCond(`c.search_vector`, q, pattern, `c.question`, `c.answer`)
+		searchCols = searchColumns(`c.search_vector`, q, `c.question`, `c.answer`)
+		orderBy = ` ORDER BY search_rank DESC, c.created_at DESC`
+		args = append(args, search, "%"+search+"%")
+		pos += 2
 	}
 	var total int
 	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*)`+baseCond, args...).Scan(&total); err != nil {
@@ -97,27 +104,496 @@ func (r *CardRepository) ListByUserIDWithFilters(ctx context.Context, userID int
 	offset := (page - 1) * limit
 	listArgs := append([]interface{}{}, args...)
 	listArgs = append(listArgs, limit, offset)
-	listQuery := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.created_at, c.updated_at` + baseCond +
-		` ORDER BY c.created_at DESC LIMIT $` + strconv.Itoa(pos) + ` OFFSET $` + strconv.Itoa(pos+1)
+	listQuery := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at` + searchCols + baseCond +
+		orderBy + ` LIMIT $` + strconv.Itoa(pos) + ` OFFSET $` + strconv.Itoa(pos+1)
 	rows, err := r.db.Pool.Query(ctx, listQuery, listArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
-	list, err := r.scanCards(rows)
-	return list, total, err
+	if search == "" {
+		list, err := r.scanCards(rows)
+		return list, total, err
+	}
+	var list []domain.Card
+	for rows.Next() {
+		var c domain.Card
+		hit := newSearchHit(2)
+		if err := scanCard(rows, &c, hit.dest()...); err != nil {
+			return nil, 0, err
+		}
+		c.Match = hit.match("question", "answer")
+		list = append(list, c)
+	}
+	return list, total, rows.Err()
 }
 
 func (r *CardRepository) Update(ctx context.Context, c *domain.Card) error {
 	query := `UPDATE cards SET question=$2, answer=$3, category_id=$4, updated_at=NOW() WHERE id=$1 RETURNING updated_at`
-	return r.db.Pool.QueryRow(ctx, query, c.ID, c.Question, c.Answer, c.CategoryID).Scan(&c.UpdatedAt)
+	return r.db.queryRow(ctx, query, c.ID, c.Question, c.Answer, c.CategoryID).Scan(&c.UpdatedAt)
 }
 
+// Delete перемещает карточку в корзину.
 func (r *CardRepository) Delete(ctx context.Context, id int) error {
-	_, err := r.db.Pool.Exec(ctx, `DELETE FROM cards WHERE id = $1`, id)
+	_, err := r.db.exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
 	return err
 }
 
+// ListByIDs возвращает карточки по списку ID (порядок не гарантируется).
+func (r *CardRepository) ListByIDs(ctx context.Context, ids []int) ([]domain.Card, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `SELECT ` + cardColumns + ` FROM cards WHERE id = ANY($1) AND deleted_at IS NULL`
+	rows, err := r.db.Pool.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return r.scanCards(rows)
+}
+
+// ExportCard — карточка для экспорта: имена тегов, категория и прогресс пользователя.
+type ExportCard struct {
+	Card         domain.Card
+	Tags         []string
+	CategoryName string
+	CategorySlug string
+	Progress     *domain.CardProgress
+}
+
+// EachForExport передаёт fn карточки наборов deckIDs по одной, не загружая их все в память.
+// Теги — видимые пользователю userID, прогресс — его же.
+func (r *CardRepository) EachForExport(ctx context.Context, deckIDs []int, userID int, fn func(*ExportCard) error) error {
+	query := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at, COALESCE(cat.name, ''), COALESCE(cat.slug, ''),
+			ARRAY(SELECT t.name FROM card_tags ct INNER JOIN tags t ON t.id = ct.tag_id
+				WHERE ct.card_id = c.id AND (t.user_id IS NULL OR t.user_id = $2) ORDER BY t.name),
+			p.ease_factor, p.interval_days, p.repetitions, p.due_at, p.last_reviewed_at
+		FROM cards c
+		LEFT JOIN categories cat ON cat.id = c.category_id
+		LEFT JOIN card_progress p ON p.card_id = c.id AND p.user_id = $2
+		WHERE c.deck_id = ANY($1) AND c.deleted_at IS NULL
+		ORDER BY c.deck_id, c.created_at, c.id`
+	rows, err := r.db.Pool.Query(ctx, query, deckIDs, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ec ExportCard
+		var ease *float64
+		var interval, reps *int
+		var dueAt, reviewedAt *time.Time
+		if err := scanCard(rows, &ec.Card, &ec.CategoryName, &ec.CategorySlug, &ec.Tags, &ease, &interval, &reps, &dueAt, &reviewedAt); err != nil {
+			return err
+		}
+		if dueAt != nil {
+			ec.Progress = &domain.CardProgress{
+				UserID: userID, CardID: ec.Card.ID, EaseFactor: *ease, IntervalDays: *interval,
+				Repetitions: *reps, DueAt: *dueAt, LastReviewedAt: reviewedAt,
+			}
+		}
+		if err := fn(&ec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CreateBatch вставляет карточки одной транзакцией через pgx.Batch, теги привязываются через COPY.
+// tagIDs[i] — теги карточки cards[i].
+func (r *CardRepository) CreateBatch(ctx context.Context, cards []domain.Card, tagIDs [][]int) error {
+	return r.CreateBatchWithTags(ctx, 0, cards, tagIDs, nil)
+}
+
+// CreateBatchWithTags создаёт карточки как CreateBatch и в той же транзакции находит или создаёт
+// личные теги userID с именами newTags[i] (вместе с недостающими родителями); их ID добавляются
+// в tagIDs[i]. Тег ищется, как и при чтении, сначала среди общих. При ошибке не остаётся
+// ни карточек, ни тегов.
+func (r *CardRepository) CreateBatchWithTags(ctx context.Context, userID int, cards []domain.Card, tagIDs [][]int, newTags [][]string) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		created := make(map[string]int)
+		for i, names := range newTags {
+			for _, name := range names {
+				id, ok := created[name]
+				if !ok {
+					segments, valid := domain.ParseTagPath(name)
+					if !valid {
+						continue // имена проверяет вызывающий код
+					}
+					path := make([]string, len(segments))
+					for k := range segments {
+						path[k] = domain.JoinTagPath(segments[:k+1])
+					}
+					owner := userID
+					tagID, err := ensureTagParents(ctx, t, path, &owner)
+					if err != nil {
+						return err
+					}
+					id = *tagID
+					created[name] = id
+				}
+				tagIDs[i] = append(tagIDs[i], id)
+			}
+		}
+		batch := &pgx.Batch{}
+		for i := range cards {
+			batch.Queue(`INSERT INTO cards (deck_id, question, answer, category_id)
+				VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`,
+				cards[i].DeckID, cards[i].Question, cards[i].Answer, cards[i].CategoryID)
+		}
+		br := t.SendBatch(ctx, batch)
+		for i := range cards {
+			if err := br.QueryRow().Scan(&cards[i].ID, &cards[i].CreatedAt, &cards[i].UpdatedAt); err != nil {
+				_ = br.Close()
+				return err
+			}
+		}
+		if err := br.Close(); err != nil {
+			return err
+		}
+		return copyCardTags(ctx, t, cards, tagIDs)
+	})
+}
+
+// ListByAnchors возвращает карточки с якорями anchors (импорт конспектов) из наборов пользователя
+// внутри roots и вложенных в них, ключ — якорь.
+func (r *CardRepository) ListByAnchors(ctx context.Context, userID int, roots []int, anchors []string) (map[string]domain.Card, error) {
+	out := make(map[string]domain.Card)
+	if len(roots) == 0 || len(anchors) == 0 {
+		return out, nil
+	}
+	query := `WITH RECURSIVE tree AS (` + deckSubtree("ANY($2)") + `)
+		SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at, c.anchor
+		FROM cards c
+		INNER JOIN tree ON tree.id = c.deck_id
+		INNER JOIN decks d ON d.id = c.deck_id
+		WHERE d.user_id = $1 AND c.anchor = ANY($3) AND c.deleted_at IS NULL AND d.deleted_at IS NULL
+		ORDER BY c.id`
+	rows, err := r.db.Pool.Query(ctx, query, userID, roots, anchors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c domain.Card
+		var anchor string
+		if err := scanCard(rows, &c, &anchor); err != nil {
+			return nil, err
+		}
+		if _, dup := out[anchor]; !dup {
+			out[anchor] = c
+		}
+	}
+	return out, rows.Err()
+}
+
+// SaveAnchored одной транзакцией создаёт наборы decks, карточки create с якорями anchors[i]
+// и обновляет текст и набор карточек update (импорт конспектов). Наборы создаются по порядку;
+// отрицательные ParentID наборов и DeckID карточек ссылаются на создаваемый набор: -(i+1) — decks[i].
+func (r *CardRepository) SaveAnchored(ctx context.Context, decks []domain.Deck, create []domain.Card, anchors []string, update []domain.Card) error {
+	if len(decks) == 0 && len(create) == 0 && len(update) == 0 {
+		return nil
+	}
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		deckID := func(id int) int {
+			if id < 0 {
+				return decks[-id-1].ID
+			}
+			return id
+		}
+		for i := range decks {
+			d := &decks[i]
+			if d.ParentID != nil && *d.ParentID < 0 {
+				parentID := deckID(*d.ParentID)
+				d.ParentID = &parentID
+			}
+			if err := t.QueryRow(ctx, `INSERT INTO decks (user_id, title, description, category_id, is_public, parent_id)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, version, created_at, updated_at`,
+				d.UserID, d.Title, d.Description, d.CategoryID, d.IsPublic, d.ParentID,
+			).Scan(&d.ID, &d.Version, &d.CreatedAt, &d.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		batch := &pgx.Batch{}
+		for i := range create {
+			create[i].DeckID = deckID(create[i].DeckID)
+			batch.Queue(`INSERT INTO cards (deck_id, question, answer, category_id, anchor)
+				VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`,
+				create[i].DeckID, create[i].Question, create[i].Answer, create[i].CategoryID, anchors[i])
+		}
+		for i := range update {
+			update[i].DeckID = deckID(update[i].DeckID)
+			batch.Queue(`UPDATE cards SET deck_id=$2, question=$3, answer=$4, updated_at=NOW() WHERE id=$1 RETURNING created_at, updated_at`,
+				update[i].ID, update[i].DeckID, update[i].Question, update[i].Answer)
+		}
+		br := t.SendBatch(ctx, batch)
+		for i := range create {
+			if err := br.QueryRow().Scan(&create[i].ID, &create[i].CreatedAt, &create[i].UpdatedAt); err != nil {
+				_ = br.Close()
+				return err
+			}
+		}
+		for i := range update {
+			if err := br.QueryRow().Scan(&update[i].CreatedAt, &update[i].UpdatedAt); err != nil {
+				_ = br.Close()
+				return err
+			}
+		}
+		return br.Close()
+	})
+}
+
+// UpdateBatch обновляет карточки одной транзакцией. Если tagIDs[i] == nil, теги карточки не меняются.
+func (r *CardRepository) UpdateBatch(ctx context.Context, cards []domain.Card, tagIDs [][]int) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		batch := &pgx.Batch{}
+		for i := range cards {
+			batch.Queue(`UPDATE cards SET question=$2, answer=$3, category_id=$4, updated_at=NOW() WHERE id=$1 RETURNING updated_at`,
+				cards[i].ID, cards[i].Question, cards[i].Answer, cards[i].CategoryID)
+		}
+		br := t.SendBatch(ctx, batch)
+		for i := range cards {
+			if err := br.QueryRow().Scan(&cards[i].UpdatedAt); err != nil {
+				_ = br.Close()
+				return err
+			}
+		}
+		if err := br.Close(); err != nil {
+			return err
+		}
+		var retagged []int
+		for i := range cards {
+			if tagIDs[i] != nil {
+				retagged = append(retagged, cards[i].ID)
+			}
+		}
+		if len(retagged) == 0 {
+			return nil
+		}
+		if _, err := t.Exec(ctx, `DELETE FROM card_tags WHERE card_id = ANY($1)`, retagged); err != nil {
+			return err
+		}
+		return copyCardTags(ctx, t, cards, tagIDs)
+	})
+}
+
+// DeleteBatch перемещает карточки в корзину одним запросом.
+func (r *CardRepository) DeleteBatch(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL`, ids)
+	return err
+}
+
+// MoveToDeck переносит карточки в набор deckID. ID, теги и категория карточек сохраняются;
+// при resetProgress состояние повторений пользователя userID по этим карточкам удаляется.
+func (r *CardRepository) MoveToDeck(ctx context.Context, ids []int, deckID int, userID int, resetProgress bool) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if _, err := t.Exec(ctx, `UPDATE cards SET deck_id = $2, updated_at = NOW() WHERE id = ANY($1)`, ids, deckID); err != nil {
+			return err
+		}
+		if resetProgress {
+			if _, err := t.Exec(ctx, `DELETE FROM card_progress WHERE card_id = ANY($1) AND user_id = $2`, ids, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CopyToDeck копирует карточки в набор deckID вместе с категорией и тегами и возвращает копии
+// в порядке ids. При keepProgress копируется и состояние повторений пользователя userID.
+func (r *CardRepository) CopyToDeck(ctx context.Context, ids []int, deckID int, userID int, keepProgress bool) ([]domain.Card, error) {
+	copies := make([]domain.Card, len(ids))
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		batch := &pgx.Batch{}
+		for _, id := range ids {
+			batch.Queue(`INSERT INTO cards (deck_id, question, answer, category_id)
+				SELECT $2, question, answer, category_id FROM cards WHERE id = $1
+				RETURNING `+cardColumns, id, deckID)
+		}
+		br := t.SendBatch(ctx, batch)
+		for i := range ids {
+			if err := scanCard(br.QueryRow(), &copies[i]); err != nil {
+				_ = br.Close()
+				return err
+			}
+		}
+		if err := br.Close(); err != nil {
+			return err
+		}
+		batch = &pgx.Batch{}
+		for i, id := range ids {
+			batch.Queue(`INSERT INTO card_tags (card_id, tag_id) SELECT $2, tag_id FROM card_tags WHERE card_id = $1`, id, copies[i].ID)
+			if keepProgress {
+				batch.Queue(`INSERT INTO card_progress (user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at)
+					SELECT user_id, $2, ease_factor, interval_days, repetitions, due_at, last_reviewed_at
+					FROM card_progress WHERE card_id = $1 AND user_id = $3`, id, copies[i].ID, userID)
+			}
+		}
+		return t.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copies, nil
+}
+
+// DuplicateCandidate — карточка пользователя, похожая на проверяемую.
+type DuplicateCandidate struct {
+	Card       domain.Card
+	DeckTitle  string
+	Similarity float64 // похожесть нормализованных вопросов по триграммам, 0..1
+	Exact      bool    // нормализованные вопросы совпадают
+}
+
+// FindSimilar ищет карточки пользователя с вопросом, похожим на question не меньше threshold.
+// deckID ограничивает поиск набором (nil — все наборы пользователя); excludeID — ID проверяемой карточки.
+func (r *CardRepository) FindSimilar(ctx context.Context, userID int, deckID *int, question string, excludeID int, threshold float64, limit int) ([]DuplicateCandidate, error) {
+	query := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at,
+			d.title, similarity(normalize_card_text(c.question), normalize_card_text($2))::float8,
+			normalize_card_text(c.question) = normalize_card_text($2)
+		FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL AND c.deleted_at IS NULL AND c.id <> $3
+			AND normalize_card_text(c.question) % normalize_card_text($2)
+			AND similarity(normalize_card_text(c.question), normalize_card_text($2)) >= $4`
+	args := []interface{}{userID, question, excludeID, threshold}
+	if deckID != nil {
+		query += ` AND c.deck_id = $5`
+		args = append(args, *deckID)
+	}
+	query += ` ORDER BY 11 DESC, 10 DESC, c.id LIMIT ` + strconv.Itoa(limit)
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []DuplicateCandidate
+	for rows.Next() {
+		var dc DuplicateCandidate
+		if err := scanCard(rows, &dc.Card, &dc.DeckTitle, &dc.Similarity, &dc.Exact); err != nil {
+			return nil, err
+		}
+		list = append(list, dc)
+	}
+	return list, rows.Err()
+}
+
+// FindSimilarBatch ищет похожие карточки для каждой из cards одним запросом; результат — по индексу в cards.
+// Карточка не считается похожей сама на себя, но остальные карточки пачки в кандидаты попадают. При deckID == nil поиск идёт по всем наборам пользователя,
+// иначе только в наборе deckID.
+func (r *CardRepository) FindSimilarBatch(ctx context.Context, userID int, deckID *int, cards []domain.Card, threshold float64, limit int) ([][]DuplicateCandidate, error) {
+	result := make([][]DuplicateCandidate, len(cards))
+	if len(cards) == 0 {
+		return result, nil
+	}
+	questions := make([]string, len(cards))
+	ids := make([]int, len(cards))
+	for i, c := range cards {
+		questions[i] = c.Question
+		ids[i] = c.ID
+	}
+	scope := ""
+	args := []interface{}{userID, questions, ids, threshold}
+	if deckID != nil {
+		scope = ` AND c.deck_id = $5`
+		args = append(args, *deckID)
+	}
+	query := `SELECT s.id, s.deck_id, s.question, s.answer, s.category_id, s.origin_card_id, s.created_at, s.updated_at,
+			s.title, s.similarity, s.exact, q.idx
+		FROM unnest($2::text[], $3::int[]) WITH ORDINALITY AS q(question, card_id, idx)
+		CROSS JOIN LATERAL (
+			SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at,
+				d.title, similarity(normalize_card_text(c.question), normalize_card_text(q.question))::float8 AS similarity,
+				normalize_card_text(c.question) = normalize_card_text(q.question) AS exact
+			FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+			WHERE d.user_id = $1 AND d.deleted_at IS NULL AND c.deleted_at IS NULL AND c.id <> q.card_id
+				AND normalize_card_text(c.question) % normalize_card_text(q.question)
+				AND similarity(normalize_card_text(c.question), normalize_card_text(q.question)) >= $4` + scope + `
+			ORDER BY exact DESC, similarity DESC, c.id LIMIT ` + strconv.Itoa(limit) + `
+		) s
+		ORDER BY q.idx, s.exact DESC, s.similarity DESC, s.id`
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idx int
+		var dc DuplicateCandidate
+		if err := scanCard(rows, &dc.Card, &dc.DeckTitle, &dc.Similarity, &dc.Exact, &idx); err != nil {
+			return nil, err
+		}
+		result[idx-1] = append(result[idx-1], dc)
+	}
+	return result, rows.Err()
+}
+
+// DuplicatePair — пара похожих карточек; A всегда карточка проверяемого набора.
+type DuplicatePair struct {
+	A, B       int
+	Similarity float64
+	Exact      bool
+}
+
+// FindDuplicatePairs ищет пары похожих карточек среди карточек набора deckID, а при acrossDecks —
+// и между карточками набора и остальными наборами его владельца.
+func (r *CardRepository) FindDuplicatePairs(ctx context.Context, deckID int, acrossDecks bool, threshold float64) ([]DuplicatePair, error) {
+	scope := `b.deck_id = a.deck_id AND a.id < b.id`
+	if acrossDecks {
+		scope = `((b.deck_id = a.deck_id AND a.id < b.id) OR b.deck_id <> a.deck_id)`
+	}
+	query := `SELECT a.id, b.id, similarity(normalize_card_text(a.question), normalize_card_text(b.question))::float8,
+			normalize_card_text(a.question) = normalize_card_text(b.question)
+		FROM cards a
+		INNER JOIN decks da ON da.id = a.deck_id
+		INNER JOIN decks db ON db.user_id = da.user_id AND db.deleted_at IS NULL
+		INNER JOIN cards b ON b.deck_id = db.id AND b.deleted_at IS NULL
+		WHERE a.deck_id = $1 AND a.deleted_at IS NULL AND ` + scope + `
+			AND normalize_card_text(a.question) % normalize_card_text(b.question)
+			AND similarity(normalize_card_text(a.question), normalize_card_text(b.question)) >= $2
+		ORDER BY a.id, b.id`
+	rows, err := r.db.Pool.Query(ctx, query, deckID, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []DuplicatePair
+	for rows.Next() {
+		var p DuplicatePair
+		if err := rows.Scan(&p.A, &p.B, &p.Similarity, &p.Exact); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// MergeDuplicates перемещает дубликаты dupIDs в корзину одной транзакцией, передав их теги
+// и прогресс (если у keptID его нет) карточке keptID.
+func (r *CardRepository) MergeDuplicates(ctx context.Context, keptID int, dupIDs []int) error {
+	kept := make([]int, len(dupIDs))
+	for i := range kept {
+		kept[i] = keptID
+	}
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		return mergeDuplicateCards(ctx, tx.(pgx.Tx), dupIDs, kept)
+	})
+}
+
 func (r *CardRepository) SetCardTags(ctx context.Context, cardID int, tagIDs []int) error {
 	_, err := r.db.Pool.Exec(ctx, `DELETE FROM card_tags WHERE card_id = $1`, cardID)
 	if err != nil {
@@ -153,10 +629,54 @@ func (r *CardRepository) scanCards(rows pgx.Rows) ([]domain.Card, error) {
 	var list []domain.Card
 	for rows.Next() {
 		var c domain.Card
-		if err := rows.Scan(&c.ID, &c.DeckID, &c.Question, &c.Answer, &c.CategoryID, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := scanCard(rows, &c); err != nil {
 			return nil, err
 		}
 		list = append(list, c)
 	}
 	return list, rows.Err()
 }
+
+// copyCardTags загружает связи card_tags через COPY (дубликаты тегов отбрасываются).
+func copyCardTags(ctx context.Context, t pgx.Tx, cards []domain.Card, tagIDs [][]int) error {
+	var rows [][]interface{}
+	for i := range cards {
+		seen := make(map[int]bool, len(tagIDs[i]))
+		for _, tagID := range tagIDs[i] {
+			if seen[tagID] {
+				continue
+			}
+			seen[tagID] = true
+			rows = append(rows, []interface{}{cards[i].ID, tagID})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := t.CopyFrom(ctx, pgx.Identifier{"card_tags"}, []string{"card_id", "tag_id"}, pgx.CopyFromRows(rows))
+	return err
+}
+
+// scanCard читает строку с колонками cardColumns; extra — дополнительные колонки после них.
+func scanCard(row pgx.Row, c *domain.Card, extra ...interface{}) error {
+	dest := []interface{}{&c.ID, &c.DeckID, &c.Question, &c.Answer, &c.CategoryID, &c.OriginCardID, &c.CreatedAt, &c.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// mergeDuplicateCards переносит теги и прогресс дубликата dups[i] на карточку kept[i]
+// (прогресс оставшейся карточки не перезаписывается) и перемещает дубликаты в корзину.
+func mergeDuplicateCards(ctx context.Context, t pgx.Tx, dups, kept []int) error {
+	if _, err := t.Exec(ctx, `INSERT INTO card_progress (user_id, card_id, ease_factor, interval_days, repetitions, due_at, last_reviewed_at)
+		SELECT p.user_id, m.kept, p.ease_factor, p.interval_days, p.repetitions, p.due_at, p.last_reviewed_at
+		FROM unnest($1::int[], $2::int[]) AS m(dup, kept) INNER JOIN card_progress p ON p.card_id = m.dup
+		ON CONFLICT (user_id, card_id) DO NOTHING`, dups, kept); err != nil {
+		return err
+	}
+	if _, err := t.Exec(ctx, `INSERT INTO card_tags (card_id, tag_id)
+		SELECT m.kept, ct.tag_id FROM unnest($1::int[], $2::int[]) AS m(dup, kept) INNER JOIN card_tags ct ON ct.card_id = m.dup
+		ON CONFLICT DO NOTHING`, dups, kept); err != nil {
+		return err
+	}
+	_, err := t.Exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL`, dups)
+	return err
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 )
 
+// categoryColumns — колонки categories в порядке scanCategory.
+const categoryColumns = `id, name, slug, parent_id, sort_order, icon, created_at`
+
 type CategoryRepository struct {
 	db *DB
 }
@@ -16,27 +19,33 @@ func NewCategoryRepository(db *DB) *CategoryRepository {
 }
 
 func (r *CategoryRepository) Create(ctx context.Context, c *domain.Category) error {
-	query := `INSERT INTO categories (name) VALUES ($1) RETURNING id, created_at`
-	return r.db.Pool.QueryRow(ctx, query, c.Name).Scan(&c.ID, &c.CreatedAt)
+	query := `INSERT INTO categories (name, slug, parent_id, sort_order, icon) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	return r.db.Pool.QueryRow(ctx, query, c.Name, c.Slug, c.ParentID, c.SortOrder, c.Icon).Scan(&c.ID, &c.CreatedAt)
 }
 
 func (r *CategoryRepository) GetByID(ctx context.Context, id int) (*domain.Category, error) {
-	query := `SELECT id, name, created_at FROM categories WHERE id = $1`
-	var c domain.Category
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(&c.ID, &c.Name, &c.CreatedAt)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &c, nil
+	return r.getOne(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id)
+}
+
+// GetByName ищет категорию с именем name среди дочерних категорий parentID (nil — верхний уровень).
+func (r *CategoryRepository) GetByName(ctx context.Context, parentID *int, name string) (*domain.Category, error) {
+	return r.getOne(ctx, `SELECT `+categoryColumns+` FROM categories WHERE parent_id IS NOT DISTINCT FROM $1 AND name = $2`, parentID, name)
+}
+
+// FindByName ищет категорию по названию без учёта регистра во всём дереве. Если таких несколько,
+// предпочитается точное совпадение, затем категория ближе к верхнему уровню.
+func (r *CategoryRepository) FindByName(ctx context.Context, name string) (*domain.Category, error) {
+	return r.getOne(ctx, `SELECT `+categoryColumns+` FROM categories WHERE lower(name) = lower($1)
+		ORDER BY name = $1 DESC, parent_id IS NOT NULL, id LIMIT 1`, name)
+}
+
+func (r *CategoryRepository) GetBySlug(ctx context.Context, slug string) (*domain.Category, error) {
+	return r.getOne(ctx, `SELECT `+categoryColumns+` FROM categories WHERE slug = $1`, slug)
 }
 
-func (r *CategoryRepository) GetByName(ctx context.Context, name string) (*domain.Category, error) {
-	query := `SELECT id, name, created_at FROM categories WHERE name = $1`
+func (r *CategoryRepository) getOne(ctx context.Context, query string, args ...interface{}) (*domain.Category, error) {
 	var c domain.Category
-	err := r.db.Pool.QueryRow(ctx, query, name).Scan(&c.ID, &c.Name, &c.CreatedAt)
+	err := scanCategory(r.db.Pool.QueryRow(ctx, query, args...), &c)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -46,8 +55,9 @@ func (r *CategoryRepository) GetByName(ctx context.Context, name string) (*domai
 	return &c, nil
 }
 
+// List возвращает все категории в порядке sort_order, затем по имени.
 func (r *CategoryRepository) List(ctx context.Context) ([]domain.Category, error) {
-	rows, err := r.db.Pool.Query(ctx, `SELECT id, name, created_at FROM categories ORDER BY name`)
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY sort_order, name`)
 	if err != nil {
 		return nil, err
 	}
@@ -55,10 +65,80 @@ func (r *CategoryRepository) List(ctx context.Context) ([]domain.Category, error
 	var list []domain.Category
 	for rows.Next() {
 		var c domain.Category
-		if err := rows.Scan(&c.ID, &c.Name, &c.CreatedAt); err != nil {
+		if err := scanCategory(rows, &c); err != nil {
 			return nil, err
 		}
 		list = append(list, c)
 	}
 	return list, rows.Err()
 }
+
+// Update сохраняет имя, slug, родителя, порядок и иконку категории.
+func (r *CategoryRepository) Update(ctx context.Context, c *domain.Category) error {
+	_, err := r.db.Pool.Exec(ctx, `UPDATE categories SET name = $2, slug = $3, parent_id = $4, sort_order = $5, icon = $6 WHERE id = $1`,
+		c.ID, c.Name, c.Slug, c.ParentID, c.SortOrder, c.Icon)
+	return err
+}
+
+// Move сохраняет родителя и порядок категории c. Категория и цепочка предков нового родителя
+// блокируются до конца транзакции (см. lockTreeMove); false — родитель c сама или её подкатегория.
+func (r *CategoryRepository) Move(ctx context.Context, c *domain.Category) (bool, error) {
+	ok := true
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if c.ParentID != nil {
+			var err error
+			if ok, err = lockTreeMove(ctx, t, "categories", c.ID, *c.ParentID); err != nil || !ok {
+				return err
+			}
+		}
+		_, err := t.Exec(ctx, `UPDATE categories SET parent_id = $2, sort_order = $3 WHERE id = $1`, c.ID, c.ParentID, c.SortOrder)
+		return err
+	})
+	return ok, err
+}
+
+// CategoryReassign — результат удаления категории с переносом ссылок на неё.
+type CategoryReassign struct {
+	Decks    int
+	Cards    int
+	Children int
+}
+
+// Delete удаляет категорию одной транзакцией: подкатегории переходят к parentID,
+// наборы и карточки (в том числе в корзине) — в категорию reassignTo (nil — без категории).
+// Базовая категория форков (origin_category_id) заменяется так же, чтобы замена не выглядела локальной правкой.
+func (r *CategoryRepository) Delete(ctx context.Context, id int, parentID, reassignTo *int) (CategoryReassign, error) {
+	var res CategoryReassign
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		tag, err := t.Exec(ctx, `UPDATE categories SET parent_id = $2 WHERE parent_id = $1`, id, parentID)
+		if err != nil {
+			return err
+		}
+		res.Children = int(tag.RowsAffected())
+		tag, err = t.Exec(ctx, `UPDATE decks SET category_id = $2 WHERE category_id = $1`, id, reassignTo)
+		if err != nil {
+			return err
+		}
+		res.Decks = int(tag.RowsAffected())
+		tag, err = t.Exec(ctx, `UPDATE cards SET category_id = $2,
+				origin_category_id = CASE WHEN origin_category_id = $1 THEN $2 ELSE origin_category_id END
+			WHERE category_id = $1`, id, reassignTo)
+		if err != nil {
+			return err
+		}
+		res.Cards = int(tag.RowsAffected())
+		if _, err := t.Exec(ctx, `UPDATE cards SET origin_category_id = $2 WHERE origin_category_id = $1`, id, reassignTo); err != nil {
+			return err
+		}
+		_, err = t.Exec(ctx, `DELETE FROM categories WHERE id = $1`, id)
+		return err
+	})
+	return res, err
+}
+
+// scanCategory читает строку с колонками categoryColumns.
+func scanCategory(row pgx.Row, c *domain.Category) error {
+	return row.Scan(&c.ID, &c.Name, &c.Slug, &c.ParentID, &c.SortOrder, &c.Icon, &c.CreatedAt)
+}
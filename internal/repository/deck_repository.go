@@ -8,6 +8,18 @@ import (
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 )
 
+// deckColumns — колонки decks в порядке scanDeck.
+const deckColumns = `id, user_id, title, description, category_id, is_public, version, forked_from_deck_id, forked_from_version, parent_id, created_at, updated_at`
+
+// deckSubtree возвращает тело рекурсивного CTE tree(id): набор с ID из параметра arg
+// и все вложенные в него неудалённые наборы. UNION отбрасывает уже пройденные наборы,
+// поэтому обход завершается и при цикле в parent_id.
+func deckSubtree(arg string) string {
+	return `SELECT id FROM decks WHERE id = ` + arg + `
+	UNION
+	SELECT d.id FROM decks d INNER JOIN tree ON d.parent_id = tree.id WHERE d.deleted_at IS NULL`
+}
+
 type DeckRepository struct {
 	db *DB
 }
@@ -17,21 +29,17 @@ func NewDeckRepository(db *DB) *DeckRepository {
 }
 
 func (r *DeckRepository) Create(ctx context.Context, d *domain.Deck) error {
-	query := `INSERT INTO decks (user_id, title, description, category_id, is_public)
-		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
-	return r.db.Pool.QueryRow(ctx, query,
-		d.UserID, d.Title, d.Description, d.CategoryID, d.IsPublic,
-	).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+	query := `INSERT INTO decks (user_id, title, description, category_id, is_public, parent_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, version, created_at, updated_at`
+	return r.db.queryRow(ctx, query,
+		d.UserID, d.Title, d.Description, d.CategoryID, d.IsPublic, d.ParentID,
+	).Scan(&d.ID, &d.Version, &d.CreatedAt, &d.UpdatedAt)
 }
 
 func (r *DeckRepository) GetByID(ctx context.Context, id int) (*domain.Deck, error) {
-	query := `SELECT id, user_id, title, description, category_id, is_public, created_at, updated_at
-		FROM decks WHERE id = $1`
+	query := `SELECT ` + deckColumns + ` FROM decks WHERE id = $1 AND deleted_at IS NULL`
 	var d domain.Deck
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&d.ID, &d.UserID, &d.Title, &d.Description, &d.CategoryID, &d.IsPublic,
-		&d.CreatedAt, &d.UpdatedAt,
-	)
+	err := scanDeck(r.db.Pool.QueryRow(ctx, query, id), &d)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -42,8 +50,7 @@ func (r *DeckRepository) GetByID(ctx context.Context, id int) (*domain.Deck, err
 }
 
 func (r *DeckRepository) ListByUserID(ctx context.Context, userID int) ([]domain.Deck, error) {
-	query := `SELECT id, user_id, title, description, category_id, is_public, created_at, updated_at
-		FROM decks WHERE user_id = $1 ORDER BY updated_at DESC`
+	query := `SELECT ` + deckColumns + ` FROM decks WHERE user_id = $1 AND deleted_at IS NULL ORDER BY updated_at DESC`
 	rows, err := r.db.Pool.Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -52,9 +59,10 @@ func (r *DeckRepository) ListByUserID(ctx context.Context, userID int) ([]domain
 	return r.scanDecks(rows)
 }
 
-// ListByUserIDWithFilters возвращает наборы с пагинацией и опционально category_id, search.
-func (r *DeckRepository) ListByUserIDWithFilters(ctx context.Context, userID int, page, limit int, categoryID *int, search string) ([]domain.Deck, int, error) {
-	baseCond := ` WHERE user_id = $1`
+// ListByUserIDWithFilters возвращает наборы с пагинацией и опционально category_id, tag_id (с дочерними тегами), search.
+// С search наборы ищутся полнотекстовым поиском, сортируются по рангу и получают Match.
+func (r *DeckRepository) ListByUserIDWithFilters(ctx context.Context, userID int, page, limit int, categoryID, tagID *int, search string) ([]domain.Deck, int, error) {
+	baseCond := ` WHERE user_id = $1 AND deleted_at IS NULL`
 	args := []interface{}{userID}
 	pos := 2
 	if categoryID != nil {
@@ -62,11 +70,20 @@ func (r *DeckRepository) ListByUserIDWithFilters(ctx context.Context, userID int
 		args = append(args, *categoryID)
 		pos++
 	}
-	if search != "" {
-		baseCond += ` AND (title ILIKE $` + strconv.Itoa(pos) + ` OR description ILIKE $` + strconv.Itoa(pos) + `)`
-		args = append(args, "%"+search+"%")
+	if tagID != nil {
+		baseCond += ` AND EXISTS (SELECT 1 FROM deck_tags dt WHERE dt.deck_id = decks.id AND dt.tag_id IN (` + tagSubtreeIDs("$"+strconv.Itoa(pos), "$1") + `))`
+		args = append(args, *tagID)
 		pos++
 	}
+	selectCols, orderBy := deckColumns, ` ORDER BY updated_at DESC`
+	if search != "" {
+		q, pattern := "$"+strconv.Itoa(pos), "$"+strconv.Itoa(pos+1)
+		baseCond += searchCond(`search_vector`, q, pattern, `title`, `description`)
+		selectCols += searchColumns(`search_vector`, q, `title`, `description`)
+		orderBy = ` ORDER BY search_rank DESC, updated_at DESC`
+		args = append(args, search, "%"+search+"%")
+		pos += 2
+	}
 	var total int
 	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM decks`+baseCond, args...).Scan(&total); err != nil {
 		return nil, 0, err
@@ -80,20 +97,31 @@ func (r *DeckRepository) ListByUserIDWithFilters(ctx context.Context, userID int
 	offset := (page - 1) * limit
 	listArgs := append([]interface{}{}, args...)
 	listArgs = append(listArgs, limit, offset)
-	listQuery := `SELECT id, user_id, title, description, category_id, is_public, created_at, updated_at
-		FROM decks` + baseCond + ` ORDER BY updated_at DESC LIMIT $` + strconv.Itoa(pos) + ` OFFSET $` + strconv.Itoa(pos+1)
+	listQuery := `SELECT ` + selectCols + ` FROM decks` + baseCond + orderBy + ` LIMIT $` + strconv.Itoa(pos) + ` OFFSET $` + strconv.Itoa(pos+1)
 	rows, err := r.db.Pool.Query(ctx, listQuery, listArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
-	list, err := r.scanDecks(rows)
-	return list, total, err
+	if search == "" {
+		list, err := r.scanDecks(rows)
+		return list, total, err
+	}
+	var list []domain.Deck
+	for rows.Next() {
+		var d domain.Deck
+		hit := newSearchHit(2)
+		if err := scanDeck(rows, &d, hit.dest()...); err != nil {
+			return nil, 0, err
+		}
+		d.Match = hit.match("title", "description")
+		list = append(list, d)
+	}
+	return list, total, rows.Err()
 }
 
 func (r *DeckRepository) ListPublic(ctx context.Context, limit, offset int) ([]domain.Deck, error) {
-	query := `SELECT id, user_id, title, description, category_id, is_public, created_at, updated_at
-		FROM decks WHERE is_public = true ORDER BY updated_at DESC LIMIT $1 OFFSET $2`
+	query := `SELECT ` + deckColumns + ` FROM decks WHERE is_public = true AND deleted_at IS NULL ORDER BY updated_at DESC LIMIT $1 OFFSET $2`
 	rows, err := r.db.Pool.Query(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
@@ -103,9 +131,10 @@ func (r *DeckRepository) ListPublic(ctx context.Context, limit, offset int) ([]d
 }
 
 // ListPublicWithFilters — публичные наборы с пагинацией, фильтрами и сортировкой.
-// sortBy: recent (updated_at DESC), popular (cards_count DESC), cards_count (cards_count DESC).
-func (r *DeckRepository) ListPublicWithFilters(ctx context.Context, page, limit int, categoryID *int, search string, sortBy string) ([]domain.Deck, int, error) {
-	baseCond := ` WHERE d.is_public = true`
+// sortBy: recent (updated_at DESC), popular (cards_count DESC), cards_count (cards_count DESC), forks (forks_count DESC),
+// relevance (ранг поиска; по умолчанию, если задан search, иначе recent).
+func (r *DeckRepository) ListPublicWithFilters(ctx context.Context, page, limit int, categoryID, tagID *int, search string, sortBy string) ([]domain.Deck, int, error) {
+	baseCond := ` WHERE d.is_public = true AND d.deleted_at IS NULL`
 	args := []interface{}{}
 	pos := 1
 	if categoryID != nil {
@@ -113,12 +142,21 @@ func (r *DeckRepository) ListPublicWithFilters(ctx context.Context, page, limit
 		args = append(args, *categoryID)
 		pos++
 	}
-	if search != "" {
-		baseCond += ` AND (d.title ILIKE $` + strconv.Itoa(pos) + ` OR d.description ILIKE $` + strconv.Itoa(pos) + `)`
-		args = append(args, "%"+search+"%")
+	if tagID != nil {
+		baseCond += ` AND EXISTS (SELECT 1 FROM deck_tags dt WHERE dt.deck_id = d.id AND dt.tag_id IN (` + tagSubtreeIDs("$"+strconv.Itoa(pos), "NULL") + `))`
+		args = append(args, *tagID)
 		pos++
 	}
-	fromClause := ` FROM decks d LEFT JOIN (SELECT deck_id, COUNT(*) AS cnt FROM cards GROUP BY deck_id) c ON d.id = c.deck_id` + baseCond
+	searchCols := ``
+	if search != "" {
+		q, pattern := "$"+strconv.Itoa(pos), "$"+strconv.Itoa(pos+1)
+		baseCond += searchCond(`d.search_vector`, q, pattern, `d.title`, `d.description`)
+		searchCols = searchColumns(`d.search_vector`, q, `d.title`, `d.description`)
+		args = append(args, search, "%"+search+"%")
+		pos += 2
+	}
+	fromClause := ` FROM decks d LEFT JOIN (SELECT deck_id, COUNT(*) AS cnt FROM cards WHERE deleted_at IS NULL GROUP BY deck_id) c ON d.id = c.deck_id
+		LEFT JOIN (SELECT forked_from_deck_id AS src_id, COUNT(*) AS cnt FROM decks WHERE forked_from_deck_id IS NOT NULL AND deleted_at IS NULL GROUP BY forked_from_deck_id) f ON d.id = f.src_id` + baseCond
 	var total int
 	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM decks d`+baseCond, args...).Scan(&total); err != nil {
 		return nil, 0, err
@@ -136,14 +174,21 @@ func (r *DeckRepository) ListPublicWithFilters(ctx context.Context, page, limit
 	switch sortBy {
 	case "popular", "cards_count":
 		orderBy = ` ORDER BY COALESCE(c.cnt, 0) DESC, d.updated_at DESC`
+	case "forks":
+		orderBy = ` ORDER BY COALESCE(f.cnt, 0) DESC, d.updated_at DESC`
 	case "recent":
 		orderBy = ` ORDER BY d.updated_at DESC`
+	case "", "relevance":
+		if search != "" {
+			orderBy = ` ORDER BY search_rank DESC, d.updated_at DESC`
+		}
 	}
 	offset := (page - 1) * limit
 	listArgs := append([]interface{}{}, args...)
 	listArgs = append(listArgs, limit, offset)
-	// возвращаем deck + cards_count из join
-	listQuery := `SELECT d.id, d.user_id, d.title, d.description, d.category_id, d.is_public, d.created_at, d.updated_at, COALESCE(c.cnt, 0)::int
+	// возвращаем deck + cards_count и forks_count из join
+	listQuery := `SELECT d.id, d.user_id, d.title, d.description, d.category_id, d.is_public, d.version, d.forked_from_deck_id, d.forked_from_version, d.parent_id,
+		d.created_at, d.updated_at, COALESCE(c.cnt, 0)::int, COALESCE(f.cnt, 0)::int` + searchCols + `
 		` + fromClause + orderBy + ` LIMIT $` + strconv.Itoa(pos) + ` OFFSET $` + strconv.Itoa(pos+1)
 	rows, err := r.db.Pool.Query(ctx, listQuery, listArgs...)
 	if err != nil {
@@ -153,27 +198,449 @@ func (r *DeckRepository) ListPublicWithFilters(ctx context.Context, page, limit
 	var list []domain.Deck
 	for rows.Next() {
 		var d domain.Deck
-		var cnt int
-		if err := rows.Scan(&d.ID, &d.UserID, &d.Title, &d.Description, &d.CategoryID, &d.IsPublic, &d.CreatedAt, &d.UpdatedAt, &cnt); err != nil {
+		dest := []interface{}{&d.CardsCount, &d.ForksCount}
+		var hit *searchHit
+		if search != "" {
+			hit = newSearchHit(2)
+			dest = append(dest, hit.dest()...)
+		}
+		if err := scanDeck(rows, &d, dest...); err != nil {
 			return nil, 0, err
 		}
-		d.CardsCount = cnt
+		if hit != nil {
+			d.Match = hit.match("title", "description")
+		}
 		list = append(list, d)
 	}
 	return list, total, rows.Err()
 }
 
+// Update сохраняет поля набора; версия растёт, только если они действительно изменились.
 func (r *DeckRepository) Update(ctx context.Context, d *domain.Deck) error {
-	query := `UPDATE decks SET title=$2, description=$3, category_id=$4, is_public=$5, updated_at=NOW()
-		WHERE id=$1 RETURNING updated_at`
-	return r.db.Pool.QueryRow(ctx, query, d.ID, d.Title, d.Description, d.CategoryID, d.IsPublic).Scan(&d.UpdatedAt)
+	query := `UPDATE decks SET title=$2, description=$3, category_id=$4, is_public=$5,
+			version = CASE WHEN (title, description, category_id, is_public) IS DISTINCT FROM ($2, $3, $4, $5)
+				THEN version + 1 ELSE version END,
+			updated_at=NOW()
+		WHERE id=$1 RETURNING version, updated_at`
+	return r.db.queryRow(ctx, query, d.ID, d.Title, d.Description, d.CategoryID, d.IsPublic).Scan(&d.Version, &d.UpdatedAt)
 }
 
+// Delete перемещает набор в корзину вместе с карточками и вложенными наборами;
+// окончательно его стирает TrashRepository.
 func (r *DeckRepository) Delete(ctx context.Context, id int) error {
-	_, err := r.db.Pool.Exec(ctx, `DELETE FROM decks WHERE id = $1`, id)
+	_, err := r.db.exec(ctx, `WITH RECURSIVE tree AS (`+deckSubtree("$1")+`)
+		UPDATE decks SET deleted_at = NOW() WHERE id IN (SELECT id FROM tree) AND deleted_at IS NULL`, id)
+	return err
+}
+
+// Purge окончательно удаляет наборы пользователя ids, минуя корзину; карточки, прогресс
+// и вложенные наборы удаляются каскадом.
+func (r *DeckRepository) Purge(ctx context.Context, userID int, ids []int) error {
+	_, err := r.db.exec(ctx, `DELETE FROM decks WHERE user_id = $1 AND id = ANY($2)`, userID, ids)
 	return err
 }
 
+// SetParent делает набор вложенным в parentID (nil — набор верхнего уровня).
+// Возвращает false, если parentID — сам набор или один из вложенных в него наборов.
+// Набор и цепочка предков parentID блокируются до конца транзакции (см. lockTreeMove).
+func (r *DeckRepository) SetParent(ctx context.Context, id int, parentID *int) (bool, error) {
+	ok := true
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if parentID != nil {
+			var err error
+			if ok, err = lockTreeMove(ctx, t, "decks", id, *parentID); err != nil || !ok {
+				return err
+			}
+		}
+		tag, err := t.Exec(ctx, `UPDATE decks SET parent_id = $2, updated_at = NOW() WHERE id = $1`, id, parentID)
+		if err != nil {
+			return err
+		}
+		ok = tag.RowsAffected() > 0
+		return nil
+	})
+	return ok, err
+}
+
+// ListSubtree возвращает набор id и все вложенные в него наборы; родительские наборы идут раньше дочерних.
+func (r *DeckRepository) ListSubtree(ctx context.Context, id int) ([]domain.Deck, error) {
+	rows, err := r.db.Pool.Query(ctx, `WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth, ARRAY[id] AS path FROM decks WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT d.id, tree.depth + 1, tree.path || d.id FROM decks d INNER JOIN tree ON d.parent_id = tree.id
+			WHERE d.deleted_at IS NULL AND d.id <> ALL(tree.path))
+		SELECT `+deckColumns+` FROM decks INNER JOIN tree USING (id) ORDER BY tree.depth, title, id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return r.scanDecks(rows)
+}
+
+// FindChild возвращает набор пользователя с названием title внутри parentID (nil — верхний уровень).
+func (r *DeckRepository) FindChild(ctx context.Context, userID int, parentID *int, title string) (*domain.Deck, error) {
+	query := `SELECT ` + deckColumns + ` FROM decks
+		WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND title = $3 AND deleted_at IS NULL
+		ORDER BY id LIMIT 1`
+	var d domain.Deck
+	err := scanDeck(r.db.Pool.QueryRow(ctx, query, userID, parentID, title), &d)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CountTreeCards возвращает количество карточек набора вместе со всеми вложенными наборами.
+func (r *DeckRepository) CountTreeCards(ctx context.Context, id int) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `WITH RECURSIVE tree AS (`+deckSubtree("$1")+`)
+		SELECT COUNT(*) FROM cards c INNER JOIN tree ON tree.id = c.deck_id WHERE c.deleted_at IS NULL`, id).Scan(&n)
+	return n, err
+}
+
+// CountCardsWithSubtrees возвращает для каждого набора из ids количество его карточек (own)
+// и карточек вместе со всеми вложенными наборами (total) одним запросом.
+func (r *DeckRepository) CountCardsWithSubtrees(ctx context.Context, ids []int) (own, total map[int]int, err error) {
+	rows, err := r.db.Pool.Query(ctx, `WITH RECURSIVE tree AS (
+			SELECT id AS root, id FROM decks WHERE id = ANY($1)
+			UNION
+			SELECT tree.root, d.id FROM decks d INNER JOIN tree ON d.parent_id = tree.id WHERE d.deleted_at IS NULL)
+		SELECT tree.root, COUNT(c.id) FILTER (WHERE c.deck_id = tree.root), COUNT(c.id)
+		FROM tree LEFT JOIN cards c ON c.deck_id = tree.id AND c.deleted_at IS NULL
+		GROUP BY tree.root`, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	own, total = make(map[int]int, len(ids)), make(map[int]int, len(ids))
+	for rows.Next() {
+		var id, o, t int
+		if err := rows.Scan(&id, &o, &t); err != nil {
+			return nil, nil, err
+		}
+		own[id], total[id] = o, t
+	}
+	return own, total, rows.Err()
+}
+
+// CountCardsByUserID возвращает количество карточек в каждом наборе пользователя (без вложенных).
+func (r *DeckRepository) CountCardsByUserID(ctx context.Context, userID int) (map[int]int, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT c.deck_id, COUNT(*) FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL AND c.deleted_at IS NULL GROUP BY c.deck_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[int]int)
+	for rows.Next() {
+		var deckID, n int
+		if err := rows.Scan(&deckID, &n); err != nil {
+			return nil, err
+		}
+		counts[deckID] = n
+	}
+	return counts, rows.Err()
+}
+
+// Fork копирует набор srcID со всеми карточками и тегами в аккаунт userID одной транзакцией.
+// Копия приватна, хранит ID и версию исходного набора, а карточки — ID исходных карточек.
+func (r *DeckRepository) Fork(ctx context.Context, srcID int, userID int) (*domain.Deck, error) {
+	var d domain.Deck
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		var srcVersion int
+		if err := t.QueryRow(ctx, `SELECT version FROM decks WHERE id = $1 FOR SHARE`, srcID).Scan(&srcVersion); err != nil {
+			return err
+		}
+		query := `INSERT INTO decks (user_id, title, description, category_id, is_public, forked_from_deck_id, forked_from_version)
+			SELECT $2, title, description, category_id, false, id, $3 FROM decks WHERE id = $1
+			RETURNING ` + deckColumns
+		if err := scanDeck(t.QueryRow(ctx, query, srcID, userID, srcVersion), &d); err != nil {
+			return err
+		}
+		// Чужие личные теги в форк не переносятся: только общие и собственные теги владельца форка.
+		if _, err := t.Exec(ctx, `INSERT INTO deck_tags (deck_id, tag_id)
+			SELECT $2, dt.tag_id FROM deck_tags dt INNER JOIN tags tg ON tg.id = dt.tag_id
+			WHERE dt.deck_id = $1 AND (tg.user_id IS NULL OR tg.user_id = $3)`, srcID, d.ID, userID); err != nil {
+			return err
+		}
+		if _, err := t.Exec(ctx, `INSERT INTO cards (deck_id, question, answer, category_id, origin_card_id, origin_question, origin_answer, origin_category_id)
+			SELECT $2, question, answer, category_id, id, question, answer, category_id FROM cards WHERE deck_id = $1 AND deleted_at IS NULL ORDER BY created_at, id`, srcID, d.ID); err != nil {
+			return err
+		}
+		_, err := t.Exec(ctx, `INSERT INTO card_tags (card_id, tag_id)
+			SELECT c.id, ct.tag_id FROM cards c INNER JOIN card_tags ct ON ct.card_id = c.origin_card_id
+			INNER JOIN tags tg ON tg.id = ct.tag_id
+			WHERE c.deck_id = $1 AND (tg.user_id IS NULL OR tg.user_id = $2)`, d.ID, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CountForks возвращает количество форков набора.
+func (r *DeckRepository) CountForks(ctx context.Context, deckID int) (int, error) {
+	var n int
+	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM decks WHERE forked_from_deck_id = $1 AND deleted_at IS NULL`, deckID).Scan(&n)
+	return n, err
+}
+
+// UpstreamChanges — изменения исходного набора, которые применяются к форку.
+type UpstreamChanges struct {
+	Add    []int // ID исходных карточек, которые копируются в форк
+	Update []int // ID карточек форка, содержимое которых заменяется исходным
+	Rebase []int // ID карточек форка, база которых сдвигается на исходную без изменения содержимого
+	Delete []int // ID карточек форка, удалённых из исходного набора
+	Detach []int // ID карточек форка, которые становятся собственными карточками форка
+}
+
+// UpstreamState — всё, что нужно для сравнения форка с исходным набором.
+type UpstreamState struct {
+	ForkedFromVersion *int
+	UpstreamVersion   int
+	Mine              []domain.ForkCard // карточки форка, связанные с исходными, с базой синхронизации
+	Theirs            []domain.Card     // карточки исходного набора
+	Dismissed         map[int]bool      // исходные карточки, которые пользователь убрал из форка
+}
+
+// UpstreamState читает форк deckID и исходный набор upstreamID для сравнения.
+func (r *DeckRepository) UpstreamState(ctx context.Context, deckID, upstreamID int) (*UpstreamState, error) {
+	var st *UpstreamState
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		var err error
+		st, err = readUpstreamState(ctx, tx.(pgx.Tx), deckID, upstreamID, "")
+		return err
+	})
+	return st, err
+}
+
+// readUpstreamState читает состояние форка и исходного набора в транзакции t; lock
+// (например FOR UPDATE) блокирует строку форка, исходный набор блокируется на чтение.
+func readUpstreamState(ctx context.Context, t pgx.Tx, deckID, upstreamID int, lock string) (*UpstreamState, error) {
+	st := &UpstreamState{Dismissed: make(map[int]bool)}
+	upstreamLock := ""
+	if lock != "" {
+		upstreamLock = " FOR SHARE"
+	}
+	if err := t.QueryRow(ctx, `SELECT forked_from_version FROM decks WHERE id = $1 `+lock, deckID).Scan(&st.ForkedFromVersion); err != nil {
+		return nil, err
+	}
+	if err := t.QueryRow(ctx, `SELECT version FROM decks WHERE id = $1`+upstreamLock, upstreamID).Scan(&st.UpstreamVersion); err != nil {
+		return nil, err
+	}
+	rows, err := t.Query(ctx, `SELECT `+cardColumns+`, COALESCE(origin_question, ''), COALESCE(origin_answer, ''), origin_category_id
+		FROM cards WHERE deck_id = $1 AND origin_card_id IS NOT NULL AND deleted_at IS NULL ORDER BY created_at, id`, deckID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var fc domain.ForkCard
+		if err := scanCard(rows, &fc.Card, &fc.Base.Question, &fc.Base.Answer, &fc.Base.CategoryID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		st.Mine = append(st.Mine, fc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = t.Query(ctx, `SELECT `+cardColumns+` FROM cards WHERE deck_id = $1 AND deleted_at IS NULL ORDER BY created_at`, upstreamID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var c domain.Card
+		if err := scanCard(rows, &c); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		st.Theirs = append(st.Theirs, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = t.Query(ctx, `SELECT origin_card_id FROM fork_dismissed_cards WHERE deck_id = $1`, deckID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		st.Dismissed[id] = true
+	}
+	return st, rows.Err()
+}
+
+// ApplyUpstream применяет изменения исходного набора upstreamID к форку deckID одной транзакцией
+// и запоминает версию исходного набора как версию последней синхронизации. Изменения выбирает
+// plan по состоянию, прочитанному в той же транзакции: форк заблокирован, исходный набор не
+// меняется до её конца, поэтому план не расходится с данными.
+// ID обновляемых карточек не меняются, поэтому состояние повторений сохраняется.
+func (r *DeckRepository) ApplyUpstream(ctx context.Context, deckID, upstreamID int, plan func(st *UpstreamState) UpstreamChanges) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		st, err := readUpstreamState(ctx, t, deckID, upstreamID, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		ch, upstreamVersion := plan(st), st.UpstreamVersion
+		if len(ch.Add) > 0 {
+			if _, err := t.Exec(ctx, `INSERT INTO cards (deck_id, question, answer, category_id, origin_card_id, origin_question, origin_answer, origin_category_id)
+				SELECT $2, question, answer, category_id, id, question, answer, category_id FROM cards WHERE id = ANY($1) ORDER BY created_at, id`,
+				ch.Add, deckID); err != nil {
+				return err
+			}
+			if _, err := t.Exec(ctx, `INSERT INTO card_tags (card_id, tag_id)
+				SELECT c.id, ct.tag_id FROM cards c INNER JOIN card_tags ct ON ct.card_id = c.origin_card_id
+				INNER JOIN tags tg ON tg.id = ct.tag_id INNER JOIN decks d ON d.id = c.deck_id
+				WHERE c.deck_id = $1 AND c.origin_card_id = ANY($2) AND (tg.user_id IS NULL OR tg.user_id = d.user_id)
+				ON CONFLICT DO NOTHING`, deckID, ch.Add); err != nil {
+				return err
+			}
+		}
+		if len(ch.Update) > 0 {
+			if _, err := t.Exec(ctx, `UPDATE cards f SET question = o.question, answer = o.answer, category_id = o.category_id,
+				origin_question = o.question, origin_answer = o.answer, origin_category_id = o.category_id, updated_at = NOW()
+				FROM cards o WHERE o.id = f.origin_card_id AND f.deck_id = $1 AND f.id = ANY($2)`, deckID, ch.Update); err != nil {
+				return err
+			}
+		}
+		if len(ch.Rebase) > 0 {
+			if _, err := t.Exec(ctx, `UPDATE cards f SET origin_question = o.question, origin_answer = o.answer, origin_category_id = o.category_id
+				FROM cards o WHERE o.id = f.origin_card_id AND f.deck_id = $1 AND f.id = ANY($2)`, deckID, ch.Rebase); err != nil {
+				return err
+			}
+		}
+		if len(ch.Delete) > 0 {
+			if _, err := t.Exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE deck_id = $1 AND id = ANY($2) AND deleted_at IS NULL`, deckID, ch.Delete); err != nil {
+				return err
+			}
+		}
+		if len(ch.Detach) > 0 {
+			if _, err := t.Exec(ctx, `UPDATE cards SET origin_card_id = NULL, origin_question = NULL, origin_answer = NULL, origin_category_id = NULL
+				WHERE deck_id = $1 AND id = ANY($2)`, deckID, ch.Detach); err != nil {
+				return err
+			}
+		}
+		_, err = t.Exec(ctx, `UPDATE decks SET forked_from_version = $2 WHERE id = $1`, deckID, upstreamVersion)
+		return err
+	})
+}
+
+// MergeChanges — план объединения наборов, подготовленный DeckService.
+type MergeChanges struct {
+	Move          []int       // ID карточек, которые переносятся в целевой набор
+	Duplicates    map[int]int // ID дубликата → ID карточки, которая остаётся вместо него
+	DeleteSources bool        // переместить опустевшие исходные наборы в корзину
+}
+
+// Merge объединяет наборы sourceIDs с набором targetID одной транзакцией и возвращает
+// ID тегов, добавленных целевому набору. Перенесённые карточки сохраняют ID и прогресс;
+// дубликаты уходят в корзину, а их теги и прогресс (если у оставшейся карточки его нет)
+// переходят к оставшейся карточке. Вложенные наборы переходят в целевой, только если исходные
+// наборы уходят в корзину; сохранённые исходные наборы остаются со своими вложенными.
+func (r *DeckRepository) Merge(ctx context.Context, targetID int, sourceIDs []int, ch MergeChanges) ([]int, error) {
+	var addedTags []int
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if len(ch.Move) > 0 {
+			if _, err := t.Exec(ctx, `UPDATE cards SET deck_id = $2, updated_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL`,
+				ch.Move, targetID); err != nil {
+				return err
+			}
+		}
+		if len(ch.Duplicates) > 0 {
+			dups := make([]int, 0, len(ch.Duplicates))
+			kept := make([]int, 0, len(ch.Duplicates))
+			for dup, keep := range ch.Duplicates {
+				dups = append(dups, dup)
+				kept = append(kept, keep)
+			}
+			if err := mergeDuplicateCards(ctx, t, dups, kept); err != nil {
+				return err
+			}
+		}
+		rows, err := t.Query(ctx, `INSERT INTO deck_tags (deck_id, tag_id)
+			SELECT DISTINCT $1::int, tag_id FROM deck_tags WHERE deck_id = ANY($2)
+			ON CONFLICT DO NOTHING RETURNING tag_id`, targetID, sourceIDs)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			addedTags = append(addedTags, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if ch.DeleteSources {
+			if _, err := t.Exec(ctx, `UPDATE decks SET parent_id = $2 WHERE parent_id = ANY($1) AND deleted_at IS NULL AND id <> $2`,
+				sourceIDs, targetID); err != nil {
+				return err
+			}
+			if _, err := t.Exec(ctx, `UPDATE decks SET deleted_at = NOW() WHERE id = ANY($1) AND deleted_at IS NULL`, sourceIDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addedTags, nil
+}
+
+// SplitGroup — новый набор, создаваемый при разделении, и карточки, которые в него переносятся.
+type SplitGroup struct {
+	Deck    *domain.Deck
+	TagIDs  []int
+	CardIDs []int
+}
+
+// Split создаёт наборы groups и переносит в них карточки набора srcID одной транзакцией.
+// Карточки сохраняют ID, теги и прогресс.
+func (r *DeckRepository) Split(ctx context.Context, srcID int, groups []SplitGroup) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		for _, g := range groups {
+			d := g.Deck
+			if err := t.QueryRow(ctx, `INSERT INTO decks (user_id, title, description, category_id, is_public, parent_id)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, version, created_at, updated_at`,
+				d.UserID, d.Title, d.Description, d.CategoryID, d.IsPublic, d.ParentID,
+			).Scan(&d.ID, &d.Version, &d.CreatedAt, &d.UpdatedAt); err != nil {
+				return err
+			}
+			if len(g.TagIDs) > 0 {
+				if _, err := t.Exec(ctx, `INSERT INTO deck_tags (deck_id, tag_id) SELECT $1, unnest($2::int[]) ON CONFLICT DO NOTHING`,
+					d.ID, g.TagIDs); err != nil {
+					return err
+				}
+			}
+			if _, err := t.Exec(ctx, `UPDATE cards SET deck_id = $3, updated_at = NOW() WHERE id = ANY($1) AND deck_id = $2 AND deleted_at IS NULL`,
+				g.CardIDs, srcID, d.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (r *DeckRepository) SetDeckTags(ctx context.Context, deckID int, tagIDs []int) error {
 	_, err := r.db.Pool.Exec(ctx, `DELETE FROM deck_tags WHERE deck_id = $1`, deckID)
 	if err != nil {
@@ -190,7 +657,7 @@ func (r *DeckRepository) SetDeckTags(ctx context.Context, deckID int, tagIDs []i
 
 func (r *DeckRepository) CountByUserID(ctx context.Context, userID int) (int, error) {
 	var n int
-	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM decks WHERE user_id = $1`, userID).Scan(&n)
+	err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM decks WHERE user_id = $1 AND deleted_at IS NULL`, userID).Scan(&n)
 	return n, err
 }
 
@@ -215,10 +682,19 @@ func (r *DeckRepository) scanDecks(rows pgx.Rows) ([]domain.Deck, error) {
 	var list []domain.Deck
 	for rows.Next() {
 		var d domain.Deck
-		if err := rows.Scan(&d.ID, &d.UserID, &d.Title, &d.Description, &d.CategoryID, &d.IsPublic, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if err := scanDeck(rows, &d); err != nil {
 			return nil, err
 		}
 		list = append(list, d)
 	}
 	return list, rows.Err()
 }
+
+// scanDeck читает строку с колонками deckColumns; extra — дополнительные колонки после них.
+func scanDeck(row pgx.Row, d *domain.Deck, extra ...interface{}) error {
+	dest := []interface{}{
+		&d.ID, &d.UserID, &d.Title, &d.Description, &d.CategoryID, &d.IsPublic,
+		&d.Version, &d.ForkedFromDeckID, &d.ForkedFromVersion, &d.ParentID, &d.CreatedAt, &d.UpdatedAt,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
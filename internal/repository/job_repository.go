@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+const jobColumns = `id, COALESCE(user_id, 0), kind, status, payload, processed, total, result, COALESCE(error, ''),
+	attempts, max_attempts, run_at, cancel_requested, created_at, started_at, finished_at`
+
+// JobRepository — очередь фоновых задач (таблица jobs).
+type JobRepository struct {
+	db *DB
+}
+
+func NewJobRepository(db *DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func scanJob(row pgx.Row, j *domain.Job) error {
+	var payload, result []byte
+	err := row.Scan(&j.ID, &j.UserID, &j.Kind, &j.Status, &payload, &j.Processed, &j.Total, &result, &j.Error,
+		&j.Attempts, &j.MaxAttempts, &j.RunAt, &j.CancelRequested, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	j.Payload, j.Result = payload, result
+	return err
+}
+
+func (r *JobRepository) scanJobs(rows pgx.Rows) ([]domain.Job, error) {
+	defer rows.Close()
+	var list []domain.Job
+	for rows.Next() {
+		var j domain.Job
+		if err := scanJob(rows, &j); err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
+
+// Create ставит задачу в очередь с запуском через delay. Если уже есть незавершённая задача
+// с тем же unique_key, новая не создаётся и в j читается существующая (created = false).
+func (r *JobRepository) Create(ctx context.Context, j *domain.Job, uniqueKey string, delay time.Duration) (created bool, err error) {
+	var key *string
+	if uniqueKey != "" {
+		key = &uniqueKey
+	}
+	query := `INSERT INTO jobs (id, user_id, kind, unique_key, payload, max_attempts, run_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, NOW() + make_interval(secs => $7))
+		ON CONFLICT (unique_key) WHERE status IN ('queued', 'running') DO NOTHING
+		RETURNING ` + jobColumns
+	// задача с тем же ключом могла завершиться между INSERT и SELECT — тогда пробуем ещё раз
+	for i := 0; i < 3; i++ {
+		err = scanJob(r.db.Pool.QueryRow(ctx, query, j.ID, j.UserID, j.Kind, key, []byte(j.Payload), j.MaxAttempts, delay.Seconds()), j)
+		if err != pgx.ErrNoRows {
+			return err == nil, err
+		}
+		err = scanJob(r.db.Pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs
+			WHERE unique_key = $1 AND status IN ('queued', 'running')`, uniqueKey), j)
+		if err != pgx.ErrNoRows {
+			return false, err
+		}
+	}
+	return false, err
+}
+
+// GetByID возвращает задачу; nil — задачи нет.
+func (r *JobRepository) GetByID(ctx context.Context, id string) (*domain.Job, error) {
+	var j domain.Job
+	err := scanJob(r.db.Pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id), &j)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ListByUserID возвращает последние задачи пользователя, новые сверху; status = "" — все.
+func (r *JobRepository) ListByUserID(ctx context.Context, userID int, status string, limit int) ([]domain.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs
+		WHERE user_id = $1 AND ($2::text = '' OR status = $2)
+		ORDER BY created_at DESC LIMIT $3`
+	rows, err := r.db.Pool.Query(ctx, query, userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanJobs(rows)
+}
+
+// Claim забирает самую раннюю готовую к запуску задачу одного из видов kinds: переводит её
+// в running, увеличивает attempts и выдаёт аренду на lease. nil — задач нет.
+// Задачи, заблокированные другими воркерами, пропускаются (SKIP LOCKED).
+func (r *JobRepository) Claim(ctx context.Context, kinds []string, lease time.Duration) (*domain.Job, error) {
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1,
+			started_at = NOW(), locked_until = NOW() + make_interval(secs => $2)
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = 'queued' AND run_at <= NOW() AND kind = ANY($1) AND NOT cancel_requested
+			ORDER BY run_at, created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	var j domain.Job
+	err := scanJob(r.db.Pool.QueryRow(ctx, query, kinds, lease.Seconds()), &j)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Методы выполняемой задачи (Heartbeat, Finish, Retry) меняют её, только пока она за воркером:
+// статус running и тот же номер попытки. Задачу, аренда которой истекла и которую забрал
+// другой воркер, они не трогают.
+const jobOwned = `id = $1 AND status = 'running' AND attempts = $2`
+
+// Heartbeat сохраняет прогресс выполняемой задачи и продлевает аренду. Возвращает true,
+// если задачу нужно остановить: пользователь её отменил или она уже не числится за воркером.
+func (r *JobRepository) Heartbeat(ctx context.Context, j *domain.Job, processed, total int, lease time.Duration) (bool, error) {
+	var cancel bool
+	err := r.db.Pool.QueryRow(ctx, `UPDATE jobs SET processed = $3, total = $4, locked_until = NOW() + make_interval(secs => $5)
+		WHERE `+jobOwned+` RETURNING cancel_requested`, j.ID, j.Attempts, processed, total, lease.Seconds()).Scan(&cancel)
+	if err == pgx.ErrNoRows {
+		return true, nil
+	}
+	return cancel, err
+}
+
+// Finish завершает выполняемую задачу со статусом j.Status (done, failed, canceled),
+// сохраняя прогресс, результат и ошибку.
+func (r *JobRepository) Finish(ctx context.Context, j *domain.Job) error {
+	return r.db.Pool.QueryRow(ctx, `UPDATE jobs SET status = $3, processed = $4, total = $5, result = $6,
+			error = NULLIF($7, ''), locked_until = NULL, finished_at = NOW()
+		WHERE `+jobOwned+` RETURNING finished_at`,
+		j.ID, j.Attempts, j.Status, j.Processed, j.Total, []byte(j.Result), j.Error).Scan(&j.FinishedAt)
+}
+
+// Retry возвращает выполняемую задачу в очередь: следующая попытка — через delay. Если за время
+// попытки пользователь запросил отмену, задача вместо этого отменяется (j.Status = canceled).
+func (r *JobRepository) Retry(ctx context.Context, j *domain.Job, delay time.Duration) error {
+	return r.db.Pool.QueryRow(ctx, `UPDATE jobs SET processed = $3, total = $4, error = NULLIF($5, ''), locked_until = NULL,
+			status = CASE WHEN cancel_requested THEN 'canceled' ELSE 'queued' END,
+			finished_at = CASE WHEN cancel_requested THEN NOW() END,
+			run_at = NOW() + make_interval(secs => $6)
+		WHERE `+jobOwned+` RETURNING status, run_at, finished_at`,
+		j.ID, j.Attempts, j.Processed, j.Total, j.Error, delay.Seconds()).Scan(&j.Status, &j.RunAt, &j.FinishedAt)
+}
+
+// RequestCancel отменяет задачу пользователя: задача в очереди отменяется сразу, выполняемой
+// выставляется cancel_requested — воркер остановит её при следующем продлении аренды.
+// nil — задачи нет или она уже завершена.
+func (r *JobRepository) RequestCancel(ctx context.Context, id string, userID int) (*domain.Job, error) {
+	query := `UPDATE jobs SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'queued' THEN 'canceled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END
+		WHERE id = $1 AND user_id = $2 AND status IN ('queued', 'running')
+		RETURNING ` + jobColumns
+	var j domain.Job
+	err := scanJob(r.db.Pool.QueryRow(ctx, query, id, userID), &j)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
+
+// ReleaseExpired возвращает в очередь задачи с истёкшей арендой (воркер упал): если попытки
+// исчерпаны — завершает их с ошибкой, если была запрошена отмена — отменяет. Возвращает
+// затронутые задачи.
+func (r *JobRepository) ReleaseExpired(ctx context.Context, reason string) ([]domain.Job, error) {
+	rows, err := r.db.Pool.Query(ctx, `UPDATE jobs SET
+			status = CASE WHEN cancel_requested THEN 'canceled' WHEN attempts < max_attempts THEN 'queued' ELSE 'failed' END,
+			finished_at = CASE WHEN cancel_requested OR attempts >= max_attempts THEN NOW() END,
+			error = $1, locked_until = NULL, run_at = NOW()
+		WHERE status = 'running' AND locked_until < NOW()
+		RETURNING `+jobColumns, reason)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanJobs(rows)
+}
+
+// DeleteFinishedBefore удаляет задачи, завершённые раньше before, и возвращает их.
+func (r *JobRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) ([]domain.Job, error) {
+	rows, err := r.db.Pool.Query(ctx, `DELETE FROM jobs
+		WHERE status IN ('done', 'failed', 'canceled') AND finished_at < $1 RETURNING `+jobColumns, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanJobs(rows)
+}
@@ -2,8 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
 )
 
 // DB обёртка над пулом подключений PostgreSQL.
@@ -15,14 +20,136 @@ func NewDB(pool *pgxpool.Pool) *DB {
 	return &DB{Pool: pool}
 }
 
+// WithTx выполняет fn в транзакции. Если в ctx задан автор изменений (domain.WithRevisionAuthor),
+// он выставляется в app.user_id транзакции: триггеры истории наборов читают его через revision_user().
 func (db *DB) WithTx(ctx context.Context, fn func(tx interface{}) error) error {
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
+	if id, ok := domain.RevisionAuthor(ctx); ok {
+		if _, err := tx.Exec(ctx, setRevisionAuthor, strconv.Itoa(id)); err != nil {
+			return err
+		}
+	}
 	if err := fn(tx); err != nil {
 		return err
 	}
 	return tx.Commit(ctx)
 }
+
+// setRevisionAuthor выставляет автора изменений до конца транзакции (SET LOCAL app.user_id).
+const setRevisionAuthor = `SELECT set_config('app.user_id', $1, true)`
+
+// exec выполняет вне транзакции запрос, который меняет наборы или карточки. Автор изменений
+// из ctx выставляется в том же пакете запросов, то есть в той же неявной транзакции.
+func (db *DB) exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	id, ok := domain.RevisionAuthor(ctx)
+	if !ok {
+		return db.Pool.Exec(ctx, sql, args...)
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(setRevisionAuthor, strconv.Itoa(id))
+	batch.Queue(sql, args...)
+	br := db.Pool.SendBatch(ctx, batch)
+	_, err := br.Exec()
+	var tag pgconn.CommandTag
+	if err == nil {
+		tag, err = br.Exec()
+	}
+	if cerr := br.Close(); err == nil {
+		err = cerr
+	}
+	return tag, err
+}
+
+// queryRow — как exec, для запроса с RETURNING.
+func (db *DB) queryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	id, ok := domain.RevisionAuthor(ctx)
+	if !ok {
+		return db.Pool.QueryRow(ctx, sql, args...)
+	}
+	batch := &pgx.Batch{}
+	batch.Queue(setRevisionAuthor, strconv.Itoa(id))
+	batch.Queue(sql, args...)
+	br := db.Pool.SendBatch(ctx, batch)
+	if _, err := br.Exec(); err != nil {
+		_ = br.Close()
+		return errRow{err}
+	}
+	return &batchRow{br: br, row: br.QueryRow()}
+}
+
+// batchRow читает строку из пакета запросов и закрывает пакет.
+type batchRow struct {
+	br  pgx.BatchResults
+	row pgx.Row
+}
+
+func (r *batchRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if cerr := r.br.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...interface{}) error { return r.err }
+
+// UniqueViolation возвращает имя уникального ограничения (индекса), которое нарушил запрос с ошибкой err,
+// или "", если ошибка другая. Позволяет сервисам отвечать конфликтом, когда параллельный запрос
+// успел занять имя между проверкой и записью.
+func UniqueViolation(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// lockTreeMove готовит перемещение строки id таблицы table (дерево по parent_id) под parentID:
+// блокирует её и всю цепочку предков parentID (SELECT ... FOR UPDATE) и проверяет, что id
+// среди них нет. Встречные перемещения блокируют общие строки и выполняются по очереди,
+// поэтому вместе не образуют цикл. Возвращает false, если перемещение создаст цикл.
+func lockTreeMove(ctx context.Context, t pgx.Tx, table string, id, parentID int) (bool, error) {
+	locked := map[int]bool{}
+	for {
+		// после ожидания блокировок цепочку читаем заново: её мог изменить завершившийся перенос
+		rows, err := t.Query(ctx, `WITH RECURSIVE up AS (
+				SELECT id, parent_id FROM `+table+` WHERE id = $1
+				UNION
+				SELECT p.id, p.parent_id FROM `+table+` p INNER JOIN up ON p.id = up.parent_id
+			) SELECT id FROM up`, parentID)
+		if err != nil {
+			return false, err
+		}
+		chain, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return false, err
+		}
+		var need []int
+		if !locked[id] {
+			need = append(need, id)
+		}
+		for _, a := range chain {
+			if a == id {
+				return false, nil
+			}
+			if !locked[a] {
+				need = append(need, a)
+			}
+		}
+		if len(need) == 0 {
+			return true, nil
+		}
+		if _, err := t.Exec(ctx, `SELECT id FROM `+table+` WHERE id = ANY($1) ORDER BY id FOR UPDATE`, need); err != nil {
+			return false, err
+		}
+		for _, a := range need {
+			locked[a] = true
+		}
+	}
+}
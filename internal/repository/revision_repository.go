@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+type RevisionRepository struct {
+	db *DB
+}
+
+func NewRevisionRepository(db *DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+const revisionColumns = `id, deck_id, user_id, entity, entity_id, action, changed_fields, snapshot, created_at`
+
+func (r *RevisionRepository) GetByID(ctx context.Context, id int) (*domain.DeckRevision, error) {
+	var rev domain.DeckRevision
+	err := scanRevision(r.db.Pool.QueryRow(ctx, `SELECT `+revisionColumns+` FROM deck_revisions WHERE id = $1`, id), &rev)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// ListByDeckID возвращает ревизии набора от новых к старым; cardID ограничивает историю одной карточкой.
+func (r *RevisionRepository) ListByDeckID(ctx context.Context, deckID int, cardID *int, page, limit int) ([]domain.DeckRevision, int, error) {
+	cond := ` FROM deck_revisions WHERE deck_id = $1`
+	args := []interface{}{deckID}
+	if cardID != nil {
+		cond += ` AND entity = 'card' AND entity_id = $2`
+		args = append(args, *cardID)
+	}
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT COUNT(*)`+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	args = append(args, limit, (page-1)*limit)
+	query := `SELECT ` + revisionColumns + cond + ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args))
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var list []domain.DeckRevision
+	for rows.Next() {
+		var rev domain.DeckRevision
+		if err := scanRevision(rows, &rev); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, rev)
+	}
+	return list, total, rows.Err()
+}
+
+// StateAt восстанавливает набор на момент ревизии revID: для каждого объекта берётся последняя
+// ревизия не новее revID; удалённые и перенесённые из набора карточки отбрасываются.
+func (r *RevisionRepository) StateAt(ctx context.Context, deckID int, revID int) (*domain.DeckSnapshot, []domain.CardSnapshot, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT DISTINCT ON (entity, entity_id) entity, action, snapshot
+		FROM deck_revisions WHERE deck_id = $1 AND id <= $2
+		ORDER BY entity, entity_id, id DESC`, deckID, revID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var deck *domain.DeckSnapshot
+	var cards []domain.CardSnapshot
+	for rows.Next() {
+		var entity, action string
+		var snapshot []byte
+		if err := rows.Scan(&entity, &action, &snapshot); err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case entity == domain.RevisionEntityDeck:
+			deck = &domain.DeckSnapshot{}
+			if err := json.Unmarshal(snapshot, deck); err != nil {
+				return nil, nil, err
+			}
+		case action != domain.RevisionDelete && action != domain.RevisionMoveOut:
+			var c domain.CardSnapshot
+			if err := json.Unmarshal(snapshot, &c); err != nil {
+				return nil, nil, err
+			}
+			cards = append(cards, c)
+		}
+	}
+	return deck, cards, rows.Err()
+}
+
+// Rollback приводит набор (или одну карточку, если deck == nil) к сохранённому состоянию одной транзакцией.
+// Карточки из cards восстанавливаются из корзины или создаются заново с прежними ID; onlyCards — ID карточек,
+// которые затрагивает откат (nil — все карточки набора: лишние перемещаются в корзину).
+func (r *RevisionRepository) Rollback(ctx context.Context, deckID int, deck *domain.DeckSnapshot, cards []domain.CardSnapshot, onlyCards []int) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if deck != nil {
+			if _, err := t.Exec(ctx, `UPDATE decks SET title=$2, description=$3, category_id=$4, is_public=$5, version=version+1, updated_at=NOW() WHERE id=$1`,
+				deckID, deck.Title, deck.Description, deck.CategoryID, deck.IsPublic); err != nil {
+				return err
+			}
+		}
+		keep := make([]int, 0, len(cards))
+		for _, c := range cards {
+			keep = append(keep, c.ID)
+		}
+		var err error
+		if onlyCards == nil {
+			_, err = t.Exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE deck_id = $1 AND deleted_at IS NULL AND NOT (id = ANY($2))`, deckID, keep)
+		} else {
+			_, err = t.Exec(ctx, `UPDATE cards SET deleted_at = NOW() WHERE deck_id = $1 AND deleted_at IS NULL AND id = ANY($2) AND NOT (id = ANY($3))`, deckID, onlyCards, keep)
+		}
+		if err != nil {
+			return err
+		}
+		batch := &pgx.Batch{}
+		for _, c := range cards {
+			batch.Queue(`INSERT INTO cards (id, deck_id, question, answer, category_id) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (id) DO UPDATE SET deck_id = EXCLUDED.deck_id, question = EXCLUDED.question,
+					answer = EXCLUDED.answer, category_id = EXCLUDED.category_id, deleted_at = NULL, updated_at = NOW()
+				WHERE cards.deleted_at IS NOT NULL OR (cards.deck_id, cards.question, cards.answer, cards.category_id)
+					IS DISTINCT FROM (EXCLUDED.deck_id, EXCLUDED.question, EXCLUDED.answer, EXCLUDED.category_id)`,
+				c.ID, deckID, c.Question, c.Answer, c.CategoryID)
+		}
+		return t.SendBatch(ctx, batch).Close()
+	})
+}
+
+// ListDeckIDs возвращает наборы, у которых есть ревизии.
+func (r *RevisionRepository) ListDeckIDs(ctx context.Context) ([]int, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT DISTINCT deck_id FROM deck_revisions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Prune удаляет ревизии набора сверх keep последних и старше since (keep <= 0 и нулевой since — без ограничения).
+// Для каждого объекта сохраняется последняя ревизия до границы, чтобы состояние на любую
+// оставшуюся ревизию можно было восстановить.
+func (r *RevisionRepository) Prune(ctx context.Context, deckID int, keep int, since time.Time) (int64, error) {
+	var cutoff int
+	if keep > 0 {
+		err := r.db.Pool.QueryRow(ctx, `SELECT id FROM deck_revisions WHERE deck_id = $1 ORDER BY id DESC OFFSET $2 LIMIT 1`,
+			deckID, keep-1).Scan(&cutoff)
+		if err != nil && err != pgx.ErrNoRows {
+			return 0, err
+		}
+	}
+	if !since.IsZero() {
+		var byAge *int
+		if err := r.db.Pool.QueryRow(ctx, `SELECT MIN(id) FROM deck_revisions WHERE deck_id = $1 AND created_at >= $2`,
+			deckID, since).Scan(&byAge); err != nil {
+			return 0, err
+		}
+		if byAge == nil {
+			if err := r.db.Pool.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) + 1 FROM deck_revisions WHERE deck_id = $1`, deckID).Scan(&byAge); err != nil {
+				return 0, err
+			}
+		}
+		if *byAge > cutoff {
+			cutoff = *byAge
+		}
+	}
+	if cutoff == 0 {
+		return 0, nil
+	}
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM deck_revisions r
+		WHERE r.deck_id = $1 AND r.id < $2
+			AND (r.action IN ('delete', 'move_out') OR EXISTS (
+				SELECT 1 FROM deck_revisions n
+				WHERE n.deck_id = r.deck_id AND n.entity = r.entity AND n.entity_id = r.entity_id
+					AND n.id > r.id AND n.id < $2))`, deckID, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanRevision(row pgx.Row, rev *domain.DeckRevision) error {
+	var snapshot []byte
+	if err := row.Scan(&rev.ID, &rev.DeckID, &rev.UserID, &rev.Entity, &rev.EntityID, &rev.Action,
+		&rev.ChangedFields, &snapshot, &rev.CreatedAt); err != nil {
+		return err
+	}
+	rev.Snapshot = snapshot
+	return nil
+}
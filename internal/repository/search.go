@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"html"
+	"strings"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+// Полнотекстовый поиск по колонкам search_vector наборов и карточек (миграция 016).
+// Текст запроса передаётся одним параметром и разбирается как в websearch_to_tsquery:
+// слова через пробел должны встретиться все, "фраза" — подряд, or — любое, -слово — исключить.
+
+// Найденные слова ts_headline обрамляет метками из области частного использования Unicode:
+// фрагмент экранируется как HTML уже в Go (highlightHTML), а затем метки заменяются на <mark>.
+// Так разметка из текста набора или карточки не попадает в ответ как HTML.
+const (
+	searchMarkStart = "\ue000"
+	searchMarkStop  = "\ue001"
+)
+
+// searchHeadlineOptions — параметры фрагментов ts_headline.
+const searchHeadlineOptions = `'StartSel="` + searchMarkStart + `", StopSel="` + searchMarkStop + `", MinWords=10, MaxWords=30, MaxFragments=2'`
+
+// searchMarks заменяет метки найденных слов на теги выделения.
+var searchMarks = strings.NewReplacer(searchMarkStart, "<mark>", searchMarkStop, "</mark>")
+
+// highlightHTML экранирует фрагмент ts_headline как HTML и заменяет метки найденных слов на <mark>.
+func highlightHTML(fragment string) string {
+	return searchMarks.Replace(html.EscapeString(fragment))
+}
+
+// searchTSQuery возвращает tsquery текста из параметра arg: словоформы по русской
+// и английской морфологии, как в search_vector.
+func searchTSQuery(arg string) string {
+	return `(websearch_to_tsquery('russian', ` + arg + `) || websearch_to_tsquery('english', ` + arg + `))`
+}
+
+// searchCond — условие поиска по колонке vector. Если в запросе одни стоп-слова («и», «the»)
+// и tsquery пуст, ищется подстрока из параметра pattern в колонках fallback.
+func searchCond(vector, arg, pattern string, fallback ...string) string {
+	like := ``
+	for i, col := range fallback {
+		if i > 0 {
+			like += ` OR `
+		}
+		like += col + ` ILIKE ` + pattern
+	}
+	return ` AND (` + vector + ` @@ ` + searchTSQuery(arg) + ` OR numnode(` + searchTSQuery(arg) + `) = 0 AND (` + like + `))`
+}
+
+// searchColumns — дополнительные колонки строки при поиске: ранг search_rank (вес A —
+// название и вопрос — выше веса B) и фрагменты колонок fields с выделенными словами.
+// Читаются через searchHit.
+func searchColumns(vector, arg string, fields ...string) string {
+	cols := `, ts_rank(` + vector + `, ` + searchTSQuery(arg) + `) AS search_rank`
+	for _, f := range fields {
+		// метки, встретившиеся в самом тексте, убираются, чтобы в ответе были только наши
+		text := `translate(` + f + `, '` + searchMarkStart + searchMarkStop + `', '')`
+		cols += `, ts_headline('russian', ` + text + `, ` + searchTSQuery(arg) + `, ` + searchHeadlineOptions + `)`
+	}
+	return cols
+}
+
+// searchHit — ранг и фрагменты, прочитанные вместе со строкой (см. searchColumns).
+type searchHit struct {
+	rank      float32
+	fragments []*string
+}
+
+func newSearchHit(fields int) *searchHit {
+	return &searchHit{fragments: make([]*string, fields)}
+}
+
+// dest возвращает указатели для Scan в порядке searchColumns.
+func (h *searchHit) dest() []interface{} {
+	dest := []interface{}{&h.rank}
+	for i := range h.fragments {
+		dest = append(dest, &h.fragments[i])
+	}
+	return dest
+}
+
+// match собирает совпадение; names — имена полей в порядке searchColumns, пустые фрагменты пропускаются.
+func (h *searchHit) match(names ...string) *domain.SearchMatch {
+	m := &domain.SearchMatch{Rank: h.rank, Highlights: map[string]string{}}
+	for i, f := range h.fragments {
+		if f != nil && *f != "" && i < len(names) {
+			m.Highlights[names[i]] = highlightHTML(*f)
+		}
+	}
+	return m
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+		want     string
+	}{
+		{"plain", "кошка", "кошка"},
+		{"mark", searchMarkStart + "кошка" + searchMarkStop + " спит", "<mark>кошка</mark> спит"},
+		{"script", "<script>alert(1)</script> " + searchMarkStart + "кот" + searchMarkStop,
+			"&lt;script&gt;alert(1)&lt;/script&gt; <mark>кот</mark>"},
+		{"img onerror", `<img src=x onerror="alert(1)">` + searchMarkStart + "x" + searchMarkStop,
+			"&lt;img src=x onerror=&#34;alert(1)&#34;&gt;<mark>x</mark>"},
+		{"entities", "A & B 'c'", "A &amp; B &#39;c&#39;"},
+		{"fake mark tag", "<mark>кот</mark>", "&lt;mark&gt;кот&lt;/mark&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightHTML(tt.fragment); got != tt.want {
+				t.Errorf("highlightHTML(%q) = %q, want %q", tt.fragment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHitMatch(t *testing.T) {
+	title := `<b onmouseover=alert(1)>` + searchMarkStart + "Кошки" + searchMarkStop
+	hit := newSearchHit(2)
+	hit.rank = 0.5
+	hit.fragments[0] = &title
+	m := hit.match("title", "description")
+	if m.Rank != 0.5 {
+		t.Errorf("rank = %v, want 0.5", m.Rank)
+	}
+	if got, want := m.Highlights["title"], "&lt;b onmouseover=alert(1)&gt;<mark>Кошки</mark>"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if _, ok := m.Highlights["description"]; ok {
+		t.Error("description without fragment must be omitted")
+	}
+}
+
+func TestSearchColumnsStripsMarks(t *testing.T) {
+	cols := searchColumns(`d.search_vector`, "$1", `d.title`)
+	if !strings.Contains(cols, `translate(d.title, '`+searchMarkStart+searchMarkStop+`', '')`) {
+		t.Errorf("headline source is not stripped of marks: %s", cols)
+	}
+}
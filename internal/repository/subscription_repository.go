@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+type SubscriptionRepository struct {
+	db *DB
+}
+
+func NewSubscriptionRepository(db *DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+// Create добавляет подписку; повторная подписка ничего не меняет.
+func (r *SubscriptionRepository) Create(ctx context.Context, s *domain.DeckSubscription) error {
+	query := `INSERT INTO deck_subscriptions (user_id, deck_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, deck_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING created_at`
+	return r.db.Pool.QueryRow(ctx, query, s.UserID, s.DeckID).Scan(&s.CreatedAt)
+}
+
+func (r *SubscriptionRepository) Delete(ctx context.Context, userID, deckID int) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM deck_subscriptions WHERE user_id = $1 AND deck_id = $2`, userID, deckID)
+	return err
+}
+
+func (r *SubscriptionRepository) Exists(ctx context.Context, userID, deckID int) (bool, error) {
+	var ok bool
+	err := r.db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM deck_subscriptions WHERE user_id = $1 AND deck_id = $2)`, userID, deckID).Scan(&ok)
+	return ok, err
+}
+
+func (r *SubscriptionRepository) ListByUserID(ctx context.Context, userID int) ([]domain.DeckSubscription, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT user_id, deck_id, created_at FROM deck_subscriptions WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []domain.DeckSubscription
+	for rows.Next() {
+		var s domain.DeckSubscription
+		if err := rows.Scan(&s.UserID, &s.DeckID, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
@@ -7,6 +7,73 @@ import (
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 )
 
+// tagColumns — колонки tags в порядке scanTag.
+const tagColumns = `id, name, user_id, parent_id, created_at`
+
+// tagVisible — условие видимости тега пользователю $1: общий или личный тег этого пользователя.
+const tagVisible = `(user_id IS NULL OR user_id = $1)`
+
+// tagSubtree — рекурсивная часть CTE tag_tree: тег arg и его потомки, видимые пользователю
+// owner (параметр запроса или NULL — только общие теги). Личные теги других пользователей
+// внутри общего тега не обходятся.
+func tagSubtree(arg, owner string) string {
+	return `SELECT id FROM tags WHERE id = ` + arg + `
+	UNION
+	SELECT t.id FROM tags t INNER JOIN tag_tree ON t.parent_id = tag_tree.id
+		WHERE t.user_id IS NULL OR t.user_id = ` + owner
+}
+
+// tagOwnSubtree — рекурсивная часть CTE own_tree: тег arg и его потомки с той же видимостью
+// (у общего тега — только общие, у личного — теги того же владельца). Личные теги других
+// пользователей внутри общего тега сюда не попадают.
+func tagOwnSubtree(arg string) string {
+	return `SELECT id, user_id FROM tags WHERE id = ` + arg + `
+	UNION
+	SELECT t.id, t.user_id FROM tags t INNER JOIN own_tree ON t.parent_id = own_tree.id
+		AND t.user_id IS NOT DISTINCT FROM own_tree.user_id`
+}
+
+// detachForeignChildren делает корневыми теги другой видимости, лежащие прямо внутри тега id
+// или его потомков той же видимости: личные теги внутри общего не переименовываются
+// и не удаляются вместе с ним, владельцы переносят их сами.
+func detachForeignChildren(ctx context.Context, t pgx.Tx, id int) error {
+	_, err := t.Exec(ctx, `WITH RECURSIVE own_tree AS (`+tagOwnSubtree("$1")+`)
+		UPDATE tags t SET parent_id = NULL FROM own_tree
+		WHERE t.parent_id = own_tree.id AND t.user_id IS DISTINCT FROM own_tree.user_id`, id)
+	return err
+}
+
+// ensureTagParents находит или создаёт в транзакции родительские теги parents (полные пути
+// от корня) и возвращает ID ближайшего родителя (nil для корневого тега). Родитель ищется
+// среди общих тегов и тегов owner; недостающие создаются с владельцем owner (nil — общие).
+func ensureTagParents(ctx context.Context, t pgx.Tx, parents []string, owner *int) (*int, error) {
+	var parentID *int
+	for _, name := range parents {
+		var id int
+		err := t.QueryRow(ctx, `SELECT id FROM tags WHERE (user_id IS NULL OR user_id = $1) AND name = $2
+			ORDER BY user_id NULLS FIRST LIMIT 1`, owner, name).Scan(&id)
+		if err == pgx.ErrNoRows {
+			// тот же родитель мог только что создать параллельный запрос — тогда читаем его
+			err = t.QueryRow(ctx, `INSERT INTO tags (name, user_id, parent_id) VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING RETURNING id`, name, owner, parentID).Scan(&id)
+			if err == pgx.ErrNoRows {
+				err = t.QueryRow(ctx, `SELECT id FROM tags WHERE user_id IS NOT DISTINCT FROM $1 AND name = $2`, owner, name).Scan(&id)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		parentID = &id
+	}
+	return parentID, nil
+}
+
+// tagSubtreeIDs — подзапрос с ID тега arg и его потомков, видимых owner (см. tagSubtree),
+// для условий вида tag_id IN (...).
+func tagSubtreeIDs(arg, owner string) string {
+	return `WITH RECURSIVE tag_tree AS (` + tagSubtree(arg, owner) + `) SELECT id FROM tag_tree`
+}
+
 type TagRepository struct {
 	db *DB
 }
@@ -15,15 +82,25 @@ func NewTagRepository(db *DB) *TagRepository {
 	return &TagRepository{db: db}
 }
 
-func (r *TagRepository) Create(ctx context.Context, t *domain.Tag) error {
-	query := `INSERT INTO tags (name) VALUES ($1) RETURNING id, created_at`
-	return r.db.Pool.QueryRow(ctx, query, t.Name).Scan(&t.ID, &t.CreatedAt)
+// Create создаёт тег вместе с недостающими родительскими тегами parents (полные пути от корня)
+// одной транзакцией и заполняет t.ParentID.
+func (r *TagRepository) Create(ctx context.Context, t *domain.Tag, parents []string) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		tr := tx.(pgx.Tx)
+		parentID, err := ensureTagParents(ctx, tr, parents, t.UserID)
+		if err != nil {
+			return err
+		}
+		t.ParentID = parentID
+		return tr.QueryRow(ctx, `INSERT INTO tags (name, user_id, parent_id) VALUES ($1, $2, $3) RETURNING id, created_at`,
+			t.Name, t.UserID, t.ParentID).Scan(&t.ID, &t.CreatedAt)
+	})
 }
 
 func (r *TagRepository) GetByID(ctx context.Context, id int) (*domain.Tag, error) {
-	query := `SELECT id, name, created_at FROM tags WHERE id = $1`
+	query := `SELECT ` + tagColumns + ` FROM tags WHERE id = $1`
 	var t domain.Tag
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(&t.ID, &t.Name, &t.CreatedAt)
+	err := scanTag(r.db.Pool.QueryRow(ctx, query, id), &t)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -33,10 +110,11 @@ func (r *TagRepository) GetByID(ctx context.Context, id int) (*domain.Tag, error
 	return &t, nil
 }
 
-func (r *TagRepository) GetByName(ctx context.Context, name string) (*domain.Tag, error) {
-	query := `SELECT id, name, created_at FROM tags WHERE name = $1`
+// GetByName ищет тег с именем name среди общих тегов и личных тегов пользователя userID.
+func (r *TagRepository) GetByName(ctx context.Context, userID int, name string) (*domain.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags WHERE ` + tagVisible + ` AND name = $2 ORDER BY user_id NULLS FIRST LIMIT 1`
 	var t domain.Tag
-	err := r.db.Pool.QueryRow(ctx, query, name).Scan(&t.ID, &t.Name, &t.CreatedAt)
+	err := scanTag(r.db.Pool.QueryRow(ctx, query, userID, name), &t)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -50,32 +128,74 @@ func (r *TagRepository) GetByIDs(ctx context.Context, ids []int) ([]domain.Tag,
 	if len(ids) == 0 {
 		return nil, nil
 	}
-	rows, err := r.db.Pool.Query(ctx, `SELECT id, name, created_at FROM tags WHERE id = ANY($1)`, ids)
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = ANY($1)`, ids)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var list []domain.Tag
-	for rows.Next() {
-		var t domain.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
-			return nil, err
-		}
-		list = append(list, t)
+	return scanTags(rows)
+}
+
+// GetVisibleByIDs возвращает теги из ids, доступные пользователю userID.
+func (r *TagRepository) GetVisibleByIDs(ctx context.Context, userID int, ids []int) ([]domain.Tag, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
-	return list, rows.Err()
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+tagColumns+` FROM tags WHERE `+tagVisible+` AND id = ANY($2)`, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTags(rows)
 }
 
-func (r *TagRepository) List(ctx context.Context) ([]domain.Tag, error) {
-	rows, err := r.db.Pool.Query(ctx, `SELECT id, name, created_at FROM tags ORDER BY name`)
+// List возвращает общие теги и личные теги пользователя userID (0 — только общие).
+func (r *TagRepository) List(ctx context.Context, userID int) ([]domain.Tag, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+tagColumns+` FROM tags WHERE `+tagVisible+` ORDER BY name`, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var list []domain.Tag
+	return scanTags(rows)
+}
+
+// ListWithSearch возвращает видимые пользователю теги с опциональным поиском по имени.
+func (r *TagRepository) ListWithSearch(ctx context.Context, userID int, search string) ([]domain.Tag, error) {
+	if search == "" {
+		return r.List(ctx, userID)
+	}
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+tagColumns+` FROM tags WHERE `+tagVisible+` AND name ILIKE $2 ORDER BY name`, userID, "%"+search+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTags(rows)
+}
+
+// ListWithUsage возвращает общие теги и личные теги пользователя userID с количеством наборов
+// и карточек, к которым они привязаны. Считаются только неудалённые наборы и карточки,
+// которые видит пользователь: его собственные и публичные.
+func (r *TagRepository) ListWithUsage(ctx context.Context, userID int, search string) ([]domain.TagListItem, error) {
+	query := `SELECT t.id, t.name, t.user_id, t.parent_id, t.created_at,
+			(SELECT COUNT(*) FROM deck_tags dt INNER JOIN decks d ON d.id = dt.deck_id
+				WHERE dt.tag_id = t.id AND d.deleted_at IS NULL AND (d.user_id = $1 OR d.is_public))::int,
+			(SELECT COUNT(*) FROM card_tags ct INNER JOIN cards c ON c.id = ct.card_id INNER JOIN decks d ON d.id = c.deck_id
+				WHERE ct.tag_id = t.id AND c.deleted_at IS NULL AND d.deleted_at IS NULL AND (d.user_id = $1 OR d.is_public))::int
+		FROM tags t WHERE (t.user_id IS NULL OR t.user_id = $1)`
+	args := []interface{}{userID}
+	if search != "" {
+		query += ` AND t.name ILIKE $2`
+		args = append(args, "%"+search+"%")
+	}
+	rows, err := r.db.Pool.Query(ctx, query+` ORDER BY t.name, t.user_id NULLS FIRST`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []domain.TagListItem
 	for rows.Next() {
-		var t domain.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+		var t domain.TagListItem
+		if err := scanTag(rows, &t.Tag, &t.DecksCount, &t.CardsCount); err != nil {
 			return nil, err
 		}
 		list = append(list, t)
@@ -83,23 +203,138 @@ func (r *TagRepository) List(ctx context.Context) ([]domain.Tag, error) {
 	return list, rows.Err()
 }
 
-// ListWithSearch возвращает теги с опциональным поиском по имени.
-func (r *TagRepository) ListWithSearch(ctx context.Context, search string) ([]domain.Tag, error) {
-	if search == "" {
-		return r.List(ctx)
+// Descendants возвращает дочерние теги id на любой глубине с той же видимостью, что у id
+// (личные теги других пользователей внутри общего тега не входят).
+func (r *TagRepository) Descendants(ctx context.Context, id int) ([]domain.Tag, error) {
+	rows, err := r.db.Pool.Query(ctx, `WITH RECURSIVE own_tree AS (`+tagOwnSubtree("$1")+`)
+		SELECT `+tagColumns+` FROM tags WHERE id IN (SELECT id FROM own_tree) AND id <> $1 ORDER BY name`, id)
+	if err != nil {
+		return nil, err
 	}
-	rows, err := r.db.Pool.Query(ctx, `SELECT id, name, created_at FROM tags WHERE name ILIKE $1 ORDER BY name`, "%"+search+"%")
+	defer rows.Close()
+	return scanTags(rows)
+}
+
+// Usage возвращает количество наборов и карточек, к которым привязан тег или его дочерние теги
+// той же видимости — те, что удалит Delete.
+func (r *TagRepository) Usage(ctx context.Context, id int) (decks, cards int, err error) {
+	err = r.db.Pool.QueryRow(ctx, `WITH RECURSIVE own_tree AS (`+tagOwnSubtree("$1")+`) SELECT
+			(SELECT COUNT(DISTINCT deck_id) FROM deck_tags WHERE tag_id IN (SELECT id FROM own_tree))::int,
+			(SELECT COUNT(DISTINCT card_id) FROM card_tags WHERE tag_id IN (SELECT id FROM own_tree))::int`, id).Scan(&decks, &cards)
+	return decks, cards, err
+}
+
+// Update сохраняет имя тега одной транзакцией вместе с недостающими родительскими тегами parents
+// и заполняет t.ParentID. Полные пути дочерних тегов той же видимости меняются вместе с ним
+// (lang::de::verbs → languages::de::verbs); личные теги других пользователей отсоединяются.
+func (r *TagRepository) Update(ctx context.Context, t *domain.Tag, parents []string) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		tr := tx.(pgx.Tx)
+		var oldName string
+		if err := tr.QueryRow(ctx, `SELECT name FROM tags WHERE id = $1 FOR UPDATE`, t.ID).Scan(&oldName); err != nil {
+			return err
+		}
+		parentID, err := ensureTagParents(ctx, tr, parents, t.UserID)
+		if err != nil {
+			return err
+		}
+		t.ParentID = parentID
+		if oldName != t.Name {
+			if err := detachForeignChildren(ctx, tr, t.ID); err != nil {
+				return err
+			}
+			if _, err := tr.Exec(ctx, `WITH RECURSIVE own_tree AS (`+tagOwnSubtree("$1")+`)
+				UPDATE tags SET name = $2 || substr(name, length($3) + 1)
+				WHERE id IN (SELECT id FROM own_tree) AND id <> $1`, t.ID, t.Name, oldName); err != nil {
+				return err
+			}
+		}
+		_, err = tr.Exec(ctx, `UPDATE tags SET name = $2, parent_id = $3 WHERE id = $1`, t.ID, t.Name, t.ParentID)
+		return err
+	})
+}
+
+// Delete удаляет тег вместе с дочерними тегами той же видимости; привязки к наборам и карточкам
+// удаляются каскадом. Личные теги других пользователей внутри общего тега остаются корневыми.
+func (r *TagRepository) Delete(ctx context.Context, id int) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if err := detachForeignChildren(ctx, t, id); err != nil {
+			return err
+		}
+		_, err := t.Exec(ctx, `DELETE FROM tags WHERE id = $1`, id)
+		return err
+	})
+}
+
+// Merge переносит привязки тега srcID к наборам и карточкам на тег dstID и удаляет srcID одной транзакцией.
+func (r *TagRepository) Merge(ctx context.Context, srcID, dstID int) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if _, err := t.Exec(ctx, `INSERT INTO deck_tags (deck_id, tag_id) SELECT deck_id, $2 FROM deck_tags WHERE tag_id = $1
+			ON CONFLICT DO NOTHING`, srcID, dstID); err != nil {
+			return err
+		}
+		if _, err := t.Exec(ctx, `INSERT INTO card_tags (card_id, tag_id) SELECT card_id, $2 FROM card_tags WHERE tag_id = $1
+			ON CONFLICT DO NOTHING`, srcID, dstID); err != nil {
+			return err
+		}
+		if err := detachForeignChildren(ctx, t, srcID); err != nil {
+			return err
+		}
+		_, err := t.Exec(ctx, `DELETE FROM tags WHERE id = $1`, srcID)
+		return err
+	})
+}
+
+// TaggedText — текст карточки (вопрос и ответ) или набора (название и описание) пользователя с его тегами.
+type TaggedText struct {
+	Text   string
+	TagIDs []int
+}
+
+// ListTaggedTexts возвращает до limit последних изменённых карточек и наборов пользователя,
+// у которых есть видимые ему теги; корзина не учитывается.
+func (r *TagRepository) ListTaggedTexts(ctx context.Context, userID int, limit int) ([]TaggedText, error) {
+	rows, err := r.db.Pool.Query(ctx, `(SELECT c.question || ' ' || c.answer, array_agg(ct.tag_id ORDER BY ct.tag_id)
+			FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+			INNER JOIN card_tags ct ON ct.card_id = c.id INNER JOIN tags t ON t.id = ct.tag_id
+			WHERE d.user_id = $1 AND c.deleted_at IS NULL AND d.deleted_at IS NULL AND (t.user_id IS NULL OR t.user_id = $1)
+			GROUP BY c.id ORDER BY c.updated_at DESC LIMIT $2)
+		UNION ALL
+		(SELECT d.title || ' ' || COALESCE(d.description, ''), array_agg(dt.tag_id ORDER BY dt.tag_id)
+			FROM decks d INNER JOIN deck_tags dt ON dt.deck_id = d.id INNER JOIN tags t ON t.id = dt.tag_id
+			WHERE d.user_id = $1 AND d.deleted_at IS NULL AND (t.user_id IS NULL OR t.user_id = $1)
+			GROUP BY d.id ORDER BY d.updated_at DESC LIMIT $2)`, userID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	var list []TaggedText
+	for rows.Next() {
+		var t TaggedText
+		if err := rows.Scan(&t.Text, &t.TagIDs); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func scanTags(rows pgx.Rows) ([]domain.Tag, error) {
 	var list []domain.Tag
 	for rows.Next() {
 		var t domain.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+		if err := scanTag(rows, &t); err != nil {
 			return nil, err
 		}
 		list = append(list, t)
 	}
 	return list, rows.Err()
 }
+
+// scanTag читает строку с колонками tagColumns; extra — дополнительные колонки после них.
+func scanTag(row pgx.Row, t *domain.Tag, extra ...interface{}) error {
+	dest := []interface{}{&t.ID, &t.Name, &t.UserID, &t.ParentID, &t.CreatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
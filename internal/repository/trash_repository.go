@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+// TrashRepository — наборы и карточки в корзине (deleted_at IS NOT NULL).
+type TrashRepository struct {
+	db *DB
+}
+
+func NewTrashRepository(db *DB) *TrashRepository {
+	return &TrashRepository{db: db}
+}
+
+// TrashedDeck — набор в корзине; ParentDeleted — родительский набор тоже удалён.
+type TrashedDeck struct {
+	Deck          domain.Deck
+	ParentDeleted bool
+	DeletedAt     time.Time
+}
+
+// TrashedCard — карточка в корзине; DeckDeleted — набор карточки тоже удалён.
+type TrashedCard struct {
+	Card        domain.Card
+	DeckTitle   string
+	DeckUserID  int
+	DeckDeleted bool
+	DeletedAt   time.Time
+}
+
+// ListDecks возвращает удалённые наборы пользователя, новые сверху. Наборы, удалённые вместе
+// с родительским, не показываются: они восстанавливаются вместе с ним.
+func (r *TrashRepository) ListDecks(ctx context.Context, userID int) ([]TrashedDeck, error) {
+	query := `SELECT ` + deckColumns + `, deleted_at,
+			(SELECT COUNT(*) FROM cards c WHERE c.deck_id = decks.id AND c.deleted_at IS NULL)::int
+		FROM decks WHERE user_id = $1 AND deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM decks p WHERE p.id = decks.parent_id AND p.deleted_at IS NOT NULL)
+		ORDER BY deleted_at DESC`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []TrashedDeck
+	for rows.Next() {
+		var td TrashedDeck
+		if err := scanDeck(rows, &td.Deck, &td.DeletedAt, &td.Deck.CardsCount); err != nil {
+			return nil, err
+		}
+		list = append(list, td)
+	}
+	return list, rows.Err()
+}
+
+// ListCards возвращает удалённые карточки из неудалённых наборов пользователя, новые сверху.
+func (r *TrashRepository) ListCards(ctx context.Context, userID int) ([]TrashedCard, error) {
+	query := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at,
+			d.title, d.user_id, false, c.deleted_at
+		FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+		WHERE d.user_id = $1 AND d.deleted_at IS NULL AND c.deleted_at IS NOT NULL
+		ORDER BY c.deleted_at DESC, c.id`
+	rows, err := r.db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []TrashedCard
+	for rows.Next() {
+		var tc TrashedCard
+		if err := scanCard(rows, &tc.Card, &tc.DeckTitle, &tc.DeckUserID, &tc.DeckDeleted, &tc.DeletedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, tc)
+	}
+	return list, rows.Err()
+}
+
+// GetDeck возвращает набор из корзины или nil, если набора нет или он не удалён.
+func (r *TrashRepository) GetDeck(ctx context.Context, id int) (*TrashedDeck, error) {
+	query := `SELECT ` + deckColumns + `, deleted_at,
+			EXISTS (SELECT 1 FROM decks p WHERE p.id = decks.parent_id AND p.deleted_at IS NOT NULL)
+		FROM decks WHERE id = $1 AND deleted_at IS NOT NULL`
+	var td TrashedDeck
+	if err := scanDeck(r.db.Pool.QueryRow(ctx, query, id), &td.Deck, &td.DeletedAt, &td.ParentDeleted); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &td, nil
+}
+
+// GetCard возвращает карточку из корзины или nil, если карточки нет или она не удалена.
+func (r *TrashRepository) GetCard(ctx context.Context, id int) (*TrashedCard, error) {
+	query := `SELECT c.id, c.deck_id, c.question, c.answer, c.category_id, c.origin_card_id, c.created_at, c.updated_at,
+			d.title, d.user_id, d.deleted_at IS NOT NULL, c.deleted_at
+		FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+		WHERE c.id = $1 AND c.deleted_at IS NOT NULL`
+	var tc TrashedCard
+	if err := scanCard(r.db.Pool.QueryRow(ctx, query, id), &tc.Card, &tc.DeckTitle, &tc.DeckUserID, &tc.DeckDeleted, &tc.DeletedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tc, nil
+}
+
+// RestoreDeck восстанавливает набор и вложенные наборы, удалённые вместе с ним.
+func (r *TrashRepository) RestoreDeck(ctx context.Context, id int) error {
+	_, err := r.db.exec(ctx, `WITH RECURSIVE tree AS (
+			SELECT id, deleted_at FROM decks WHERE id = $1
+			UNION
+			SELECT d.id, d.deleted_at FROM decks d INNER JOIN tree ON d.parent_id = tree.id AND d.deleted_at = tree.deleted_at
+		)
+		UPDATE decks SET deleted_at = NULL WHERE id IN (SELECT id FROM tree)`, id)
+	return err
+}
+
+func (r *TrashRepository) RestoreCard(ctx context.Context, id int) error {
+	_, err := r.db.exec(ctx, `UPDATE cards SET deleted_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// PurgeDeck окончательно удаляет набор из корзины; карточки, прогресс и вложенные наборы удаляются каскадом.
+func (r *TrashRepository) PurgeDeck(ctx context.Context, id int) error {
+	_, err := r.db.exec(ctx, `DELETE FROM decks WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return err
+}
+
+// PurgeCard окончательно удаляет карточку из корзины.
+func (r *TrashRepository) PurgeCard(ctx context.Context, id int) error {
+	_, err := r.db.exec(ctx, `DELETE FROM cards WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	return err
+}
+
+// Empty окончательно удаляет всё содержимое корзины пользователя.
+func (r *TrashRepository) Empty(ctx context.Context, userID int) error {
+	return r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		if _, err := t.Exec(ctx, `DELETE FROM cards c USING decks d
+			WHERE d.id = c.deck_id AND d.user_id = $1 AND c.deleted_at IS NOT NULL`, userID); err != nil {
+			return err
+		}
+		_, err := t.Exec(ctx, `DELETE FROM decks WHERE user_id = $1 AND deleted_at IS NOT NULL`, userID)
+		return err
+	})
+}
+
+// PurgeBefore окончательно удаляет наборы и карточки, удалённые раньше before, и возвращает их количество.
+func (r *TrashRepository) PurgeBefore(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := r.db.WithTx(ctx, func(tx interface{}) error {
+		t := tx.(pgx.Tx)
+		tag, err := t.Exec(ctx, `DELETE FROM cards WHERE deleted_at < $1`, before)
+		if err != nil {
+			return err
+		}
+		n = tag.RowsAffected()
+		tag, err = t.Exec(ctx, `DELETE FROM decks WHERE deleted_at < $1`, before)
+		if err != nil {
+			return err
+		}
+		n += tag.RowsAffected()
+		return nil
+	})
+	return n, err
+}
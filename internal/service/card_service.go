@@ -12,6 +12,8 @@ import (
 var (
 	ErrCardNotFound   = errors.New("карточка не найдена")
 	ErrCardForbidden  = errors.New("нет доступа к карточке")
+	ErrBulkInvalid    = errors.New("ошибка валидации элементов")
+	ErrMergeNothing   = errors.New("укажите карточки, отличные от оставляемой")
 )
 
 type CardService struct {
@@ -48,12 +50,16 @@ func (s *CardService) Create(ctx context.Context, deckID int, userID int, req do
 		return nil, err
 	}
 	if len(req.TagIDs) > 0 {
-		_ = s.cardRepo.SetCardTags(ctx, c.ID, req.TagIDs)
-		c.Tags, _ = s.tagRepo.GetByIDs(ctx, req.TagIDs)
+		tags, tagIDs := attachableTags(ctx, s.tagRepo, userID, req.TagIDs)
+		_ = s.cardRepo.SetCardTags(ctx, c.ID, tagIDs)
+		c.Tags = tags
 	}
 	if c.CategoryID != nil {
 		c.Category, _ = s.categoryRepo.GetByID(ctx, *c.CategoryID)
 	}
+	if req.SuggestTags {
+		c.SuggestedTags, _ = suggestTags(ctx, s.tagRepo, userID, c.Question+" "+c.Answer, req.TagIDs, 0)
+	}
 	return c, nil
 }
 
@@ -68,7 +74,8 @@ func (s *CardService) GetByID(ctx context.Context, id int, userID int) (*domain.
 	}
 	tagIDs, _ := s.cardRepo.GetCardTagIDs(ctx, c.ID)
 	if len(tagIDs) > 0 {
-		c.Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+		tags, _ := s.tagRepo.GetByIDs(ctx, tagIDs)
+		c.Tags = visibleTags(tags, userID)
 	}
 	if c.CategoryID != nil {
 		c.Category, _ = s.categoryRepo.GetByID(ctx, *c.CategoryID)
@@ -88,7 +95,8 @@ func (s *CardService) ListByDeck(ctx context.Context, deckID int, userID int) ([
 	for i := range list {
 		tagIDs, _ := s.cardRepo.GetCardTagIDs(ctx, list[i].ID)
 		if len(tagIDs) > 0 {
-			list[i].Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+			tags, _ := s.tagRepo.GetByIDs(ctx, tagIDs)
+			list[i].Tags = visibleTags(tags, userID)
 		}
 		if list[i].CategoryID != nil {
 			list[i].Category, _ = s.categoryRepo.GetByID(ctx, *list[i].CategoryID)
@@ -119,8 +127,9 @@ func (s *CardService) Update(ctx context.Context, id int, userID int, req domain
 		return nil, err
 	}
 	if req.TagIDs != nil {
-		_ = s.cardRepo.SetCardTags(ctx, c.ID, req.TagIDs)
-		c.Tags, _ = s.tagRepo.GetByIDs(ctx, req.TagIDs)
+		tags, tagIDs := attachableTags(ctx, s.tagRepo, userID, req.TagIDs)
+		_ = s.cardRepo.SetCardTags(ctx, c.ID, tagIDs)
+		c.Tags = tags
 	} else {
 		tagIDs, _ := s.cardRepo.GetCardTagIDs(ctx, c.ID)
 		if len(tagIDs) > 0 {
@@ -168,6 +177,7 @@ func (s *CardService) ListByUserPaginated(ctx context.Context, userID int, page,
 			Question:  c.Question,
 			Answer:    c.Answer,
 			CreatedAt: c.CreatedAt.Format(time.RFC3339),
+			Match:     c.Match,
 		}
 		if deck != nil {
 			item.Deck = domain.DeckBrief{ID: deck.ID, Title: deck.Title}
@@ -211,3 +221,564 @@ func (s *CardService) GetByIDForAPI(ctx context.Context, id int, userID int) (*d
 	item.Tags = c.Tags
 	return &item, nil
 }
+
+// BulkCreate создаёт карточки набора одной транзакцией.
+// invalid — ошибки валидации элементов по индексу, найденные на уровне handler.
+// В режиме all_or_nothing при любой ошибке ничего не сохраняется и возвращается ErrBulkInvalid.
+// duplicates — область поиска похожих карточек, как у Create (none — не проверять).
+func (s *CardService) BulkCreate(ctx context.Context, deckID int, userID int, req domain.BulkCreateCardsRequest, invalid map[int]map[string]string, duplicates string) (*domain.BulkCardsResponse, error) {
+	if err := s.checkDeckOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	var categoryIDs, tagIDs []int
+	for _, item := range req.Cards {
+		if item.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *item.CategoryID)
+		}
+		tagIDs = append(tagIDs, item.TagIDs...)
+	}
+	refs, err := s.loadBulkRefs(ctx, userID, categoryIDs, tagIDs)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.BulkCardsResponse{Mode: bulkMode(req.Mode)}
+	cards := make([]domain.Card, 0, len(req.Cards))
+	cardTags := make([][]int, 0, len(req.Cards))
+	newTags := make([][]string, 0, len(req.Cards))
+	for i, item := range req.Cards {
+		errs := refs.check(item.CategoryID, item.TagIDs, invalid[i])
+		if len(errs) > 0 {
+			resp.Errors = append(resp.Errors, domain.BulkItemError{Index: i, Errors: errs})
+			continue
+		}
+		cards = append(cards, domain.Card{
+			DeckID:     deckID,
+			Question:   item.Question,
+			Answer:     item.Answer,
+			CategoryID: item.CategoryID,
+		})
+		cardTags = append(cardTags, item.TagIDs)
+		newTags = append(newTags, item.NewTags)
+	}
+	if len(resp.Errors) > 0 && resp.Mode == domain.BulkModeAllOrNothing {
+		return resp, ErrBulkInvalid
+	}
+	if err := s.cardRepo.CreateBatchWithTags(ctx, userID, cards, cardTags, newTags); err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		if len(newTags[i]) > 0 {
+			if err := refs.loadTags(ctx, s.tagRepo, userID, cardTags[i]); err != nil {
+				return nil, err
+			}
+		}
+		refs.fill(&cards[i], cardTags[i])
+	}
+	if err := s.markDuplicates(ctx, userID, deckID, cards, duplicates); err != nil {
+		return nil, err
+	}
+	resp.Cards = cards
+	return resp, nil
+}
+
+// BulkUpdate обновляет выбранные карточки набора одной транзакцией.
+func (s *CardService) BulkUpdate(ctx context.Context, deckID int, userID int, req domain.BulkUpdateCardsRequest, invalid map[int]map[string]string, duplicates string) (*domain.BulkCardsResponse, error) {
+	if err := s.checkDeckOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(req.Cards))
+	var categoryIDs, tagIDs []int
+	for _, item := range req.Cards {
+		ids = append(ids, item.ID)
+		if item.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *item.CategoryID)
+		}
+		tagIDs = append(tagIDs, item.TagIDs...)
+	}
+	existing, err := s.deckCardsByID(ctx, deckID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range existing {
+		if c.CategoryID != nil {
+			categoryIDs = append(categoryIDs, *c.CategoryID)
+		}
+	}
+	refs, err := s.loadBulkRefs(ctx, userID, categoryIDs, tagIDs)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.BulkCardsResponse{Mode: bulkMode(req.Mode)}
+	seen := make(map[int]bool, len(req.Cards))
+	cards := make([]domain.Card, 0, len(req.Cards))
+	cardTags := make([][]int, 0, len(req.Cards))
+	for i, item := range req.Cards {
+		errs := refs.check(item.CategoryID, item.TagIDs, invalid[i])
+		c, ok := existing[item.ID]
+		switch {
+		case !ok:
+			errs = withError(errs, "id", "карточка не найдена в наборе")
+		case seen[item.ID]:
+			errs = withError(errs, "id", "карточка указана повторно")
+		}
+		seen[item.ID] = true
+		if len(errs) > 0 {
+			resp.Errors = append(resp.Errors, domain.BulkItemError{Index: i, Errors: errs})
+			continue
+		}
+		if item.Question != nil {
+			c.Question = *item.Question
+		}
+		if item.Answer != nil {
+			c.Answer = *item.Answer
+		}
+		if item.CategoryID != nil {
+			c.CategoryID = item.CategoryID
+		}
+		cards = append(cards, c)
+		cardTags = append(cardTags, item.TagIDs)
+	}
+	if len(resp.Errors) > 0 && resp.Mode == domain.BulkModeAllOrNothing {
+		return resp, ErrBulkInvalid
+	}
+	if err := s.cardRepo.UpdateBatch(ctx, cards, cardTags); err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		if cardTags[i] == nil {
+			if cardTags[i], err = s.cardRepo.GetCardTagIDs(ctx, cards[i].ID); err != nil {
+				return nil, err
+			}
+			if err := refs.loadTags(ctx, s.tagRepo, userID, cardTags[i]); err != nil {
+				return nil, err
+			}
+		}
+		refs.fill(&cards[i], cardTags[i])
+	}
+	if err := s.markDuplicates(ctx, userID, deckID, cards, duplicates); err != nil {
+		return nil, err
+	}
+	resp.Cards = cards
+	return resp, nil
+}
+
+// BulkDelete удаляет выбранные карточки набора одним запросом.
+func (s *CardService) BulkDelete(ctx context.Context, deckID int, userID int, req domain.BulkDeleteCardsRequest) (*domain.BulkCardsResponse, error) {
+	if err := s.checkDeckOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	existing, err := s.deckCardsByID(ctx, deckID, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.BulkCardsResponse{Mode: bulkMode(req.Mode)}
+	seen := make(map[int]bool, len(req.IDs))
+	ids := make([]int, 0, len(req.IDs))
+	for i, id := range req.IDs {
+		if _, ok := existing[id]; !ok {
+			resp.Errors = append(resp.Errors, domain.BulkItemError{Index: i, Errors: map[string]string{"id": "карточка не найдена в наборе"}})
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(resp.Errors) > 0 && resp.Mode == domain.BulkModeAllOrNothing {
+		return resp, ErrBulkInvalid
+	}
+	if err := s.cardRepo.DeleteBatch(ctx, ids); err != nil {
+		return nil, err
+	}
+	resp.DeletedIDs = ids
+	return resp, nil
+}
+
+func (s *CardService) checkDeckOwner(ctx context.Context, deckID int, userID int) error {
+	deck, err := s.deckRepo.GetByID(ctx, deckID)
+	if err != nil || deck == nil || deck.UserID != userID {
+		return ErrCardForbidden
+	}
+	return nil
+}
+
+// deckCardsByID возвращает карточки набора deckID из списка ids, индексированные по ID.
+func (s *CardService) deckCardsByID(ctx context.Context, deckID int, ids []int) (map[int]domain.Card, error) {
+	list, err := s.cardRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[int]domain.Card, len(list))
+	for _, c := range list {
+		if c.DeckID == deckID {
+			m[c.ID] = c
+		}
+	}
+	return m, nil
+}
+
+// bulkRefs — категории и теги, на которые ссылаются элементы массовой операции.
+type bulkRefs struct {
+	categories map[int]*domain.Category
+	tags       map[int]domain.Tag
+}
+
+func (s *CardService) loadBulkRefs(ctx context.Context, userID int, categoryIDs []int, tagIDs []int) (*bulkRefs, error) {
+	refs := &bulkRefs{categories: make(map[int]*domain.Category), tags: make(map[int]domain.Tag)}
+	for _, id := range categoryIDs {
+		if _, ok := refs.categories[id]; ok {
+			continue
+		}
+		cat, err := s.categoryRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		refs.categories[id] = cat
+	}
+	if err := refs.loadTags(ctx, s.tagRepo, userID, tagIDs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// loadTags загружает теги, доступные пользователю userID; чужие личные теги считаются несуществующими.
+func (r *bulkRefs) loadTags(ctx context.Context, tagRepo *repository.TagRepository, userID int, ids []int) error {
+	var missing []int
+	for _, id := range ids {
+		if _, ok := r.tags[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	tags, err := tagRepo.GetVisibleByIDs(ctx, userID, missing)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		r.tags[t.ID] = t
+	}
+	return nil
+}
+
+// check дополняет errs ошибками ссылок на несуществующие категорию и теги.
+func (r *bulkRefs) check(categoryID *int, tagIDs []int, errs map[string]string) map[string]string {
+	if categoryID != nil && r.categories[*categoryID] == nil {
+		errs = withError(errs, "category_id", "категория не найдена")
+	}
+	for _, id := range tagIDs {
+		if _, ok := r.tags[id]; !ok {
+			errs = withError(errs, "tag_ids", "тег не найден")
+			break
+		}
+	}
+	return errs
+}
+
+func (r *bulkRefs) fill(c *domain.Card, tagIDs []int) {
+	if c.CategoryID != nil {
+		c.Category = r.categories[*c.CategoryID]
+	}
+	c.Tags = nil
+	seen := make(map[int]bool, len(tagIDs))
+	for _, id := range tagIDs {
+		if t, ok := r.tags[id]; ok && !seen[id] {
+			seen[id] = true
+			c.Tags = append(c.Tags, t)
+		}
+	}
+}
+
+func withError(errs map[string]string, field, msg string) map[string]string {
+	out := make(map[string]string, len(errs)+1)
+	for k, v := range errs {
+		out[k] = v
+	}
+	out[field] = msg
+	return out
+}
+
+func bulkMode(mode string) string {
+	if mode == domain.BulkModeBestEffort {
+		return mode
+	}
+	return domain.BulkModeAllOrNothing
+}
+
+// MoveCards переносит карточки пользователя в другой его набор.
+// ID карточек, теги и категория не меняются; resetProgress сбрасывает состояние повторений.
+func (s *CardService) MoveCards(ctx context.Context, userID int, cardIDs []int, targetDeckID int, resetProgress bool) (*domain.TransferCardsResponse, error) {
+	ids, err := s.checkTransfer(ctx, userID, cardIDs, targetDeckID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cardRepo.MoveToDeck(ctx, ids, targetDeckID, userID, resetProgress); err != nil {
+		return nil, err
+	}
+	cards, err := s.cardRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	s.loadDetails(ctx, cards)
+	return &domain.TransferCardsResponse{TargetDeckID: targetDeckID, Cards: cards}, nil
+}
+
+// CopyCards копирует карточки пользователя в другой его набор вместе с тегами и категорией.
+// Состояние повторений копируется, если не задан resetProgress.
+func (s *CardService) CopyCards(ctx context.Context, userID int, cardIDs []int, targetDeckID int, resetProgress bool) (*domain.TransferCardsResponse, error) {
+	ids, err := s.checkTransfer(ctx, userID, cardIDs, targetDeckID)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := s.cardRepo.CopyToDeck(ctx, ids, targetDeckID, userID, !resetProgress)
+	if err != nil {
+		return nil, err
+	}
+	s.loadDetails(ctx, cards)
+	return &domain.TransferCardsResponse{TargetDeckID: targetDeckID, Cards: cards}, nil
+}
+
+// checkTransfer проверяет, что пользователь владеет целевым набором и наборами всех карточек,
+// и возвращает ID карточек без повторов в исходном порядке.
+func (s *CardService) checkTransfer(ctx context.Context, userID int, cardIDs []int, targetDeckID int) ([]int, error) {
+	if err := s.checkDeckOwner(ctx, targetDeckID, userID); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(cardIDs))
+	seen := make(map[int]bool, len(cardIDs))
+	for _, id := range cardIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	cards, err := s.cardRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != len(ids) {
+		return nil, ErrCardNotFound
+	}
+	owned := map[int]bool{targetDeckID: true}
+	for _, c := range cards {
+		if owned[c.DeckID] {
+			continue
+		}
+		if err := s.checkDeckOwner(ctx, c.DeckID, userID); err != nil {
+			return nil, err
+		}
+		owned[c.DeckID] = true
+	}
+	return ids, nil
+}
+
+// loadDetails подгружает категории и теги карточек.
+func (s *CardService) loadDetails(ctx context.Context, cards []domain.Card) {
+	for i := range cards {
+		tagIDs, _ := s.cardRepo.GetCardTagIDs(ctx, cards[i].ID)
+		if len(tagIDs) > 0 {
+			cards[i].Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+		}
+		if cards[i].CategoryID != nil {
+			cards[i].Category, _ = s.categoryRepo.GetByID(ctx, *cards[i].CategoryID)
+		}
+	}
+}
+
+const (
+	duplicateThreshold = 0.5 // минимальная похожесть вопросов по триграммам
+	duplicateLimit     = 10  // сколько кандидатов возвращать в предупреждении
+)
+
+// FindDuplicates ищет карточки, похожие на c, в его наборе (scope = deck) или во всех наборах
+// пользователя (scope = all). Возвращает nil, если похожих карточек нет или проверка отключена.
+func (s *CardService) FindDuplicates(ctx context.Context, userID int, c *domain.Card, scope string) (*domain.DuplicateWarning, error) {
+	deckID, ok := duplicatesDeck(scope, c.DeckID)
+	if !ok {
+		return nil, nil
+	}
+	list, err := s.cardRepo.FindSimilar(ctx, userID, deckID, c.Question, c.ID, duplicateThreshold, duplicateLimit)
+	if err != nil {
+		return nil, err
+	}
+	return duplicateWarning(list), nil
+}
+
+// markDuplicates заполняет DuplicateWarning у сохранённых карточек набора deckID одним запросом;
+// карточки одной пачки находят и друг друга.
+func (s *CardService) markDuplicates(ctx context.Context, userID, deckID int, cards []domain.Card, scope string) error {
+	scopeDeck, ok := duplicatesDeck(scope, deckID)
+	if !ok || len(cards) == 0 {
+		return nil
+	}
+	found, err := s.cardRepo.FindSimilarBatch(ctx, userID, scopeDeck, cards, duplicateThreshold, duplicateLimit)
+	if err != nil {
+		return err
+	}
+	for i := range cards {
+		cards[i].DuplicateWarning = duplicateWarning(found[i])
+	}
+	return nil
+}
+
+// duplicatesDeck возвращает набор, в котором ищутся дубликаты для scope (nil — все наборы пользователя);
+// false — проверка отключена.
+func duplicatesDeck(scope string, deckID int) (*int, bool) {
+	switch scope {
+	case domain.DuplicatesNone:
+		return nil, false
+	case domain.DuplicatesAll:
+		return nil, true
+	default:
+		return &deckID, true
+	}
+}
+
+// duplicateWarning собирает предупреждение из найденных кандидатов; nil, если их нет.
+func duplicateWarning(list []repository.DuplicateCandidate) *domain.DuplicateWarning {
+	if len(list) == 0 {
+		return nil
+	}
+	w := &domain.DuplicateWarning{
+		Message:    "найдены похожие карточки",
+		Candidates: make([]domain.DuplicateCandidate, 0, len(list)),
+	}
+	for _, dc := range list {
+		w.Candidates = append(w.Candidates, domain.DuplicateCandidate{
+			ID:         dc.Card.ID,
+			Question:   dc.Card.Question,
+			Answer:     dc.Card.Answer,
+			Deck:       domain.DeckBrief{ID: dc.Card.DeckID, Title: dc.DeckTitle},
+			Similarity: dc.Similarity,
+			Exact:      dc.Exact,
+		})
+	}
+	return w
+}
+
+// DeckDuplicates группирует похожие карточки набора; при acrossDecks в группы попадают
+// и похожие карточки из других наборов пользователя.
+func (s *CardService) DeckDuplicates(ctx context.Context, deckID int, userID int, acrossDecks bool) (*domain.DeckDuplicatesResponse, error) {
+	if err := s.checkDeckOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	pairs, err := s.cardRepo.FindDuplicatePairs(ctx, deckID, acrossDecks, duplicateThreshold)
+	if err != nil {
+		return nil, err
+	}
+	// объединение пар в группы (система непересекающихся множеств)
+	parent := map[int]int{}
+	var find func(int) int
+	find = func(x int) int {
+		if p, ok := parent[x]; ok && p != x {
+			parent[x] = find(p)
+			return parent[x]
+		}
+		parent[x] = x
+		return x
+	}
+	best := map[int]float64{}
+	exact := map[int]bool{}
+	var ids []int
+	for _, p := range pairs {
+		for _, id := range []int{p.A, p.B} {
+			if _, ok := parent[id]; !ok {
+				ids = append(ids, id)
+			}
+			find(id)
+			if p.Similarity > best[id] {
+				best[id] = p.Similarity
+			}
+		}
+		parent[find(p.A)] = find(p.B)
+		if p.Exact {
+			exact[p.A], exact[p.B] = true, true
+		}
+	}
+	resp := &domain.DeckDuplicatesResponse{Groups: []domain.DuplicateGroup{}}
+	if len(ids) == 0 {
+		return resp, nil
+	}
+	cards, err := s.cardRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]domain.Card, len(cards))
+	for _, c := range cards {
+		byID[c.ID] = c
+	}
+	titles := map[int]string{}
+	groupIndex := map[int]int{}
+	for _, id := range ids {
+		c, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if _, ok := titles[c.DeckID]; !ok {
+			if d, _ := s.deckRepo.GetByID(ctx, c.DeckID); d != nil {
+				titles[c.DeckID] = d.Title
+			}
+		}
+		root := find(id)
+		gi, ok := groupIndex[root]
+		if !ok {
+			gi = len(resp.Groups)
+			groupIndex[root] = gi
+			resp.Groups = append(resp.Groups, domain.DuplicateGroup{})
+		}
+		g := &resp.Groups[gi]
+		g.Exact = g.Exact || exact[id]
+		g.Cards = append(g.Cards, domain.DuplicateCandidate{
+			ID:         c.ID,
+			Question:   c.Question,
+			Answer:     c.Answer,
+			Deck:       domain.DeckBrief{ID: c.DeckID, Title: titles[c.DeckID]},
+			Similarity: best[id],
+			Exact:      exact[id],
+		})
+	}
+	return resp, nil
+}
+
+// MergeDuplicates оставляет карточку req.KeepCardID, а остальные перемещает в корзину,
+// перенося их теги и прогресс повторений. Все карточки должны принадлежать пользователю.
+func (s *CardService) MergeDuplicates(ctx context.Context, deckID int, userID int, req domain.MergeDuplicatesRequest) (*domain.MergeDuplicatesResponse, error) {
+	if err := s.checkDeckOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	seen := map[int]bool{req.KeepCardID: true}
+	var dupIDs []int
+	for _, id := range req.CardIDs {
+		if !seen[id] {
+			seen[id] = true
+			dupIDs = append(dupIDs, id)
+		}
+	}
+	if len(dupIDs) == 0 {
+		return nil, ErrMergeNothing
+	}
+	ids := append([]int{req.KeepCardID}, dupIDs...)
+	cards, err := s.cardRepo.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(cards) != len(ids) {
+		return nil, ErrCardNotFound
+	}
+	owned := map[int]bool{deckID: true}
+	for _, c := range cards {
+		if owned[c.DeckID] {
+			continue
+		}
+		if err := s.checkDeckOwner(ctx, c.DeckID, userID); err != nil {
+			return nil, err
+		}
+		owned[c.DeckID] = true
+	}
+	if err := s.cardRepo.MergeDuplicates(ctx, req.KeepCardID, dupIDs); err != nil {
+		return nil, err
+	}
+	return &domain.MergeDuplicatesResponse{KeptCardID: req.KeepCardID, RemovedCardIDs: dupIDs}, nil
+}
@@ -3,12 +3,22 @@ package service
 import (
 	"context"
 	"errors"
+	"strconv"
+	"strings"
 
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 	"github.com/pro100kartochki/mozgoemka/internal/repository"
 )
 
-var ErrCategoryExists = errors.New("категория с таким именем уже существует")
+var (
+	ErrCategoryExists   = errors.New("категория с таким именем уже существует")
+	ErrCategoryNotFound = errors.New("категория не найдена")
+	ErrCategoryParent   = errors.New("родительская категория не найдена")
+	ErrCategoryCycle    = errors.New("нельзя перенести категорию внутрь самой себя")
+	ErrCategorySlug     = errors.New("slug должен содержать латинские буквы или цифры")
+	ErrCategorySlugUsed = errors.New("категория с таким slug уже существует")
+	ErrCategoryReassign = errors.New("нельзя перенести наборы и карточки в удаляемую категорию")
+)
 
 type CategoryService struct {
 	repo *repository.CategoryRepository
@@ -18,15 +28,24 @@ func NewCategoryService(repo *repository.CategoryRepository) *CategoryService {
 	return &CategoryService{repo: repo}
 }
 
+// Create создаёт категорию; имя уникально среди соседних категорий.
+// Без slug он строится из названия (транслитерация), при совпадении добавляется номер.
 func (s *CategoryService) Create(ctx context.Context, req domain.CreateCategoryRequest) (*domain.Category, error) {
-	existing, _ := s.repo.GetByName(ctx, req.Name)
+	if err := s.checkParent(ctx, req.ParentID); err != nil {
+		return nil, err
+	}
+	existing, _ := s.repo.GetByName(ctx, req.ParentID, req.Name)
 	if existing != nil {
 		return nil, ErrCategoryExists
 	}
-	c := &domain.Category{Name: req.Name}
-	if err := s.repo.Create(ctx, c); err != nil {
+	slug, err := s.resolveSlug(ctx, 0, req.Name, req.Slug)
+	if err != nil {
 		return nil, err
 	}
+	c := &domain.Category{Name: req.Name, Slug: slug, ParentID: req.ParentID, SortOrder: req.SortOrder, Icon: req.Icon}
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, categoryConflict(err)
+	}
 	return c, nil
 }
 
@@ -34,6 +53,236 @@ func (s *CategoryService) List(ctx context.Context) ([]domain.Category, error) {
 	return s.repo.List(ctx)
 }
 
+// ListTree возвращает категории деревом; соседние категории упорядочены по sort_order и имени.
+func (s *CategoryService) ListTree(ctx context.Context) (*domain.CategoryTreeResponse, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]domain.Category, len(list))
+	for _, c := range list {
+		byID[c.ID] = c
+	}
+	children := make(map[int][]int)
+	var roots []int
+	for _, c := range list {
+		if c.ParentID != nil {
+			if _, ok := byID[*c.ParentID]; ok {
+				children[*c.ParentID] = append(children[*c.ParentID], c.ID)
+				continue
+			}
+		}
+		roots = append(roots, c.ID)
+	}
+	var build func(id int) domain.CategoryTreeNode
+	build = func(id int) domain.CategoryTreeNode {
+		node := domain.CategoryTreeNode{Category: byID[id], Children: []domain.CategoryTreeNode{}}
+		for _, childID := range children[id] {
+			node.Children = append(node.Children, build(childID))
+		}
+		return node
+	}
+	resp := &domain.CategoryTreeResponse{Categories: make([]domain.CategoryTreeNode, 0, len(roots))}
+	for _, id := range roots {
+		resp.Categories = append(resp.Categories, build(id))
+	}
+	return resp, nil
+}
+
 func (s *CategoryService) GetByID(ctx context.Context, id int) (*domain.Category, error) {
 	return s.repo.GetByID(ctx, id)
 }
+
+// Update переименовывает категорию и меняет slug, порядок и иконку.
+func (s *CategoryService) Update(ctx context.Context, id int, req domain.UpdateCategoryRequest) (*domain.Category, error) {
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil || c == nil {
+		return nil, ErrCategoryNotFound
+	}
+	if req.Name != nil && *req.Name != c.Name {
+		if existing, _ := s.repo.GetByName(ctx, c.ParentID, *req.Name); existing != nil {
+			return nil, ErrCategoryExists
+		}
+		c.Name = *req.Name
+	}
+	if req.Slug != nil {
+		slug, err := s.resolveSlug(ctx, c.ID, c.Name, *req.Slug)
+		if err != nil {
+			return nil, err
+		}
+		c.Slug = slug
+	}
+	if req.SortOrder != nil {
+		c.SortOrder = *req.SortOrder
+	}
+	if req.Icon != nil {
+		c.Icon = *req.Icon
+	}
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, categoryConflict(err)
+	}
+	return c, nil
+}
+
+// Move переносит категорию вместе с подкатегориями в parentID (nil — на верхний уровень).
+func (s *CategoryService) Move(ctx context.Context, id int, req domain.MoveCategoryRequest) (*domain.Category, error) {
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil || c == nil {
+		return nil, ErrCategoryNotFound
+	}
+	if err := s.checkParent(ctx, req.ParentID); err != nil {
+		return nil, err
+	}
+	if existing, _ := s.repo.GetByName(ctx, req.ParentID, c.Name); existing != nil && existing.ID != id {
+		return nil, ErrCategoryExists
+	}
+	c.ParentID = req.ParentID
+	if req.SortOrder != nil {
+		c.SortOrder = *req.SortOrder
+	}
+	ok, err := s.repo.Move(ctx, c)
+	if err != nil {
+		return nil, categoryConflict(err)
+	}
+	if !ok {
+		return nil, ErrCategoryCycle
+	}
+	return c, nil
+}
+
+// categoryConflict превращает нарушение уникальности имени или slug (параллельный запрос занял
+// их после проверки) в ErrCategoryExists или ErrCategorySlugUsed.
+func categoryConflict(err error) error {
+	switch repository.UniqueViolation(err) {
+	case "":
+		return err
+	case "idx_categories_slug":
+		return ErrCategorySlugUsed
+	default:
+		return ErrCategoryExists
+	}
+}
+
+// Delete удаляет категорию. Подкатегории переходят в родительскую категорию, наборы и карточки —
+// в reassignTo, а без него — тоже в родительскую (у категории верхнего уровня — остаются без категории).
+func (s *CategoryService) Delete(ctx context.Context, id int, reassignTo *int) (*domain.DeleteCategoryResponse, error) {
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil || c == nil {
+		return nil, ErrCategoryNotFound
+	}
+	target := c.ParentID
+	if reassignTo != nil {
+		if *reassignTo == id {
+			return nil, ErrCategoryReassign
+		}
+		t, err := s.repo.GetByID(ctx, *reassignTo)
+		if err != nil || t == nil {
+			return nil, ErrCategoryNotFound
+		}
+		target = reassignTo
+	}
+	for _, child := range s.children(ctx, id) {
+		if existing, _ := s.repo.GetByName(ctx, c.ParentID, child.Name); existing != nil && existing.ID != id {
+			return nil, ErrCategoryExists
+		}
+	}
+	res, err := s.repo.Delete(ctx, id, c.ParentID, target)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.DeleteCategoryResponse{
+		ReassignedTo:    target,
+		DecksReassigned: res.Decks,
+		CardsReassigned: res.Cards,
+		ChildrenMoved:   res.Children,
+	}, nil
+}
+
+func (s *CategoryService) children(ctx context.Context, id int) []domain.Category {
+	list, _ := s.repo.List(ctx)
+	var out []domain.Category
+	for _, c := range list {
+		if c.ParentID != nil && *c.ParentID == id {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (s *CategoryService) checkParent(ctx context.Context, parentID *int) error {
+	if parentID == nil {
+		return nil
+	}
+	p, err := s.repo.GetByID(ctx, *parentID)
+	if err != nil || p == nil {
+		return ErrCategoryParent
+	}
+	return nil
+}
+
+// resolveSlug нормализует явно заданный slug (занятый — ошибка) или строит свободный slug из названия.
+// selfID — категория, которой slug уже может принадлежать (0 при создании).
+func (s *CategoryService) resolveSlug(ctx context.Context, selfID int, name, requested string) (string, error) {
+	if requested != "" {
+		slug := slugify(requested)
+		if slug == "" {
+			return "", ErrCategorySlug
+		}
+		if existing, _ := s.repo.GetBySlug(ctx, slug); existing != nil && existing.ID != selfID {
+			return "", ErrCategorySlugUsed
+		}
+		return slug, nil
+	}
+	base := slugify(name)
+	if base == "" {
+		base = "category"
+	}
+	slug := base
+	for n := 2; ; n++ {
+		existing, err := s.repo.GetBySlug(ctx, slug)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil || existing.ID == selfID {
+			return slug, nil
+		}
+		slug = base + "-" + strconv.Itoa(n)
+	}
+}
+
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "j", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// slugify переводит строку в slug: транслитерация кириллицы, нижний регистр, остальное — дефисы.
+// Правила совпадают с заполнением slug в миграции 012.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		part, ok := translit[r]
+		switch {
+		case ok:
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			part = string(r)
+		default:
+			dash = b.Len() > 0
+			continue
+		}
+		if part == "" {
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(part)
+	}
+	if b.Len() > 120 {
+		return strings.TrimRight(b.String()[:120], "-")
+	}
+	return b.String()
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
@@ -12,6 +14,12 @@ import (
 var (
 	ErrDeckNotFound  = errors.New("набор не найден")
 	ErrDeckForbidden = errors.New("нет доступа к набору")
+	ErrNoUpstream    = errors.New("исходный набор недоступен")
+	ErrParentDeck    = errors.New("родительский набор не найден")
+	ErrDeckCycle     = errors.New("нельзя вложить набор в самого себя или во вложенный в него набор")
+	ErrMergeSelf     = errors.New("укажите наборы, отличные от целевого")
+	ErrMergeAncestor = errors.New("нельзя объединить набор с родительским набором")
+	ErrSplitEmpty    = errors.New("нет карточек с подходящими тегами или категориями")
 )
 
 type DeckService struct {
@@ -39,14 +47,21 @@ func (s *DeckService) Create(ctx context.Context, userID int, req domain.CreateD
 		Description: req.Description,
 		CategoryID:  req.CategoryID,
 		IsPublic:    req.IsPublic,
+		ParentID:    req.ParentID,
 		CardsCount:  0,
 	}
+	if req.ParentID != nil {
+		if err := s.checkParent(ctx, *req.ParentID, userID); err != nil {
+			return nil, err
+		}
+	}
 	if err := s.deckRepo.Create(ctx, d); err != nil {
 		return nil, err
 	}
 	if len(req.TagIDs) > 0 {
-		_ = s.deckRepo.SetDeckTags(ctx, d.ID, req.TagIDs)
-		d.Tags, _ = s.tagRepo.GetByIDs(ctx, req.TagIDs)
+		tags, tagIDs := attachableTags(ctx, s.tagRepo, userID, req.TagIDs)
+		_ = s.deckRepo.SetDeckTags(ctx, d.ID, tagIDs)
+		d.Tags = tags
 	}
 	if d.CategoryID != nil {
 		d.Category, _ = s.categoryRepo.GetByID(ctx, *d.CategoryID)
@@ -64,12 +79,14 @@ func (s *DeckService) GetByID(ctx context.Context, id int, userID int) (*domain.
 	}
 	tagIDs, _ := s.deckRepo.GetDeckTagIDs(ctx, d.ID)
 	if len(tagIDs) > 0 {
-		d.Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+		tags, _ := s.tagRepo.GetByIDs(ctx, tagIDs)
+		d.Tags = visibleTags(tags, userID)
 	}
 	if d.CategoryID != nil {
 		d.Category, _ = s.categoryRepo.GetByID(ctx, *d.CategoryID)
 	}
 	d.CardsCount, _ = s.cardRepo.CountByDeckID(ctx, d.ID)
+	d.TotalCardsCount, _ = s.deckRepo.CountTreeCards(ctx, d.ID)
 	cards, _ := s.cardRepo.ListByDeckID(ctx, d.ID)
 	for i := range cards {
 		if cards[i].CategoryID != nil {
@@ -77,7 +94,8 @@ func (s *DeckService) GetByID(ctx context.Context, id int, userID int) (*domain.
 		}
 		tagIDs, _ := s.cardRepo.GetCardTagIDs(ctx, cards[i].ID)
 		if len(tagIDs) > 0 {
-			cards[i].Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+			tags, _ := s.tagRepo.GetByIDs(ctx, tagIDs)
+			cards[i].Tags = visibleTags(tags, userID)
 		}
 	}
 	d.Cards = cards
@@ -103,14 +121,22 @@ func (s *DeckService) ListByUser(ctx context.Context, userID int) ([]domain.Deck
 }
 
 // ListByUserPaginated возвращает наборы с пагинацией и фильтрами.
-func (s *DeckService) ListByUserPaginated(ctx context.Context, userID int, page, limit int, categoryID *int, search string) (*domain.DecksListResponse, error) {
+func (s *DeckService) ListByUserPaginated(ctx context.Context, userID int, page, limit int, categoryID, tagID *int, search string) (*domain.DecksListResponse, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 	if page <= 0 {
 		page = 1
 	}
-	list, total, err := s.deckRepo.ListByUserIDWithFilters(ctx, userID, page, limit, categoryID, search)
+	list, total, err := s.deckRepo.ListByUserIDWithFilters(ctx, userID, page, limit, categoryID, tagID, search)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(list))
+	for i, d := range list {
+		ids[i] = d.ID
+	}
+	own, treeCounts, err := s.deckRepo.CountCardsWithSubtrees(ctx, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -123,16 +149,18 @@ func (s *DeckService) ListByUserPaginated(ctx context.Context, userID int, page,
 		if d.CategoryID != nil {
 			d.Category, _ = s.categoryRepo.GetByID(ctx, *d.CategoryID)
 		}
-		cnt, _ := s.cardRepo.CountByDeckID(ctx, d.ID)
 		items = append(items, domain.DeckListItem{
-			ID:          d.ID,
-			Title:       d.Title,
-			Description: d.Description,
-			Category:    d.Category,
-			Tags:        d.Tags,
-			IsPublic:    d.IsPublic,
-			CardsCount:  cnt,
-			CreatedAt:   d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:              d.ID,
+			Title:           d.Title,
+			Description:     d.Description,
+			Category:        d.Category,
+			Tags:            d.Tags,
+			IsPublic:        d.IsPublic,
+			ParentID:        d.ParentID,
+			CardsCount:      own[d.ID],
+			TotalCardsCount: treeCounts[d.ID],
+			CreatedAt:       d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			Match:           d.Match,
 		})
 	}
 	return &domain.DecksListResponse{
@@ -145,6 +173,102 @@ func (s *DeckService) ListByUserPaginated(ctx context.Context, userID int, page,
 	}, nil
 }
 
+// ListTree возвращает все наборы пользователя деревом: вложенные наборы — в children родителя.
+// total_cards_count узла включает карточки всех вложенных наборов.
+func (s *DeckService) ListTree(ctx context.Context, userID int) (*domain.DeckTreeResponse, error) {
+	list, err := s.deckRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.deckRepo.CountCardsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[int]domain.DeckListItem, len(list))
+	children := make(map[int][]int)
+	var roots []int
+	for _, d := range list {
+		item := domain.DeckListItem{
+			ID:          d.ID,
+			Title:       d.Title,
+			Description: d.Description,
+			IsPublic:    d.IsPublic,
+			ParentID:    d.ParentID,
+			CardsCount:  counts[d.ID],
+			CreatedAt:   d.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+		tagIDs, _ := s.deckRepo.GetDeckTagIDs(ctx, d.ID)
+		if len(tagIDs) > 0 {
+			item.Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+		}
+		if d.CategoryID != nil {
+			item.Category, _ = s.categoryRepo.GetByID(ctx, *d.CategoryID)
+		}
+		items[d.ID] = item
+	}
+	for _, d := range list {
+		if d.ParentID != nil {
+			if _, ok := items[*d.ParentID]; ok {
+				children[*d.ParentID] = append(children[*d.ParentID], d.ID)
+				continue
+			}
+		}
+		roots = append(roots, d.ID)
+	}
+	var build func(id int) domain.DeckTreeNode
+	build = func(id int) domain.DeckTreeNode {
+		node := domain.DeckTreeNode{DeckListItem: items[id], Children: []domain.DeckTreeNode{}}
+		node.TotalCardsCount = node.CardsCount
+		for _, childID := range children[id] {
+			child := build(childID)
+			node.TotalCardsCount += child.TotalCardsCount
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+	resp := &domain.DeckTreeResponse{Decks: make([]domain.DeckTreeNode, 0, len(roots))}
+	for _, id := range roots {
+		resp.Decks = append(resp.Decks, build(id))
+	}
+	return resp, nil
+}
+
+// Move делает набор вложенным в parentID (nil — переносит на верхний уровень).
+func (s *DeckService) Move(ctx context.Context, id int, userID int, parentID *int) (*domain.Deck, error) {
+	d, err := s.deckRepo.GetByID(ctx, id)
+	if err != nil || d == nil {
+		return nil, ErrDeckNotFound
+	}
+	if d.UserID != userID {
+		return nil, ErrDeckForbidden
+	}
+	if parentID != nil {
+		if *parentID == id {
+			return nil, ErrDeckCycle
+		}
+		if err := s.checkParent(ctx, *parentID, userID); err != nil {
+			return nil, err
+		}
+	}
+	ok, err := s.deckRepo.SetParent(ctx, id, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeckCycle
+	}
+	return s.GetByID(ctx, id, userID)
+}
+
+// checkParent проверяет, что родительский набор существует и принадлежит пользователю.
+func (s *DeckService) checkParent(ctx context.Context, parentID int, userID int) error {
+	p, err := s.deckRepo.GetByID(ctx, parentID)
+	if err != nil || p == nil || p.UserID != userID {
+		return ErrParentDeck
+	}
+	return nil
+}
+
 func (s *DeckService) ListPublic(ctx context.Context, limit, offset int) ([]domain.Deck, error) {
 	if limit <= 0 {
 		limit = 20
@@ -156,7 +280,8 @@ func (s *DeckService) ListPublic(ctx context.Context, limit, offset int) ([]doma
 	for i := range list {
 		tagIDs, _ := s.deckRepo.GetDeckTagIDs(ctx, list[i].ID)
 		if len(tagIDs) > 0 {
-			list[i].Tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+			tags, _ := s.tagRepo.GetByIDs(ctx, tagIDs)
+			list[i].Tags = visibleTags(tags, 0)
 		}
 		if list[i].CategoryID != nil {
 			list[i].Category, _ = s.categoryRepo.GetByID(ctx, *list[i].CategoryID)
@@ -190,8 +315,9 @@ func (s *DeckService) Update(ctx context.Context, id int, userID int, req domain
 		return nil, err
 	}
 	if req.TagIDs != nil {
-		_ = s.deckRepo.SetDeckTags(ctx, d.ID, req.TagIDs)
-		d.Tags, _ = s.tagRepo.GetByIDs(ctx, req.TagIDs)
+		tags, tagIDs := attachableTags(ctx, s.tagRepo, userID, req.TagIDs)
+		_ = s.deckRepo.SetDeckTags(ctx, d.ID, tagIDs)
+		d.Tags = tags
 	} else {
 		tagIDs, _ := s.deckRepo.GetDeckTagIDs(ctx, d.ID)
 		if len(tagIDs) > 0 {
@@ -217,7 +343,7 @@ func (s *DeckService) Delete(ctx context.Context, id int, userID int) error {
 }
 
 // ListPublicPaginated — публичные наборы с пагинацией, фильтрами, сортировкой и автором.
-func (s *DeckService) ListPublicPaginated(ctx context.Context, page, limit int, categoryID *int, search string, sortBy string) (*domain.PublicDecksListResponse, error) {
+func (s *DeckService) ListPublicPaginated(ctx context.Context, page, limit int, categoryID, tagID *int, search string, sortBy string) (*domain.PublicDecksListResponse, error) {
 	if limit > 100 {
 		limit = 100
 	}
@@ -227,7 +353,7 @@ func (s *DeckService) ListPublicPaginated(ctx context.Context, page, limit int,
 	if page <= 0 {
 		page = 1
 	}
-	list, total, err := s.deckRepo.ListPublicWithFilters(ctx, page, limit, categoryID, search, sortBy)
+	list, total, err := s.deckRepo.ListPublicWithFilters(ctx, page, limit, categoryID, tagID, search, sortBy)
 	if err != nil {
 		return nil, err
 	}
@@ -243,6 +369,7 @@ func (s *DeckService) ListPublicPaginated(ctx context.Context, page, limit int,
 		var tags []domain.Tag
 		if len(tagIDs) > 0 {
 			tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+			tags = visibleTags(tags, 0)
 		}
 		var cat *domain.Category
 		if d.CategoryID != nil {
@@ -255,8 +382,10 @@ func (s *DeckService) ListPublicPaginated(ctx context.Context, page, limit int,
 			Category:    cat,
 			Tags:        tags,
 			CardsCount:  d.CardsCount,
+			ForksCount:  d.ForksCount,
 			Author:      author,
 			CreatedAt:   d.CreatedAt.Format(time.RFC3339),
+			Match:       d.Match,
 		})
 	}
 	return &domain.PublicDecksListResponse{
@@ -285,12 +414,14 @@ func (s *DeckService) GetPublicByID(ctx context.Context, id int) (*domain.Public
 	var tags []domain.Tag
 	if len(tagIDs) > 0 {
 		tags, _ = s.tagRepo.GetByIDs(ctx, tagIDs)
+		tags = visibleTags(tags, 0)
 	}
 	var cat *domain.Category
 	if d.CategoryID != nil {
 		cat, _ = s.categoryRepo.GetByID(ctx, *d.CategoryID)
 	}
 	cardsCount, _ := s.cardRepo.CountByDeckID(ctx, d.ID)
+	forksCount, _ := s.deckRepo.CountForks(ctx, d.ID)
 	cards, _ := s.cardRepo.ListByDeckID(ctx, d.ID)
 	publicCards := make([]domain.PublicCardItem, 0, len(cards))
 	for _, c := range cards {
@@ -303,7 +434,406 @@ func (s *DeckService) GetPublicByID(ctx context.Context, id int) (*domain.Public
 		Category:    cat,
 		Tags:        tags,
 		CardsCount:  cardsCount,
+		ForksCount:  forksCount,
+		Version:     d.Version,
 		Author:      author,
 		Cards:       publicCards,
 	}, nil
 }
+
+// Fork копирует публичный набор со всеми карточками и тегами в аккаунт пользователя.
+// Форк создаётся приватным и хранит ID и версию исходного набора для атрибуции.
+func (s *DeckService) Fork(ctx context.Context, id int, userID int) (*domain.Deck, error) {
+	src, err := s.deckRepo.GetByID(ctx, id)
+	if err != nil || src == nil || !src.IsPublic {
+		return nil, ErrDeckNotFound
+	}
+	d, err := s.deckRepo.Fork(ctx, src.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, d.ID, userID)
+}
+
+// UpstreamDiff сравнивает форк с исходным набором. Карточки сопоставляются по ID исходной карточки;
+// изменения с обеих сторон определяются относительно базы последней синхронизации. Исходные
+// карточки, которые пользователь удалил из форка или перенёс в другой набор, не предлагаются снова.
+func (s *DeckService) UpstreamDiff(ctx context.Context, id int, userID int) (*domain.UpstreamDiffResponse, error) {
+	fork, upstream, err := s.forkWithUpstream(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.deckRepo.UpstreamState(ctx, fork.ID, upstream.ID)
+	if err != nil {
+		return nil, err
+	}
+	return upstreamDiff(upstream.ID, st), nil
+}
+
+// ApplyUpstream принимает выбранные изменения исходного набора. Карточки форка обновляются на месте,
+// поэтому прогресс изучения сохраняется; не выбранные изменения и правки форка не трогаются.
+// Отличия пересчитываются в транзакции применения. Возвращает оставшиеся отличия.
+func (s *DeckService) ApplyUpstream(ctx context.Context, id int, userID int, req domain.ApplyUpstreamRequest) (*domain.UpstreamDiffResponse, error) {
+	fork, upstream, err := s.forkWithUpstream(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	apply := make(map[int]bool, len(req.Apply))
+	for _, id := range req.Apply {
+		apply[id] = true
+	}
+	keep := make(map[int]bool, len(req.KeepMine))
+	for _, id := range req.KeepMine {
+		keep[id] = true
+	}
+	err = s.deckRepo.ApplyUpstream(ctx, fork.ID, upstream.ID, func(st *repository.UpstreamState) repository.UpstreamChanges {
+		return upstreamChanges(upstreamDiff(upstream.ID, st), apply, keep)
+	})
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.deckRepo.UpstreamState(ctx, fork.ID, upstream.ID)
+	if err != nil {
+		return nil, err
+	}
+	return upstreamDiff(upstream.ID, st), nil
+}
+
+// upstreamChanges выбирает из diff изменения, которые пользователь принял (apply) или
+// для которых оставил свою версию (keep).
+func upstreamChanges(diff *domain.UpstreamDiffResponse, apply, keep map[int]bool) repository.UpstreamChanges {
+	var ch repository.UpstreamChanges
+	for _, c := range diff.Added {
+		if apply[c.OriginCardID] {
+			ch.Add = append(ch.Add, c.OriginCardID)
+		}
+	}
+	for _, c := range diff.Changed {
+		if apply[c.OriginCardID] {
+			ch.Update = append(ch.Update, *c.CardID)
+		}
+	}
+	for _, c := range diff.Removed {
+		if apply[c.OriginCardID] {
+			ch.Delete = append(ch.Delete, *c.CardID)
+		}
+	}
+	for _, c := range diff.Conflicts {
+		switch {
+		case apply[c.OriginCardID] && c.Theirs == nil:
+			ch.Delete = append(ch.Delete, *c.CardID)
+		case apply[c.OriginCardID]:
+			ch.Update = append(ch.Update, *c.CardID)
+		case keep[c.OriginCardID] && c.Theirs == nil:
+			ch.Detach = append(ch.Detach, *c.CardID)
+		case keep[c.OriginCardID]:
+			ch.Rebase = append(ch.Rebase, *c.CardID)
+		}
+	}
+	return ch
+}
+
+// forkWithUpstream возвращает форк пользователя и доступный ему исходный набор.
+func (s *DeckService) forkWithUpstream(ctx context.Context, id int, userID int) (*domain.Deck, *domain.Deck, error) {
+	fork, err := s.deckRepo.GetByID(ctx, id)
+	if err != nil || fork == nil {
+		return nil, nil, ErrDeckNotFound
+	}
+	if fork.UserID != userID {
+		return nil, nil, ErrDeckForbidden
+	}
+	if fork.ForkedFromDeckID == nil {
+		return nil, nil, ErrNoUpstream
+	}
+	upstream, err := s.deckRepo.GetByID(ctx, *fork.ForkedFromDeckID)
+	if err != nil || upstream == nil || (!upstream.IsPublic && upstream.UserID != userID) {
+		return nil, nil, ErrNoUpstream
+	}
+	return fork, upstream, nil
+}
+
+func upstreamDiff(upstreamID int, st *repository.UpstreamState) *domain.UpstreamDiffResponse {
+	resp := &domain.UpstreamDiffResponse{
+		UpstreamDeckID:  upstreamID,
+		UpstreamVersion: st.UpstreamVersion,
+		Added:           []domain.UpstreamCardChange{},
+		Changed:         []domain.UpstreamCardChange{},
+		Removed:         []domain.UpstreamCardChange{},
+		Conflicts:       []domain.UpstreamCardChange{},
+	}
+	if st.ForkedFromVersion != nil {
+		resp.ForkedFromVersion = *st.ForkedFromVersion
+	}
+	mine := st.Mine
+	mineByOrigin := make(map[int]domain.ForkCard, len(mine))
+	for _, m := range mine {
+		mineByOrigin[*m.OriginCardID] = m
+	}
+	inUpstream := make(map[int]bool, len(st.Theirs))
+	for _, t := range st.Theirs {
+		inUpstream[t.ID] = true
+		theirsContent := cardContent(t)
+		m, ok := mineByOrigin[t.ID]
+		if !ok {
+			if !st.Dismissed[t.ID] {
+				resp.Added = append(resp.Added, domain.UpstreamCardChange{OriginCardID: t.ID, Theirs: &theirsContent})
+			}
+			continue
+		}
+		base, mineContent := m.Base, cardContent(m.Card)
+		if sameContent(base, theirsContent) {
+			continue
+		}
+		change := domain.UpstreamCardChange{OriginCardID: t.ID, CardID: &m.ID, Base: &base, Mine: &mineContent, Theirs: &theirsContent}
+		if sameContent(base, mineContent) || sameContent(mineContent, theirsContent) {
+			resp.Changed = append(resp.Changed, change)
+		} else {
+			resp.Conflicts = append(resp.Conflicts, change)
+		}
+	}
+	for i := range mine {
+		m := &mine[i]
+		if inUpstream[*m.OriginCardID] {
+			continue
+		}
+		base, mineContent := m.Base, cardContent(m.Card)
+		change := domain.UpstreamCardChange{OriginCardID: *m.OriginCardID, CardID: &m.ID, Base: &base, Mine: &mineContent}
+		if sameContent(base, mineContent) {
+			resp.Removed = append(resp.Removed, change)
+		} else {
+			resp.Conflicts = append(resp.Conflicts, change)
+		}
+	}
+	return resp
+}
+
+func cardContent(c domain.Card) domain.CardContent {
+	return domain.CardContent{Question: c.Question, Answer: c.Answer, CategoryID: c.CategoryID}
+}
+
+func sameContent(a, b domain.CardContent) bool {
+	if a.Question != b.Question || a.Answer != b.Answer {
+		return false
+	}
+	if a.CategoryID == nil || b.CategoryID == nil {
+		return a.CategoryID == nil && b.CategoryID == nil
+	}
+	return *a.CategoryID == *b.CategoryID
+}
+
+// Merge объединяет наборы req.SourceDeckIDs с набором targetID одной транзакцией.
+// Одинаковые карточки (вопрос и ответ без учёта регистра и пробелов) не дублируются.
+func (s *DeckService) Merge(ctx context.Context, targetID int, userID int, req domain.MergeDecksRequest) (*domain.MergeDecksResponse, error) {
+	target, err := s.deckRepo.GetByID(ctx, targetID)
+	if err != nil || target == nil {
+		return nil, ErrDeckNotFound
+	}
+	if target.UserID != userID {
+		return nil, ErrDeckForbidden
+	}
+	seen := map[int]bool{targetID: true}
+	var sourceIDs []int
+	for _, id := range req.SourceDeckIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		src, err := s.deckRepo.GetByID(ctx, id)
+		if err != nil || src == nil {
+			return nil, ErrDeckNotFound
+		}
+		if src.UserID != userID {
+			return nil, ErrDeckForbidden
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	if len(sourceIDs) == 0 {
+		return nil, ErrMergeSelf
+	}
+	if err := s.checkNotAncestor(ctx, target, sourceIDs); err != nil {
+		return nil, err
+	}
+	targetCards, err := s.cardRepo.ListByDeckID(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]int, len(targetCards))
+	for _, c := range targetCards {
+		keys[cardKey(c.Question, c.Answer)] = c.ID
+	}
+	resp := &domain.MergeDecksResponse{
+		Deck:           domain.DeckBrief{ID: target.ID, Title: target.Title},
+		Moved:          []domain.CardMove{},
+		Duplicates:     []domain.MergedDuplicate{},
+		TagsAdded:      []domain.Tag{},
+		DeletedDeckIDs: []int{},
+	}
+	ch := repository.MergeChanges{Duplicates: map[int]int{}, DeleteSources: !req.KeepSources}
+	for _, srcID := range sourceIDs {
+		cards, err := s.cardRepo.ListByDeckID(ctx, srcID)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cards {
+			key := cardKey(c.Question, c.Answer)
+			if kept, ok := keys[key]; ok {
+				ch.Duplicates[c.ID] = kept
+				resp.Duplicates = append(resp.Duplicates, domain.MergedDuplicate{CardID: c.ID, FromDeckID: srcID, KeptCardID: kept})
+				continue
+			}
+			keys[key] = c.ID
+			ch.Move = append(ch.Move, c.ID)
+			resp.Moved = append(resp.Moved, domain.CardMove{CardID: c.ID, FromDeckID: srcID, ToDeckID: targetID})
+		}
+	}
+	added, err := s.deckRepo.Merge(ctx, targetID, sourceIDs, ch)
+	if err != nil {
+		return nil, err
+	}
+	if len(added) > 0 {
+		resp.TagsAdded, _ = s.tagRepo.GetByIDs(ctx, added)
+	}
+	if ch.DeleteSources {
+		resp.DeletedDeckIDs = sourceIDs
+	}
+	return resp, nil
+}
+
+// checkNotAncestor запрещает объединять набор с его родительскими наборами:
+// иначе целевой набор оказался бы вложен в удаляемый.
+func (s *DeckService) checkNotAncestor(ctx context.Context, target *domain.Deck, sourceIDs []int) error {
+	sources := make(map[int]bool, len(sourceIDs))
+	for _, id := range sourceIDs {
+		sources[id] = true
+	}
+	visited := map[int]bool{target.ID: true}
+	for p := target.ParentID; p != nil && !visited[*p]; {
+		if sources[*p] {
+			return ErrMergeAncestor
+		}
+		visited[*p] = true
+		d, err := s.deckRepo.GetByID(ctx, *p)
+		if err != nil || d == nil {
+			return nil
+		}
+		p = d.ParentID
+	}
+	return nil
+}
+
+// Split раскладывает карточки набора по новым наборам — по тегам или категориям — одной транзакцией.
+// Карточка с несколькими тегами попадает в набор первого подходящего тега; карточки без
+// подходящего тега или категории остаются в исходном наборе.
+func (s *DeckService) Split(ctx context.Context, id int, userID int, req domain.SplitDeckRequest) (*domain.SplitDeckResponse, error) {
+	d, err := s.deckRepo.GetByID(ctx, id)
+	if err != nil || d == nil {
+		return nil, ErrDeckNotFound
+	}
+	if d.UserID != userID {
+		return nil, ErrDeckForbidden
+	}
+	cards, err := s.cardRepo.ListByDeckID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deckTags, _ := s.deckRepo.GetDeckTagIDs(ctx, id)
+	parentID := d.ParentID
+	if req.SubDecks {
+		parentID = &d.ID
+	}
+	newDeck := func(suffix string, categoryID *int) *domain.Deck {
+		return &domain.Deck{
+			UserID:      userID,
+			Title:       d.Title + " — " + suffix,
+			Description: d.Description,
+			CategoryID:  categoryID,
+			ParentID:    parentID,
+		}
+	}
+	var groups []repository.SplitGroup
+	resp := &domain.SplitDeckResponse{SourceDeckID: id, Decks: []domain.SplitDeckItem{}}
+	switch req.By {
+	case domain.SplitByCategory:
+		byCategory := map[int][]int{}
+		var order []int
+		for _, c := range cards {
+			if c.CategoryID == nil {
+				continue
+			}
+			if _, ok := byCategory[*c.CategoryID]; !ok {
+				order = append(order, *c.CategoryID)
+			}
+			byCategory[*c.CategoryID] = append(byCategory[*c.CategoryID], c.ID)
+		}
+		for _, catID := range order {
+			cat, _ := s.categoryRepo.GetByID(ctx, catID)
+			if cat == nil {
+				continue
+			}
+			catID := catID
+			groups = append(groups, repository.SplitGroup{Deck: newDeck(cat.Name, &catID), TagIDs: deckTags, CardIDs: byCategory[catID]})
+			resp.Decks = append(resp.Decks, domain.SplitDeckItem{CategoryID: &catID, CardIDs: byCategory[catID]})
+		}
+	case domain.SplitByTag:
+		cardTags := make(map[int]map[int]bool, len(cards))
+		used := map[int]bool{}
+		for _, c := range cards {
+			ids, _ := s.cardRepo.GetCardTagIDs(ctx, c.ID)
+			cardTags[c.ID] = map[int]bool{}
+			for _, t := range ids {
+				cardTags[c.ID][t] = true
+				used[t] = true
+			}
+		}
+		order := req.TagIDs
+		if len(order) == 0 {
+			for t := range used {
+				order = append(order, t)
+			}
+		}
+		tags, _ := s.tagRepo.GetVisibleByIDs(ctx, userID, order)
+		names := make(map[int]string, len(tags))
+		for _, t := range tags {
+			names[t.ID] = t.Name
+		}
+		if len(req.TagIDs) == 0 {
+			sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+		}
+		byTag := map[int][]int{}
+		for _, c := range cards {
+			for _, t := range order {
+				if cardTags[c.ID][t] {
+					byTag[t] = append(byTag[t], c.ID)
+					break
+				}
+			}
+		}
+		for _, t := range order {
+			if len(byTag[t]) == 0 || names[t] == "" {
+				continue
+			}
+			t := t
+			groups = append(groups, repository.SplitGroup{Deck: newDeck(names[t], d.CategoryID), TagIDs: append(append([]int{}, deckTags...), t), CardIDs: byTag[t]})
+			resp.Decks = append(resp.Decks, domain.SplitDeckItem{TagID: &t, CardIDs: byTag[t]})
+		}
+	}
+	if len(groups) == 0 {
+		return nil, ErrSplitEmpty
+	}
+	if err := s.deckRepo.Split(ctx, id, groups); err != nil {
+		return nil, err
+	}
+	moved := 0
+	for i, g := range groups {
+		resp.Decks[i].Deck = domain.DeckBrief{ID: g.Deck.ID, Title: g.Deck.Title}
+		moved += len(g.CardIDs)
+	}
+	resp.RemainingCards = len(cards) - moved
+	return resp, nil
+}
+
+// cardKey — ключ сравнения карточек: вопрос и ответ без учёта регистра и лишних пробелов.
+func cardKey(question, answer string) string {
+	norm := func(v string) string { return strings.ToLower(strings.Join(strings.Fields(v), " ")) }
+	return norm(question) + "\x00" + norm(answer)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+// deckExportJob — параметры фоновой выгрузки набора; Print задан для формата pdf.
+type deckExportJob struct {
+	DeckID     int                  `json:"deck_id"`
+	Format     string               `json:"format"`
+	Scheduling bool                 `json:"scheduling,omitempty"`
+	Print      *domain.PrintOptions `json:"print,omitempty"`
+}
+
+// RegisterJobs регистрирует в jobs фоновую выгрузку наборов. Её можно безопасно повторить:
+// файл пишется заново.
+func (s *ExportService) RegisterJobs(jobs *JobService) {
+	s.jobs = jobs
+	jobs.Register(domain.JobExportDeck, JobKind{Run: s.runDeckExportJob, MaxAttempts: 3})
+}
+
+// StartDeckExport ставит в очередь выгрузку набора в файл — то же, что ExportDeck, а для формата
+// pdf — PrintDeck с параметрами opts. Доступ и параметры проверяются сразу; готовый файл
+// отдаёт GET /jobs/:id/download.
+func (s *ExportService) StartDeckExport(ctx context.Context, id int, userID int, format string, scheduling bool, opts *domain.PrintOptions) (*domain.Job, error) {
+	p := deckExportJob{DeckID: id, Format: format, Scheduling: scheduling, Print: opts}
+	if _, err := s.deckExport(ctx, userID, p); err != nil {
+		return nil, err
+	}
+	return s.jobs.Enqueue(ctx, userID, domain.JobExportDeck, "", p)
+}
+
+func (s *ExportService) deckExport(ctx context.Context, userID int, p deckExportJob) (*DeckExport, error) {
+	if p.Format == domain.ExportPDF {
+		if p.Print == nil {
+			return nil, ErrPrintLayout
+		}
+		return s.PrintDeck(ctx, p.DeckID, userID, *p.Print)
+	}
+	return s.ExportDeck(ctx, p.DeckID, userID, p.Format, p.Scheduling)
+}
+
+func (s *ExportService) runDeckExportJob(ctx context.Context, job *domain.Job, _ func(done, total int)) (interface{}, error) {
+	var p deckExportJob
+	if err := decodePayload(job, &p); err != nil {
+		return nil, err
+	}
+	exp, err := s.deckExport(ctx, job.UserID, p)
+	if err != nil {
+		return nil, err
+	}
+	path := s.jobs.FilePath(job.ID)
+	size, err := writeJobFile(ctx, path, exp.Write)
+	if err != nil {
+		return nil, err
+	}
+	return domain.JobFile{Filename: exp.Filename, ContentType: exp.ContentType, Size: size}, nil
+}
+
+// writeJobFile пишет файл задачи через write и возвращает его размер; при ошибке файл удаляется.
+func writeJobFile(ctx context.Context, path string, write func(ctx context.Context, w io.Writer) error) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	err = write(ctx, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return st.Size(), nil
+}
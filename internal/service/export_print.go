@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+	"github.com/pro100kartochki/mozgoemka/pkg/cardpdf"
+)
+
+var ErrPrintLayout = cardpdf.ErrLayout
+
+// PrintDeck готовит PDF для печати карточек набора с вложенными наборами: лицевые стороны
+// и обороты на чередующихся страницах. Доступ — как у выгрузки.
+func (s *ExportService) PrintDeck(ctx context.Context, id int, userID int, opts domain.PrintOptions) (*DeckExport, error) {
+	layout := cardpdf.Layout{Paper: cardpdf.PaperA4, Cols: opts.Cols, Rows: opts.Rows, CutLines: opts.CutLines, Mirror: opts.Mirror}
+	if strings.EqualFold(opts.Paper, "letter") {
+		layout.Paper = cardpdf.PaperLetter
+	}
+	if layout.Cols < 1 || layout.Cols > cardpdf.MaxCols || layout.Rows < 1 || layout.Rows > cardpdf.MaxRows {
+		return nil, ErrPrintLayout
+	}
+	decks, err := s.exportDecks(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &DeckExport{
+		Filename:    exportFilename(decks[0].Title) + ".pdf",
+		ContentType: "application/pdf",
+		deckIDs:     exportDeckIDs(decks),
+		inlineLimit: maxInlinePDFCards,
+		write: func(ctx context.Context, w io.Writer) error {
+			doc, err := cardpdf.New(layout)
+			if err != nil {
+				return err
+			}
+			for _, d := range orderDecks(decks) {
+				err := s.cardRepo.EachForExport(ctx, []int{d.ID}, userID, func(ec *repository.ExportCard) error {
+					doc.Add(cardpdf.Card{
+						Front: s.printSide(ec.Card.Question, opts.Images),
+						Back:  s.printSide(ec.Card.Answer, opts.Images),
+					})
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+			_, err = doc.WriteTo(w)
+			return err
+		},
+	}, nil
+}
+
+// printSide убирает из текста разметку ссылок: картинки из загрузок выносятся отдельно (images=true)
+// или выбрасываются, у остальных ссылок остаётся подпись.
+func (s *ExportService) printSide(text string, images bool) cardpdf.Side {
+	var side cardpdf.Side
+	side.Text = mdLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdLinkRe.FindStringSubmatch(m)
+		if sub[1] == "" {
+			return sub[2]
+		}
+		if images {
+			if path := s.localUpload(sub[3]); path != "" {
+				side.Images = append(side.Images, path)
+			}
+		}
+		return ""
+	})
+	return side
+}
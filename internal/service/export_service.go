@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+	"github.com/pro100kartochki/mozgoemka/pkg/anki"
+)
+
+var (
+	ErrExportFormat   = errors.New("неизвестный формат экспорта")
+	ErrExportTooLarge = errors.New("набор слишком большой для выгрузки в запросе: используйте фоновую выгрузку (POST)")
+)
+
+// Пакет Anki и PDF собираются целиком перед отправкой, поэтому в запросе (GET) выгружаются
+// только небольшие наборы; большие — фоновой задачей (StartDeckExport).
+const (
+	maxInlineAPKGCards = 1000
+	maxInlinePDFCards  = 500
+)
+
+// mdLinkRe — markdown-ссылки и картинки в тексте карточки: ![alt](url) и [name](url).
+var mdLinkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// ExportService выгружает наборы во внешние форматы. Файлы пишутся потоком: карточки читаются
+// из базы по одной и сразу попадают в ответ.
+type ExportService struct {
+	deckRepo     *repository.DeckRepository
+	cardRepo     *repository.CardRepository
+	categoryRepo *repository.CategoryRepository
+	tagRepo      *repository.TagRepository
+	jobs         *JobService
+	uploadPath   string // корень загрузок (например ./uploads)
+	baseURL      string // например http://localhost:8080
+}
+
+func NewExportService(deckRepo *repository.DeckRepository, cardRepo *repository.CardRepository, categoryRepo *repository.CategoryRepository, tagRepo *repository.TagRepository) *ExportService {
+	return &ExportService{deckRepo: deckRepo, cardRepo: cardRepo, categoryRepo: categoryRepo, tagRepo: tagRepo}
+}
+
+func (s *ExportService) SetUploadConfig(uploadPath, baseURL string) {
+	s.uploadPath = uploadPath
+	s.baseURL = baseURL
+}
+
+// DeckExport — подготовленный экспорт: доступ и формат проверены, файл пишется в Write.
+type DeckExport struct {
+	Filename    string
+	ContentType string
+	write       func(ctx context.Context, w io.Writer) error
+	deckIDs     []int
+	inlineLimit int // сколько карточек можно выгрузить в запросе (0 — без ограничения)
+}
+
+func (e *DeckExport) Write(ctx context.Context, w io.Writer) error {
+	return e.write(ctx, w)
+}
+
+// CheckInline проверяет, что выгрузку exp можно собрать прямо в запросе: в наборе не больше
+// карточек, чем допускает формат. Иначе — ErrExportTooLarge.
+func (s *ExportService) CheckInline(ctx context.Context, exp *DeckExport) error {
+	if exp.inlineLimit == 0 {
+		return nil
+	}
+	own, _, err := s.deckRepo.CountCardsWithSubtrees(ctx, exp.deckIDs)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, n := range own {
+		total += n
+	}
+	if total > exp.inlineLimit {
+		return ErrExportTooLarge
+	}
+	return nil
+}
+
+func exportDeckIDs(decks []exportDeck) []int {
+	ids := make([]int, len(decks))
+	for i, d := range decks {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+// exportDeck — набор в выгрузке; Path — полный путь от экспортируемого набора (Немецкий::Глаголы).
+type exportDeck struct {
+	domain.Deck
+	Path []string
+}
+
+// ExportDeck готовит выгрузку набора id вместе с вложенными наборами в формате format (domain.Export*).
+// Выгрузить можно свой или публичный набор (чужие приватные вложенные наборы пропускаются);
+// scheduling добавляет в apkg состояние повторений пользователя.
+func (s *ExportService) ExportDeck(ctx context.Context, id int, userID int, format string, scheduling bool) (*DeckExport, error) {
+	decks, err := s.exportDecks(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	exp := &DeckExport{Filename: exportFilename(decks[0].Title) + "." + format, deckIDs: exportDeckIDs(decks)}
+	switch format {
+	case domain.ExportAPKG:
+		exp.ContentType = "application/octet-stream"
+		exp.inlineLimit = maxInlineAPKGCards
+		exp.write = func(ctx context.Context, w io.Writer) error {
+			return s.writeAPKG(ctx, w, decks, userID, scheduling)
+		}
+	case domain.ExportCSV:
+		exp.ContentType = "text/csv; charset=utf-8"
+		exp.write = func(ctx context.Context, w io.Writer) error { return s.writeCSV(ctx, w, decks, userID) }
+	case domain.ExportJSON:
+		exp.ContentType = "application/json; charset=utf-8"
+		exp.write = func(ctx context.Context, w io.Writer) error { return s.writeJSON(ctx, w, decks, userID) }
+	case domain.ExportMarkdown:
+		exp.ContentType = "text/markdown; charset=utf-8"
+		exp.write = func(ctx context.Context, w io.Writer) error { return s.writeMarkdown(ctx, w, decks, userID) }
+	default:
+		return nil, ErrExportFormat
+	}
+	return exp, nil
+}
+
+func (s *ExportService) exportDecks(ctx context.Context, id int, userID int) ([]exportDeck, error) {
+	tree, err := s.deckRepo.ListSubtree(ctx, id)
+	if err != nil || len(tree) == 0 {
+		return nil, ErrDeckNotFound
+	}
+	if !tree[0].IsPublic && tree[0].UserID != userID {
+		return nil, ErrDeckForbidden
+	}
+	paths := make(map[int][]string, len(tree))
+	var decks []exportDeck
+	for i, d := range tree {
+		var path []string
+		if i > 0 {
+			parent, ok := paths[*d.ParentID]
+			if !ok || (!d.IsPublic && d.UserID != userID) {
+				continue
+			}
+			path = append(path, parent...)
+		}
+		path = append(path, d.Title)
+		paths[d.ID] = path
+		decks = append(decks, exportDeck{Deck: d, Path: path})
+	}
+	return decks, nil
+}
+
+func (s *ExportService) writeAPKG(ctx context.Context, out io.Writer, decks []exportDeck, userID int, scheduling bool) error {
+	w, err := anki.NewWriter()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	ids := make([]int, len(decks))
+	ankiDecks := make(map[int]int64, len(decks))
+	for i, d := range decks {
+		ids[i] = d.ID
+		segments := make([]string, len(d.Path))
+		for j, seg := range d.Path {
+			// «::» в названии Anki прочитал бы как вложенность
+			segments[j] = strings.ReplaceAll(seg, "::", ":")
+		}
+		ankiDecks[d.ID] = w.Deck(strings.Join(segments, "::"))
+	}
+
+	files := make(map[string]string)
+	media := func(link string) string {
+		if name, ok := files[link]; ok {
+			return name
+		}
+		name := ""
+		if path := s.localUpload(link); path != "" {
+			name = w.AddMedia(filepath.Base(path), func() (io.ReadCloser, error) { return os.Open(path) })
+		}
+		files[link] = name
+		return name
+	}
+	err = s.cardRepo.EachForExport(ctx, ids, userID, func(ec *repository.ExportCard) error {
+		var sched *anki.Schedule
+		if p := ec.Progress; scheduling && p != nil && p.IntervalDays > 0 {
+			sched = &anki.Schedule{Due: p.DueAt, Interval: p.IntervalDays, Factor: int(p.EaseFactor * 1000), Reps: p.Repetitions}
+		}
+		return w.AddNote(ankiDecks[ec.Card.DeckID], "mzg"+strconv.Itoa(ec.Card.ID),
+			anki.HTMLText(ec.Card.Question, media), anki.HTMLText(ec.Card.Answer, media), ec.Tags, sched)
+	})
+	if err != nil {
+		return err
+	}
+	return w.Finish(out)
+}
+
+// localUpload возвращает путь к файлу из загрузок по его URL или "", если файл внешний или отсутствует.
+func (s *ExportService) localUpload(link string) string {
+	rel := ""
+	switch {
+	case s.baseURL != "" && strings.HasPrefix(link, s.baseURL+"/uploads/"):
+		rel = strings.TrimPrefix(link, s.baseURL+"/uploads/")
+	case strings.HasPrefix(link, "/uploads/"):
+		rel = strings.TrimPrefix(link, "/uploads/")
+	default:
+		return ""
+	}
+	rel, err := url.PathUnescape(rel)
+	if err != nil {
+		return ""
+	}
+	rel = filepath.Clean(filepath.FromSlash(rel))
+	if rel == "." || filepath.IsAbs(rel) || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	path := filepath.Join(s.uploadPath, rel)
+	if st, err := os.Stat(path); err != nil || !st.Mode().IsRegular() {
+		return ""
+	}
+	return path
+}
+
+// exportFilename — имя файла выгрузки по названию набора: без символов, недопустимых в именах файлов.
+func exportFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		return "deck"
+	}
+	return name
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+// deckMeta — категория и видимые пользователю теги набора для выгрузки. Ошибка чтения прерывает
+// выгрузку: без категории и тегов резервная копия была бы неполной.
+func (s *ExportService) deckMeta(ctx context.Context, d *domain.Deck, userID int) (*domain.BackupCategory, []string, error) {
+	var cat *domain.BackupCategory
+	if d.CategoryID != nil {
+		c, err := s.categoryRepo.GetByID(ctx, *d.CategoryID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if c != nil {
+			cat = &domain.BackupCategory{Slug: c.Slug, Name: c.Name}
+		}
+	}
+	tags := []string{}
+	ids, err := s.deckRepo.GetDeckTagIDs(ctx, d.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ids) > 0 {
+		list, err := s.tagRepo.GetVisibleByIDs(ctx, userID, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, t := range list {
+			tags = append(tags, t.Name)
+		}
+	}
+	return cat, tags, nil
+}
+
+// writeCSV выводит таблицу с колонками question, answer, tags, category, deck; её читает импорт CSV.
+func (s *ExportService) writeCSV(ctx context.Context, out io.Writer, decks []exportDeck, userID int) error {
+	bw := bufio.NewWriter(out)
+	// BOM: без него Excel открывает UTF-8 как CP1251
+	bw.WriteString("\ufeff")
+	w := csv.NewWriter(bw)
+	if err := w.Write([]string{"question", "answer", "tags", "category", "deck"}); err != nil {
+		return err
+	}
+	for _, d := range orderDecks(decks) {
+		path := strings.Join(d.Path, "::")
+		err := s.cardRepo.EachForExport(ctx, []int{d.ID}, userID, func(ec *repository.ExportCard) error {
+			return w.Write([]string{ec.Card.Question, ec.Card.Answer, strings.Join(ec.Tags, ", "), ec.CategoryName, path})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeMarkdown выводит конспект: набор — заголовок по глубине вложенности, карточка — пара Q:/A:.
+func (s *ExportService) writeMarkdown(ctx context.Context, out io.Writer, decks []exportDeck, userID int) error {
+	bw := bufio.NewWriter(out)
+	for _, d := range orderDecks(decks) {
+		bw.WriteString(strings.Repeat("#", min(len(d.Path), 6)) + " " + d.Title + "\n\n")
+		if d.Description != nil && strings.TrimSpace(*d.Description) != "" {
+			bw.WriteString(strings.TrimSpace(*d.Description) + "\n\n")
+		}
+		cat, tags, err := s.deckMeta(ctx, &d.Deck, userID)
+		if err != nil {
+			return err
+		}
+		if cat != nil || len(tags) > 0 {
+			if cat != nil {
+				bw.WriteString("Category: " + cat.Name + "\n")
+			}
+			if len(tags) > 0 {
+				bw.WriteString("Tags: " + strings.Join(tags, ", ") + "\n")
+			}
+			bw.WriteString("\n")
+		}
+		err = s.cardRepo.EachForExport(ctx, []int{d.ID}, userID, func(ec *repository.ExportCard) error {
+			writeMarkdownField(bw, "Q: ", ec.Card.Question)
+			writeMarkdownField(bw, "A: ", ec.Card.Answer)
+			if ec.CategoryName != "" || len(ec.Tags) > 0 {
+				// пустая строка закрывает пару: иначе импорт конспекта прочтёт метаданные как продолжение ответа
+				bw.WriteString("\n")
+			}
+			if ec.CategoryName != "" {
+				bw.WriteString("Category: " + ec.CategoryName + "\n")
+			}
+			if len(ec.Tags) > 0 {
+				bw.WriteString("Tags: " + strings.Join(ec.Tags, ", ") + "\n")
+			}
+			_, err := bw.WriteString("\n")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownField выводит поле карточки после метки label. Строки после первой пишутся
+// с отступом mdContinuation: parseMarkdown читает их как продолжение поля, даже если они пустые
+// или похожи на заголовок, пару Q:/A: или начало блока кода.
+func writeMarkdownField(bw *bufio.Writer, label, text string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	bw.WriteString(label + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		bw.WriteString(mdContinuation + line + "\n")
+	}
+}
+
+// writeJSON выводит резервную копию domain.DeckBackup. Карточки пишутся по одной,
+// поэтому объект собирается вручную, а не через json.Marshal всей копии.
+func (s *ExportService) writeJSON(ctx context.Context, out io.Writer, decks []exportDeck, userID int) error {
+	bw := bufio.NewWriter(out)
+	head, _ := json.Marshal(struct {
+		Format     string    `json:"format"`
+		Version    int       `json:"version"`
+		ExportedAt time.Time `json:"exported_at"`
+	}{domain.BackupFormat, domain.BackupVersion, time.Now().UTC()})
+	bw.Write(head[:len(head)-1])
+	bw.WriteString(`,"deck":`)
+	children := make(map[int][]*exportDeck)
+	for i := range decks[1:] {
+		d := &decks[i+1]
+		children[*d.ParentID] = append(children[*d.ParentID], d)
+	}
+	if err := s.writeJSONDeck(ctx, bw, &decks[0], children, userID); err != nil {
+		return err
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func (s *ExportService) writeJSONDeck(ctx context.Context, bw *bufio.Writer, d *exportDeck, children map[int][]*exportDeck, userID int) error {
+	cat, tags, err := s.deckMeta(ctx, &d.Deck, userID)
+	if err != nil {
+		return err
+	}
+	head, _ := json.Marshal(struct {
+		Title       string                 `json:"title"`
+		Description *string                `json:"description"`
+		IsPublic    bool                   `json:"is_public"`
+		Category    *domain.BackupCategory `json:"category"`
+		Tags        []string               `json:"tags"`
+	}{d.Title, d.Description, d.IsPublic, cat, tags})
+	bw.Write(head[:len(head)-1])
+	bw.WriteString(`,"cards":[`)
+	first := true
+	err = s.cardRepo.EachForExport(ctx, []int{d.ID}, userID, func(ec *repository.ExportCard) error {
+		c := domain.BackupCard{Question: ec.Card.Question, Answer: ec.Card.Answer, Tags: ec.Tags}
+		if ec.CategorySlug != "" {
+			c.Category = &domain.BackupCategory{Slug: ec.CategorySlug, Name: ec.CategoryName}
+		}
+		data, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		_, err = bw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString(`],"sub_decks":[`)
+	for i, child := range children[d.ID] {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		if err := s.writeJSONDeck(ctx, bw, child, children, userID); err != nil {
+			return err
+		}
+	}
+	_, err = bw.WriteString("]}")
+	return err
+}
+
+// orderDecks возвращает наборы в порядке обхода дерева в глубину: вложенные сразу после родителя.
+func orderDecks(decks []exportDeck) []exportDeck {
+	children := make(map[int][]exportDeck)
+	for _, d := range decks[1:] {
+		children[*d.ParentID] = append(children[*d.ParentID], d)
+	}
+	out := make([]exportDeck, 0, len(decks))
+	var walk func(d exportDeck)
+	walk = func(d exportDeck) {
+		out = append(out, d)
+		for _, c := range children[d.ID] {
+			walk(c)
+		}
+	}
+	walk(decks[0])
+	return out
+}
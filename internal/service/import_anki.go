@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/pkg/anki"
+)
+
+const importMaxWarnings = 50
+
+// ankiJob — параметры задачи импорта пакета Anki.
+type ankiJob struct {
+	Path       string `json:"path"`
+	ParentID   *int   `json:"parent_id"`
+	Scheduling bool   `json:"scheduling"`
+	History    bool   `json:"history"`
+}
+
+// ImportAnki ставит в очередь импорт пакета Anki из временного файла path.
+// Колоды Anki воссоздаются вложенными наборами (Языки::Немецкий → Языки → Немецкий) внутри opts.ParentID,
+// теги заметок привязываются к карточкам, медиафайлы копируются в загрузки.
+// Файл удаляется после импорта; при сбое или отмене созданные наборы и медиафайлы удаляются.
+func (s *ImportService) ImportAnki(ctx context.Context, userID int, path string, opts domain.AnkiImportOptions) (*domain.Job, error) {
+	if opts.ParentID != nil {
+		if err := s.checkDeck(ctx, *opts.ParentID, userID); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	job, err := s.jobs.Enqueue(ctx, userID, domain.JobImportAnki, "", ankiJob{
+		Path: path, ParentID: opts.ParentID, Scheduling: opts.Scheduling, History: opts.History,
+	})
+	if err != nil {
+		os.Remove(path)
+	}
+	return job, err
+}
+
+func (s *ImportService) runAnkiJob(ctx context.Context, job *domain.Job, progress func(done, total int)) (interface{}, error) {
+	var p ankiJob
+	if err := decodePayload(job, &p); err != nil {
+		return nil, err
+	}
+	defer os.Remove(p.Path)
+	pkg, err := anki.OpenFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer pkg.Close()
+	opts := domain.AnkiImportOptions{ParentID: p.ParentID, Scheduling: p.Scheduling, History: p.History}
+	quota := &mediaQuota{}
+	res, err := s.importAnki(ctx, job.UserID, pkg, opts, quota, progress)
+	if err != nil {
+		// задача не повторяется, поэтому сбой или отмена не должны оставить половину пакета
+		if rbErr := s.rollbackImport(job.UserID, res, quota); rbErr != nil {
+			return res, fmt.Errorf("%w; откат импорта: %v", err, rbErr)
+		}
+		return &domain.ImportResult{Decks: []domain.DeckBrief{}, Warnings: res.Warnings}, err
+	}
+	return res, nil
+}
+
+// rollbackImport окончательно удаляет наборы, созданные прерванным импортом (с карточками
+// и прогрессом), и скопированные им медиафайлы. Выполняется и после отмены задачи,
+// поэтому не зависит от её контекста.
+func (s *ImportService) rollbackImport(userID int, res *domain.ImportResult, quota *mediaQuota) error {
+	quota.remove()
+	ids := make([]int, 0, len(res.Decks))
+	for _, d := range res.Decks {
+		ids = append(ids, d.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.deckRepo.Purge(domain.WithRevisionAuthor(context.Background(), userID), userID, ids)
+}
+
+func (s *ImportService) importAnki(ctx context.Context, userID int, pkg *anki.Package, opts domain.AnkiImportOptions, quota *mediaQuota, progress func(done, total int)) (*domain.ImportResult, error) {
+	res := &domain.ImportResult{Decks: []domain.DeckBrief{}}
+	warn := func(msg string) {
+		if len(res.Warnings) < importMaxWarnings {
+			res.Warnings = append(res.Warnings, msg)
+		}
+	}
+	total := len(pkg.Cards)
+	progress(0, total)
+
+	deckIDs, err := s.ankiDecks(ctx, userID, pkg, opts.ParentID, res)
+	if err != nil {
+		return res, err
+	}
+	media := s.ankiMedia(userID, pkg, res, quota, warn)
+	tagCache := make(map[string]int)
+	cardIDs := make(map[int64]int, total) // ID карточки Anki → ID нашей карточки
+
+	for start := 0; start < total; start += importBatchSize {
+		chunk := pkg.Cards[start:min(start+importBatchSize, total)]
+		cards := make([]domain.Card, 0, len(chunk))
+		cardTags := make([][]int, 0, len(chunk))
+		src := make([]int64, 0, len(chunk))
+		for _, c := range chunk {
+			q, a, ok := pkg.Render(c)
+			if ok {
+				q = anki.PlainText(q, media)
+				a = anki.PlainText(a, media)
+			}
+			if !ok || q == "" {
+				res.CardsSkipped++
+				continue
+			}
+			cards = append(cards, domain.Card{DeckID: deckIDs[c.DeckID], Question: q, Answer: a})
+			cardTags = append(cardTags, s.importTags(ctx, userID, pkg.Notes[c.NoteID].Tags, tagCache, warn))
+			src = append(src, c.ID)
+		}
+		if err := s.cardRepo.CreateBatch(ctx, cards, cardTags); err != nil {
+			return res, err
+		}
+		for i := range cards {
+			cardIDs[src[i]] = cards[i].ID
+		}
+		res.CardsCreated += len(cards)
+		progress(start+len(chunk), total)
+	}
+	for _, id := range tagCache {
+		if id != 0 {
+			res.TagsUsed++
+		}
+	}
+
+	lastReview := make(map[int64]time.Time)
+	for _, r := range pkg.Reviews {
+		lastReview[r.CardID] = time.UnixMilli(r.ID)
+	}
+	if opts.Scheduling {
+		var list []domain.CardProgress
+		for _, c := range pkg.Cards {
+			id, ok := cardIDs[c.ID]
+			if !ok {
+				continue
+			}
+			var last *time.Time
+			if t, ok := lastReview[c.ID]; ok {
+				last = &t
+			}
+			if p, ok := ankiProgress(userID, id, c, pkg.Created, last); ok {
+				list = append(list, p)
+			}
+		}
+		if err := s.progressRepo.UpsertBatch(ctx, list); err != nil {
+			return res, err
+		}
+		res.Scheduled = len(list)
+	}
+	if opts.History {
+		var list []domain.ReviewLog
+		for _, r := range pkg.Reviews {
+			id, ok := cardIDs[r.CardID]
+			grade := ankiGrade(r.Ease)
+			if !ok || grade < 0 {
+				continue
+			}
+			l := domain.ReviewLog{UserID: userID, CardID: id, Grade: grade, EaseFactor: 2.5, DurationMs: r.Time, ReviewedAt: time.UnixMilli(r.ID)}
+			if r.Interval > 0 {
+				l.IntervalDays = r.Interval
+			}
+			if r.Factor > 0 {
+				l.EaseFactor = float64(r.Factor) / 1000
+			}
+			list = append(list, l)
+		}
+		if err := s.progressRepo.LogReviewsBatch(ctx, list); err != nil {
+			return res, err
+		}
+		res.Reviews = len(list)
+	}
+	return res, nil
+}
+
+// ankiDecks создаёт наборы для колод Anki, в которых есть карточки, вместе с родительскими колодами,
+// и возвращает соответствие ID колоды Anki → ID набора.
+func (s *ImportService) ankiDecks(ctx context.Context, userID int, pkg *anki.Package, parentID *int, res *domain.ImportResult) (map[int64]int, error) {
+	names := make(map[int64]string)
+	paths := make(map[string]bool)
+	for _, c := range pkg.Cards {
+		if _, ok := names[c.DeckID]; ok {
+			continue
+		}
+		var segments []string
+		if d := pkg.Decks[c.DeckID]; d != nil {
+			for _, seg := range strings.Split(d.Name, "::") {
+				if seg = strings.TrimSpace(seg); seg != "" {
+					segments = append(segments, seg)
+				}
+			}
+		}
+		if len(segments) == 0 {
+			segments = []string{"Anki"}
+		}
+		names[c.DeckID] = strings.Join(segments, "::")
+		for i := 1; i <= len(segments); i++ {
+			paths[strings.Join(segments[:i], "::")] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	// родительские колоды создаются раньше дочерних
+	sort.Slice(sorted, func(i, j int) bool {
+		di, dj := strings.Count(sorted[i], "::"), strings.Count(sorted[j], "::")
+		if di != dj {
+			return di < dj
+		}
+		return sorted[i] < sorted[j]
+	})
+	byPath := make(map[string]int, len(sorted))
+	for _, p := range sorted {
+		d := &domain.Deck{UserID: userID, Title: p, ParentID: parentID}
+		if i := strings.LastIndex(p, "::"); i >= 0 {
+			id := byPath[p[:i]]
+			d.Title = p[i+2:]
+			d.ParentID = &id
+		}
+		if err := s.deckRepo.Create(ctx, d); err != nil {
+			return nil, err
+		}
+		byPath[p] = d.ID
+		res.Decks = append(res.Decks, domain.DeckBrief{ID: d.ID, Title: p})
+	}
+	ids := make(map[int64]int, len(names))
+	for id, name := range names {
+		ids[id] = byPath[name]
+	}
+	return ids, nil
+}
+
+// ankiMedia копирует медиафайлы пакета в загрузки при первом упоминании в тексте карточки.
+func (s *ImportService) ankiMedia(userID int, pkg *anki.Package, res *domain.ImportResult, quota *mediaQuota, warn func(string)) anki.MediaLink {
+	urls := make(map[string]string)
+	return func(name string) string {
+		if u, ok := urls[name]; ok {
+			return u
+		}
+		rc, err := pkg.Media(name)
+		if errors.Is(err, anki.ErrNoMedia) {
+			// в src картинок имена иногда записаны в URL-кодировке
+			if un, uerr := url.PathUnescape(name); uerr == nil && un != name {
+				rc, err = pkg.Media(un)
+			}
+		}
+		u := ""
+		switch {
+		case err == nil:
+			if u, err = s.saveMedia(userID, name, rc, quota); err != nil {
+				warn("медиафайл не сохранён: " + name)
+			} else {
+				res.MediaCopied++
+			}
+			rc.Close()
+		case errors.Is(err, anki.ErrNoMedia):
+			warn("медиафайл не найден в пакете: " + name)
+		default:
+			warn("медиафайл не сохранён: " + name)
+		}
+		urls[name] = u
+		return u
+	}
+}
+
+// ankiProgress переводит состояние карточки Anki в состояние повторений SM-2; новые карточки пропускаются.
+func ankiProgress(userID, cardID int, c anki.Card, created time.Time, last *time.Time) (domain.CardProgress, bool) {
+	if c.Type == anki.CardNew {
+		return domain.CardProgress{}, false
+	}
+	p := domain.CardProgress{UserID: userID, CardID: cardID, EaseFactor: 2.5, LastReviewedAt: last}
+	if c.Factor > 0 {
+		p.EaseFactor = float64(c.Factor) / 1000
+	}
+	if p.EaseFactor < 1.3 {
+		p.EaseFactor = 1.3
+	}
+	if c.Interval > 0 {
+		p.IntervalDays = c.Interval
+	}
+	if c.Type == anki.CardReview {
+		// с двух успешных повторений SM-2 умножает интервал на лёгкость, как Anki
+		p.Repetitions = max(c.Reps-c.Lapses, 2)
+	}
+	// у изучаемых карточек срок — unix-время, у карточек на повторении — день от создания коллекции
+	if c.Due > 1_000_000_000 {
+		p.DueAt = time.Unix(c.Due, 0)
+	} else {
+		p.DueAt = created.AddDate(0, 0, int(c.Due))
+	}
+	return p, true
+}
+
+// ankiGrade переводит кнопку ответа Anki (1–4) в оценку SM-2 (0–5); -1 — запись без ответа (ручной перенос).
+func ankiGrade(ease int) int {
+	switch ease {
+	case 1:
+		return 1
+	case 2:
+		return 3
+	case 3:
+		return 4
+	case 4:
+		return 5
+	}
+	return -1
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var (
+	ErrCSVUploadNotFound = errors.New("загруженная таблица не найдена или устарела")
+	ErrCSVInvalid        = errors.New("не удалось разобрать таблицу")
+	ErrCSVTooManyRows    = errors.New("слишком много строк")
+	ErrCSVMapping        = errors.New("колонка вне таблицы")
+)
+
+const (
+	csvMaxRows     = 10000
+	csvPreviewRows = 10
+	csvSniffRows   = 20
+	csvUploadTTL   = time.Hour // сколько хранится загруженная таблица
+)
+
+var csvDelimiters = []rune{'\t', ';', ',', '|'}
+
+// csvHeaderNames — узнаваемые заголовки колонок и поля карточки, к которым они относятся.
+var csvHeaderNames = map[string]string{
+	"question": "question", "front": "question", "term": "question", "word": "question",
+	"вопрос": "question", "термин": "question", "слово": "question", "лицевая сторона": "question",
+	"answer": "answer", "back": "answer", "definition": "answer", "translation": "answer",
+	"ответ": "answer", "определение": "answer", "перевод": "answer", "оборот": "answer",
+	"tags": "tags", "tag": "tags", "теги": "tags", "тег": "tags", "метки": "tags",
+	"category": "category", "категория": "category", "рубрика": "category",
+}
+
+// csvTable — разобранная таблица.
+type csvTable struct {
+	delimiter rune
+	encoding  string
+	hasHeader bool
+	records   [][]string
+}
+
+// UploadCSV сохраняет таблицу из временного файла path (файл переходит во владение сервиса)
+// и возвращает предпросмотр с определёнными разделителем, кодировкой и заголовком.
+// Таблица лежит в общем каталоге задач, чтобы следующие шаги импорта мог обработать
+// любой экземпляр API; через csvUploadTTL её удаляет задача csv_uploads_cleanup.
+func (s *ImportService) UploadCSV(ctx context.Context, userID int, path string, opts domain.CSVParseOptions) (*domain.CSVPreviewResponse, error) {
+	t, err := readCSV(path, opts)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	id := uuid.New().String()
+	dst := s.csvUploadPath(userID, id)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	if err := os.Rename(path, dst); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return t.preview(id), nil
+}
+
+// PreviewCSV заново разбирает загруженную таблицу с уточнёнными параметрами.
+func (s *ImportService) PreviewCSV(ctx context.Context, userID int, uploadID string, opts domain.CSVParseOptions) (*domain.CSVPreviewResponse, error) {
+	path, err := s.csvUpload(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := readCSV(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return t.preview(uploadID), nil
+}
+
+// CSVRows превращает строки загруженной таблицы в запросы создания карточек по соответствию колонок.
+// Теги ищутся по имени без создания: недостающие попадают в Card.NewTags и создаются личными
+// вместе с карточками, категории — по названию или slug; ошибки ссылок записываются
+// в CSVRow.Errors. Пустые строки пропускаются.
+func (s *ImportService) CSVRows(ctx context.Context, userID int, uploadID string, req domain.CSVImportRequest) ([]domain.CSVRow, error) {
+	path, err := s.csvUpload(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkDeck(ctx, req.DeckID, userID); err != nil {
+		return nil, err
+	}
+	t, err := readCSV(path, req.CSVParseOptions)
+	if err != nil {
+		return nil, err
+	}
+	m := req.Mapping
+	width := t.width()
+	for _, col := range []*int{&m.Question, &m.Answer, m.Tags, m.Category} {
+		if col != nil && *col >= width {
+			return nil, ErrCSVMapping
+		}
+	}
+	sep := req.TagSeparator
+	if sep == "" {
+		sep = ","
+	}
+
+	tags := make(map[string]csvTag)
+	categories := make(map[string]*int)
+	rows := make([]domain.CSVRow, 0, len(t.records))
+	for i, rec := range t.records {
+		if t.hasHeader && i == 0 {
+			continue
+		}
+		if blankRecord(rec) {
+			continue
+		}
+		row := domain.CSVRow{Row: i + 1, Card: domain.CreateCardRequest{
+			Question: csvCell(rec, m.Question),
+			Answer:   csvCell(rec, m.Answer),
+		}}
+		if m.Tags != nil {
+			var names []string
+			for _, name := range strings.Split(csvCell(rec, *m.Tags), sep) {
+				if name = strings.TrimSpace(name); name != "" {
+					names = append(names, name)
+				}
+			}
+			for _, name := range names {
+				tag, ok := tags[name]
+				if !ok {
+					if tag, err = s.lookupCSVTag(ctx, userID, name); err != nil {
+						return nil, err
+					}
+					tags[name] = tag
+				}
+				switch {
+				case tag.id != 0:
+					row.Card.TagIDs = append(row.Card.TagIDs, tag.id)
+				case tag.path != "":
+					row.Card.NewTags = append(row.Card.NewTags, tag.path)
+				default:
+					row.Errors = withError(row.Errors, "tags", "тег пропущен: "+name)
+				}
+			}
+		}
+		if m.Category != nil {
+			if name := csvCell(rec, *m.Category); name != "" {
+				id, ok := categories[name]
+				if !ok {
+					id = s.findCategory(ctx, name)
+					categories[name] = id
+				}
+				if id == nil {
+					row.Errors = withError(row.Errors, "category", "категория не найдена: "+name)
+				}
+				row.Card.CategoryID = id
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ImportCSV создаёт карточки из строк таблицы через CardService.BulkCreate; ошибки элементов
+// возвращаются с номерами строк файла. После успешного импорта загрузка удаляется.
+func (s *ImportService) ImportCSV(ctx context.Context, userID int, uploadID string, req domain.CSVImportRequest, rows []domain.CSVRow) (*domain.CSVImportResponse, error) {
+	bulk := domain.BulkCreateCardsRequest{Mode: req.Mode, Cards: make([]domain.CreateCardRequest, len(rows))}
+	invalid := make(map[int]map[string]string)
+	for i, row := range rows {
+		bulk.Cards[i] = row.Card
+		if len(row.Errors) > 0 {
+			invalid[i] = row.Errors
+		}
+	}
+	res, err := s.cards.BulkCreate(ctx, req.DeckID, userID, bulk, invalid, domain.DuplicatesNone)
+	var resp *domain.CSVImportResponse
+	if res != nil {
+		resp = &domain.CSVImportResponse{Mode: res.Mode, Created: len(res.Cards)}
+		for _, e := range res.Errors {
+			resp.Errors = append(resp.Errors, domain.CSVRowError{Row: rows[e.Index].Row, Errors: e.Errors})
+		}
+	}
+	if err != nil {
+		return resp, err
+	}
+	os.Remove(s.csvUploadPath(userID, uploadID))
+	return resp, nil
+}
+
+// csvTag — тег из ячейки таблицы: найденный (id) или недостающий (path, создаётся при импорте);
+// пустой — имя не подходит для тега.
+type csvTag struct {
+	id   int
+	path string
+}
+
+func (s *ImportService) lookupCSVTag(ctx context.Context, userID int, name string) (csvTag, error) {
+	if utf8.RuneCountInString(name) > 100 {
+		return csvTag{}, nil
+	}
+	t, path, err := s.tags.LookupTag(ctx, userID, name)
+	switch {
+	case errors.Is(err, ErrTagPath):
+		return csvTag{}, nil
+	case err != nil:
+		return csvTag{}, err
+	case t != nil:
+		return csvTag{id: t.ID}, nil
+	}
+	return csvTag{path: path}, nil
+}
+
+// csvUploadPath — файл загруженной таблицы: JOBS_DIR/inputs/csv/{userID}/{uploadID}.csv.
+func (s *ImportService) csvUploadPath(userID int, uploadID string) string {
+	return filepath.Join(s.jobs.InputDir(), "csv", strconv.Itoa(userID), uploadID+".csv")
+}
+
+// csvUpload возвращает путь к таблице uploadID пользователя, если она загружена не раньше csvUploadTTL.
+func (s *ImportService) csvUpload(uploadID string, userID int) (string, error) {
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return "", ErrCSVUploadNotFound
+	}
+	path := s.csvUploadPath(userID, uploadID)
+	fi, err := os.Stat(path)
+	if err != nil || time.Since(fi.ModTime()) > csvUploadTTL {
+		return "", ErrCSVUploadNotFound
+	}
+	return path, nil
+}
+
+// cleanupCSVUploads удаляет таблицы, загруженные раньше csvUploadTTL (задача csv_uploads_cleanup).
+func (s *ImportService) cleanupCSVUploads(ctx context.Context, _ *domain.Job, _ func(done, total int)) (interface{}, error) {
+	root := filepath.Join(s.jobs.InputDir(), "csv")
+	users, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return map[string]int{"deleted": 0}, err
+	}
+	deleted := 0
+	for _, u := range users {
+		dir := filepath.Join(root, u.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if fi, err := f.Info(); err == nil && time.Since(fi.ModTime()) > csvUploadTTL {
+				if os.Remove(filepath.Join(dir, f.Name())) == nil {
+					deleted++
+				}
+			}
+		}
+		if len(files) == 0 {
+			os.Remove(dir)
+		}
+	}
+	return map[string]int{"deleted": deleted}, nil
+}
+
+// findCategory ищет категорию по slug, затем по названию и по slug от названия.
+func (s *ImportService) findCategory(ctx context.Context, name string) *int {
+	if c, err := s.categoryRepo.GetBySlug(ctx, strings.ToLower(name)); err == nil && c != nil {
+		return &c.ID
+	}
+	if c, err := s.categoryRepo.FindByName(ctx, name); err == nil && c != nil {
+		return &c.ID
+	}
+	if c, err := s.categoryRepo.GetBySlug(ctx, slugify(name)); err == nil && c != nil {
+		return &c.ID
+	}
+	return nil
+}
+
+// readCSV читает таблицу: кодировка, разделитель и наличие заголовка берутся из opts или определяются по содержимому.
+func readCSV(path string, opts domain.CSVParseOptions) (*csvTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &csvTable{encoding: opts.Encoding}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if t.encoding == "" {
+		t.encoding = domain.EncodingUTF8
+		if !utf8.Valid(data) {
+			t.encoding = domain.EncodingCP1251
+		}
+	}
+	text := string(data)
+	if t.encoding == domain.EncodingCP1251 {
+		if text, err = charmap.Windows1251.NewDecoder().String(text); err != nil {
+			return nil, ErrCSVInvalid
+		}
+	}
+
+	switch opts.Delimiter {
+	case "":
+		t.delimiter = sniffDelimiter(text)
+	case "tab", `\t`:
+		t.delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(opts.Delimiter)
+		if size != len(opts.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+			return nil, ErrCSVInvalid
+		}
+		t.delimiter = r
+	}
+	if t.records, err = parseCSV(text, t.delimiter, csvMaxRows+2); err != nil {
+		return nil, err
+	}
+	if len(t.records) > csvMaxRows+1 {
+		return nil, ErrCSVTooManyRows
+	}
+	if opts.HasHeader != nil {
+		t.hasHeader = *opts.HasHeader
+	} else if len(t.records) > 1 {
+		t.hasHeader = len(headerMapping(t.records[0])) > 0
+	}
+	return t, nil
+}
+
+// parseCSV разбирает не более limit записей; кавычки разбираются нестрого, как в таблицах из Excel.
+func parseCSV(text string, delimiter rune, limit int) ([][]string, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var records [][]string
+	for len(records) < limit {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrCSVInvalid
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// sniffDelimiter выбирает разделитель, дающий одинаковое число колонок (больше одной) в первых строках.
+func sniffDelimiter(text string) rune {
+	best, bestScore, bestWidth := ',', 0, 0
+	for _, d := range csvDelimiters {
+		records, err := parseCSV(text, d, csvSniffRows)
+		if err != nil || len(records) == 0 {
+			continue
+		}
+		counts := make(map[int]int)
+		for _, rec := range records {
+			counts[len(rec)]++
+		}
+		width, score := 0, 0
+		for w, n := range counts {
+			if n > score || (n == score && w > width) {
+				width, score = w, n
+			}
+		}
+		if width < 2 {
+			continue
+		}
+		if score > bestScore || (score == bestScore && width > bestWidth) {
+			best, bestScore, bestWidth = d, score, width
+		}
+	}
+	return best
+}
+
+// headerMapping сопоставляет узнаваемые заголовки колонок полям карточки.
+func headerMapping(header []string) map[string]int {
+	m := make(map[string]int)
+	for i, name := range header {
+		field, ok := csvHeaderNames[strings.ToLower(strings.TrimSpace(name))]
+		if _, taken := m[field]; ok && !taken {
+			m[field] = i
+		}
+	}
+	return m
+}
+
+func (t *csvTable) width() int {
+	w := 0
+	for _, rec := range t.records {
+		w = max(w, len(rec))
+	}
+	return w
+}
+
+func (t *csvTable) preview(uploadID string) *domain.CSVPreviewResponse {
+	width := t.width()
+	p := &domain.CSVPreviewResponse{
+		UploadID:  uploadID,
+		Delimiter: string(t.delimiter),
+		Encoding:  t.encoding,
+		HasHeader: t.hasHeader,
+		Columns:   make([]string, width),
+		Rows:      [][]string{},
+		Mapping:   domain.CSVMapping{Question: 0, Answer: min(1, max(width-1, 0))},
+	}
+	data := t.records
+	if t.hasHeader && len(data) > 0 {
+		data = data[1:]
+		if m := headerMapping(t.records[0]); len(m) > 0 {
+			if i, ok := m["question"]; ok {
+				p.Mapping.Question = i
+			}
+			if i, ok := m["answer"]; ok {
+				p.Mapping.Answer = i
+			}
+			if i, ok := m["tags"]; ok {
+				p.Mapping.Tags = &i
+			}
+			if i, ok := m["category"]; ok {
+				p.Mapping.Category = &i
+			}
+		}
+	}
+	for i := range p.Columns {
+		if t.hasHeader && i < len(t.records[0]) && strings.TrimSpace(t.records[0][i]) != "" {
+			p.Columns[i] = strings.TrimSpace(t.records[0][i])
+		} else {
+			p.Columns[i] = "Колонка " + strconv.Itoa(i+1)
+		}
+	}
+	for _, rec := range data {
+		if blankRecord(rec) {
+			continue
+		}
+		p.TotalRows++
+		if len(p.Rows) < csvPreviewRows {
+			p.Rows = append(p.Rows, rec)
+		}
+	}
+	return p
+}
+
+func csvCell(rec []string, col int) string {
+	if col < len(rec) {
+		return strings.TrimSpace(rec[col])
+	}
+	return ""
+}
+
+func blankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
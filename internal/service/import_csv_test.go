@@ -0,0 +1,115 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want rune
+	}{
+		{"comma", "question,answer\ncat,кошка\ndog,собака", ','},
+		{"semicolon from excel", "вопрос;ответ\nкот;cat\nпёс;dog", ';'},
+		{"tab", "cat\tкошка, кот\ndog\tсобака, пёс", '\t'},
+		{"pipe", "a|b|c\nd|e|f", '|'},
+		{"comma inside quotes", "\"a;b\",c\n\"d;e\",f", ','},
+		{"consistent width wins", "a,b;c\nd,e;f\ng,h,i;j", ';'},
+		{"wider on tie", "a;b,c,d\ne;f,g,h", ','},
+		{"priority on full tie", "a;b,c\nd;e,f", ';'},
+		{"single column", "cat\ndog", ','},
+		{"empty", "", ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sniffDelimiter(tt.text); got != tt.want {
+				t.Errorf("sniffDelimiter(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	cp1251, err := charmap.Windows1251.NewEncoder().String("вопрос;ответ\nкот;кошка\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes, no := true, false
+	tests := []struct {
+		name      string
+		data      string
+		opts      domain.CSVParseOptions
+		encoding  string
+		delimiter rune
+		hasHeader bool
+		records   [][]string
+	}{
+		{"utf-8 with header", "question,answer\ncat,кошка\n", domain.CSVParseOptions{},
+			domain.EncodingUTF8, ',', true, [][]string{{"question", "answer"}, {"cat", "кошка"}}},
+		{"utf-8 bom", "\ufeffcat\tкошка\ndog\tсобака\n", domain.CSVParseOptions{},
+			domain.EncodingUTF8, '\t', false, [][]string{{"cat", "кошка"}, {"dog", "собака"}}},
+		{"cp1251 detected", cp1251, domain.CSVParseOptions{},
+			domain.EncodingCP1251, ';', true, [][]string{{"вопрос", "ответ"}, {"кот", "кошка"}}},
+		{"explicit options", "question|answer\ncat|кошка\n", domain.CSVParseOptions{Delimiter: "|", HasHeader: &no},
+			domain.EncodingUTF8, '|', false, [][]string{{"question", "answer"}, {"cat", "кошка"}}},
+		{"tab alias and header forced", "a\tb\nc\td\n", domain.CSVParseOptions{Delimiter: "tab", HasHeader: &yes},
+			domain.EncodingUTF8, '\t', true, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"lazy quotes", "a,say \"hi\"\n\"multi\nline\",b\n", domain.CSVParseOptions{},
+			domain.EncodingUTF8, ',', false, [][]string{{"a", `say "hi"`}, {"multi\nline", "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "table.csv")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readCSV(path, tt.opts)
+			if err != nil {
+				t.Fatalf("readCSV: %v", err)
+			}
+			if got.encoding != tt.encoding || got.delimiter != tt.delimiter || got.hasHeader != tt.hasHeader {
+				t.Errorf("encoding, delimiter, header = %s, %q, %v; want %s, %q, %v",
+					got.encoding, got.delimiter, got.hasHeader, tt.encoding, tt.delimiter, tt.hasHeader)
+			}
+			if !reflect.DeepEqual(got.records, tt.records) {
+				t.Errorf("records = %q, want %q", got.records, tt.records)
+			}
+		})
+	}
+}
+
+func TestReadCSVInvalidDelimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.csv")
+	if err := os.WriteFile(path, []byte("a,b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{`"`, "ab", "\n"} {
+		if _, err := readCSV(path, domain.CSVParseOptions{Delimiter: d}); err != ErrCSVInvalid {
+			t.Errorf("delimiter %q: error = %v, want ErrCSVInvalid", d, err)
+		}
+	}
+}
+
+func TestHeaderMapping(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   map[string]int
+	}{
+		{[]string{"Question", "Answer"}, map[string]int{"question": 0, "answer": 1}},
+		{[]string{" Термин ", "Определение", "Теги", "Рубрика"}, map[string]int{"question": 0, "answer": 1, "tags": 2, "category": 3}},
+		{[]string{"front", "back", "front"}, map[string]int{"question": 0, "answer": 1}},
+		{[]string{"cat", "кошка"}, map[string]int{}},
+	}
+	for _, tt := range tests {
+		if got := headerMapping(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("headerMapping(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+var (
+	ErrBackupFormat  = errors.New("файл не является резервной копией набора")
+	ErrBackupVersion = errors.New("версия резервной копии не поддерживается")
+)
+
+// jsonJob — параметры задачи восстановления резервной копии.
+type jsonJob struct {
+	Path     string `json:"path"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// ImportJSON ставит в очередь восстановление набора из резервной копии (GET /decks/:id/export?format=json).
+// Файл разбирается и проверяется сразу, чтобы ошибка формата вернулась в ответе на загрузку;
+// задача читает его заново и удаляет.
+func (s *ImportService) ImportJSON(ctx context.Context, userID int, path string, parentID *int) (*domain.Job, error) {
+	if parentID != nil {
+		if err := s.checkDeck(ctx, *parentID, userID); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	if _, err := readBackup(path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	job, err := s.jobs.Enqueue(ctx, userID, domain.JobImportJSON, "", jsonJob{Path: path, ParentID: parentID})
+	if err != nil {
+		os.Remove(path)
+	}
+	return job, err
+}
+
+func (s *ImportService) runJSONJob(ctx context.Context, job *domain.Job, progress func(done, total int)) (interface{}, error) {
+	var p jsonJob
+	if err := decodePayload(job, &p); err != nil {
+		return nil, err
+	}
+	defer os.Remove(p.Path)
+	b, err := readBackup(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	r := &backupRestore{
+		s: s, userID: job.UserID, progress: progress,
+		res:        &domain.ImportResult{Decks: []domain.DeckBrief{}},
+		tags:       make(map[string]int),
+		categories: make(map[domain.BackupCategory]*int),
+		total:      countBackupCards(&b.Deck),
+	}
+	progress(0, r.total)
+	err = r.deck(ctx, &b.Deck, p.ParentID, "")
+	for _, id := range r.tags {
+		if id != 0 {
+			r.res.TagsUsed++
+		}
+	}
+	return r.res, err
+}
+
+// readBackup читает и проверяет резервную копию набора.
+func readBackup(path string) (*domain.DeckBackup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var b domain.DeckBackup
+	if err := json.NewDecoder(f).Decode(&b); err != nil || b.Format != domain.BackupFormat {
+		return nil, ErrBackupFormat
+	}
+	if b.Version < 1 || b.Version > domain.BackupVersion {
+		return nil, ErrBackupVersion
+	}
+	return &b, nil
+}
+
+// backupRestore — состояние восстановления резервной копии.
+type backupRestore struct {
+	s          *ImportService
+	userID     int
+	progress   func(done, total int)
+	res        *domain.ImportResult
+	tags       map[string]int
+	categories map[domain.BackupCategory]*int
+	done       int
+	total      int
+}
+
+func (r *backupRestore) warn(msg string) {
+	if len(r.res.Warnings) < importMaxWarnings {
+		r.res.Warnings = append(r.res.Warnings, msg)
+	}
+}
+
+// deck создаёт набор копии внутри parentID, затем его карточки и вложенные наборы.
+func (r *backupRestore) deck(ctx context.Context, bd *domain.BackupDeck, parentID *int, path string) error {
+	title := strings.TrimSpace(bd.Title)
+	if title == "" {
+		title = "Без названия"
+	}
+	if utf8.RuneCountInString(title) > 255 {
+		title = string([]rune(title)[:255])
+	}
+	if path != "" {
+		path += "::"
+	}
+	path += title
+	d := &domain.Deck{
+		UserID:      r.userID,
+		Title:       title,
+		Description: bd.Description,
+		CategoryID:  r.category(ctx, bd.Category),
+		IsPublic:    bd.IsPublic,
+		ParentID:    parentID,
+	}
+	if err := r.s.deckRepo.Create(ctx, d); err != nil {
+		return err
+	}
+	r.res.Decks = append(r.res.Decks, domain.DeckBrief{ID: d.ID, Title: path})
+	if tagIDs := r.s.importTags(ctx, r.userID, bd.Tags, r.tags, r.warn); len(tagIDs) > 0 {
+		if err := r.s.deckRepo.SetDeckTags(ctx, d.ID, tagIDs); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(bd.Cards); start += importBatchSize {
+		chunk := bd.Cards[start:min(start+importBatchSize, len(bd.Cards))]
+		cards := make([]domain.Card, 0, len(chunk))
+		cardTags := make([][]int, 0, len(chunk))
+		for i, bc := range chunk {
+			if strings.TrimSpace(bc.Question) == "" || strings.TrimSpace(bc.Answer) == "" {
+				r.res.CardsSkipped++
+				r.warn(fmt.Sprintf("%s: карточка %d пропущена — пустой вопрос или ответ", path, start+i+1))
+				continue
+			}
+			cards = append(cards, domain.Card{DeckID: d.ID, Question: bc.Question, Answer: bc.Answer, CategoryID: r.category(ctx, bc.Category)})
+			cardTags = append(cardTags, r.s.importTags(ctx, r.userID, bc.Tags, r.tags, r.warn))
+		}
+		if err := r.s.cardRepo.CreateBatch(ctx, cards, cardTags); err != nil {
+			return err
+		}
+		r.res.CardsCreated += len(cards)
+		r.done += len(chunk)
+		r.progress(r.done, r.total)
+	}
+
+	for i := range bd.SubDecks {
+		if err := r.deck(ctx, &bd.SubDecks[i], &d.ID, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// category находит категорию копии по slug, затем по названию; ненайденная категория не ставится.
+func (r *backupRestore) category(ctx context.Context, c *domain.BackupCategory) *int {
+	if c == nil {
+		return nil
+	}
+	id, ok := r.categories[*c]
+	if !ok {
+		if found, err := r.s.categoryRepo.GetBySlug(ctx, c.Slug); err == nil && found != nil {
+			id = &found.ID
+		} else if c.Name != "" {
+			id = r.s.findCategory(ctx, c.Name)
+		}
+		if id == nil {
+			r.warn("категория не найдена: " + c.Name)
+		}
+		r.categories[*c] = id
+	}
+	return id
+}
+
+func countBackupCards(d *domain.BackupDeck) int {
+	n := len(d.Cards)
+	for i := range d.SubDecks {
+		n += countBackupCards(&d.SubDecks[i])
+	}
+	return n
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+var ErrMarkdownEmpty = errors.New("в конспекте не найдено карточек")
+
+var (
+	mdHeadingRe    = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	mdQuestionRe   = regexp.MustCompile(`^(?i:q):\s*(.*)$`)
+	mdAnswerRe     = regexp.MustCompile(`^(?i:a):\s*(.*)$`)
+	mdDefinitionRe = regexp.MustCompile(`^ {0,3}:\s+(.*)$`)
+	mdListRe       = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	mdAnchorRe     = regexp.MustCompile(`\s*\^([A-Za-z0-9][A-Za-z0-9_-]{0,99})\s*$`)
+	mdClozeRe      = regexp.MustCompile(`==([^=\n]+?)==`)
+	mdSpaceRe      = regexp.MustCompile(`\s+`)
+)
+
+// mdPathSeparator разделяет заголовки в пути набора (domain.MarkdownCardItem.Deck).
+const mdPathSeparator = " / "
+
+// mdContinuation — отступ строки, которая всегда продолжает поле пары Q:/A:; так выгрузка
+// в Markdown записывает многострочные вопросы и ответы.
+const mdContinuation = "    "
+
+// Виды карточек конспекта (domain.MarkdownCardItem.Kind).
+const (
+	mdKindQA         = "qa"
+	mdKindDefinition = "definition"
+	mdKindInline     = "inline"
+	mdKindCloze      = "cloze"
+)
+
+// mdCard — карточка, найденная в конспекте; deck — путь заголовков, anchor — явный якорь (^id) или "".
+type mdCard struct {
+	line     int
+	deck     []string
+	kind     string
+	question string
+	answer   string
+	anchor   string
+}
+
+// mdParser разбирает конспект построчно. Незавершённые блоки (пара Q:/A:, определение, строка,
+// которая может оказаться термином списка определений) закрываются пустой строкой, заголовком
+// или началом другого блока.
+type mdParser struct {
+	deck   []string
+	levels []int
+	decks  [][]string
+	seen   map[string]bool
+	cards  []mdCard
+
+	qa       *mdCard
+	inAnswer bool
+	def      *mdCard
+	plain    string
+	plainAt  int
+}
+
+// parseMarkdown находит в конспекте наборы (заголовки) и карточки:
+//
+//	Q: вопрос          Термин               термин :: определение
+//	A: ответ           : определение        В ==1961== году полетел Гагарин.
+//
+// Строки пар Q:/A: и определений могут продолжаться на следующих строках; строка пары
+// с отступом в четыре пробела или табуляцией продолжает поле как есть, даже пустая или похожая
+// на заголовок, Q:/A: или блок кода. « ^id» в конце карточки задаёт явный якорь. Блоки кода
+// пропускаются.
+func parseMarkdown(text string) *mdParser {
+	p := &mdParser{seen: make(map[string]bool)}
+	text = strings.ReplaceAll(strings.TrimPrefix(text, "\ufeff"), "\r\n", "\n")
+	fence := ""
+	for i, line := range strings.Split(text, "\n") {
+		n := i + 1
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if rest, ok := mdContinued(line); ok && p.qa != nil {
+			if p.inAnswer {
+				p.qa.answer += "\n" + rest
+			} else {
+				p.qa.question += "\n" + rest
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			p.flush()
+			fence = trimmed[:3]
+			continue
+		}
+		if m := mdHeadingRe.FindStringSubmatch(line); m != nil {
+			p.flush()
+			p.heading(len(m[1]), m[2])
+			continue
+		}
+		if trimmed == "" {
+			p.flush()
+			continue
+		}
+		if m := mdQuestionRe.FindStringSubmatch(trimmed); m != nil {
+			p.flush()
+			p.qa = &mdCard{line: n, deck: p.deck, kind: mdKindQA, question: m[1]}
+			p.inAnswer = false
+			continue
+		}
+		if p.qa != nil {
+			if m := mdAnswerRe.FindStringSubmatch(trimmed); m != nil && !p.inAnswer {
+				p.inAnswer = true
+				p.qa.answer = m[1]
+			} else if p.inAnswer {
+				p.qa.answer += "\n" + trimmed
+			} else {
+				p.qa.question += "\n" + trimmed
+			}
+			continue
+		}
+		if m := mdDefinitionRe.FindStringSubmatch(line); m != nil {
+			if p.def != nil {
+				p.def.answer += "\n" + m[1]
+				continue
+			}
+			if p.plainAt > 0 {
+				p.def = &mdCard{line: p.plainAt, deck: p.deck, kind: mdKindDefinition, question: strings.TrimSpace(p.plain), answer: m[1]}
+				p.plainAt = 0
+				continue
+			}
+		}
+		if p.def != nil && (line[0] == ' ' || line[0] == '\t') {
+			p.def.answer += "\n" + trimmed
+			continue
+		}
+		p.flush()
+		p.plain, p.plainAt = line, n
+	}
+	p.flush()
+	return p
+}
+
+// mdContinued отрезает отступ строки-продолжения пары Q:/A: (mdContinuation или табуляция).
+func mdContinued(line string) (string, bool) {
+	for _, indent := range []string{mdContinuation, "\t"} {
+		if rest, ok := strings.CutPrefix(line, indent); ok {
+			return strings.TrimRight(rest, " \t"), true
+		}
+	}
+	return "", false
+}
+
+// heading открывает набор уровня level: он вложен в ближайший заголовок уровнем выше.
+func (p *mdParser) heading(level int, title string) {
+	title, _ = takeAnchor(strings.TrimSpace(title))
+	if title == "" {
+		return
+	}
+	if utf8.RuneCountInString(title) > 255 {
+		title = string([]rune(title)[:255])
+	}
+	for len(p.levels) > 0 && p.levels[len(p.levels)-1] >= level {
+		p.levels = p.levels[:len(p.levels)-1]
+		p.deck = p.deck[:len(p.deck)-1]
+	}
+	p.levels = append(p.levels, level)
+	// новый срез: пути уже найденных карточек не должны меняться
+	p.deck = append(append([]string{}, p.deck...), title)
+	if key := strings.Join(p.deck, mdPathSeparator); !p.seen[key] {
+		p.seen[key] = true
+		p.decks = append(p.decks, p.deck)
+	}
+}
+
+func (p *mdParser) flush() {
+	if p.qa != nil {
+		p.add(*p.qa)
+		p.qa = nil
+	}
+	if p.def != nil {
+		p.add(*p.def)
+		p.def = nil
+	}
+	if p.plainAt > 0 {
+		p.plainLine(p.plain, p.plainAt)
+		p.plainAt = 0
+	}
+}
+
+// plainLine ищет в обычной строке «термин :: определение» или выделения-пропуски ==...==.
+func (p *mdParser) plainLine(line string, n int) {
+	line, anchor := takeAnchor(strings.TrimSpace(mdListRe.ReplaceAllString(line, "")))
+	if term, def, ok := strings.Cut(line, " :: "); ok {
+		p.add(mdCard{line: n, deck: p.deck, kind: mdKindInline, question: term, answer: def, anchor: anchor})
+		return
+	}
+	matches := mdClozeRe.FindAllStringSubmatchIndex(line, -1)
+	for k, m := range matches {
+		var q strings.Builder
+		last := 0
+		for j, o := range matches {
+			q.WriteString(line[last:o[0]])
+			if j == k {
+				q.WriteString("[...]")
+			} else {
+				q.WriteString(line[o[2]:o[3]])
+			}
+			last = o[1]
+		}
+		q.WriteString(line[last:])
+		c := mdCard{line: n, deck: p.deck, kind: mdKindCloze, question: q.String(), answer: line[m[2]:m[3]]}
+		if anchor != "" {
+			c.anchor = anchor
+			if k > 0 {
+				c.anchor += "-" + strconv.Itoa(k+1)
+			}
+		}
+		p.add(c)
+	}
+}
+
+func (p *mdParser) add(c mdCard) {
+	var anchor string
+	c.question, anchor = takeAnchor(strings.TrimSpace(c.question))
+	if c.anchor == "" {
+		c.anchor = anchor
+	}
+	c.answer, anchor = takeAnchor(strings.TrimSpace(c.answer))
+	if c.anchor == "" {
+		c.anchor = anchor
+	}
+	p.cards = append(p.cards, c)
+}
+
+// takeAnchor отрезает явный якорь « ^id» в конце текста.
+func takeAnchor(s string) (string, string) {
+	m := mdAnchorRe.FindStringSubmatchIndex(s)
+	if m == nil {
+		return s, ""
+	}
+	return strings.TrimSpace(s[:m[0]]), s[m[2]:m[3]]
+}
+
+// stableAnchor — якорь карточки для повторного импорта: явный ^id или хеш пути набора и вопроса,
+// поэтому правка ответа обновляет карточку, а правка вопроса без явного якоря создаёт новую.
+func (c *mdCard) stableAnchor() string {
+	if c.anchor != "" {
+		return "id:" + c.anchor
+	}
+	q := strings.ToLower(mdSpaceRe.ReplaceAllString(c.question, " "))
+	sum := sha1.Sum([]byte(strings.Join(c.deck, "\x1f") + "\x00" + q))
+	return "h:" + hex.EncodeToString(sum[:12])
+}
+
+// ImportMarkdown создаёт карточки из конспекта; карточки с уже известным якорем обновляются,
+// наборы из заголовков находятся по названию внутри родителя или создаются. Якоря ищутся только
+// внутри корня импорта: deck_id или найденных наборов заголовков верхнего уровня. validate
+// проверяет карточку по правилам domain.CreateCardRequest. Наборы и карточки сохраняются одной
+// транзакцией; в dry_run ничего не сохраняется: ответ показывает, какие наборы будут созданы
+// и что станет с каждой карточкой. Карточки, исчезнувшие из конспекта, не удаляются.
+func (s *ImportService) ImportMarkdown(ctx context.Context, userID int, req domain.MarkdownImportRequest, validate func(card *domain.CreateCardRequest) map[string]string) (*domain.MarkdownImportResponse, error) {
+	var roots []int
+	if req.DeckID != nil {
+		if err := s.checkDeck(ctx, *req.DeckID, userID); err != nil {
+			return nil, err
+		}
+		roots = append(roots, *req.DeckID)
+	}
+	doc := parseMarkdown(req.Text)
+	if len(doc.cards) == 0 {
+		return nil, ErrMarkdownEmpty
+	}
+	resp := &domain.MarkdownImportResponse{DryRun: req.DryRun, Decks: []domain.MarkdownDeckItem{}, Cards: make([]domain.MarkdownCardItem, len(doc.cards))}
+
+	// наборы: родитель всегда встречается раньше вложенного. Новые наборы создаются вместе
+	// с карточками, до этого их ID — ссылка -(i+1) на newDecks[i] (см. CardRepository.SaveAnchored)
+	deckIDs := map[string]*int{"": req.DeckID}
+	var newDecks []domain.Deck
+	var newDeckItems []int
+	for _, path := range doc.decks {
+		key := strings.Join(path, mdPathSeparator)
+		parentKey := strings.Join(path[:len(path)-1], mdPathSeparator)
+		parentID := deckIDs[parentKey]
+		item := domain.MarkdownDeckItem{Path: key}
+		id := 0
+		if parentID == nil || *parentID > 0 {
+			d, err := s.deckRepo.FindChild(ctx, userID, parentID, path[len(path)-1])
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				id = d.ID
+				item.ID = &d.ID
+				if parentID == nil {
+					roots = append(roots, d.ID)
+				}
+			}
+		}
+		if id == 0 {
+			item.Created = true
+			newDecks = append(newDecks, domain.Deck{UserID: userID, Title: path[len(path)-1], ParentID: parentID})
+			newDeckItems = append(newDeckItems, len(resp.Decks))
+			id = -len(newDecks)
+		}
+		deckIDs[key] = &id
+		resp.Decks = append(resp.Decks, item)
+	}
+
+	anchors := make([]string, len(doc.cards))
+	for i := range doc.cards {
+		anchors[i] = doc.cards[i].stableAnchor()
+	}
+	existing, err := s.cardRepo.ListByAnchors(ctx, userID, roots, anchors)
+	if err != nil {
+		return nil, err
+	}
+	var create, update []domain.Card
+	var createAnchors []string
+	var createIdx []int
+	used := make(map[string]bool, len(anchors))
+	for i, c := range doc.cards {
+		deckKey := strings.Join(c.deck, mdPathSeparator)
+		item := domain.MarkdownCardItem{
+			Line: c.line, Deck: deckKey, Kind: c.kind, Anchor: anchors[i],
+			Question: c.question, Answer: c.answer, Action: domain.MarkdownSkip,
+		}
+		deckID := deckIDs[deckKey]
+		switch {
+		case c.question == "" || c.answer == "":
+			item.Error = "пустой вопрос или ответ"
+		case used[anchors[i]]:
+			item.Error = "якорь повторяется в конспекте"
+		case deckID == nil:
+			item.Error = "карточка до первого заголовка: укажите deck_id"
+		default:
+			item.Error = cardErrors(validate(&domain.CreateCardRequest{Question: c.question, Answer: c.answer}))
+		}
+		used[anchors[i]] = true
+		if item.Error != "" {
+			resp.Skipped++
+			resp.Cards[i] = item
+			continue
+		}
+		if old, ok := existing[anchors[i]]; ok {
+			item.CardID = &old.ID
+			if old.DeckID == *deckID && old.Question == c.question && old.Answer == c.answer {
+				item.Action = domain.MarkdownUnchanged
+				resp.Unchanged++
+			} else {
+				item.Action = domain.MarkdownUpdate
+				resp.Updated++
+				update = append(update, domain.Card{ID: old.ID, DeckID: *deckID, Question: c.question, Answer: c.answer})
+			}
+		} else {
+			item.Action = domain.MarkdownCreate
+			resp.Created++
+			create = append(create, domain.Card{DeckID: *deckID, Question: c.question, Answer: c.answer})
+			createAnchors = append(createAnchors, anchors[i])
+			createIdx = append(createIdx, i)
+		}
+		resp.Cards[i] = item
+	}
+	if req.DryRun {
+		return resp, nil
+	}
+	if err := s.cardRepo.SaveAnchored(ctx, newDecks, create, createAnchors, update); err != nil {
+		return nil, err
+	}
+	for j, k := range newDeckItems {
+		id := newDecks[j].ID
+		resp.Decks[k].ID = &id
+	}
+	for j, i := range createIdx {
+		id := create[j].ID
+		resp.Cards[i].CardID = &id
+	}
+	return resp, nil
+}
+
+// cardErrors собирает ошибки проверки карточки в одну строку: «question: максимум 10000 символов».
+func cardErrors(errs map[string]string) string {
+	var parts []string
+	for _, field := range []string{"question", "answer"} {
+		if msg, ok := errs[field]; ok {
+			parts = append(parts, field+": "+msg)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
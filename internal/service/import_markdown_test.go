@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		decks [][]string
+		cards []mdCard
+	}{
+		{"question and answer",
+			"Q: Столица Франции?\nA: Париж",
+			nil,
+			[]mdCard{{line: 1, kind: mdKindQA, question: "Столица Франции?", answer: "Париж"}}},
+		{"lowercase labels and multiline",
+			"q: Назовите\nтри цвета\na: красный\nжёлтый\nзелёный",
+			nil,
+			[]mdCard{{line: 1, kind: mdKindQA, question: "Назовите\nтри цвета", answer: "красный\nжёлтый\nзелёный"}}},
+		{"indented continuation keeps special lines",
+			"Q: первая строка\n    # не заголовок\n    \n    Q: не вопрос\nA: ответ\n    ```\n    A: не ответ",
+			nil,
+			[]mdCard{{line: 1, kind: mdKindQA, question: "первая строка\n# не заголовок\n\nQ: не вопрос", answer: "ответ\n```\nA: не ответ"}}},
+		{"headings are nested decks",
+			"# Биология\n## Клетка\nQ: Органоид дыхания?\nA: Митохондрия\n## Ткани\n# Химия\nВода :: H2O",
+			[][]string{{"Биология"}, {"Биология", "Клетка"}, {"Биология", "Ткани"}, {"Химия"}},
+			[]mdCard{
+				{line: 3, deck: []string{"Биология", "Клетка"}, kind: mdKindQA, question: "Органоид дыхания?", answer: "Митохондрия"},
+				{line: 7, deck: []string{"Химия"}, kind: mdKindInline, question: "Вода", answer: "H2O"},
+			}},
+		{"skipped heading level",
+			"# A\n### B\n## C\nx :: y",
+			[][]string{{"A"}, {"A", "B"}, {"A", "C"}},
+			[]mdCard{{line: 4, deck: []string{"A", "C"}, kind: mdKindInline, question: "x", answer: "y"}}},
+		{"definition list",
+			"Фотосинтез\n: образование органических веществ\n  на свету\n\nОсмос\n: диффузия воды",
+			nil,
+			[]mdCard{
+				{line: 1, kind: mdKindDefinition, question: "Фотосинтез", answer: "образование органических веществ\nна свету"},
+				{line: 5, kind: mdKindDefinition, question: "Осмос", answer: "диффузия воды"},
+			}},
+		{"inline in list item",
+			"- кот :: cat\n1. собака :: dog",
+			nil,
+			[]mdCard{
+				{line: 1, kind: mdKindInline, question: "кот", answer: "cat"},
+				{line: 2, kind: mdKindInline, question: "собака", answer: "dog"},
+			}},
+		{"cloze with several gaps",
+			"В ==1961== году ==Гагарин== полетел в космос ^gagarin",
+			nil,
+			[]mdCard{
+				{line: 1, kind: mdKindCloze, question: "В [...] году Гагарин полетел в космос", answer: "1961", anchor: "gagarin"},
+				{line: 1, kind: mdKindCloze, question: "В 1961 году [...] полетел в космос", answer: "Гагарин", anchor: "gagarin-2"},
+			}},
+		{"explicit anchor after answer",
+			"Q: 2+2?\nA: 4 ^sum-1",
+			nil,
+			[]mdCard{{line: 1, kind: mdKindQA, question: "2+2?", answer: "4", anchor: "sum-1"}}},
+		{"code block skipped",
+			"```\nQ: внутри кода\nA: нет\n```\nQ: снаружи\nA: да",
+			nil,
+			[]mdCard{{line: 5, kind: mdKindQA, question: "снаружи", answer: "да"}}},
+		{"plain text ignored",
+			"Просто абзац без карточек.\nЕщё одна строка.",
+			nil,
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parseMarkdown(tt.text)
+			if !reflect.DeepEqual(p.decks, tt.decks) {
+				t.Errorf("decks = %q, want %q", p.decks, tt.decks)
+			}
+			if !reflect.DeepEqual(p.cards, tt.cards) {
+				t.Errorf("cards =\n%+v\nwant\n%+v", p.cards, tt.cards)
+			}
+		})
+	}
+}
+
+// Выгрузка в Markdown должна читаться импортом конспектов без потерь.
+func TestMarkdownFieldRoundTrip(t *testing.T) {
+	tests := []struct {
+		name             string
+		question, answer string
+	}{
+		{"single line", "Вопрос", "Ответ"},
+		{"multiline", "строка 1\nстрока 2", "ответ 1\nответ 2"},
+		{"blank line inside", "абзац 1\n\nабзац 2", "a\n\nb"},
+		{"looks like markup", "начало\n# заголовок\nQ: вопрос", "начало\n```\nA: ответ\n~~~"},
+		{"nested indentation", "код:\n  if x {\n    y()\n  }", "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			bw := bufio.NewWriter(&buf)
+			writeMarkdownField(bw, "Q: ", tt.question)
+			writeMarkdownField(bw, "A: ", tt.answer)
+			bw.WriteString("\nTags: a, b\n")
+			bw.Flush()
+			p := parseMarkdown(buf.String())
+			if len(p.cards) != 1 {
+				t.Fatalf("cards = %+v, want one card from %q", p.cards, buf.String())
+			}
+			if c := p.cards[0]; c.question != tt.question || c.answer != tt.answer {
+				t.Errorf("card = %q / %q, want %q / %q", c.question, c.answer, tt.question, tt.answer)
+			}
+		})
+	}
+}
+
+func TestStableAnchor(t *testing.T) {
+	base := mdCard{deck: []string{"A", "B"}, question: "Столица  Франции?", answer: "Париж"}
+	same := base
+	same.question = "столица франции?"
+	same.answer = "Paris"
+	if base.stableAnchor() != same.stableAnchor() {
+		t.Error("anchor must not depend on answer, case and spaces")
+	}
+	moved := base
+	moved.deck = []string{"A", "C"}
+	if base.stableAnchor() == moved.stableAnchor() {
+		t.Error("anchor must depend on deck path")
+	}
+	explicit := base
+	explicit.anchor = "capital"
+	if got := explicit.stableAnchor(); got != "id:capital" {
+		t.Errorf("explicit anchor = %q, want id:capital", got)
+	}
+}
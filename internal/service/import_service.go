@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+const importBatchSize = 500 // карточек на одну транзакцию
+
+// Ограничения медиафайлов импорта: архив распаковывается на диск загрузок, а сжатый
+// файл может быть во много раз меньше своего содержимого.
+const (
+	maxMediaFileSize   = 100 << 20 // один файл
+	maxImportMediaSize = 2 << 30   // все файлы одного импорта
+)
+
+var ErrMediaTooLarge = errors.New("медиафайл слишком большой или превышен объём медиафайлов импорта")
+
+// mediaQuota — сколько байт медиафайлов уже сохранено одним импортом и в какие файлы.
+type mediaQuota struct {
+	used  int64
+	files []string
+}
+
+// remove удаляет сохранённые импортом медиафайлы при его откате.
+func (q *mediaQuota) remove() {
+	for _, f := range q.files {
+		os.Remove(f)
+	}
+	q.used, q.files = 0, nil
+}
+
+var mediaExtRe = regexp.MustCompile(`^\.[a-z0-9]{1,8}$`)
+
+// ImportService импортирует наборы из внешних форматов. Долгие импорты выполняются
+// фоновыми задачами JobService.
+type ImportService struct {
+	deckRepo     *repository.DeckRepository
+	cardRepo     *repository.CardRepository
+	progressRepo *repository.CardProgressRepository
+	categoryRepo *repository.CategoryRepository
+	tags         *TagService
+	cards        *CardService
+	jobs         *JobService
+	uploadPath   string // корень загрузок (например ./uploads)
+	baseURL      string // например http://localhost:8080
+}
+
+func NewImportService(deckRepo *repository.DeckRepository, cardRepo *repository.CardRepository, progressRepo *repository.CardProgressRepository, categoryRepo *repository.CategoryRepository, tags *TagService, cards *CardService) *ImportService {
+	return &ImportService{
+		deckRepo:     deckRepo,
+		cardRepo:     cardRepo,
+		progressRepo: progressRepo,
+		categoryRepo: categoryRepo,
+		tags:         tags,
+		cards:        cards,
+	}
+}
+
+func (s *ImportService) SetUploadConfig(uploadPath, baseURL string) {
+	s.uploadPath = uploadPath
+	s.baseURL = baseURL
+}
+
+// RegisterJobs регистрирует задачи импорта в jobs. Импорт не повторяется после сбоя:
+// новая попытка создала бы уже созданные наборы ещё раз.
+func (s *ImportService) RegisterJobs(jobs *JobService) {
+	s.jobs = jobs
+	jobs.Register(domain.JobImportAnki, JobKind{Run: s.runAnkiJob})
+	jobs.Register(domain.JobImportJSON, JobKind{Run: s.runJSONJob})
+	jobs.Register(domain.JobCleanupCSV, JobKind{Run: s.cleanupCSVUploads, Every: 10 * time.Minute})
+}
+
+// SaveUpload сохраняет загруженный для импорта файл в каталог входных файлов задач и возвращает путь.
+func (s *ImportService) SaveUpload(r io.Reader, pattern string) (string, error) {
+	return s.jobs.SaveInput(r, pattern)
+}
+
+// checkDeck проверяет, что набор deckID существует и принадлежит пользователю.
+func (s *ImportService) checkDeck(ctx context.Context, deckID int, userID int) error {
+	d, err := s.deckRepo.GetByID(ctx, deckID)
+	if err != nil || d == nil {
+		return ErrDeckNotFound
+	}
+	if d.UserID != userID {
+		return ErrDeckForbidden
+	}
+	return nil
+}
+
+// importTags возвращает ID тегов с именами names, создавая недостающие личные теги.
+// cache хранит уже найденные теги (0 — имя не подходит для тега).
+func (s *ImportService) importTags(ctx context.Context, userID int, names []string, cache map[string]int, warn func(string)) []int {
+	var ids []int
+	for _, name := range names {
+		id, ok := cache[name]
+		if !ok {
+			if utf8.RuneCountInString(name) <= 100 {
+				if t, err := s.tags.EnsureTag(ctx, userID, name); err == nil {
+					id = t.ID
+				}
+			}
+			if id == 0 {
+				warn("тег пропущен: " + name)
+			}
+			cache[name] = id
+		}
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// saveMedia сохраняет медиафайл в uploadPath/media/{userID}/{uuid}.ext и возвращает URL.
+// Файл больше maxMediaFileSize или сверх остатка квоты импорта q не сохраняется (ErrMediaTooLarge).
+func (s *ImportService) saveMedia(userID int, name string, r io.Reader, q *mediaQuota) (string, error) {
+	limit := min(int64(maxMediaFileSize), maxImportMediaSize-q.used)
+	if limit <= 0 {
+		return "", ErrMediaTooLarge
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	if !mediaExtRe.MatchString(ext) {
+		ext = ""
+	}
+	file := uuid.New().String() + ext
+	dir := filepath.Join(s.uploadPath, "media", strconv.Itoa(userID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err == nil && n > limit {
+		err = ErrMediaTooLarge
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	q.used += n
+	q.files = append(q.files, f.Name())
+	return s.baseURL + "/uploads/media/" + strconv.Itoa(userID) + "/" + file, nil
+}
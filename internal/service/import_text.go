@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+var (
+	ErrTextSeparator    = errors.New("разделители термина и карточек совпадают")
+	ErrTextTooManyCards = errors.New("слишком много карточек")
+)
+
+const errTextNoSeparator = "нет разделителя между термином и определением"
+
+// textSeparatorAliases — имена разделителей, которые удобно передавать в JSON.
+var textSeparatorAliases = map[string]string{
+	"tab":        "\t",
+	"comma":      ",",
+	"semicolon":  ";",
+	"dash":       " - ",
+	"newline":    "\n",
+	"blank_line": "\n\n",
+}
+
+// textRecord — карточка текста до разбора на поля; line — строка, с которой она начинается.
+type textRecord struct {
+	line   int
+	fields []string
+}
+
+// TextCards разбирает вставленный текст на карточки для набора req.DeckID.
+// Ошибки отдельных карточек (нет разделителя) записываются в TextCard.Errors.
+func (s *ImportService) TextCards(ctx context.Context, userID int, req domain.TextImportRequest) ([]domain.TextCard, error) {
+	if err := s.checkDeck(ctx, req.DeckID, userID); err != nil {
+		return nil, err
+	}
+	return parseText(req.Text, textSeparator(req.TermSeparator, "\t"), textSeparator(req.CardSeparator, "\n"))
+}
+
+// ImportText создаёт разобранные карточки через CardService.BulkCreate; ошибки возвращаются с номерами строк.
+func (s *ImportService) ImportText(ctx context.Context, userID int, req domain.TextImportRequest, cards []domain.TextCard) (*domain.TextImportResponse, error) {
+	bulk := domain.BulkCreateCardsRequest{Mode: req.Mode, Cards: make([]domain.CreateCardRequest, len(cards))}
+	invalid := make(map[int]map[string]string)
+	for i, c := range cards {
+		bulk.Cards[i] = domain.CreateCardRequest{Question: c.Question, Answer: c.Answer}
+		if len(c.Errors) > 0 {
+			invalid[i] = c.Errors
+		}
+	}
+	res, err := s.cards.BulkCreate(ctx, req.DeckID, userID, bulk, invalid, domain.DuplicatesNone)
+	var resp *domain.TextImportResponse
+	if res != nil {
+		resp = &domain.TextImportResponse{Mode: res.Mode, Created: len(res.Cards)}
+		for _, e := range res.Errors {
+			c := cards[e.Index]
+			c.Errors = e.Errors
+			resp.Errors = append(resp.Errors, c)
+		}
+	}
+	return resp, err
+}
+
+// textSeparator раскрывает имя разделителя (tab, newline, ...) и escape-последовательности \t, \n.
+func textSeparator(name, def string) string {
+	if name == "" {
+		return def
+	}
+	if sep, ok := textSeparatorAliases[name]; ok {
+		return sep
+	}
+	return strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(name)
+}
+
+// parseText делит текст на карточки по cardSep, карточку — на термин и определение по первому termSep.
+// Поле в двойных кавычках может содержать разделители и переводы строк ("" — сама кавычка);
+// кавычки без закрывающей пары или с текстом после неё остаются частью поля.
+// Если карточки разделены переводом строки, строка без termSep продолжает определение предыдущей
+// карточки — так вставляются многострочные определения.
+func parseText(text, termSep, cardSep string) ([]domain.TextCard, error) {
+	if termSep == "" || cardSep == "" || strings.HasPrefix(termSep, cardSep) {
+		return nil, ErrTextSeparator
+	}
+	text = strings.ReplaceAll(strings.TrimPrefix(text, "\ufeff"), "\r\n", "\n")
+
+	var records []textRecord
+	var field strings.Builder
+	rec := textRecord{}
+	line := 1
+	fieldStart := true
+	end := func() {
+		rec.fields = append(rec.fields, field.String())
+		field.Reset()
+		if rec.line > 0 {
+			records = append(records, rec)
+		}
+		rec = textRecord{}
+		fieldStart = true
+	}
+	for i := 0; i < len(text); {
+		if fieldStart && text[i] == '"' {
+			if value, n, ok := quotedField(text[i:], termSep, cardSep, len(rec.fields) == 0); ok {
+				if rec.line == 0 {
+					rec.line = line
+				}
+				field.WriteString(value)
+				line += strings.Count(text[i:i+n], "\n")
+				i += n
+				fieldStart = false
+				continue
+			}
+		}
+		switch {
+		case strings.HasPrefix(text[i:], cardSep):
+			end()
+			line += strings.Count(cardSep, "\n")
+			i += len(cardSep)
+			continue
+		case len(rec.fields) == 0 && strings.HasPrefix(text[i:], termSep):
+			rec.fields = append(rec.fields, field.String())
+			field.Reset()
+			line += strings.Count(termSep, "\n")
+			i += len(termSep)
+			fieldStart = true
+			continue
+		}
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+		case rec.line == 0 && c != ' ' && c != '\t':
+			rec.line = line
+		}
+		if c != ' ' && c != '\t' {
+			fieldStart = false
+		}
+		field.WriteByte(c)
+		i++
+	}
+	end()
+
+	multiline := strings.Contains(cardSep, "\n")
+	cards := make([]domain.TextCard, 0, len(records))
+	for _, r := range records {
+		q := strings.TrimSpace(r.fields[0])
+		if len(r.fields) == 1 {
+			if q == "" {
+				continue
+			}
+			if last := len(cards) - 1; multiline && last >= 0 && len(cards[last].Errors) == 0 {
+				cards[last].Answer += "\n" + q
+				continue
+			}
+			cards = append(cards, domain.TextCard{Line: r.line, Question: q, Errors: map[string]string{"answer": errTextNoSeparator}})
+		} else {
+			cards = append(cards, domain.TextCard{Line: r.line, Question: q, Answer: strings.TrimSpace(r.fields[1])})
+		}
+		if len(cards) > csvMaxRows {
+			return nil, ErrTextTooManyCards
+		}
+	}
+	return cards, nil
+}
+
+// quotedField читает поле в кавычках в начале s и возвращает значение и длину прочитанного.
+// Поле принимается, только если за закрывающей кавычкой (и пробелами) идёт разделитель или конец текста.
+func quotedField(s, termSep, cardSep string, term bool) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		n := i + 1
+		for n < len(s) && s[n] == ' ' && !strings.HasPrefix(s[n:], cardSep) && !(term && strings.HasPrefix(s[n:], termSep)) {
+			n++
+		}
+		rest := s[n:]
+		if rest == "" || strings.HasPrefix(rest, cardSep) || (term && strings.HasPrefix(rest, termSep)) {
+			return b.String(), n, true
+		}
+		return "", 0, false
+	}
+	return "", 0, false
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+func TestParseText(t *testing.T) {
+	noSep := map[string]string{"answer": errTextNoSeparator}
+	tests := []struct {
+		name             string
+		text             string
+		termSep, cardSep string
+		want             []domain.TextCard
+	}{
+		{"tab and newline", "cat\tкошка\ndog\tсобака",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: "cat", Answer: "кошка"},
+				{Line: 2, Question: "dog", Answer: "собака"},
+			}},
+		{"crlf and bom", "\ufeffcat\tкошка\r\ndog\tсобака\r\n",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: "cat", Answer: "кошка"},
+				{Line: 2, Question: "dog", Answer: "собака"},
+			}},
+		{"first term separator only", "a - b - c",
+			" - ", "\n", []domain.TextCard{
+				{Line: 1, Question: "a", Answer: "b - c"},
+			}},
+		{"blank lines skipped", "\n\ncat,кошка\n\n\ndog,собака\n",
+			",", "\n", []domain.TextCard{
+				{Line: 3, Question: "cat", Answer: "кошка"},
+				{Line: 6, Question: "dog", Answer: "собака"},
+			}},
+		{"line without separator continues definition", "cat\tкошка\nдомашнее животное\ndog\tсобака",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: "cat", Answer: "кошка\nдомашнее животное"},
+				{Line: 3, Question: "dog", Answer: "собака"},
+			}},
+		{"first line without separator", "заметка\ncat\tкошка",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: "заметка", Errors: noSep},
+				{Line: 2, Question: "cat", Answer: "кошка"},
+			}},
+		{"no continuation with card separator", "cat,кошка;заметка;dog,собака",
+			",", ";", []domain.TextCard{
+				{Line: 1, Question: "cat", Answer: "кошка"},
+				{Line: 1, Question: "заметка", Errors: noSep},
+				{Line: 1, Question: "dog", Answer: "собака"},
+			}},
+		{"quoted fields with separators", "\"a, b\",\"line 1\nline 2\"\nc,d",
+			",", "\n", []domain.TextCard{
+				{Line: 1, Question: "a, b", Answer: "line 1\nline 2"},
+				{Line: 3, Question: "c", Answer: "d"},
+			}},
+		{"escaped quote", "\"say \"\"hi\"\"\"\tпривет",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: `say "hi"`, Answer: "привет"},
+			}},
+		{"unclosed quote is text", "\"cat\tкошка",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: `"cat`, Answer: "кошка"},
+			}},
+		{"text after closing quote", "\"cat\" tail\tкошка",
+			"\t", "\n", []domain.TextCard{
+				{Line: 1, Question: `"cat" tail`, Answer: "кошка"},
+			}},
+		{"blank line cards", "cat\nкошка\n\ndog\nсобака\nпёс",
+			"\n", "\n\n", []domain.TextCard{
+				{Line: 1, Question: "cat", Answer: "кошка"},
+				{Line: 4, Question: "dog", Answer: "собака\nпёс"},
+			}},
+		{"empty text", "  \n\n", "\t", "\n", []domain.TextCard{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseText(tt.text, tt.termSep, tt.cardSep)
+			if err != nil {
+				t.Fatalf("parseText: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseText(%q) =\n%+v\nwant\n%+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		text             string
+		termSep, cardSep string
+		want             error
+	}{
+		{"empty term separator", "a b", "", "\n", ErrTextSeparator},
+		{"same separators", "a b", "\n", "\n", ErrTextSeparator},
+		{"term separator starts with card separator", "a b", "\n\n", "\n", ErrTextSeparator},
+		{"too many cards", strings.Repeat("a\tb\n", csvMaxRows+1), "\t", "\n", ErrTextTooManyCards},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseText(tt.text, tt.termSep, tt.cardSep); !errors.Is(err, tt.want) {
+				t.Errorf("parseText() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextSeparator(t *testing.T) {
+	tests := []struct {
+		name, def, want string
+	}{
+		{"", "\t", "\t"},
+		{"tab", "\n", "\t"},
+		{"dash", "\t", " - "},
+		{"blank_line", "\n", "\n\n"},
+		{`\t`, "\n", "\t"},
+		{`;\n`, "\n", ";\n"},
+		{" = ", "\t", " = "},
+	}
+	for _, tt := range tests {
+		if got := textSeparator(tt.name, tt.def); got != tt.want {
+			t.Errorf("textSeparator(%q, %q) = %q, want %q", tt.name, tt.def, got, tt.want)
+		}
+	}
+}
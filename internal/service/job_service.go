@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrJobNotFound = errors.New("задача не найдена")
+	ErrJobFinished = errors.New("задача уже завершена")
+	ErrJobNotReady = errors.New("задача ещё не завершена")
+)
+
+const (
+	jobLease        = time.Minute      // аренда задачи воркером; продлевается, пока задача выполняется
+	jobHeartbeat    = 10 * time.Second // как часто сохраняется прогресс и продлевается аренда
+	jobPollInterval = 2 * time.Second  // как часто свободный воркер проверяет очередь
+	jobRetryBase    = 30 * time.Second // пауза перед второй попыткой, дальше удваивается
+	jobRetryMax     = 30 * time.Minute
+	jobListLimit    = 50
+)
+
+// JobRun выполняет задачу; progress сообщает, сколько обработано из total. Результат сохраняется
+// в jobs.result как JSON, в том числе частичный результат вместе с ошибкой. Задача должна
+// прерываться, когда ctx отменён: пользователь отменил её или сервер останавливается.
+type JobRun func(ctx context.Context, job *domain.Job, progress func(done, total int)) (interface{}, error)
+
+// JobKind — вид задачи: как её выполнять и сколько раз пробовать.
+type JobKind struct {
+	Run JobRun
+	// MaxAttempts — попыток до статуса failed (0 — одна). Повторять стоит только задачи,
+	// которые можно безопасно начать заново: импорт после сбоя создал бы наборы повторно.
+	MaxAttempts int
+	// Every > 0 — служебная задача без пользователя, которая запускается с этим периодом.
+	Every time.Duration
+}
+
+// JobService — очередь фоновых задач в PostgreSQL и пул воркеров, который их выполняет.
+// Задачи ставятся в очередь из любого экземпляра API, а выполняются теми, где запущен пул
+// (Start): воркеры забирают их через SELECT ... FOR UPDATE SKIP LOCKED, так что одну задачу
+// выполняет один воркер. Файлы задач (входные и результаты) лежат на диске, поэтому воркеры
+// должны видеть тот же каталог, что и API, — как и с загрузками.
+type JobService struct {
+	jobRepo   *repository.JobRepository
+	logger    *zap.Logger
+	dir       string        // файлы результатов задач
+	baseURL   string        // например http://localhost:8080
+	retention time.Duration // сколько хранятся завершённые задачи и их файлы
+
+	kinds map[string]JobKind
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc // задачи, выполняемые этим экземпляром
+	stop    chan struct{}                 // закрывается при остановке: новые задачи не забираются
+	wg      sync.WaitGroup
+	ctx     context.Context // отменяется, если задачи не успели завершиться при остановке
+	abort   context.CancelFunc
+}
+
+func NewJobService(jobRepo *repository.JobRepository, logger *zap.Logger) *JobService {
+	ctx, abort := context.WithCancel(context.Background())
+	s := &JobService{
+		jobRepo:   jobRepo,
+		logger:    logger,
+		dir:       filepath.Join(os.TempDir(), "mozgoemka-jobs"),
+		retention: 24 * time.Hour,
+		kinds:     make(map[string]JobKind),
+		wake:      make(chan struct{}, 1),
+		running:   make(map[string]context.CancelFunc),
+		stop:      make(chan struct{}),
+		ctx:       ctx,
+		abort:     abort,
+	}
+	s.Register(domain.JobCleanup, JobKind{Run: s.cleanup, Every: time.Hour})
+	return s
+}
+
+// SetConfig задаёт каталог файлов задач, адрес API для ссылок на скачивание и срок хранения завершённых задач.
+func (s *JobService) SetConfig(dir, baseURL string, retention time.Duration) {
+	if dir != "" {
+		s.dir = dir
+	}
+	s.baseURL = baseURL
+	if retention > 0 {
+		s.retention = retention
+	}
+}
+
+// Register задаёт выполнение задач вида kind. Вызывается до Start.
+func (s *JobService) Register(kind string, k JobKind) {
+	if k.MaxAttempts < 1 {
+		k.MaxAttempts = 1
+	}
+	s.kinds[kind] = k
+}
+
+// FilePath — путь к файлу задачи id (результат для скачивания).
+func (s *JobService) FilePath(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// InputDir — каталог входных файлов задач (загруженные для импорта файлы). Лежит в общем
+// каталоге задач, чтобы файл, принятый одним экземпляром API, прочитал воркер другого.
+func (s *JobService) InputDir() string {
+	return filepath.Join(s.dir, "inputs")
+}
+
+// SaveInput сохраняет входной файл задачи из r в InputDir (имя по шаблону pattern, как
+// в os.CreateTemp) и возвращает путь. Путь передаётся задаче в поле path параметров:
+// файл удаляется, когда задача завершается, отменяется или удаляется.
+func (s *JobService) SaveInput(r io.Reader, pattern string) (string, error) {
+	if err := os.MkdirAll(s.InputDir(), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(s.InputDir(), pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeInput удаляет входной файл задачи (поле path параметров), если он лежит в InputDir.
+func (s *JobService) removeInput(job *domain.Job) {
+	var in struct {
+		Path string `json:"path"`
+	}
+	if json.Unmarshal(job.Payload, &in) != nil || in.Path == "" || filepath.Dir(in.Path) != s.InputDir() {
+		return
+	}
+	if err := os.Remove(in.Path); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("job input", zap.String("job", job.ID), zap.Error(err))
+	}
+}
+
+// Enqueue ставит задачу пользователя в очередь (userID = 0 — служебная задача). payload
+// сохраняется как JSON и доступен выполнению в job.Payload. Если задан uniqueKey и задача
+// с тем же ключом ещё не завершена, возвращается она.
+func (s *JobService) Enqueue(ctx context.Context, userID int, kind, uniqueKey string, payload interface{}) (*domain.Job, error) {
+	return s.enqueue(ctx, userID, kind, uniqueKey, payload, 0)
+}
+
+func (s *JobService) enqueue(ctx context.Context, userID int, kind, uniqueKey string, payload interface{}, delay time.Duration) (*domain.Job, error) {
+	k, ok := s.kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный вид задачи %q", kind)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := &domain.Job{ID: uuid.New().String(), UserID: userID, Kind: kind, Payload: data, MaxAttempts: k.MaxAttempts}
+	if _, err := s.jobRepo.Create(ctx, job, uniqueKey, delay); err != nil {
+		return nil, err
+	}
+	if delay == 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	s.decorate(job)
+	return job, nil
+}
+
+// Get возвращает задачу пользователя.
+func (s *JobService) Get(ctx context.Context, id string, userID int) (*domain.Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.UserID == 0 || job.UserID != userID {
+		return nil, ErrJobNotFound
+	}
+	s.decorate(job)
+	return job, nil
+}
+
+// List возвращает последние задачи пользователя, новые сверху; status = "" — все.
+func (s *JobService) List(ctx context.Context, userID int, status string) ([]domain.Job, error) {
+	jobs, err := s.jobRepo.ListByUserID(ctx, userID, status, jobListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if jobs == nil {
+		jobs = []domain.Job{}
+	}
+	for i := range jobs {
+		s.decorate(&jobs[i])
+	}
+	return jobs, nil
+}
+
+// Cancel отменяет задачу пользователя. Задача в очереди отменяется сразу; выполняемая
+// останавливается в ближайшие секунды (cancel_requested), сделанное до остановки не откатывается.
+func (s *JobService) Cancel(ctx context.Context, id string, userID int) (*domain.Job, error) {
+	job, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrJobFinished
+	}
+	canceled, err := s.jobRepo.RequestCancel(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if canceled == nil {
+		return nil, ErrJobFinished
+	}
+	if canceled.Finished() {
+		s.removeInput(canceled)
+	}
+	s.mu.Lock()
+	if cancel := s.running[id]; cancel != nil {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.decorate(canceled)
+	return canceled, nil
+}
+
+// File возвращает файл результата завершённой задачи пользователя и его описание.
+func (s *JobService) File(ctx context.Context, id string, userID int) (string, *domain.JobFile, error) {
+	job, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !job.Finished() {
+		return "", nil, ErrJobNotReady
+	}
+	f := jobFile(job)
+	if job.Status != domain.JobDone || f == nil {
+		return "", nil, ErrJobNotFound
+	}
+	return s.FilePath(id), f, nil
+}
+
+// jobFile возвращает описание файла из результата задачи; nil — задача не готовит файл.
+func jobFile(job *domain.Job) *domain.JobFile {
+	var f domain.JobFile
+	if len(job.Result) == 0 || json.Unmarshal(job.Result, &f) != nil || f.Filename == "" {
+		return nil
+	}
+	return &f
+}
+
+// decorate заполняет вычисляемые поля ответа: ссылку на файл и срок хранения.
+func (s *JobService) decorate(job *domain.Job) {
+	if job.FinishedAt != nil {
+		expires := job.FinishedAt.Add(s.retention)
+		job.ExpiresAt = &expires
+	}
+	if job.Status == domain.JobDone && jobFile(job) != nil {
+		job.DownloadURL = s.baseURL + "/api/jobs/" + job.ID + "/download"
+	}
+}
+
+// Start запускает workers воркеров и ставит в очередь периодические задачи. workers = 0 —
+// этот экземпляр только принимает задачи, выполняют их другие.
+func (s *JobService) Start(workers int) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	kinds := make([]string, 0, len(s.kinds))
+	for kind, k := range s.kinds {
+		kinds = append(kinds, kind)
+		if k.Every > 0 {
+			// ключ — вид задачи: каждый экземпляр ставит её при старте, в очереди остаётся одна
+			if _, err := s.enqueue(s.ctx, 0, kind, kind, struct{}{}, 0); err != nil {
+				return err
+			}
+		}
+	}
+	sort.Strings(kinds)
+	if workers <= 0 {
+		return nil
+	}
+	s.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go s.worker(kinds)
+	}
+	go s.reaper()
+	s.logger.Info("job workers started", zap.Int("workers", workers))
+	return nil
+}
+
+// Shutdown перестаёт забирать задачи и ждёт выполняемые. Если ctx истекает раньше, задачи
+// прерываются: те, у которых остались попытки, возвращаются в очередь, остальные завершаются с ошибкой.
+func (s *JobService) Shutdown(ctx context.Context) error {
+	close(s.stop)
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *JobService) worker(kinds []string) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		job, err := s.jobRepo.Claim(s.ctx, kinds, jobLease)
+		if err != nil {
+			s.logger.Error("job claim", zap.Error(err))
+		}
+		if job != nil {
+			s.execute(job)
+			continue
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// reaper возвращает в очередь задачи, аренда которых истекла: их воркер упал или был убит.
+func (s *JobService) reaper() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(jobLease / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		jobs, err := s.jobRepo.ReleaseExpired(s.ctx, "задача прервана: воркер перестал отвечать")
+		if err != nil {
+			s.logger.Error("job release", zap.Error(err))
+			continue
+		}
+		for i := range jobs {
+			if jobs[i].Finished() {
+				s.removeInput(&jobs[i])
+			}
+		}
+		if len(jobs) > 0 {
+			s.logger.Warn("jobs released", zap.Int("count", len(jobs)))
+		}
+	}
+}
+
+// execute выполняет забранную задачу: пока она идёт, прогресс сохраняется и аренда продлевается,
+// затем задача завершается, отменяется или возвращается в очередь для следующей попытки.
+func (s *JobService) execute(job *domain.Job) {
+	k := s.kinds[job.Kind]
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	if job.UserID != 0 {
+		// изменения наборов задачей записываются в историю от имени поставившего её пользователя
+		ctx = domain.WithRevisionAuthor(ctx, job.UserID)
+	}
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+
+	var mu sync.Mutex
+	progress := func(done, total int) {
+		mu.Lock()
+		job.Processed, job.Total = done, total
+		mu.Unlock()
+	}
+	stopBeat := make(chan struct{})
+	var beat sync.WaitGroup
+	beat.Add(1)
+	go func() {
+		defer beat.Done()
+		ticker := time.NewTicker(jobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopBeat:
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			done, total := job.Processed, job.Total
+			mu.Unlock()
+			stop, err := s.jobRepo.Heartbeat(context.Background(), job, done, total, jobLease)
+			if err != nil {
+				s.logger.Error("job heartbeat", zap.String("job", job.ID), zap.Error(err))
+				continue
+			}
+			if stop {
+				cancel()
+			}
+		}
+	}()
+
+	res, err := runJob(ctx, k.Run, job, progress)
+	close(stopBeat)
+	beat.Wait()
+
+	job.Result = nil
+	if res != nil {
+		if data, mErr := json.Marshal(res); mErr == nil && string(data) != "null" {
+			job.Result = data
+		}
+	}
+	interrupted := s.ctx.Err() != nil
+	job.Error = ""
+	switch {
+	case err == nil:
+		job.Status = domain.JobDone
+	case ctx.Err() != nil && !interrupted:
+		job.Status = domain.JobCanceled
+		job.Error = "задача отменена"
+	default:
+		job.Status = domain.JobFailed
+		job.Error = err.Error()
+		if interrupted {
+			job.Error = "задача прервана остановкой сервера"
+		}
+	}
+	if job.Status == domain.JobFailed && job.Attempts < job.MaxAttempts {
+		delay := jobBackoff(job.Attempts)
+		if interrupted {
+			delay = 0
+		}
+		if err := s.jobRepo.Retry(context.Background(), job, delay); err != nil {
+			s.logger.Error("job retry", zap.String("job", job.ID), zap.Error(err))
+		} else if job.Status == domain.JobCanceled {
+			// отмену запросили, пока шла попытка: задача не повторяется
+			s.removeInput(job)
+			return
+		}
+		s.logger.Warn("job retry", zap.String("job", job.ID), zap.String("kind", job.Kind),
+			zap.Int("attempt", job.Attempts), zap.String("error", job.Error))
+		return
+	}
+	if err := s.jobRepo.Finish(context.Background(), job); err != nil {
+		// задача уже не за этим воркером: её входной файл нужен тому, кто её забрал
+		s.logger.Error("job finish", zap.String("job", job.ID), zap.Error(err))
+	} else {
+		s.removeInput(job)
+	}
+	if job.Status == domain.JobFailed {
+		s.logger.Error("job failed", zap.String("job", job.ID), zap.String("kind", job.Kind), zap.String("error", job.Error))
+	}
+	if k.Every > 0 {
+		if _, err := s.enqueue(context.Background(), 0, job.Kind, job.Kind, struct{}{}, k.Every); err != nil {
+			s.logger.Error("job schedule", zap.String("kind", job.Kind), zap.Error(err))
+		}
+	}
+}
+
+func runJob(ctx context.Context, run JobRun, job *domain.Job, progress func(done, total int)) (res interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("внутренняя ошибка: %v", r)
+		}
+	}()
+	return run(ctx, job, progress)
+}
+
+// jobBackoff — пауза перед следующей попыткой после attempt неудачных.
+func jobBackoff(attempt int) time.Duration {
+	d := jobRetryBase
+	for i := 1; i < attempt && d < jobRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, jobRetryMax)
+}
+
+// cleanup удаляет задачи, завершённые раньше срока хранения, вместе с их файлами.
+func (s *JobService) cleanup(ctx context.Context, _ *domain.Job, _ func(done, total int)) (interface{}, error) {
+	jobs, err := s.jobRepo.DeleteFinishedBefore(ctx, time.Now().Add(-s.retention))
+	for i := range jobs {
+		if err := os.Remove(s.FilePath(jobs[i].ID)); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("job file", zap.String("job", jobs[i].ID), zap.Error(err))
+		}
+		s.removeInput(&jobs[i])
+	}
+	return map[string]int{"deleted": len(jobs)}, err
+}
+
+// decodePayload читает параметры задачи.
+func decodePayload(job *domain.Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return fmt.Errorf("параметры задачи: %w", err)
+	}
+	return nil
+}
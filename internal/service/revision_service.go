@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+var ErrRevisionNotFound = errors.New("ревизия не найдена")
+
+type RevisionService struct {
+	revisionRepo *repository.RevisionRepository
+	deckRepo     *repository.DeckRepository
+	keep         int
+	maxAge       time.Duration
+}
+
+func NewRevisionService(revisionRepo *repository.RevisionRepository, deckRepo *repository.DeckRepository) *RevisionService {
+	return &RevisionService{revisionRepo: revisionRepo, deckRepo: deckRepo}
+}
+
+// SetRetention задаёт ограничения хранения ревизий (0 — без ограничения).
+func (s *RevisionService) SetRetention(keep int, maxAge time.Duration) {
+	s.keep = keep
+	s.maxAge = maxAge
+}
+
+// List возвращает историю набора; cardID ограничивает её одной карточкой.
+func (s *RevisionService) List(ctx context.Context, deckID int, userID int, cardID *int, page, limit int) (*domain.DeckRevisionsResponse, error) {
+	if err := s.checkOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+	list, total, err := s.revisionRepo.ListByDeckID(ctx, deckID, cardID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []domain.DeckRevision{}
+	}
+	return &domain.DeckRevisionsResponse{
+		Revisions:  list,
+		Pagination: domain.Pagination{Page: page, Limit: limit, Total: total},
+	}, nil
+}
+
+// StateAt возвращает набор в состоянии на момент ревизии.
+func (s *RevisionService) StateAt(ctx context.Context, deckID int, userID int, revID int) (*domain.DeckAtRevision, error) {
+	if err := s.checkOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	return s.stateAt(ctx, deckID, revID)
+}
+
+// Diff сравнивает состояния набора на момент двух ревизий.
+func (s *RevisionService) Diff(ctx context.Context, deckID int, userID int, from, to int) (*domain.RevisionDiffResponse, error) {
+	if err := s.checkOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	a, err := s.stateAt(ctx, deckID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.stateAt(ctx, deckID, to)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.RevisionDiffResponse{
+		From:    from,
+		To:      to,
+		Added:   []domain.CardSnapshot{},
+		Removed: []domain.CardSnapshot{},
+		Changed: []domain.CardRevisionChange{},
+	}
+	resp.Deck = diffFields(a.Deck, b.Deck)
+	before := make(map[int]domain.CardSnapshot, len(a.Cards))
+	for _, c := range a.Cards {
+		before[c.ID] = c
+	}
+	after := make(map[int]bool, len(b.Cards))
+	for _, c := range b.Cards {
+		after[c.ID] = true
+		old, ok := before[c.ID]
+		if !ok {
+			resp.Added = append(resp.Added, c)
+			continue
+		}
+		if fields := diffFields(old, c); len(fields) > 0 {
+			names := make([]string, 0, len(fields))
+			for k := range fields {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			resp.Changed = append(resp.Changed, domain.CardRevisionChange{CardID: c.ID, Fields: names, From: old, To: c})
+		}
+	}
+	for _, c := range a.Cards {
+		if !after[c.ID] {
+			resp.Removed = append(resp.Removed, c)
+		}
+	}
+	return resp, nil
+}
+
+// Rollback возвращает набор (или одну карточку) к состоянию на момент ревизии.
+// Откат сам записывается в историю новыми ревизиями, старые ревизии не меняются.
+func (s *RevisionService) Rollback(ctx context.Context, deckID int, userID int, revID int, cardID *int) (*domain.DeckAtRevision, error) {
+	if err := s.checkOwner(ctx, deckID, userID); err != nil {
+		return nil, err
+	}
+	state, err := s.stateAt(ctx, deckID, revID)
+	if err != nil {
+		return nil, err
+	}
+	if cardID == nil {
+		err = s.revisionRepo.Rollback(ctx, deckID, &state.Deck, state.Cards, nil)
+	} else {
+		var cards []domain.CardSnapshot
+		for _, c := range state.Cards {
+			if c.ID == *cardID {
+				cards = append(cards, c)
+			}
+		}
+		err = s.revisionRepo.Rollback(ctx, deckID, nil, cards, []int{*cardID})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// RegisterJobs регистрирует в jobs ежечасную очистку истории по ограничениям хранения.
+func (s *RevisionService) RegisterJobs(jobs *JobService) {
+	jobs.Register(domain.JobPruneRevisions, JobKind{Every: time.Hour, Run: func(ctx context.Context, _ *domain.Job, _ func(done, total int)) (interface{}, error) {
+		n, err := s.PruneAll(ctx)
+		return map[string]int64{"pruned": n}, err
+	}})
+}
+
+// PruneAll применяет ограничения хранения ко всем наборам и возвращает число удалённых ревизий.
+func (s *RevisionService) PruneAll(ctx context.Context) (int64, error) {
+	if s.keep <= 0 && s.maxAge <= 0 {
+		return 0, nil
+	}
+	var since time.Time
+	if s.maxAge > 0 {
+		since = time.Now().Add(-s.maxAge)
+	}
+	ids, err := s.revisionRepo.ListDeckIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, id := range ids {
+		n, err := s.revisionRepo.Prune(ctx, id, s.keep, since)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (s *RevisionService) checkOwner(ctx context.Context, deckID int, userID int) error {
+	d, err := s.deckRepo.GetByID(ctx, deckID)
+	if err != nil || d == nil {
+		return ErrDeckNotFound
+	}
+	if d.UserID != userID {
+		return ErrDeckForbidden
+	}
+	return nil
+}
+
+func (s *RevisionService) stateAt(ctx context.Context, deckID int, revID int) (*domain.DeckAtRevision, error) {
+	rev, err := s.revisionRepo.GetByID(ctx, revID)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil || rev.DeckID != deckID {
+		return nil, ErrRevisionNotFound
+	}
+	deck, cards, err := s.revisionRepo.StateAt(ctx, deckID, revID)
+	if err != nil {
+		return nil, err
+	}
+	if deck == nil {
+		return nil, ErrRevisionNotFound
+	}
+	if cards == nil {
+		cards = []domain.CardSnapshot{}
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].ID < cards[j].ID })
+	return &domain.DeckAtRevision{RevisionID: revID, Deck: *deck, Cards: cards}, nil
+}
+
+// diffFields сравнивает JSON-представления двух снимков поле за полем.
+func diffFields(a, b interface{}) map[string]domain.FieldChange {
+	var before, after map[string]interface{}
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	_ = json.Unmarshal(ab, &before)
+	_ = json.Unmarshal(bb, &after)
+	changes := make(map[string]domain.FieldChange)
+	for k, v := range after {
+		old := before[k]
+		ov, _ := json.Marshal(old)
+		nv, _ := json.Marshal(v)
+		if string(ov) != string(nv) {
+			changes[k] = domain.FieldChange{From: old, To: v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+type StudyService struct {
+	progressRepo *repository.CardProgressRepository
+	cardRepo     *repository.CardRepository
+	deckRepo     *repository.DeckRepository
+	subRepo      *repository.SubscriptionRepository
+}
+
+func NewStudyService(progressRepo *repository.CardProgressRepository, cardRepo *repository.CardRepository, deckRepo *repository.DeckRepository, subRepo *repository.SubscriptionRepository) *StudyService {
+	return &StudyService{progressRepo: progressRepo, cardRepo: cardRepo, deckRepo: deckRepo, subRepo: subRepo}
+}
+
+// Queue возвращает очередь изучения: карточки своих наборов и наборов по подписке,
+// сначала просроченные повторения, затем новые.
+func (s *StudyService) Queue(ctx context.Context, userID int, deckID *int, limit int) (*domain.StudyQueueResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	list, err := s.progressRepo.ListDue(ctx, userID, deckID, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]domain.StudyCard, 0, len(list))
+	for _, it := range list {
+		cards = append(cards, domain.StudyCard{
+			ID:         it.Card.ID,
+			Question:   it.Card.Question,
+			Answer:     it.Card.Answer,
+			Deck:       domain.DeckBrief{ID: it.Card.DeckID, Title: it.DeckTitle},
+			Subscribed: it.Subscribed,
+			Progress:   it.Progress,
+		})
+	}
+	return &domain.StudyQueueResponse{Cards: cards}, nil
+}
+
+// Review сохраняет оценку ответа и пересчитывает следующий срок повторения.
+// Карточки наборов по подписке доступны, пока набор публичен.
+func (s *StudyService) Review(ctx context.Context, cardID int, userID int, grade int) (*domain.CardProgress, error) {
+	c, err := s.cardRepo.GetByID(ctx, cardID)
+	if err != nil || c == nil {
+		return nil, ErrCardNotFound
+	}
+	if err := s.checkStudyAccess(ctx, c.DeckID, userID); err != nil {
+		return nil, err
+	}
+	p, err := s.progressRepo.Get(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &domain.CardProgress{UserID: userID, CardID: cardID, EaseFactor: 2.5}
+	}
+	next := nextProgress(*p, grade, time.Now())
+	if err := s.progressRepo.Upsert(ctx, &next); err != nil {
+		return nil, err
+	}
+	_ = s.progressRepo.LogReview(ctx, &domain.ReviewLog{
+		UserID: userID, CardID: cardID, Grade: grade,
+		IntervalDays: next.IntervalDays, EaseFactor: next.EaseFactor, ReviewedAt: *next.LastReviewedAt,
+	})
+	return &next, nil
+}
+
+func (s *StudyService) checkStudyAccess(ctx context.Context, deckID int, userID int) error {
+	d, _ := s.deckRepo.GetByID(ctx, deckID)
+	if d == nil {
+		return ErrCardNotFound
+	}
+	if d.UserID == userID {
+		return nil
+	}
+	if d.IsPublic {
+		if ok, _ := s.subRepo.Exists(ctx, userID, deckID); ok {
+			return nil
+		}
+	}
+	return ErrCardForbidden
+}
+
+// nextProgress пересчитывает состояние повторений по алгоритму SM-2; grade — оценка ответа 0..5.
+func nextProgress(p domain.CardProgress, grade int, now time.Time) domain.CardProgress {
+	if grade < 3 {
+		p.Repetitions = 0
+		p.IntervalDays = 1
+	} else {
+		switch p.Repetitions {
+		case 0:
+			p.IntervalDays = 1
+		case 1:
+			p.IntervalDays = 6
+		default:
+			p.IntervalDays = int(math.Round(float64(p.IntervalDays) * p.EaseFactor))
+		}
+		p.Repetitions++
+	}
+	q := float64(5 - grade)
+	p.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if p.EaseFactor < 1.3 {
+		p.EaseFactor = 1.3
+	}
+	p.DueAt = now.AddDate(0, 0, p.IntervalDays)
+	p.LastReviewedAt = &now
+	return p
+}
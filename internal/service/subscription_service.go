@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+var ErrSubscribeOwnDeck = errors.New("нельзя подписаться на свой набор")
+
+type SubscriptionService struct {
+	subRepo  *repository.SubscriptionRepository
+	deckRepo *repository.DeckRepository
+	cardRepo *repository.CardRepository
+	userRepo *repository.UserRepository
+}
+
+func NewSubscriptionService(subRepo *repository.SubscriptionRepository, deckRepo *repository.DeckRepository, cardRepo *repository.CardRepository, userRepo *repository.UserRepository) *SubscriptionService {
+	return &SubscriptionService{subRepo: subRepo, deckRepo: deckRepo, cardRepo: cardRepo, userRepo: userRepo}
+}
+
+// Subscribe подписывает пользователя на публичный набор: карточки попадают в его очередь изучения
+// с собственным прогрессом, а содержимое остаётся у автора.
+func (s *SubscriptionService) Subscribe(ctx context.Context, deckID int, userID int) (*domain.DeckSubscription, error) {
+	d, err := s.deckRepo.GetByID(ctx, deckID)
+	if err != nil || d == nil || !d.IsPublic {
+		return nil, ErrDeckNotFound
+	}
+	if d.UserID == userID {
+		return nil, ErrSubscribeOwnDeck
+	}
+	sub := &domain.DeckSubscription{UserID: userID, DeckID: deckID}
+	if err := s.subRepo.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Unsubscribe отменяет подписку. Прогресс по карточкам сохраняется на случай повторной подписки.
+func (s *SubscriptionService) Unsubscribe(ctx context.Context, deckID int, userID int) error {
+	return s.subRepo.Delete(ctx, userID, deckID)
+}
+
+// List возвращает подписки пользователя. Наборы, удалённые автором, исчезают из списка вместе с подпиской;
+// наборы, ставшие приватными, остаются с available = false и не попадают в очередь изучения.
+func (s *SubscriptionService) List(ctx context.Context, userID int) (*domain.SubscriptionsResponse, error) {
+	subs, err := s.subRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]domain.SubscriptionItem, 0, len(subs))
+	for _, sub := range subs {
+		d, _ := s.deckRepo.GetByID(ctx, sub.DeckID)
+		if d == nil {
+			continue
+		}
+		item := domain.SubscriptionItem{
+			DeckID:       d.ID,
+			Title:        d.Title,
+			Available:    d.IsPublic,
+			SubscribedAt: sub.CreatedAt.Format(time.RFC3339),
+		}
+		if u, _ := s.userRepo.GetByID(ctx, d.UserID); u != nil {
+			item.Author = domain.DeckAuthor{ID: u.ID, Username: u.Username, AvatarURL: u.AvatarURL}
+		}
+		if d.IsPublic {
+			item.CardsCount, _ = s.cardRepo.CountByDeckID(ctx, d.ID)
+		}
+		items = append(items, item)
+	}
+	return &domain.SubscriptionsResponse{Subscriptions: items}, nil
+}
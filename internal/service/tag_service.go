@@ -3,12 +3,33 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"github.com/pro100kartochki/mozgoemka/internal/domain"
 	"github.com/pro100kartochki/mozgoemka/internal/repository"
 )
 
-var ErrTagExists = errors.New("тег с таким именем уже существует")
+var (
+	ErrTagExists      = errors.New("тег с таким именем уже существует")
+	ErrTagNotFound    = errors.New("тег не найден")
+	ErrTagForbidden   = errors.New("общими тегами управляют только модераторы")
+	ErrTagInUse       = errors.New("тег используется в наборах или карточках")
+	ErrTagMergeSelf   = errors.New("нельзя объединить тег с самим собой")
+	ErrTagMergeScope  = errors.New("общий тег можно объединить только с общим тегом")
+	ErrTagPath        = errors.New("в пути тега не должно быть пустых уровней")
+	ErrTagCycle       = errors.New("нельзя перенести тег внутрь самого себя")
+	ErrTagHasChildren = errors.New("у тега есть дочерние теги, сначала перенесите или объедините их")
+)
+
+// TagInUseError — тег нельзя удалить без force: он привязан к наборам или карточкам.
+type TagInUseError struct {
+	DecksCount int `json:"decks_count"`
+	CardsCount int `json:"cards_count"`
+}
+
+func (e *TagInUseError) Error() string { return ErrTagInUse.Error() }
+
+func (e *TagInUseError) Unwrap() error { return ErrTagInUse }
 
 type TagService struct {
 	repo *repository.TagRepository
@@ -18,26 +39,239 @@ func NewTagService(repo *repository.TagRepository) *TagService {
 	return &TagService{repo: repo}
 }
 
-func (s *TagService) Create(ctx context.Context, req domain.CreateTagRequest) (*domain.Tag, error) {
-	existing, _ := s.repo.GetByName(ctx, req.Name)
+// Create создаёт личный тег пользователя, а с req.Global — общий тег (только moderator, admin).
+// Имя не должно совпадать с общим тегом или другим личным тегом пользователя.
+// Имя вида lang::de::verbs задаёт иерархию: недостающие родительские теги создаются с той же видимостью,
+// личный тег может лежать внутри общего, общий — только внутри общего.
+func (s *TagService) Create(ctx context.Context, userID int, role domain.UserRole, req domain.CreateTagRequest) (*domain.Tag, error) {
+	segments, ok := domain.ParseTagPath(req.Name)
+	if !ok {
+		return nil, ErrTagPath
+	}
+	t := &domain.Tag{Name: domain.JoinTagPath(segments), UserID: &userID}
+	scope := userID
+	if req.Global {
+		if !isModerator(role) {
+			return nil, ErrTagForbidden
+		}
+		t.UserID = nil
+		scope = 0
+	}
+	existing, _ := s.repo.GetByName(ctx, scope, t.Name)
 	if existing != nil {
 		return nil, ErrTagExists
 	}
-	t := &domain.Tag{Name: req.Name}
-	if err := s.repo.Create(ctx, t); err != nil {
+	if err := s.repo.Create(ctx, t, tagParents(segments)); err != nil {
+		return nil, tagConflict(err)
+	}
+	return t, nil
+}
+
+// EnsureTag возвращает видимый пользователю тег с именем name (общий или личный), а если такого нет —
+// создаёт личный тег вместе с недостающими родительскими. Используется при импорте.
+func (s *TagService) EnsureTag(ctx context.Context, userID int, name string) (*domain.Tag, error) {
+	t, name, err := s.LookupTag(ctx, userID, name)
+	if err != nil || t != nil {
+		return t, err
+	}
+	segments, _ := domain.ParseTagPath(name)
+	owner := userID
+	t = &domain.Tag{Name: name, UserID: &owner}
+	if err := s.repo.Create(ctx, t, tagParents(segments)); err != nil {
 		return nil, err
 	}
 	return t, nil
 }
 
-func (s *TagService) List(ctx context.Context) ([]domain.Tag, error) {
-	return s.repo.List(ctx)
+// LookupTag ищет видимый пользователю тег с именем name, ничего не создавая. Возвращает
+// нормализованный путь; тег nil, если такого тега нет.
+func (s *TagService) LookupTag(ctx context.Context, userID int, name string) (*domain.Tag, string, error) {
+	segments, ok := domain.ParseTagPath(name)
+	if !ok {
+		return nil, "", ErrTagPath
+	}
+	name = domain.JoinTagPath(segments)
+	t, err := s.repo.GetByName(ctx, userID, name)
+	return t, name, err
+}
+
+// tagConflict превращает нарушение уникальности имени (тег с тем же именем создал
+// параллельный запрос после проверки) в ErrTagExists.
+func tagConflict(err error) error {
+	if repository.UniqueViolation(err) != "" {
+		return ErrTagExists
+	}
+	return err
+}
+
+// tagParents возвращает полные пути родительских тегов для пути segments, от корня
+// (lang::de::verbs → lang, lang::de). Недостающие родители создаются вместе с тегом.
+func tagParents(segments []string) []string {
+	parents := make([]string, 0, len(segments)-1)
+	for i := 1; i < len(segments); i++ {
+		parents = append(parents, domain.JoinTagPath(segments[:i]))
+	}
+	return parents
 }
 
-func (s *TagService) ListWithSearch(ctx context.Context, search string) ([]domain.Tag, error) {
-	return s.repo.ListWithSearch(ctx, search)
+// List возвращает общие теги и личные теги пользователя (0 — только общие).
+func (s *TagService) List(ctx context.Context, userID int) ([]domain.Tag, error) {
+	return s.repo.List(ctx, userID)
+}
+
+func (s *TagService) ListWithSearch(ctx context.Context, userID int, search string) ([]domain.Tag, error) {
+	return s.repo.ListWithSearch(ctx, userID, search)
+}
+
+// ListWithUsage возвращает общие и личные теги пользователя с количеством наборов и карточек, где они используются.
+func (s *TagService) ListWithUsage(ctx context.Context, userID int, search string) ([]domain.TagListItem, error) {
+	list, err := s.repo.ListWithUsage(ctx, userID, search)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []domain.TagListItem{}
+	}
+	return list, nil
+}
+
+// Rename переименовывает тег; имя должно оставаться уникальным. Новый путь может перенести тег
+// к другому родителю (lang::de → languages::de), дочерние теги той же видимости переносятся
+// вместе с ним, а личные теги пользователей внутри общего тега становятся корневыми.
+func (s *TagService) Rename(ctx context.Context, id int, userID int, role domain.UserRole, req domain.UpdateTagRequest) (*domain.Tag, error) {
+	t, err := s.manageable(ctx, id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	segments, ok := domain.ParseTagPath(req.Name)
+	if !ok {
+		return nil, ErrTagPath
+	}
+	name := domain.JoinTagPath(segments)
+	if name == t.Name {
+		return t, nil
+	}
+	if strings.HasPrefix(name, t.Name+domain.TagPathSeparator) {
+		return nil, ErrTagCycle
+	}
+	if existing, _ := s.repo.GetByName(ctx, tagScope(*t), name); existing != nil && existing.ID != id {
+		return nil, ErrTagExists
+	}
+	children, err := s.repo.Descendants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		moved := name + strings.TrimPrefix(c.Name, t.Name)
+		if existing, _ := s.repo.GetByName(ctx, tagScope(c), moved); existing != nil {
+			return nil, ErrTagExists
+		}
+	}
+	t.Name = name
+	if err := s.repo.Update(ctx, t, tagParents(segments)); err != nil {
+		return nil, tagConflict(err)
+	}
+	return t, nil
+}
+
+// Merge заменяет тег id тегом req.TargetTagID во всех наборах и карточках и удаляет тег id.
+func (s *TagService) Merge(ctx context.Context, id int, userID int, role domain.UserRole, req domain.MergeTagRequest) (*domain.Tag, error) {
+	if id == req.TargetTagID {
+		return nil, ErrTagMergeSelf
+	}
+	src, err := s.manageable(ctx, id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if children, err := s.repo.Descendants(ctx, id); err != nil {
+		return nil, err
+	} else if len(children) > 0 {
+		return nil, ErrTagHasChildren
+	}
+	dst, err := s.repo.GetByID(ctx, req.TargetTagID)
+	if err != nil || dst == nil || !dst.VisibleTo(userID) {
+		return nil, ErrTagNotFound
+	}
+	if src.IsGlobal() && !dst.IsGlobal() {
+		return nil, ErrTagMergeScope
+	}
+	if err := s.repo.Merge(ctx, src.ID, dst.ID); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// Delete удаляет тег вместе с дочерними тегами той же видимости (личные теги других пользователей
+// внутри общего тега остаются корневыми). Если тег или его потомки используются,
+// удаление выполняется только при force, вместе с привязками; иначе возвращается *TagInUseError.
+func (s *TagService) Delete(ctx context.Context, id int, userID int, role domain.UserRole, force bool) error {
+	if _, err := s.manageable(ctx, id, userID, role); err != nil {
+		return err
+	}
+	if !force {
+		decks, cards, err := s.repo.Usage(ctx, id)
+		if err != nil {
+			return err
+		}
+		if decks > 0 || cards > 0 {
+			return &TagInUseError{DecksCount: decks, CardsCount: cards}
+		}
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Suggest предлагает теги для черновика карточки или набора по разметке пользователя.
+func (s *TagService) Suggest(ctx context.Context, userID int, req domain.SuggestTagsRequest) ([]domain.TagSuggestion, error) {
+	text := strings.Join([]string{req.Question, req.Answer, req.Text}, " ")
+	return suggestTags(ctx, s.repo, userID, text, req.ExcludeTagIDs, req.Limit)
 }
 
 func (s *TagService) GetByID(ctx context.Context, id int) (*domain.Tag, error) {
 	return s.repo.GetByID(ctx, id)
 }
+
+// manageable возвращает тег, если пользователь может его изменять: общий — moderator и admin,
+// личный — только владелец. Чужие личные теги считаются несуществующими.
+func (s *TagService) manageable(ctx context.Context, id int, userID int, role domain.UserRole) (*domain.Tag, error) {
+	t, err := s.repo.GetByID(ctx, id)
+	if err != nil || t == nil || !t.VisibleTo(userID) {
+		return nil, ErrTagNotFound
+	}
+	if t.IsGlobal() && !isModerator(role) {
+		return nil, ErrTagForbidden
+	}
+	return t, nil
+}
+
+// tagScope — пространство уникальности имени тега: 0 для общих тегов, иначе владелец.
+func tagScope(t domain.Tag) int {
+	if t.UserID == nil {
+		return 0
+	}
+	return *t.UserID
+}
+
+func isModerator(role domain.UserRole) bool {
+	return role == domain.RoleModerator || role == domain.RoleAdmin
+}
+
+// visibleTags оставляет теги, которые пользователь userID может видеть (0 — только общие).
+func visibleTags(tags []domain.Tag, userID int) []domain.Tag {
+	var list []domain.Tag
+	for _, t := range tags {
+		if t.VisibleTo(userID) {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// attachableTags возвращает теги из ids, которые пользователь может привязать, и их ID.
+func attachableTags(ctx context.Context, repo *repository.TagRepository, userID int, ids []int) ([]domain.Tag, []int) {
+	tags, _ := repo.GetVisibleByIDs(ctx, userID, ids)
+	valid := make([]int, 0, len(tags))
+	for _, t := range tags {
+		valid = append(valid, t.ID)
+	}
+	return tags, valid
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+const (
+	suggestCorpusLimit  = 2000 // сколько последних карточек (и столько же наборов) пользователя учитывать
+	suggestNeighbours   = 25   // по скольким самым похожим текстам голосуют теги
+	suggestMinScore     = 0.05
+	suggestDefaultLimit = 5
+	suggestNameBonus    = 0.5 // доля, на которую приближает score к 1 совпадение имени тега со словом текста
+	suggestStemLength   = 6   // слова обрезаются до стольких букв: грубая замена морфологии (глаголы, глаголами)
+)
+
+var suggestStopWords = map[string]bool{
+	"и": true, "в": true, "во": true, "не": true, "что": true, "он": true, "на": true, "я": true, "с": true, "со": true,
+	"как": true, "а": true, "то": true, "все": true, "она": true, "так": true, "его": true, "но": true, "да": true,
+	"ты": true, "к": true, "у": true, "же": true, "вы": true, "за": true, "бы": true, "по": true, "только": true,
+	"ее": true, "мне": true, "было": true, "вот": true, "от": true, "меня": true, "еще": true, "нет": true, "о": true,
+	"из": true, "ему": true, "это": true, "для": true, "или": true, "ли": true, "если": true, "уже": true, "при": true,
+	"the": true, "a": true, "an": true, "and": true, "or": true, "of": true, "to": true, "in": true, "on": true,
+	"is": true, "are": true, "was": true, "for": true, "with": true, "as": true, "by": true, "at": true, "it": true,
+	"be": true, "this": true, "that": true, "what": true, "which": true, "from": true,
+}
+
+// suggestTags предлагает теги для текста по TF-IDF: текст сравнивается с уже размеченными карточками
+// и наборами пользователя, теги самых похожих из них голосуют с весом сходства. Теги, имя которых
+// встречается в тексте, получают бонус, поэтому подсказки есть и у пользователя без разметки.
+func suggestTags(ctx context.Context, repo *repository.TagRepository, userID int, text string, exclude []int, limit int) ([]domain.TagSuggestion, error) {
+	if limit <= 0 {
+		limit = suggestDefaultLimit
+	}
+	query := suggestTokens(text)
+	if len(query) == 0 {
+		return []domain.TagSuggestion{}, nil
+	}
+	corpus, err := repo.ListTaggedTexts(ctx, userID, suggestCorpusLimit)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := repo.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return rankTags(query, corpus, tags, exclude, limit), nil
+}
+
+// rankTags оценивает теги tags для текста, разбитого suggestTokens на слова query, по корпусу
+// размеченных текстов и возвращает до limit лучших по убыванию score.
+func rankTags(query []string, corpus []repository.TaggedText, tags []domain.Tag, exclude []int, limit int) []domain.TagSuggestion {
+	docs := make([][]string, len(corpus))
+	df := make(map[string]int)
+	for i, d := range corpus {
+		docs[i] = suggestTokens(d.Text)
+		seen := make(map[string]bool)
+		for _, t := range docs[i] {
+			if !seen[t] {
+				seen[t] = true
+				df[t]++
+			}
+		}
+	}
+	idf := func(term string) float64 {
+		return math.Log(float64(len(docs)+1)/float64(df[term]+1)) + 1
+	}
+	qv := tfidfVector(query, idf)
+
+	type neighbour struct {
+		doc int
+		sim float64
+	}
+	var near []neighbour
+	for i, d := range docs {
+		if sim := cosine(qv, tfidfVector(d, idf)); sim > 0 {
+			near = append(near, neighbour{doc: i, sim: sim})
+		}
+	}
+	sort.Slice(near, func(i, j int) bool { return near[i].sim > near[j].sim })
+	if len(near) > suggestNeighbours {
+		near = near[:suggestNeighbours]
+	}
+	scores := make(map[int]float64)
+	var total float64
+	for _, n := range near {
+		total += n.sim
+		for _, id := range corpus[n.doc].TagIDs {
+			scores[id] += n.sim
+		}
+	}
+	if total > 0 {
+		for id := range scores {
+			scores[id] /= total
+		}
+	}
+
+	words := make(map[string]bool, len(query))
+	for _, t := range query {
+		words[t] = true
+	}
+	skip := make(map[int]bool, len(exclude))
+	for _, id := range exclude {
+		skip[id] = true
+	}
+	list := make([]domain.TagSuggestion, 0)
+	for _, t := range tags {
+		if skip[t.ID] {
+			continue
+		}
+		score := scores[t.ID]
+		if tagNameIn(t.Name, words) {
+			score += suggestNameBonus * (1 - score)
+		}
+		if score < suggestMinScore {
+			continue
+		}
+		list = append(list, domain.TagSuggestion{Tag: t, Score: math.Round(score*1000) / 1000})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Tag.Name < list[j].Tag.Name
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// suggestTokens разбивает текст на слова в нижнем регистре без стоп-слов и обрезает их до основы.
+func suggestTokens(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	fields := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		r := []rune(f)
+		if len(r) < 2 || suggestStopWords[f] {
+			continue
+		}
+		if len(r) > suggestStemLength {
+			f = string(r[:suggestStemLength])
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// tfidfVector строит нормированный TF-IDF вектор слов.
+func tfidfVector(tokens []string, idf func(string) float64) map[string]float64 {
+	v := make(map[string]float64)
+	for _, t := range tokens {
+		v[t]++
+	}
+	var norm float64
+	for t, n := range v {
+		v[t] = n / float64(len(tokens)) * idf(t)
+		norm += v[t] * v[t]
+	}
+	norm = math.Sqrt(norm)
+	for t := range v {
+		v[t] /= norm
+	}
+	return v
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	var dot float64
+	for t, x := range a {
+		dot += x * b[t]
+	}
+	return dot
+}
+
+// tagNameIn — все слова последнего уровня имени тега (verbs в lang::de::verbs) встречаются в тексте.
+func tagNameIn(name string, words map[string]bool) bool {
+	if i := strings.LastIndex(name, domain.TagPathSeparator); i >= 0 {
+		name = name[i+len(domain.TagPathSeparator):]
+	}
+	tokens := suggestTokens(name)
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, t := range tokens {
+		if !words[t] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+func TestRankTags(t *testing.T) {
+	tags := []domain.Tag{
+		{ID: 1, Name: "grammar"},
+		{ID: 2, Name: "animals"},
+		{ID: 3, Name: "lang::de::verbs"},
+		{ID: 4, Name: "food"},
+	}
+	corpus := []repository.TaggedText{
+		{Text: "Hund собака", TagIDs: []int{2}},
+		{Text: "Katze кошка", TagIDs: []int{2}},
+		{Text: "gehen идти ходить", TagIDs: []int{3}},
+		{Text: "Brot хлеб", TagIDs: []int{4}},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		corpus  []repository.TaggedText
+		tags    []domain.Tag
+		exclude []int
+		limit   int
+		want    []domain.TagSuggestion
+	}{
+		{"neighbours vote", "Hund und Katze", corpus, tags, nil, 5,
+			[]domain.TagSuggestion{{Tag: tags[1], Score: 1}}},
+		{"closer neighbour ranks first", "Hund gehen", corpus, tags, nil, 5,
+			[]domain.TagSuggestion{{Tag: tags[1], Score: 0.551}, {Tag: tags[2], Score: 0.449}}},
+		{"name bonus", "Hund: verbs", corpus, tags, nil, 5,
+			[]domain.TagSuggestion{{Tag: tags[1], Score: 1}, {Tag: tags[2], Score: 0.5}}},
+		{"limit", "Hund gehen", corpus, tags, nil, 1,
+			[]domain.TagSuggestion{{Tag: tags[1], Score: 0.551}}},
+		{"excluded tag", "Hund gehen", corpus, tags, []int{2}, 5,
+			[]domain.TagSuggestion{{Tag: tags[2], Score: 0.449}}},
+		{"no similar texts", "Wasser вода", corpus, tags, nil, 5,
+			[]domain.TagSuggestion{}},
+		{"empty corpus uses names", "Irregular verbs", nil, tags, nil, 5,
+			[]domain.TagSuggestion{{Tag: tags[2], Score: 0.5}}},
+		{"empty corpus without names", "Hund", nil, tags, nil, 5,
+			[]domain.TagSuggestion{}},
+		{"no candidate tags", "Hund gehen", corpus, nil, nil, 5,
+			[]domain.TagSuggestion{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rankTags(suggestTokens(tt.text), tt.corpus, tt.tags, tt.exclude, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankTags(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Глаголы и глаголами", []string{"глагол", "глагол"}},
+		{"Ёжик в тумане", []string{"ежик", "тумане"}},
+		{"The cat, a dog!", []string{"cat", "dog"}},
+		{"x = 42", []string{"42"}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := suggestTokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggestTokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
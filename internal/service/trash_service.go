@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+var (
+	ErrTrashNotFound = errors.New("в корзине нет такого объекта")
+	ErrDeckInTrash   = errors.New("набор карточки удалён, сначала восстановите набор")
+	ErrParentInTrash = errors.New("родительский набор удалён, сначала восстановите его")
+)
+
+type TrashService struct {
+	trashRepo *repository.TrashRepository
+	retention time.Duration
+}
+
+func NewTrashService(trashRepo *repository.TrashRepository, retention time.Duration) *TrashService {
+	return &TrashService{trashRepo: trashRepo, retention: retention}
+}
+
+// List возвращает содержимое корзины пользователя со сроком окончательного удаления.
+func (s *TrashService) List(ctx context.Context, userID int) (*domain.TrashResponse, error) {
+	decks, err := s.trashRepo.ListDecks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := s.trashRepo.ListCards(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.TrashResponse{
+		Decks: make([]domain.TrashDeckItem, 0, len(decks)),
+		Cards: make([]domain.TrashCardItem, 0, len(cards)),
+	}
+	for _, td := range decks {
+		resp.Decks = append(resp.Decks, domain.TrashDeckItem{
+			ID:         td.Deck.ID,
+			Title:      td.Deck.Title,
+			CardsCount: td.Deck.CardsCount,
+			DeletedAt:  td.DeletedAt.Format(time.RFC3339),
+			PurgeAt:    td.DeletedAt.Add(s.retention).Format(time.RFC3339),
+		})
+	}
+	for _, tc := range cards {
+		resp.Cards = append(resp.Cards, domain.TrashCardItem{
+			ID:        tc.Card.ID,
+			Question:  tc.Card.Question,
+			Answer:    tc.Card.Answer,
+			Deck:      domain.DeckBrief{ID: tc.Card.DeckID, Title: tc.DeckTitle},
+			DeletedAt: tc.DeletedAt.Format(time.RFC3339),
+			PurgeAt:   tc.DeletedAt.Add(s.retention).Format(time.RFC3339),
+		})
+	}
+	return resp, nil
+}
+
+// RestoreDeck восстанавливает набор вместе с карточками и вложенными наборами,
+// которые не удалялись отдельно.
+func (s *TrashService) RestoreDeck(ctx context.Context, id int, userID int) error {
+	td, err := s.trashedDeck(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if td.ParentDeleted {
+		return ErrParentInTrash
+	}
+	return s.trashRepo.RestoreDeck(ctx, id)
+}
+
+// RestoreCard восстанавливает карточку; набор карточки не должен быть в корзине.
+func (s *TrashService) RestoreCard(ctx context.Context, id int, userID int) error {
+	tc, err := s.trashedCard(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if tc.DeckDeleted {
+		return ErrDeckInTrash
+	}
+	return s.trashRepo.RestoreCard(ctx, id)
+}
+
+// PurgeDeck окончательно удаляет набор из корзины.
+func (s *TrashService) PurgeDeck(ctx context.Context, id int, userID int) error {
+	if _, err := s.trashedDeck(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.trashRepo.PurgeDeck(ctx, id)
+}
+
+// PurgeCard окончательно удаляет карточку из корзины.
+func (s *TrashService) PurgeCard(ctx context.Context, id int, userID int) error {
+	if _, err := s.trashedCard(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.trashRepo.PurgeCard(ctx, id)
+}
+
+// Empty очищает корзину пользователя.
+func (s *TrashService) Empty(ctx context.Context, userID int) error {
+	return s.trashRepo.Empty(ctx, userID)
+}
+
+// RegisterJobs регистрирует в jobs ежечасную очистку корзины от просроченного.
+func (s *TrashService) RegisterJobs(jobs *JobService) {
+	jobs.Register(domain.JobPurgeTrash, JobKind{Every: time.Hour, Run: func(ctx context.Context, _ *domain.Job, _ func(done, total int)) (interface{}, error) {
+		n, err := s.PurgeExpired(ctx)
+		return map[string]int64{"purged": n}, err
+	}})
+}
+
+// PurgeExpired окончательно удаляет всё, что пролежало в корзине дольше срока хранения.
+func (s *TrashService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.trashRepo.PurgeBefore(ctx, time.Now().Add(-s.retention))
+}
+
+func (s *TrashService) trashedDeck(ctx context.Context, id int, userID int) (*repository.TrashedDeck, error) {
+	td, err := s.trashRepo.GetDeck(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if td == nil {
+		return nil, ErrTrashNotFound
+	}
+	if td.Deck.UserID != userID {
+		return nil, ErrDeckForbidden
+	}
+	return td, nil
+}
+
+func (s *TrashService) trashedCard(ctx context.Context, id int, userID int) (*repository.TrashedCard, error) {
+	tc, err := s.trashRepo.GetCard(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tc == nil {
+		return nil, ErrTrashNotFound
+	}
+	if tc.DeckUserID != userID {
+		return nil, ErrCardForbidden
+	}
+	return tc, nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+	"github.com/pro100kartochki/mozgoemka/internal/repository"
+)
+
+var (
+	ErrUserNotFound          = errors.New("пользователь не найден")
+	ErrAccountExportNotFound = errors.New("выгрузка не найдена или устарела")
+	ErrAccountExportNotReady = errors.New("выгрузка ещё не готова")
+)
+
+// StartExport ставит в очередь выгрузку всех данных пользователя в zip (схема — domain.AccountManifest).
+// Пока предыдущая выгрузка пользователя не завершена, возвращается она.
+func (s *UserService) StartExport(ctx context.Context, userID int) (*domain.Job, error) {
+	return s.jobs.Enqueue(ctx, userID, domain.JobExportAccount, domain.JobExportAccount+":"+strconv.Itoa(userID), struct{}{})
+}
+
+// Export возвращает состояние выгрузки пользователя.
+func (s *UserService) Export(ctx context.Context, id string, userID int) (*domain.Job, error) {
+	job, err := s.jobs.Get(ctx, id, userID)
+	if errors.Is(err, ErrJobNotFound) || (err == nil && job.Kind != domain.JobExportAccount) {
+		return nil, ErrAccountExportNotFound
+	}
+	return job, err
+}
+
+// ExportFile возвращает путь к готовому архиву выгрузки и его описание.
+func (s *UserService) ExportFile(ctx context.Context, id string, userID int) (string, *domain.JobFile, error) {
+	if _, err := s.Export(ctx, id, userID); err != nil {
+		return "", nil, err
+	}
+	path, f, err := s.jobs.File(ctx, id, userID)
+	switch {
+	case errors.Is(err, ErrJobNotReady):
+		return "", nil, ErrAccountExportNotReady
+	case errors.Is(err, ErrJobNotFound):
+		return "", nil, ErrAccountExportNotFound
+	}
+	return path, f, err
+}
+
+// runAccountExportJob пишет архив в файл задачи. Выгрузку можно безопасно повторить: файл пишется заново.
+func (s *UserService) runAccountExportJob(ctx context.Context, job *domain.Job, progress func(done, total int)) (interface{}, error) {
+	size, err := writeJobFile(ctx, s.jobs.FilePath(job.ID), func(ctx context.Context, w io.Writer) error {
+		zw := zip.NewWriter(w)
+		if err := s.writeAccount(ctx, zw, job.UserID, progress); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return domain.JobFile{
+		Filename:    "mozgoemka-account-" + time.Now().Format("2006-01-02") + ".zip",
+		ContentType: "application/zip",
+		Size:        size,
+	}, nil
+}
+
+// accountArchive — состояние записи архива: файлы загрузок, на которые сослались карточки и профиль.
+type accountArchive struct {
+	zw       *zip.Writer
+	manifest domain.AccountManifest
+	files    map[string]string // файл на диске → файл архива
+}
+
+// writeAccount пишет в zw все файлы архива аккаунта; manifest.json — последним, когда известны счётчики.
+func (s *UserService) writeAccount(ctx context.Context, zw *zip.Writer, userID int, progress func(done, total int)) error {
+	u, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || u == nil {
+		return ErrUserNotFound
+	}
+	a := &accountArchive{
+		zw:       zw,
+		manifest: domain.AccountManifest{Format: domain.AccountArchiveFormat, Version: domain.AccountArchiveVersion, ExportedAt: time.Now().UTC(), Media: map[string]string{}},
+		files:    make(map[string]string),
+	}
+
+	profile := domain.AccountProfile{Email: u.Email, Username: u.Username, Role: u.Role, CreatedAt: u.CreatedAt}
+	if u.AvatarURL != nil {
+		profile.Avatar = a.media(s.exporter, *u.AvatarURL)
+	}
+	if err := a.writeJSON("profile.json", profile); err != nil {
+		return err
+	}
+
+	subs, err := s.subRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	settings := domain.AccountSettings{Subscriptions: []int{}}
+	for _, sub := range subs {
+		settings.Subscriptions = append(settings.Subscriptions, sub.DeckID)
+	}
+	if err := a.writeJSON("settings.json", settings); err != nil {
+		return err
+	}
+
+	tags, err := s.tagRepo.List(ctx, userID)
+	if err != nil {
+		return err
+	}
+	private := []string{}
+	for _, t := range tags {
+		if !t.IsGlobal() {
+			private = append(private, t.Name)
+		}
+	}
+	if err := a.writeJSON("tags.json", private); err != nil {
+		return err
+	}
+
+	decks, err := s.deckRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	list := make([]domain.AccountDeck, len(decks))
+	ids := make([]int, len(decks))
+	for i := range decks {
+		d := &decks[i]
+		cat, tags, err := s.exporter.deckMeta(ctx, d, userID)
+		if err != nil {
+			return err
+		}
+		list[i] = domain.AccountDeck{
+			ID: d.ID, ParentID: d.ParentID, Title: d.Title, Description: d.Description,
+			IsPublic: d.IsPublic, Category: cat, Tags: tags, CreatedAt: d.CreatedAt,
+		}
+		ids[i] = d.ID
+	}
+	a.manifest.Counts.Decks = len(list)
+	if err := a.writeJSON("decks.json", list); err != nil {
+		return err
+	}
+
+	total, err := s.cardRepo.CountByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	progress(0, total)
+	err = a.writeLines("cards.jsonl", func(write func(v interface{}) error) error {
+		return s.cardRepo.EachForExport(ctx, ids, userID, func(ec *repository.ExportCard) error {
+			c := domain.AccountCard{
+				ID: ec.Card.ID, DeckID: ec.Card.DeckID, Question: ec.Card.Question, Answer: ec.Card.Answer,
+				Tags: ec.Tags, CreatedAt: ec.Card.CreatedAt,
+			}
+			if ec.CategorySlug != "" {
+				c.Category = &domain.BackupCategory{Slug: ec.CategorySlug, Name: ec.CategoryName}
+			}
+			for _, text := range []string{c.Question, c.Answer} {
+				for _, m := range mdLinkRe.FindAllStringSubmatch(text, -1) {
+					a.media(s.exporter, m[3])
+				}
+			}
+			a.manifest.Counts.Cards++
+			if a.manifest.Counts.Cards%importBatchSize == 0 {
+				progress(a.manifest.Counts.Cards, max(total, a.manifest.Counts.Cards))
+			}
+			return write(c)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	progress(a.manifest.Counts.Cards, a.manifest.Counts.Cards)
+
+	err = a.writeLines("progress.jsonl", func(write func(v interface{}) error) error {
+		return s.progressRepo.EachByUserID(ctx, userID, func(p *domain.CardProgress) error {
+			a.manifest.Counts.Progress++
+			return write(p)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = a.writeLines("reviews.jsonl", func(write func(v interface{}) error) error {
+		return s.progressRepo.EachReview(ctx, userID, func(l *domain.ReviewLog) error {
+			a.manifest.Counts.Reviews++
+			return write(l)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for path, name := range a.files {
+		if err := a.copyFile(name, path); err != nil {
+			return err
+		}
+	}
+	a.manifest.Counts.Media = len(a.files)
+	return a.writeJSON("manifest.json", a.manifest)
+}
+
+// media запоминает файл загрузок по ссылке link и возвращает его имя в архиве; "" — файл внешний.
+func (a *accountArchive) media(exporter *ExportService, link string) string {
+	path := exporter.localUpload(link)
+	if path == "" {
+		return ""
+	}
+	name, ok := a.files[path]
+	if !ok {
+		rel, err := filepath.Rel(exporter.uploadPath, path)
+		if err != nil {
+			return ""
+		}
+		name = "media/" + filepath.ToSlash(rel)
+		a.files[path] = name
+		a.manifest.Media[name] = link
+	}
+	return name
+}
+
+func (a *accountArchive) writeJSON(name string, v interface{}) error {
+	w, err := a.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeLines пишет файл JSON Lines: each вызывает write для каждой записи.
+func (a *accountArchive) writeLines(name string, each func(write func(v interface{}) error) error) error {
+	w, err := a.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := each(func(v interface{}) error { return enc.Encode(v) }); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (a *accountArchive) copyFile(name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
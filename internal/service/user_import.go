@@ -0,0 +1,590 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pro100kartochki/mozgoemka/internal/domain"
+)
+
+var (
+	ErrAccountArchive        = errors.New("файл не является архивом аккаунта")
+	ErrAccountArchiveVersion = errors.New("версия архива аккаунта не поддерживается")
+	ErrAccountNotEmpty       = errors.New("архив восстанавливается только в аккаунт без наборов")
+)
+
+// accountImportJob — параметры задачи восстановления аккаунта.
+type accountImportJob struct {
+	Path string `json:"path"`
+}
+
+// ImportAccount ставит в очередь восстановление архива выгрузки аккаунта (POST /users/me/export).
+// Аккаунт должен быть без наборов, чтобы восстановление не смешалось с существующими данными.
+// Архив проверяется сразу; файл path переходит во владение сервиса. Пока предыдущее восстановление
+// пользователя не завершено, возвращается оно, а новый архив удаляется.
+func (s *UserService) ImportAccount(ctx context.Context, userID int, path string) (*domain.Job, error) {
+	err := s.checkEmptyAccount(ctx, userID)
+	if err == nil {
+		var r *accountRestore
+		if r, err = s.openAccountArchive(path, userID); err == nil {
+			r.zr.Close()
+		}
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	job, err := s.jobs.Enqueue(ctx, userID, domain.JobImportAccount, domain.JobImportAccount+":"+strconv.Itoa(userID), accountImportJob{Path: path})
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	var p accountImportJob
+	if decodePayload(job, &p) != nil || p.Path != path {
+		os.Remove(path)
+	}
+	return job, nil
+}
+
+func (s *UserService) runAccountImportJob(ctx context.Context, job *domain.Job, progress func(done, total int)) (interface{}, error) {
+	var p accountImportJob
+	if err := decodePayload(job, &p); err != nil {
+		return nil, err
+	}
+	defer os.Remove(p.Path)
+	if err := s.checkEmptyAccount(ctx, job.UserID); err != nil {
+		return nil, err
+	}
+	r, err := s.openAccountArchive(p.Path, job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer r.zr.Close()
+	r.progress = progress
+	progress(0, r.total)
+	err = r.run(ctx)
+	if err != nil {
+		// восстановление не должно оставить аккаунт наполовину заполненным: иначе его не повторить
+		if rbErr := r.rollback(); rbErr != nil {
+			return r.res, fmt.Errorf("%w; откат восстановления: %v", err, rbErr)
+		}
+		r.res = &domain.ImportResult{Decks: []domain.DeckBrief{}, Warnings: r.res.Warnings}
+		return r.res, err
+	}
+	for _, id := range r.tags {
+		if id != 0 {
+			r.res.TagsUsed++
+		}
+	}
+	return r.res, err
+}
+
+func (s *UserService) checkEmptyAccount(ctx context.Context, userID int) error {
+	n, err := s.deckRepo.CountByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrAccountNotEmpty
+	}
+	return nil
+}
+
+// openAccountArchive открывает архив аккаунта и читает манифест; архив закрывает вызывающий (r.zr).
+func (s *UserService) openAccountArchive(path string, userID int) (*accountRestore, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, ErrAccountArchive
+	}
+	r := &accountRestore{
+		backupRestore: &backupRestore{
+			s: s.importer, userID: userID,
+			res:        &domain.ImportResult{Decks: []domain.DeckBrief{}},
+			tags:       make(map[string]int),
+			categories: make(map[domain.BackupCategory]*int),
+		},
+		u:        s,
+		zr:       zr,
+		files:    make(map[string]*zip.File),
+		media:    make(map[string]string),
+		links:    make(map[string]string),
+		decks:    make(map[int]int),
+		cards:    make(map[int]int),
+		foreign:  make(map[int]bool),
+		subDecks: make(map[int]bool),
+	}
+	for _, f := range zr.File {
+		r.files[f.Name] = f
+	}
+	err = r.readJSON("manifest.json", &r.manifest)
+	if err == nil && r.manifest.Format != domain.AccountArchiveFormat {
+		err = ErrAccountArchive
+	}
+	if err == nil && (r.manifest.Version < 1 || r.manifest.Version > domain.AccountArchiveVersion) {
+		err = ErrAccountArchiveVersion
+	}
+	if err != nil {
+		zr.Close()
+		if errors.Is(err, ErrAccountArchiveVersion) {
+			return nil, err
+		}
+		return nil, ErrAccountArchive
+	}
+	for name, link := range r.manifest.Media {
+		r.links[link] = name
+	}
+	r.total = r.manifest.Counts.Cards
+	return r, nil
+}
+
+// accountRestore — состояние восстановления архива аккаунта; ID наборов и карточек архива
+// отображаются на созданные.
+type accountRestore struct {
+	*backupRestore
+	u        *UserService
+	zr       *zip.ReadCloser
+	manifest domain.AccountManifest
+	files    map[string]*zip.File
+	media    map[string]string // файл архива → URL скопированного файла ("" — не скопирован)
+	links    map[string]string // исходный URL → файл архива
+	decks    map[int]int
+	cards    map[int]int
+	foreign  map[int]bool // карточки наборов по подписке: true — карточка существует и доступна
+	subDecks map[int]bool // наборы, на которые восстановлена подписка
+	newSubs  []int        // подписки, созданные восстановлением
+	quota    mediaQuota
+	profiled *domain.User // имя и аватар до восстановления; nil — профиль не менялся
+}
+
+// rollback отменяет частично выполненное восстановление: окончательно удаляет созданные наборы
+// (с карточками, прогрессом и журналом повторений) и созданные подписки, возвращает прежние
+// имя и аватар и удаляет скопированные медиафайлы. Выполняется и после отмены задачи,
+// поэтому не зависит от её контекста.
+func (r *accountRestore) rollback() error {
+	ctx := domain.WithRevisionAuthor(context.Background(), r.userID)
+	if r.profiled != nil {
+		u, err := r.u.userRepo.GetByID(ctx, r.userID)
+		if err != nil {
+			return err
+		}
+		if u != nil {
+			u.Username, u.AvatarURL = r.profiled.Username, r.profiled.AvatarURL
+			if err := r.u.userRepo.Update(ctx, u); err != nil {
+				return err
+			}
+		}
+	}
+	r.quota.remove()
+	ids := make([]int, 0, len(r.decks))
+	for _, id := range r.decks {
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		if err := r.u.deckRepo.Purge(ctx, r.userID, ids); err != nil {
+			return err
+		}
+	}
+	for _, id := range r.newSubs {
+		if err := r.u.subRepo.Delete(ctx, r.userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *accountRestore) run(ctx context.Context) error {
+	if err := r.profile(ctx); err != nil {
+		return err
+	}
+	var tags []string
+	if err := r.readJSON("tags.json", &tags); err != nil {
+		return err
+	}
+	r.s.importTags(ctx, r.userID, tags, r.tags, r.warn)
+	if err := r.settings(ctx); err != nil {
+		return err
+	}
+	if err := r.restoreDecks(ctx); err != nil {
+		return err
+	}
+	if err := r.restoreCards(ctx); err != nil {
+		return err
+	}
+	if err := r.restoreProgress(ctx); err != nil {
+		return err
+	}
+	return r.restoreReviews(ctx)
+}
+
+// profile переносит имя пользователя (если в аккаунте его нет) и аватар; email и роль не меняются.
+func (r *accountRestore) profile(ctx context.Context) error {
+	var p domain.AccountProfile
+	if err := r.readJSON("profile.json", &p); err != nil {
+		return err
+	}
+	u, err := r.u.userRepo.GetByID(ctx, r.userID)
+	if err != nil || u == nil {
+		return ErrUserNotFound
+	}
+	prev := *u
+	changed := false
+	if u.Username == nil && p.Username != nil {
+		u.Username = p.Username
+		changed = true
+	}
+	if p.Avatar != "" {
+		if link := r.mediaURL(p.Avatar); link != "" {
+			u.AvatarURL = &link
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	r.profiled = &prev
+	return r.u.userRepo.Update(ctx, u)
+}
+
+// settings восстанавливает подписки на наборы, которые по-прежнему публичны.
+func (r *accountRestore) settings(ctx context.Context) error {
+	var st domain.AccountSettings
+	if err := r.readJSON("settings.json", &st); err != nil {
+		return err
+	}
+	for _, id := range st.Subscriptions {
+		d, _ := r.u.deckRepo.GetByID(ctx, id)
+		if d == nil || !d.IsPublic || d.UserID == r.userID {
+			r.warn(fmt.Sprintf("подписка на набор %d пропущена: набор недоступен", id))
+			continue
+		}
+		exists, err := r.u.subRepo.Exists(ctx, r.userID, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := r.u.subRepo.Create(ctx, &domain.DeckSubscription{UserID: r.userID, DeckID: id}); err != nil {
+				return err
+			}
+			r.newSubs = append(r.newSubs, id)
+		}
+		r.subDecks[id] = true
+	}
+	return nil
+}
+
+// restoreDecks создаёт наборы, родительские раньше вложенных; набор без родителя в архиве
+// становится набором верхнего уровня.
+func (r *accountRestore) restoreDecks(ctx context.Context) error {
+	var list []domain.AccountDeck
+	if err := r.readJSON("decks.json", &list); err != nil {
+		return err
+	}
+	byID := make(map[int]*domain.AccountDeck, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+	}
+	var create func(ad *domain.AccountDeck, depth int) error
+	create = func(ad *domain.AccountDeck, depth int) error {
+		if _, ok := r.decks[ad.ID]; ok {
+			return nil
+		}
+		var parentID *int
+		if ad.ParentID != nil {
+			if parent := byID[*ad.ParentID]; parent != nil && depth < len(list) {
+				if err := create(parent, depth+1); err != nil {
+					return err
+				}
+				id := r.decks[parent.ID]
+				parentID = &id
+			}
+			if _, ok := r.decks[ad.ID]; ok {
+				// цикл parent_id в повреждённом архиве: набор уже создан при обходе
+				return nil
+			}
+		}
+		title := strings.TrimSpace(ad.Title)
+		if title == "" {
+			title = "Без названия"
+		}
+		if utf8.RuneCountInString(title) > 255 {
+			title = string([]rune(title)[:255])
+		}
+		d := &domain.Deck{
+			UserID:      r.userID,
+			Title:       title,
+			Description: ad.Description,
+			CategoryID:  r.category(ctx, ad.Category),
+			IsPublic:    ad.IsPublic,
+			ParentID:    parentID,
+		}
+		if err := r.u.deckRepo.Create(ctx, d); err != nil {
+			return err
+		}
+		r.decks[ad.ID] = d.ID
+		r.res.Decks = append(r.res.Decks, domain.DeckBrief{ID: d.ID, Title: d.Title})
+		if tagIDs := r.s.importTags(ctx, r.userID, ad.Tags, r.tags, r.warn); len(tagIDs) > 0 {
+			return r.u.deckRepo.SetDeckTags(ctx, d.ID, tagIDs)
+		}
+		return nil
+	}
+	for i := range list {
+		if err := create(&list[i], 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *accountRestore) restoreCards(ctx context.Context) error {
+	var cards []domain.Card
+	var cardTags [][]int
+	var oldIDs []int
+	flush := func() error {
+		if err := r.u.cardRepo.CreateBatch(ctx, cards, cardTags); err != nil {
+			return err
+		}
+		for i := range cards {
+			r.cards[oldIDs[i]] = cards[i].ID
+		}
+		r.res.CardsCreated += len(cards)
+		r.progress(r.done, max(r.total, r.done))
+		cards, cardTags, oldIDs = cards[:0], cardTags[:0], oldIDs[:0]
+		return nil
+	}
+	err := r.eachLine("cards.jsonl", func(next func(v interface{}) error) error {
+		var ac domain.AccountCard
+		if err := next(&ac); err != nil {
+			return err
+		}
+		r.done++
+		deckID, ok := r.decks[ac.DeckID]
+		if !ok || strings.TrimSpace(ac.Question) == "" || strings.TrimSpace(ac.Answer) == "" {
+			r.res.CardsSkipped++
+			r.warn(fmt.Sprintf("карточка %d пропущена: нет набора, вопроса или ответа", ac.ID))
+			return nil
+		}
+		cards = append(cards, domain.Card{
+			DeckID:     deckID,
+			Question:   r.rewriteMedia(ac.Question),
+			Answer:     r.rewriteMedia(ac.Answer),
+			CategoryID: r.category(ctx, ac.Category),
+		})
+		cardTags = append(cardTags, r.s.importTags(ctx, r.userID, ac.Tags, r.tags, r.warn))
+		oldIDs = append(oldIDs, ac.ID)
+		if len(cards) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (r *accountRestore) restoreProgress(ctx context.Context) error {
+	var batch []domain.CardProgress
+	flush := func() error {
+		if err := r.resolveCards(ctx, progressCardIDs(batch)); err != nil {
+			return err
+		}
+		list := batch[:0]
+		for _, p := range batch {
+			if id, ok := r.cardID(p.CardID); ok {
+				p.UserID, p.CardID = r.userID, id
+				list = append(list, p)
+			}
+		}
+		if err := r.s.progressRepo.UpsertBatch(ctx, list); err != nil {
+			return err
+		}
+		r.res.Scheduled += len(list)
+		batch = batch[:0]
+		return nil
+	}
+	err := r.eachLine("progress.jsonl", func(next func(v interface{}) error) error {
+		var p domain.CardProgress
+		if err := next(&p); err != nil {
+			return err
+		}
+		batch = append(batch, p)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (r *accountRestore) restoreReviews(ctx context.Context) error {
+	var batch []domain.ReviewLog
+	flush := func() error {
+		ids := make([]int, len(batch))
+		for i, l := range batch {
+			ids[i] = l.CardID
+		}
+		if err := r.resolveCards(ctx, ids); err != nil {
+			return err
+		}
+		list := batch[:0]
+		for _, l := range batch {
+			if id, ok := r.cardID(l.CardID); ok {
+				l.UserID, l.CardID = r.userID, id
+				list = append(list, l)
+			}
+		}
+		if err := r.s.progressRepo.LogReviewsBatch(ctx, list); err != nil {
+			return err
+		}
+		r.res.Reviews += len(list)
+		batch = batch[:0]
+		return nil
+	}
+	err := r.eachLine("reviews.jsonl", func(next func(v interface{}) error) error {
+		var l domain.ReviewLog
+		if err := next(&l); err != nil {
+			return err
+		}
+		batch = append(batch, l)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// resolveCards проверяет карточки архива, которых нет среди восстановленных: прогресс по ним
+// переносится, если карточка по-прежнему лежит в наборе с восстановленной подпиской.
+func (r *accountRestore) resolveCards(ctx context.Context, ids []int) error {
+	var unknown []int
+	for _, id := range ids {
+		if _, own := r.cards[id]; !own {
+			if _, checked := r.foreign[id]; !checked {
+				r.foreign[id] = false
+				unknown = append(unknown, id)
+			}
+		}
+	}
+	if len(unknown) == 0 || len(r.subDecks) == 0 {
+		return nil
+	}
+	cards, err := r.u.cardRepo.ListByIDs(ctx, unknown)
+	if err != nil {
+		return err
+	}
+	for _, c := range cards {
+		r.foreign[c.ID] = r.subDecks[c.DeckID]
+	}
+	return nil
+}
+
+// cardID — ID карточки в восстановленном аккаунте по ID из архива.
+func (r *accountRestore) cardID(old int) (int, bool) {
+	if id, ok := r.cards[old]; ok {
+		return id, true
+	}
+	return old, r.foreign[old]
+}
+
+// rewriteMedia заменяет в тексте ссылки на файлы архива ссылками на их копии в загрузках.
+func (r *accountRestore) rewriteMedia(text string) string {
+	return mdLinkRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := mdLinkRe.FindStringSubmatch(m)
+		if name, ok := r.links[sub[3]]; ok {
+			if copied := r.mediaURL(name); copied != "" {
+				return sub[1] + "[" + sub[2] + "](" + copied + ")"
+			}
+		}
+		return m
+	})
+}
+
+// mediaURL копирует файл архива name в загрузки пользователя (один раз) и возвращает его URL.
+func (r *accountRestore) mediaURL(name string) string {
+	if link, ok := r.media[name]; ok {
+		return link
+	}
+	link := ""
+	if f := r.files[name]; f != nil && f.UncompressedSize64 <= maxMediaFileSize {
+		if rc, err := f.Open(); err == nil {
+			link, err = r.s.saveMedia(r.userID, path.Base(name), rc, &r.quota)
+			rc.Close()
+			if err != nil {
+				link = ""
+			} else {
+				r.res.MediaCopied++
+			}
+		}
+	}
+	if link == "" {
+		r.warn("файл не скопирован: " + name)
+	}
+	r.media[name] = link
+	return link
+}
+
+func (r *accountRestore) readJSON(name string, v interface{}) error {
+	f := r.files[name]
+	if f == nil {
+		return fmt.Errorf("%w: нет %s", ErrAccountArchive, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrAccountArchive, name, err)
+	}
+	return nil
+}
+
+// eachLine вызывает fn для каждой записи файла JSON Lines; запись читается через next.
+// Отсутствующий файл — пустой.
+func (r *accountRestore) eachLine(name string, fn func(next func(v interface{}) error) error) error {
+	f := r.files[name]
+	if f == nil {
+		return nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	next := func(v interface{}) error {
+		if err := dec.Decode(v); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrAccountArchive, name, err)
+		}
+		return nil
+	}
+	for dec.More() {
+		if err := fn(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func progressCardIDs(list []domain.CardProgress) []int {
+	ids := make([]int, len(list))
+	for i, p := range list {
+		ids[i] = p.CardID
+	}
+	return ids
+}
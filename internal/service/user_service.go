@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -12,15 +13,42 @@ import (
 )
 
 type UserService struct {
-	userRepo    *repository.UserRepository
-	deckRepo    *repository.DeckRepository
-	cardRepo    *repository.CardRepository
-	uploadPath  string // корень загрузок (например ./uploads)
-	baseURL     string // например http://localhost:8080
+	userRepo     *repository.UserRepository
+	deckRepo     *repository.DeckRepository
+	cardRepo     *repository.CardRepository
+	tagRepo      *repository.TagRepository
+	progressRepo *repository.CardProgressRepository
+	subRepo      *repository.SubscriptionRepository
+	exporter     *ExportService // метаданные наборов и файлы загрузок для выгрузки аккаунта
+	importer     *ImportService // теги и медиафайлы для восстановления аккаунта
+	jobs         *JobService
+	uploadPath   string // корень загрузок (например ./uploads)
+	baseURL      string // например http://localhost:8080
 }
 
-func NewUserService(userRepo *repository.UserRepository, deckRepo *repository.DeckRepository, cardRepo *repository.CardRepository) *UserService {
-	return &UserService{userRepo: userRepo, deckRepo: deckRepo, cardRepo: cardRepo}
+func NewUserService(userRepo *repository.UserRepository, deckRepo *repository.DeckRepository, cardRepo *repository.CardRepository, tagRepo *repository.TagRepository, progressRepo *repository.CardProgressRepository, subRepo *repository.SubscriptionRepository, exporter *ExportService, importer *ImportService) *UserService {
+	return &UserService{
+		userRepo:     userRepo,
+		deckRepo:     deckRepo,
+		cardRepo:     cardRepo,
+		tagRepo:      tagRepo,
+		progressRepo: progressRepo,
+		subRepo:      subRepo,
+		exporter:     exporter,
+		importer:     importer,
+	}
+}
+
+// RegisterJobs регистрирует в jobs выгрузку и восстановление аккаунта.
+func (s *UserService) RegisterJobs(jobs *JobService) {
+	s.jobs = jobs
+	jobs.Register(domain.JobExportAccount, JobKind{Run: s.runAccountExportJob, MaxAttempts: 3})
+	jobs.Register(domain.JobImportAccount, JobKind{Run: s.runAccountImportJob})
+}
+
+// SaveUpload сохраняет загруженный архив аккаунта в каталог входных файлов задач и возвращает путь.
+func (s *UserService) SaveUpload(r io.Reader, pattern string) (string, error) {
+	return s.jobs.SaveInput(r, pattern)
 }
 
 func (s *UserService) SetUploadConfig(uploadPath, baseURL string) {
@@ -0,0 +1,13 @@
+-- Состояние интервальных повторений карточки для пользователя (SM-2)
+CREATE TABLE card_progress (
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    card_id INT REFERENCES cards(id) ON DELETE CASCADE,
+    ease_factor REAL NOT NULL DEFAULT 2.5,
+    interval_days INT NOT NULL DEFAULT 0,
+    repetitions INT NOT NULL DEFAULT 0,
+    due_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_reviewed_at TIMESTAMP,
+    PRIMARY KEY (user_id, card_id)
+);
+
+CREATE INDEX idx_card_progress_card_id ON card_progress(card_id);
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS trg_cards_deck_version_delete ON cards;
+DROP TRIGGER IF EXISTS trg_cards_deck_version_update ON cards;
+DROP TRIGGER IF EXISTS trg_cards_deck_version_insert ON cards;
+DROP FUNCTION IF EXISTS bump_deck_version();
+ALTER TABLE cards DROP COLUMN IF EXISTS origin_card_id;
+ALTER TABLE decks DROP COLUMN IF EXISTS forked_from_version;
+ALTER TABLE decks DROP COLUMN IF EXISTS forked_from_deck_id;
+ALTER TABLE decks DROP COLUMN IF EXISTS version;
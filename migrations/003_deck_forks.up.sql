@@ -0,0 +1,50 @@
+-- Версия содержимого набора: растёт при изменении набора и его карточек
+ALTER TABLE decks ADD COLUMN version INT NOT NULL DEFAULT 1;
+
+-- Происхождение форка: исходный набор и его версия на момент копирования
+ALTER TABLE decks ADD COLUMN forked_from_deck_id INT REFERENCES decks(id) ON DELETE SET NULL;
+ALTER TABLE decks ADD COLUMN forked_from_version INT;
+
+-- Исходная карточка, из которой скопирована карточка форка. Без внешнего ключа:
+-- ссылка сохраняется после удаления исходной карточки, иначе сравнение с исходным
+-- набором не увидит удалённые карточки
+ALTER TABLE cards ADD COLUMN origin_card_id INT;
+
+CREATE INDEX idx_decks_forked_from_deck_id ON decks(forked_from_deck_id);
+CREATE INDEX idx_cards_origin_card_id ON cards(origin_card_id);
+
+-- Версия растёт один раз на оператор для каждого затронутого набора. UPDATE сравнивает только
+-- содержимое карточки: повторное сохранение того же текста меняет updated_at, но не версию.
+-- deleted_at появляется в 007_trash; тело функции разбирается при вызове, поэтому ссылка допустима
+CREATE FUNCTION bump_deck_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE decks SET version = version + 1 WHERE id IN (SELECT deck_id FROM new_rows);
+    ELSIF TG_OP = 'DELETE' THEN
+        UPDATE decks SET version = version + 1 WHERE id IN (SELECT deck_id FROM old_rows);
+    ELSE
+        UPDATE decks SET version = version + 1 WHERE id IN (
+            SELECT o.deck_id FROM old_rows o INNER JOIN new_rows n ON n.id = o.id WHERE
+                (o.question, o.answer, o.category_id, o.deck_id, o.deleted_at)
+                IS DISTINCT FROM (n.question, n.answer, n.category_id, n.deck_id, n.deleted_at)
+            UNION
+            SELECT n.deck_id FROM old_rows o INNER JOIN new_rows n ON n.id = o.id WHERE
+                (o.question, o.answer, o.category_id, o.deck_id, o.deleted_at)
+                IS DISTINCT FROM (n.question, n.answer, n.category_id, n.deck_id, n.deleted_at));
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Таблицы переходов нельзя объявить у триггера на несколько событий, поэтому триггеров три
+CREATE TRIGGER trg_cards_deck_version_insert
+    AFTER INSERT ON cards REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_deck_version();
+
+CREATE TRIGGER trg_cards_deck_version_update
+    AFTER UPDATE ON cards REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_deck_version();
+
+CREATE TRIGGER trg_cards_deck_version_delete
+    AFTER DELETE ON cards REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_deck_version();
@@ -0,0 +1,3 @@
+ALTER TABLE cards DROP COLUMN IF EXISTS origin_category_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS origin_answer;
+ALTER TABLE cards DROP COLUMN IF EXISTS origin_question;
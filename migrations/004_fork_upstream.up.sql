@@ -0,0 +1,8 @@
+-- Содержимое исходной карточки на момент форка или последней синхронизации
+-- (база для трёхстороннего сравнения «база / моя / исходная»)
+ALTER TABLE cards ADD COLUMN origin_question TEXT;
+ALTER TABLE cards ADD COLUMN origin_answer TEXT;
+ALTER TABLE cards ADD COLUMN origin_category_id INT;
+
+UPDATE cards SET origin_question = question, origin_answer = answer, origin_category_id = category_id
+WHERE origin_card_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_card_progress_user_due;
+DROP TABLE IF EXISTS deck_subscriptions;
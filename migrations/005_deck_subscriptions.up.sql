@@ -0,0 +1,11 @@
+-- Подписки на публичные наборы: набор изучается без копирования,
+-- содержимое остаётся у автора, прогресс хранится в card_progress подписчика
+CREATE TABLE deck_subscriptions (
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    deck_id INT REFERENCES decks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, deck_id)
+);
+
+CREATE INDEX idx_deck_subscriptions_deck_id ON deck_subscriptions(deck_id);
+CREATE INDEX idx_card_progress_user_due ON card_progress(user_id, due_at);
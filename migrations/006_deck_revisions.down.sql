@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS trg_cards_revision ON cards;
+DROP TRIGGER IF EXISTS trg_decks_revision ON decks;
+DROP FUNCTION IF EXISTS record_card_revision();
+DROP FUNCTION IF EXISTS record_deck_revision();
+DROP FUNCTION IF EXISTS card_snapshot(cards);
+DROP FUNCTION IF EXISTS deck_snapshot(decks);
+DROP FUNCTION IF EXISTS changed_keys(JSONB, JSONB);
+DROP FUNCTION IF EXISTS revision_user(INT);
+DROP TABLE IF EXISTS deck_revisions;
@@ -0,0 +1,120 @@
+-- Неизменяемая история изменений наборов и их карточек.
+-- Ревизии пишут триггеры, поэтому история не зависит от того, какой код изменил данные.
+-- deck_id без внешнего ключа: удаление карточек каскадом из удаляемого набора не должно падать.
+CREATE TABLE deck_revisions (
+    id SERIAL PRIMARY KEY,
+    deck_id INT NOT NULL,
+    user_id INT,                                -- кто внёс изменение (по умолчанию владелец набора)
+    entity VARCHAR(10) NOT NULL,                -- deck | card
+    entity_id INT NOT NULL,
+    action VARCHAR(10) NOT NULL,                -- create | update | delete | move_in | move_out
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+    snapshot JSONB NOT NULL,                    -- состояние после изменения (для delete/move_out — до)
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_deck_revisions_deck_id ON deck_revisions(deck_id, id);
+CREATE INDEX idx_deck_revisions_entity ON deck_revisions(deck_id, entity, entity_id, id);
+
+-- Автор изменения: SET LOCAL app.user_id в транзакции или владелец набора
+CREATE FUNCTION revision_user(owner INT) RETURNS INT AS $$
+    SELECT COALESCE(NULLIF(current_setting('app.user_id', true), '')::INT, owner)
+$$ LANGUAGE sql STABLE;
+
+CREATE FUNCTION changed_keys(old_doc JSONB, new_doc JSONB) RETURNS TEXT[] AS $$
+    SELECT COALESCE(array_agg(k ORDER BY k), '{}') FROM jsonb_object_keys(new_doc) k
+    WHERE old_doc -> k IS DISTINCT FROM new_doc -> k
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION deck_snapshot(d decks) RETURNS JSONB AS $$
+    SELECT jsonb_build_object('title', d.title, 'description', d.description,
+        'category_id', d.category_id, 'is_public', d.is_public)
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION card_snapshot(c cards) RETURNS JSONB AS $$
+    SELECT jsonb_build_object('id', c.id, 'deck_id', c.deck_id, 'question', c.question,
+        'answer', c.answer, 'category_id', c.category_id)
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION record_deck_revision() RETURNS TRIGGER AS $$
+DECLARE
+    doc JSONB;
+    fields TEXT[];
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM deck_revisions WHERE deck_id = OLD.id;
+        RETURN NULL;
+    END IF;
+    doc := deck_snapshot(NEW);
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+        VALUES (NEW.id, revision_user(NEW.user_id), 'deck', NEW.id, 'create', ARRAY(SELECT jsonb_object_keys(doc)), doc);
+        RETURN NULL;
+    END IF;
+    fields := changed_keys(deck_snapshot(OLD), doc);
+    IF cardinality(fields) > 0 THEN
+        INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+        VALUES (NEW.id, revision_user(NEW.user_id), 'deck', NEW.id, 'update', fields, doc);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION record_card_revision() RETURNS TRIGGER AS $$
+DECLARE
+    owner INT;
+    old_doc JSONB;
+    new_doc JSONB;
+    fields TEXT[];
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        old_doc := card_snapshot(OLD);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        new_doc := card_snapshot(NEW);
+    END IF;
+    IF TG_OP = 'UPDATE' AND OLD.deck_id IS NOT DISTINCT FROM NEW.deck_id THEN
+        fields := changed_keys(old_doc, new_doc);
+        IF cardinality(fields) > 0 THEN
+            SELECT user_id INTO owner FROM decks WHERE id = NEW.deck_id;
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+            VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id, 'update', fields, new_doc);
+        END IF;
+        RETURN NULL;
+    END IF;
+    -- удаление или перенос: в старом наборе карточка исчезает
+    IF old_doc IS NOT NULL THEN
+        SELECT user_id INTO owner FROM decks WHERE id = OLD.deck_id;
+        IF FOUND THEN
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, snapshot)
+            VALUES (OLD.deck_id, revision_user(owner), 'card', OLD.id,
+                CASE WHEN TG_OP = 'DELETE' THEN 'delete' ELSE 'move_out' END, old_doc);
+        END IF;
+    END IF;
+    IF new_doc IS NOT NULL THEN
+        SELECT user_id INTO owner FROM decks WHERE id = NEW.deck_id;
+        INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+        VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id,
+            CASE WHEN TG_OP = 'INSERT' THEN 'create' ELSE 'move_in' END, ARRAY(SELECT jsonb_object_keys(new_doc)), new_doc);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_decks_revision
+    AFTER INSERT OR UPDATE OR DELETE ON decks
+    FOR EACH ROW EXECUTE FUNCTION record_deck_revision();
+
+CREATE TRIGGER trg_cards_revision
+    AFTER INSERT OR UPDATE OR DELETE ON cards
+    FOR EACH ROW EXECUTE FUNCTION record_card_revision();
+
+-- Базовые ревизии для уже существующих данных
+INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot, created_at)
+SELECT d.id, d.user_id, 'deck', d.id, 'create', ARRAY(SELECT jsonb_object_keys(deck_snapshot(d))), deck_snapshot(d), d.created_at
+FROM decks d;
+
+INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot, created_at)
+SELECT c.deck_id, d.user_id, 'card', c.id, 'create', ARRAY(SELECT jsonb_object_keys(card_snapshot(c))), card_snapshot(c), c.created_at
+FROM cards c INNER JOIN decks d ON d.id = c.deck_id
+ORDER BY c.id;
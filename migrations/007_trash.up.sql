@@ -0,0 +1,66 @@
+-- Корзина: удалённые наборы и карточки помечаются deleted_at и окончательно стираются
+-- фоновой очисткой по истечении срока хранения (TRASH_RETENTION).
+-- Карточки удалённого набора остаются без пометки и восстанавливаются вместе с ним.
+ALTER TABLE decks ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE cards ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_decks_deleted_at ON decks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_cards_deleted_at ON cards(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Перемещение карточки в корзину и обратно записывается в историю как delete/restore,
+-- окончательное удаление уже удалённой карточки в историю не попадает.
+CREATE OR REPLACE FUNCTION record_card_revision() RETURNS TRIGGER AS $$
+DECLARE
+    owner INT;
+    old_doc JSONB;
+    new_doc JSONB;
+    fields TEXT[];
+BEGIN
+    IF TG_OP = 'DELETE' AND OLD.deleted_at IS NOT NULL THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'UPDATE' AND (OLD.deleted_at IS NULL) <> (NEW.deleted_at IS NULL) THEN
+        SELECT user_id INTO owner FROM decks WHERE id = NEW.deck_id;
+        IF NEW.deleted_at IS NOT NULL THEN
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, snapshot)
+            VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id, 'delete', card_snapshot(OLD));
+        ELSE
+            new_doc := card_snapshot(NEW);
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+            VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id, 'restore', ARRAY(SELECT jsonb_object_keys(new_doc)), new_doc);
+        END IF;
+        RETURN NULL;
+    END IF;
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        old_doc := card_snapshot(OLD);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        new_doc := card_snapshot(NEW);
+    END IF;
+    IF TG_OP = 'UPDATE' AND OLD.deck_id IS NOT DISTINCT FROM NEW.deck_id THEN
+        fields := changed_keys(old_doc, new_doc);
+        IF cardinality(fields) > 0 THEN
+            SELECT user_id INTO owner FROM decks WHERE id = NEW.deck_id;
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+            VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id, 'update', fields, new_doc);
+        END IF;
+        RETURN NULL;
+    END IF;
+    -- удаление или перенос: в старом наборе карточка исчезает
+    IF old_doc IS NOT NULL THEN
+        SELECT user_id INTO owner FROM decks WHERE id = OLD.deck_id;
+        IF FOUND THEN
+            INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, snapshot)
+            VALUES (OLD.deck_id, revision_user(owner), 'card', OLD.id,
+                CASE WHEN TG_OP = 'DELETE' THEN 'delete' ELSE 'move_out' END, old_doc);
+        END IF;
+    END IF;
+    IF new_doc IS NOT NULL THEN
+        SELECT user_id INTO owner FROM decks WHERE id = NEW.deck_id;
+        INSERT INTO deck_revisions (deck_id, user_id, entity, entity_id, action, changed_fields, snapshot)
+        VALUES (NEW.deck_id, revision_user(owner), 'card', NEW.id,
+            CASE WHEN TG_OP = 'INSERT' THEN 'create' ELSE 'move_in' END, ARRAY(SELECT jsonb_object_keys(new_doc)), new_doc);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_decks_parent_id;
+ALTER TABLE decks DROP CONSTRAINT IF EXISTS decks_parent_not_self;
+ALTER TABLE decks DROP COLUMN IF EXISTS parent_id;
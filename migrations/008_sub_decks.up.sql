@@ -0,0 +1,6 @@
+-- Вложенные наборы: курс → раздел → тема.
+-- При окончательном удалении набора удаляются и все вложенные наборы.
+ALTER TABLE decks ADD COLUMN parent_id INT REFERENCES decks(id) ON DELETE CASCADE;
+ALTER TABLE decks ADD CONSTRAINT decks_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX idx_decks_parent_id ON decks(parent_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_cards_question_trgm;
+DROP FUNCTION IF EXISTS normalize_card_text(TEXT);
@@ -0,0 +1,10 @@
+-- Поиск дубликатов карточек: точное совпадение нормализованного вопроса и похожесть по триграммам.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Нормализация текста карточки: регистр и пробелы не учитываются
+CREATE FUNCTION normalize_card_text(t TEXT) RETURNS TEXT AS $$
+    SELECT lower(regexp_replace(btrim(t), '\s+', ' ', 'g'))
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+CREATE INDEX idx_cards_question_trgm ON cards USING gin (normalize_card_text(question) gin_trgm_ops)
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Личные теги при откате удаляются вместе с привязками
+DELETE FROM tags WHERE user_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_tags_user_name;
+DROP INDEX IF EXISTS idx_tags_global_name;
+ALTER TABLE tags DROP COLUMN IF EXISTS user_id;
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (name);
@@ -0,0 +1,7 @@
+-- Личные теги пользователей рядом с общими (user_id IS NULL).
+-- Существующие теги остаются общими; имена уникальны среди общих тегов и среди тегов одного пользователя.
+ALTER TABLE tags ADD COLUMN user_id INT REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+
+CREATE UNIQUE INDEX idx_tags_global_name ON tags(name) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX idx_tags_user_name ON tags(user_id, name) WHERE user_id IS NOT NULL;
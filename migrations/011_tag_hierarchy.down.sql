@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tags_parent_id;
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_parent_not_self;
+ALTER TABLE tags DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,30 @@
+-- Иерархические теги: lang::de::verbs. В name хранится полный путь, parent_id — родительский тег.
+-- При удалении тега удаляются и все дочерние теги.
+ALTER TABLE tags ADD COLUMN parent_id INT REFERENCES tags(id) ON DELETE CASCADE;
+ALTER TABLE tags ADD CONSTRAINT tags_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX idx_tags_parent_id ON tags(parent_id);
+
+-- Существующие теги с путём в имени (a::b::c) встраиваются в иерархию: недостающие родители
+-- создаются с той же видимостью, родитель ищется среди общих тегов и тегов того же владельца.
+CREATE FUNCTION pg_temp.tag_parent_path(name TEXT) RETURNS TEXT AS $$
+    SELECT NULLIF(btrim(substring(name FROM '^(.*)::')), '')
+$$ LANGUAGE sql IMMUTABLE;
+
+DO $$
+BEGIN
+    LOOP
+        INSERT INTO tags (name, user_id)
+        SELECT DISTINCT pg_temp.tag_parent_path(t.name), t.user_id FROM tags t
+        WHERE pg_temp.tag_parent_path(t.name) IS NOT NULL AND NOT EXISTS (
+            SELECT 1 FROM tags p WHERE p.name = pg_temp.tag_parent_path(t.name)
+                AND (p.user_id IS NULL OR p.user_id = t.user_id));
+        EXIT WHEN NOT FOUND;
+    END LOOP;
+END $$;
+
+UPDATE tags t SET parent_id = (
+    SELECT p.id FROM tags p
+    WHERE p.name = pg_temp.tag_parent_path(t.name) AND (p.user_id IS NULL OR p.user_id = t.user_id)
+    ORDER BY p.user_id NULLS FIRST LIMIT 1)
+WHERE pg_temp.tag_parent_path(t.name) IS NOT NULL;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_categories_parent_id;
+DROP INDEX IF EXISTS idx_categories_sibling_name;
+DROP INDEX IF EXISTS idx_categories_slug;
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_not_self;
+ALTER TABLE categories DROP COLUMN IF EXISTS icon;
+ALTER TABLE categories DROP COLUMN IF EXISTS sort_order;
+ALTER TABLE categories DROP COLUMN IF EXISTS slug;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
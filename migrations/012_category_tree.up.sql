@@ -0,0 +1,24 @@
+-- Дерево категорий: родитель, slug для URL, порядок сортировки и иконка.
+-- Имена уникальны среди соседних категорий, slug — глобально.
+ALTER TABLE categories ADD COLUMN parent_id INT REFERENCES categories(id);
+ALTER TABLE categories ADD COLUMN slug VARCHAR(120);
+ALTER TABLE categories ADD COLUMN sort_order INT NOT NULL DEFAULT 0;
+ALTER TABLE categories ADD COLUMN icon VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE categories ADD CONSTRAINT categories_parent_not_self CHECK (parent_id <> id);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key;
+
+-- slug существующих категорий: транслитерация названия, при совпадении добавляется id
+UPDATE categories SET slug = trim(BOTH '-' FROM regexp_replace(
+    translate(
+        replace(replace(replace(replace(replace(replace(replace(replace(lower(name),
+            'ж', 'zh'), 'х', 'kh'), 'ц', 'ts'), 'ч', 'ch'), 'щ', 'shch'), 'ш', 'sh'), 'ю', 'yu'), 'я', 'ya'),
+        'абвгдеёзийклмнопрстуфыэъь', 'abvgdeezijklmnoprstufye'),
+    '[^a-z0-9]+', '-', 'g'));
+UPDATE categories SET slug = 'category' WHERE slug = '';
+UPDATE categories c SET slug = c.slug || '-' || c.id
+WHERE EXISTS (SELECT 1 FROM categories o WHERE o.slug = c.slug AND o.id < c.id);
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+CREATE UNIQUE INDEX idx_categories_slug ON categories(slug);
+CREATE UNIQUE INDEX idx_categories_sibling_name ON categories(COALESCE(parent_id, 0), name);
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
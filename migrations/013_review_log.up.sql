@@ -0,0 +1,14 @@
+-- Журнал ответов при повторении: ответы в приложении и история, импортированная из Anki.
+CREATE TABLE review_log (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    card_id INT NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    grade SMALLINT NOT NULL,
+    interval_days INT NOT NULL DEFAULT 0,
+    ease_factor REAL NOT NULL DEFAULT 2.5,
+    duration_ms INT NOT NULL DEFAULT 0,
+    reviewed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_review_log_user_card ON review_log(user_id, card_id, reviewed_at);
+CREATE INDEX idx_review_log_card_id ON review_log(card_id);
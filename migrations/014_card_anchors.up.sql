@@ -0,0 +1,4 @@
+-- Якорь карточки из импортированного конспекта: повторный импорт обновляет карточку с тем же якорем.
+ALTER TABLE cards ADD COLUMN anchor VARCHAR(128);
+
+CREATE INDEX idx_cards_anchor ON cards(anchor) WHERE anchor IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,29 @@
+-- Очередь фоновых задач: импорт, выгрузки, печать PDF, очистка. Воркеры забирают задачи
+-- через SELECT ... FOR UPDATE SKIP LOCKED и продлевают аренду (locked_until), пока задача
+-- выполняется; задача с истёкшей арендой (процесс упал) возвращается в очередь.
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE, -- NULL — служебная задача
+    kind VARCHAR(50) NOT NULL,
+    unique_key VARCHAR(255), -- не больше одной незавершённой задачи с тем же ключом
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 1,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    processed INT NOT NULL DEFAULT 0,
+    total INT NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_queue ON jobs(run_at) WHERE status = 'queued';
+CREATE INDEX idx_jobs_running ON jobs(locked_until) WHERE status = 'running';
+CREATE INDEX idx_jobs_user ON jobs(user_id, created_at DESC);
+CREATE INDEX idx_jobs_finished ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(unique_key) WHERE status IN ('queued', 'running');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cards_search;
+DROP INDEX IF EXISTS idx_decks_search;
+ALTER TABLE cards DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE decks DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,18 @@
+-- Полнотекстовый поиск по наборам и карточкам: словоформы по русской и английской морфологии.
+-- Название набора и вопрос карточки весят больше (A), чем описание и ответ (B).
+ALTER TABLE decks ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+ALTER TABLE cards ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', coalesce(question, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(question, '')), 'A') ||
+    setweight(to_tsvector('russian', coalesce(answer, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(answer, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_decks_search ON decks USING gin (search_vector) WHERE deleted_at IS NULL;
+CREATE INDEX idx_cards_search ON cards USING gin (search_vector) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS trg_cards_fork_dismissed ON cards;
+DROP FUNCTION IF EXISTS track_fork_dismissed();
+DROP TABLE IF EXISTS fork_dismissed_cards;
@@ -0,0 +1,36 @@
+-- Исходные карточки, которые пользователь убрал из форка (удалил или перенёс в другой набор).
+-- Сравнение с исходным набором не предлагает их снова как новые, в том числе после того,
+-- как удалённая карточка окончательно стёрта из корзины.
+CREATE TABLE fork_dismissed_cards (
+    deck_id INT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+    origin_card_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (deck_id, origin_card_id)
+);
+
+CREATE FUNCTION track_fork_dismissed() RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.origin_card_id IS NOT NULL AND OLD.deleted_at IS NULL
+        AND (NEW.deleted_at IS NOT NULL OR NEW.deck_id <> OLD.deck_id) THEN
+        INSERT INTO fork_dismissed_cards (deck_id, origin_card_id) VALUES (OLD.deck_id, OLD.origin_card_id)
+        ON CONFLICT DO NOTHING;
+    END IF;
+    -- карточка восстановлена из корзины или вернулась в набор
+    IF NEW.origin_card_id IS NOT NULL AND NEW.deleted_at IS NULL
+        AND (OLD.deleted_at IS NOT NULL OR NEW.deck_id <> OLD.deck_id) THEN
+        DELETE FROM fork_dismissed_cards WHERE deck_id = NEW.deck_id AND origin_card_id = NEW.origin_card_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_cards_fork_dismissed
+    AFTER UPDATE OF deleted_at, deck_id ON cards
+    FOR EACH ROW EXECUTE FUNCTION track_fork_dismissed();
+
+INSERT INTO fork_dismissed_cards (deck_id, origin_card_id)
+SELECT DISTINCT c.deck_id, c.origin_card_id FROM cards c
+WHERE c.origin_card_id IS NOT NULL AND c.deleted_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM cards l
+                  WHERE l.deck_id = c.deck_id AND l.origin_card_id = c.origin_card_id AND l.deleted_at IS NULL)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,392 @@
+// Package anki читает и пишет пакеты Anki (.apkg): zip с SQLite-коллекцией (схема 11) и медиафайлами.
+package anki
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // драйвер SQLite на чистом Go
+)
+
+var (
+	ErrNotPackage  = errors.New("файл не является пакетом Anki (.apkg)")
+	ErrUnsupported = errors.New("формат коллекции не поддерживается: экспортируйте колоду в Anki с опцией «Support older Anki versions»")
+	ErrTooLarge    = errors.New("файл пакета Anki слишком большой после распаковки")
+	ErrNoMedia     = errors.New("медиафайла нет в пакете")
+)
+
+// Ограничения распаковки: сжатый пакет может быть во много раз меньше своего содержимого.
+const (
+	MaxCollectionSize = 1 << 30   // коллекция SQLite
+	MaxMediaSize      = 100 << 20 // один медиафайл
+)
+
+// Типы моделей (note types) Anki.
+const (
+	ModelStandard = 0
+	ModelCloze    = 1
+)
+
+// Типы карточек Anki: новая, изучается, на повторении, переучивается.
+const (
+	CardNew        = 0
+	CardLearning   = 1
+	CardReview     = 2
+	CardRelearning = 3
+)
+
+// Model — тип заметки: поля и шаблоны карточек.
+type Model struct {
+	ID        int64
+	Name      string
+	Type      int
+	Fields    []string // имена полей в порядке ord
+	Templates []Template
+	CSS       string
+}
+
+type Template struct {
+	Name string
+	Ord  int
+	QFmt string
+	AFmt string
+}
+
+type Deck struct {
+	ID   int64
+	Name string // полный путь: Языки::Немецкий
+}
+
+type Note struct {
+	ID      int64
+	GUID    string
+	ModelID int64
+	Tags    []string
+	Fields  []string
+}
+
+type Card struct {
+	ID       int64
+	NoteID   int64
+	DeckID   int64 // исходная колода (для карточек в фильтрованной колоде — odid)
+	Ord      int
+	Type     int
+	Queue    int
+	Due      int64 // новые — позиция; на повторении — день от создания коллекции; изучаемые — unix-время
+	Interval int   // дни; отрицательное — секунды (изучаемые карточки)
+	Factor   int   // лёгкость ×1000
+	Reps     int
+	Lapses   int
+}
+
+// Review — запись журнала повторений (revlog).
+type Review struct {
+	ID       int64 // unix-время ответа в миллисекундах
+	CardID   int64
+	Ease     int // 1 — снова, 2 — трудно, 3 — хорошо, 4 — легко
+	Interval int
+	Factor   int
+	Time     int // сколько длился ответ, мс
+}
+
+// Package — открытый пакет Anki. Коллекция распаковывается во временный файл, Close удаляет его.
+type Package struct {
+	Created time.Time // создание коллекции: от него отсчитывается Card.Due карточек на повторении
+	Models  map[int64]*Model
+	Decks   map[int64]*Deck
+	Notes   map[int64]*Note
+	Cards   []Card
+	Reviews []Review
+
+	media   map[string]*zip.File // имя файла в тексте заметок → запись архива
+	tmp     string
+	closers []io.Closer
+}
+
+// Open открывает пакет из r размером size (например, загруженный файл).
+func Open(r io.ReaderAt, size int64) (*Package, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrNotPackage
+	}
+	entries := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+	// anki21 новее anki2: в пакетах с обоими файлами anki2 содержит лишь заглушку для старых версий
+	col := entries["collection.anki21"]
+	if col == nil {
+		col = entries["collection.anki2"]
+	}
+	if col == nil {
+		if entries["collection.anki21b"] != nil {
+			return nil, ErrUnsupported
+		}
+		return nil, ErrNotPackage
+	}
+	p := &Package{media: make(map[string]*zip.File)}
+	if p.tmp, err = extract(col); err != nil {
+		return nil, err
+	}
+	if err := p.load(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if m := entries["media"]; m != nil {
+		names := make(map[string]string)
+		if rc, err := m.Open(); err == nil {
+			_ = json.NewDecoder(rc).Decode(&names)
+			rc.Close()
+		}
+		for key, name := range names {
+			if f := entries[key]; f != nil {
+				p.media[name] = f
+			}
+		}
+	}
+	return p, nil
+}
+
+// OpenFile открывает пакет из файла path. Файл должен существовать до Close.
+func OpenFile(path string) (*Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p, err := Open(f, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// zip читает медиа из f по требованию, поэтому f закрывается вместе с пакетом
+	p.closers = append(p.closers, f)
+	return p, nil
+}
+
+// Close удаляет временный файл коллекции и закрывает файл пакета.
+func (p *Package) Close() {
+	for _, c := range p.closers {
+		c.Close()
+	}
+	if p.tmp != "" {
+		os.Remove(p.tmp)
+	}
+}
+
+// Media открывает медиафайл по имени, как оно записано в тексте заметки. Файлы больше
+// MaxMediaSize не открываются (ErrTooLarge); чтение сверх MaxMediaSize тоже возвращает
+// ErrTooLarge, даже если размер в заголовке архива занижен.
+func (p *Package) Media(name string) (io.ReadCloser, error) {
+	f := p.media[name]
+	if f == nil {
+		return nil, ErrNoMedia
+	}
+	if f.UncompressedSize64 > MaxMediaSize {
+		return nil, ErrTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedReader{ReadCloser: rc, left: MaxMediaSize}, nil
+}
+
+// limitedReader возвращает ErrTooLarge, если прочитано больше left байт.
+type limitedReader struct {
+	io.ReadCloser
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// MediaCount — количество медиафайлов в пакете.
+func (p *Package) MediaCount() int {
+	return len(p.media)
+}
+
+// extract распаковывает коллекцию во временный файл, не больше MaxCollectionSize.
+func extract(f *zip.File) (string, error) {
+	if f.UncompressedSize64 > MaxCollectionSize {
+		return "", ErrTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", ErrNotPackage
+	}
+	defer rc.Close()
+	tmp, err := os.CreateTemp("", "anki-*.sqlite")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	n, err := io.Copy(tmp, io.LimitReader(rc, MaxCollectionSize+1))
+	if err == nil && n > MaxCollectionSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func (p *Package) load() error {
+	db, err := sql.Open("sqlite", "file:"+p.tmp+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var crt int64
+	var models, decks string
+	if err := db.QueryRow(`SELECT crt, models, decks FROM col`).Scan(&crt, &models, &decks); err != nil {
+		return ErrNotPackage
+	}
+	// в схеме 18 типы заметок и колоды лежат в отдельных таблицах, а col.models пуст
+	if strings.TrimSpace(models) == "" || strings.TrimSpace(models) == "{}" {
+		return ErrUnsupported
+	}
+	p.Created = time.Unix(crt, 0)
+	if p.Models, err = parseModels(models); err != nil {
+		return err
+	}
+	if p.Decks, err = parseDecks(decks); err != nil {
+		return err
+	}
+
+	p.Notes = make(map[int64]*Note)
+	rows, err := db.Query(`SELECT id, guid, mid, tags, flds FROM notes`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var n Note
+		var tags, flds string
+		if err := rows.Scan(&n.ID, &n.GUID, &n.ModelID, &tags, &flds); err != nil {
+			rows.Close()
+			return err
+		}
+		n.Tags = strings.Fields(tags)
+		n.Fields = strings.Split(flds, "\x1f")
+		p.Notes[n.ID] = &n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`SELECT id, nid, CASE WHEN odid <> 0 THEN odid ELSE did END, ord, type, queue, due, ivl, factor, reps, lapses
+		FROM cards ORDER BY nid, ord`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c Card
+		if err := rows.Scan(&c.ID, &c.NoteID, &c.DeckID, &c.Ord, &c.Type, &c.Queue, &c.Due, &c.Interval, &c.Factor, &c.Reps, &c.Lapses); err != nil {
+			rows.Close()
+			return err
+		}
+		p.Cards = append(p.Cards, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`SELECT id, cid, ease, ivl, factor, time FROM revlog ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Review
+		if err := rows.Scan(&r.ID, &r.CardID, &r.Ease, &r.Interval, &r.Factor, &r.Time); err != nil {
+			return err
+		}
+		p.Reviews = append(p.Reviews, r)
+	}
+	return rows.Err()
+}
+
+func parseModels(s string) (map[int64]*Model, error) {
+	var raw map[string]struct {
+		Name string `json:"name"`
+		Type int    `json:"type"`
+		CSS  string `json:"css"`
+		Flds []struct {
+			Name string `json:"name"`
+			Ord  int    `json:"ord"`
+		} `json:"flds"`
+		Tmpls []struct {
+			Name string `json:"name"`
+			Ord  int    `json:"ord"`
+			QFmt string `json:"qfmt"`
+			AFmt string `json:"afmt"`
+		} `json:"tmpls"`
+	}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("%w: типы заметок: %v", ErrNotPackage, err)
+	}
+	models := make(map[int64]*Model, len(raw))
+	for key, m := range raw {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		model := &Model{ID: id, Name: m.Name, Type: m.Type, CSS: m.CSS, Fields: make([]string, len(m.Flds))}
+		for _, f := range m.Flds {
+			if f.Ord >= 0 && f.Ord < len(model.Fields) {
+				model.Fields[f.Ord] = f.Name
+			}
+		}
+		for _, t := range m.Tmpls {
+			model.Templates = append(model.Templates, Template{Name: t.Name, Ord: t.Ord, QFmt: t.QFmt, AFmt: t.AFmt})
+		}
+		models[id] = model
+	}
+	return models, nil
+}
+
+func parseDecks(s string) (map[int64]*Deck, error) {
+	var raw map[string]struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("%w: колоды: %v", ErrNotPackage, err)
+	}
+	decks := make(map[int64]*Deck, len(raw))
+	for key, d := range raw {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		decks[id] = &Deck{ID: id, Name: d.Name}
+	}
+	return decks, nil
+}
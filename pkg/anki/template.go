@@ -0,0 +1,193 @@
+package anki
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	clozeRe     = regexp.MustCompile(`(?s)\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+	answerHRRe  = regexp.MustCompile(`(?i)<hr\s+id\s*=\s*["']?answer["']?\s*/?>`)
+	specialKeys = []string{"Tags", "Type", "Deck", "Subdeck", "Card"}
+)
+
+// Render собирает вопрос и ответ карточки по шаблону её типа заметки, как это делает Anki.
+// Из ответа убирается повтор вопроса ({{FrontSide}} до <hr id=answer>).
+// ok = false, если карточку построить нельзя: неизвестный тип заметки или пустой вопрос.
+func (p *Package) Render(c Card) (question, answer string, ok bool) {
+	note := p.Notes[c.NoteID]
+	if note == nil {
+		return "", "", false
+	}
+	model := p.Models[note.ModelID]
+	if model == nil || len(model.Templates) == 0 {
+		return "", "", false
+	}
+	fields := make(map[string]string, len(model.Fields)+len(specialKeys))
+	for i, name := range model.Fields {
+		if i < len(note.Fields) {
+			fields[name] = note.Fields[i]
+		}
+	}
+	fields["Tags"] = strings.Join(note.Tags, " ")
+	fields["Type"] = model.Name
+	if d := p.Decks[c.DeckID]; d != nil {
+		fields["Deck"] = d.Name
+		fields["Subdeck"] = d.Name
+		if i := strings.LastIndex(d.Name, "::"); i >= 0 {
+			fields["Subdeck"] = d.Name[i+2:]
+		}
+	}
+
+	var tmpl Template
+	cloze := 0
+	if model.Type == ModelCloze {
+		tmpl = model.Templates[0]
+		cloze = c.Ord + 1
+		if !hasCloze(note, cloze) {
+			return "", "", false
+		}
+	} else {
+		found := false
+		for _, t := range model.Templates {
+			if t.Ord == c.Ord {
+				tmpl, found = t, true
+				break
+			}
+		}
+		if !found {
+			return "", "", false
+		}
+	}
+	fields["Card"] = tmpl.Name
+
+	question = renderTemplate(tmpl.QFmt, fields, cloze, false, "")
+	if strings.TrimSpace(question) == "" {
+		return "", "", false
+	}
+	answer = renderTemplate(tmpl.AFmt, fields, cloze, true, question)
+	if loc := answerHRRe.FindStringIndex(answer); loc != nil {
+		answer = answer[loc[1]:]
+	} else {
+		answer = strings.TrimPrefix(answer, question)
+	}
+	return question, answer, true
+}
+
+// hasCloze — в полях заметки есть пропуск с номером n.
+func hasCloze(note *Note, n int) bool {
+	for _, v := range note.Fields {
+		for _, m := range clozeRe.FindAllStringSubmatch(v, -1) {
+			if num, _ := strconv.Atoi(m[1]); num == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderTemplate подставляет поля в шаблон Anki: {{Поле}}, фильтры {{text:Поле}} и {{cloze:Поле}},
+// условные секции {{#Поле}}…{{/Поле}} и {{^Поле}}…{{/Поле}}, {{FrontSide}} в ответе.
+func renderTemplate(tmpl string, fields map[string]string, cloze int, answer bool, front string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(tmpl, "{{")
+		if start < 0 {
+			b.WriteString(tmpl)
+			return b.String()
+		}
+		end := strings.Index(tmpl[start:], "}}")
+		if end < 0 {
+			b.WriteString(tmpl)
+			return b.String()
+		}
+		b.WriteString(tmpl[:start])
+		tag := strings.TrimSpace(tmpl[start+2 : start+end])
+		rest := tmpl[start+end+2:]
+
+		if tag != "" && (tag[0] == '#' || tag[0] == '^') {
+			name := strings.TrimSpace(tag[1:])
+			inner, after := splitSection(rest, name)
+			filled := strings.TrimSpace(stripHTML(fields[name])) != ""
+			if filled == (tag[0] == '#') {
+				b.WriteString(renderTemplate(inner, fields, cloze, answer, front))
+			}
+			tmpl = after
+			continue
+		}
+		if tag != "" && tag[0] == '/' {
+			// непарное закрытие секции: Anki его пропускает
+			tmpl = rest
+			continue
+		}
+		b.WriteString(renderField(tag, fields, cloze, answer, front))
+		tmpl = rest
+	}
+}
+
+// splitSection возвращает содержимое секции name до парного {{/name}} и текст после неё.
+func splitSection(s, name string) (inner, after string) {
+	depth := 0
+	pos := 0
+	for {
+		i := strings.Index(s[pos:], "{{")
+		if i < 0 {
+			return s, ""
+		}
+		i += pos
+		j := strings.Index(s[i:], "}}")
+		if j < 0 {
+			return s, ""
+		}
+		tag := strings.TrimSpace(s[i+2 : i+j])
+		switch {
+		case (strings.HasPrefix(tag, "#") || strings.HasPrefix(tag, "^")) && strings.TrimSpace(tag[1:]) == name:
+			depth++
+		case strings.HasPrefix(tag, "/") && strings.TrimSpace(tag[1:]) == name:
+			if depth == 0 {
+				return s[:i], s[i+j+2:]
+			}
+			depth--
+		}
+		pos = i + j + 2
+	}
+}
+
+// renderField подставляет значение {{фильтр:…:Поле}}; фильтры применяются справа налево.
+func renderField(tag string, fields map[string]string, cloze int, answer bool, front string) string {
+	if tag == "FrontSide" {
+		return front
+	}
+	parts := strings.Split(tag, ":")
+	name := strings.TrimSpace(parts[len(parts)-1])
+	v := fields[name]
+	for i := len(parts) - 2; i >= 0; i-- {
+		switch strings.TrimSpace(parts[i]) {
+		case "cloze":
+			v = renderCloze(v, cloze, answer)
+		case "text":
+			v = stripHTML(v)
+		case "type":
+			// поле ввода ответа в Anki; у нас ответ не вводится
+			return ""
+		}
+	}
+	return v
+}
+
+// renderCloze показывает пропуск номер n: в вопросе — [подсказка] или [...], в ответе — текст.
+// Остальные пропуски показываются текстом.
+func renderCloze(s string, n int, answer bool) string {
+	return clozeRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := clozeRe.FindStringSubmatch(m)
+		num, _ := strconv.Atoi(sub[1])
+		if num != n || answer {
+			return sub[2]
+		}
+		if sub[3] != "" {
+			return "[" + sub[3] + "]"
+		}
+		return "[...]"
+	})
+}
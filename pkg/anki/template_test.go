@@ -0,0 +1,87 @@
+package anki
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	fields := map[string]string{
+		"Front": "Hund",
+		"Back":  "<b>собака</b>",
+		"Extra": "",
+		"Text":  "{{c1::Berlin}} is the capital of {{c2::Germany::country}}",
+	}
+	tests := []struct {
+		name   string
+		tmpl   string
+		cloze  int
+		answer bool
+		front  string
+		want   string
+	}{
+		{"field", "{{Front}}", 0, false, "", "Hund"},
+		{"spaces in tag", "{{ Front }}", 0, false, "", "Hund"},
+		{"text filter", "{{text:Back}}", 0, false, "", "собака"},
+		{"unknown field", "[{{Missing}}]", 0, false, "", "[]"},
+		{"front side", "{{FrontSide}}<hr id=answer>{{Back}}", 0, true, "Hund", "Hund<hr id=answer><b>собака</b>"},
+		{"filled section", "{{#Back}}ответ: {{Back}}{{/Back}}", 0, false, "", "ответ: <b>собака</b>"},
+		{"empty section", "{{Front}}{{#Extra}} ({{Extra}}){{/Extra}}", 0, false, "", "Hund"},
+		{"inverted section", "{{^Extra}}нет пояснения{{/Extra}}", 0, false, "", "нет пояснения"},
+		{"nested sections", "{{#Front}}{{#Back}}оба{{/Back}}{{/Front}}", 0, false, "", "оба"},
+		{"stray close", "{{/Extra}}{{Front}}", 0, false, "", "Hund"},
+		{"type filter", "{{Front}}{{type:Back}}", 0, false, "", "Hund"},
+		{"unclosed tag", "{{Front}} {{Back", 0, false, "", "Hund {{Back"},
+		{"cloze question", "{{cloze:Text}}", 1, false, "", "[...] is the capital of Germany"},
+		{"cloze hint", "{{cloze:Text}}", 2, false, "", "Berlin is the capital of [country]"},
+		{"cloze answer", "{{cloze:Text}}", 2, true, "", "Berlin is the capital of Germany"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTemplate(tt.tmpl, fields, tt.cloze, tt.answer, tt.front); got != tt.want {
+				t.Errorf("renderTemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := &Package{
+		Models: map[int64]*Model{
+			1: {ID: 1, Name: "Basic (and reversed)", Type: ModelStandard, Fields: []string{"Front", "Back"}, Templates: []Template{
+				{Name: "Card 1", Ord: 0, QFmt: "{{Front}}", AFmt: "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"},
+				{Name: "Card 2", Ord: 1, QFmt: "{{Back}}", AFmt: "{{FrontSide}}{{Front}}"},
+			}},
+			2: {ID: 2, Name: "Cloze", Type: ModelCloze, Fields: []string{"Text"}, Templates: []Template{
+				{Name: "Cloze", Ord: 0, QFmt: "{{cloze:Text}} ({{Subdeck}})", AFmt: "{{cloze:Text}}"},
+			}},
+		},
+		Decks: map[int64]*Deck{10: {ID: 10, Name: "Языки::Немецкий"}},
+		Notes: map[int64]*Note{
+			100: {ID: 100, ModelID: 1, Fields: []string{"Hund", "собака"}},
+			101: {ID: 101, ModelID: 2, Fields: []string{"{{c1::Berlin}}"}},
+			102: {ID: 102, ModelID: 1, Fields: []string{"", "пусто"}},
+			103: {ID: 103, ModelID: 99, Fields: []string{"x", "y"}},
+		},
+	}
+	tests := []struct {
+		name             string
+		card             Card
+		question, answer string
+		ok               bool
+	}{
+		{"forward", Card{NoteID: 100, DeckID: 10, Ord: 0}, "Hund", "\n\nсобака", true},
+		{"reverse without hr", Card{NoteID: 100, DeckID: 10, Ord: 1}, "собака", "Hund", true},
+		{"cloze", Card{NoteID: 101, DeckID: 10, Ord: 0}, "[...] (Немецкий)", "Berlin", true},
+		{"missing cloze number", Card{NoteID: 101, DeckID: 10, Ord: 1}, "", "", false},
+		{"missing template", Card{NoteID: 100, DeckID: 10, Ord: 5}, "", "", false},
+		{"empty question", Card{NoteID: 102, DeckID: 10, Ord: 0}, "", "", false},
+		{"unknown model", Card{NoteID: 103, DeckID: 10, Ord: 0}, "", "", false},
+		{"unknown note", Card{NoteID: 999, DeckID: 10, Ord: 0}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, a, ok := p.Render(tt.card)
+			if q != tt.question || a != tt.answer || ok != tt.ok {
+				t.Errorf("Render() = %q, %q, %v; want %q, %q, %v", q, a, ok, tt.question, tt.answer, tt.ok)
+			}
+		})
+	}
+}
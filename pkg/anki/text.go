@@ -0,0 +1,107 @@
+package anki
+
+import (
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	hiddenRe    = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+	imgRe       = regexp.MustCompile(`(?i)<img[^>]*?\ssrc\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))[^>]*>`)
+	soundRe     = regexp.MustCompile(`\[sound:([^\]]+)\]`)
+	blockGapRe  = regexp.MustCompile(`(?i)</(div|p|li|tr|h[1-6])>\s*<(div|p|h[1-6])(\s[^>]*)?>`)
+	breakRe     = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li|tr|h[1-6])>|<(div|p|h[1-6])(\s[^>]*)?>|<hr[^>]*>`)
+	listItemRe  = regexp.MustCompile(`(?i)<li[^>]*>`)
+	tagRe       = regexp.MustCompile(`<[^>]*>`)
+	blankLineRe = regexp.MustCompile(`\n{3,}`)
+	mdLinkRe    = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+)
+
+var audioExts = map[string]bool{".mp3": true, ".ogg": true, ".oga": true, ".wav": true, ".m4a": true, ".flac": true}
+
+// MediaLink возвращает ссылку на медиафайл пакета по его имени; "" — файл не найден.
+type MediaLink func(name string) string
+
+// PlainText переводит HTML поля Anki в текст карточки: переносы строк сохраняются, картинки
+// становятся ![](ссылка), звуки [sound:x.mp3] — [x.mp3](ссылка), остальная разметка убирается.
+func PlainText(s string, media MediaLink) string {
+	s = hiddenRe.ReplaceAllString(s, "")
+	s = imgRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := imgRe.FindStringSubmatch(m)
+		name := html.UnescapeString(sub[1] + sub[2] + sub[3])
+		if media == nil {
+			return ""
+		}
+		if url := media(name); url != "" {
+			return "![](" + url + ")"
+		}
+		return ""
+	})
+	s = soundRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := soundRe.FindStringSubmatch(m)[1]
+		if media == nil {
+			return ""
+		}
+		if url := media(name); url != "" {
+			return "[" + name + "](" + url + ")"
+		}
+		return ""
+	})
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	// Anki пишет новую строку как «строка<div>следующая</div>»: перенос даёт и открытие блока,
+	// а стык двух блоков — один перенос
+	s = blockGapRe.ReplaceAllString(s, "\n")
+	s = breakRe.ReplaceAllString(s, "\n")
+	s = listItemRe.ReplaceAllString(s, "- ")
+	s = stripHTML(s)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	s = blankLineRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// HTMLText переводит текст карточки в HTML поля Anki — обратно PlainText: картинки ![](ссылка)
+// становятся <img>, ссылки на аудио — [sound:…], переносы строк — <br>. media возвращает имя
+// файла в пакете по ссылке или "" — тогда картинка остаётся внешней, а ссылка — текстом.
+func HTMLText(s string, media func(url string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mdLinkRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escapeText(s[last:m[0]]))
+		last = m[1]
+		image := m[3] > m[2]
+		url := s[m[6]:m[7]]
+		name := ""
+		if media != nil && (image || audioExts[strings.ToLower(path.Ext(url))]) {
+			name = media(url)
+		}
+		switch {
+		case image && name != "":
+			b.WriteString(`<img src="` + html.EscapeString(name) + `">`)
+		case image:
+			b.WriteString(`<img src="` + html.EscapeString(url) + `">`)
+		case name != "":
+			b.WriteString("[sound:" + name + "]")
+		default:
+			b.WriteString(escapeText(s[m[0]:m[1]]))
+		}
+	}
+	b.WriteString(escapeText(s[last:]))
+	return b.String()
+}
+
+func escapeText(s string) string {
+	s = html.EscapeString(strings.ReplaceAll(s, "\r\n", "\n"))
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// stripHTML убирает теги и раскрывает HTML-сущности.
+func stripHTML(s string) string {
+	s = tagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.ReplaceAll(s, "\u00a0", " ")
+}
@@ -0,0 +1,74 @@
+package anki
+
+import "testing"
+
+func TestPlainText(t *testing.T) {
+	media := func(name string) string {
+		if name == "missing.png" {
+			return ""
+		}
+		return "/uploads/media/" + name
+	}
+	tests := []struct {
+		name  string
+		html  string
+		media MediaLink
+		want  string
+	}{
+		{"plain", "Hund", media, "Hund"},
+		{"entities and nbsp", "A &amp; B&nbsp;&lt;C&gt;", media, "A & B <C>"},
+		{"breaks", "one<br>two<br/>three<div>four</div>", media, "one\ntwo\nthree\nfour"},
+		{"anki divs", "first<div>second</div><div>third</div>", media, "first\nsecond\nthird"},
+		{"paragraphs", "<p class=\"x\">a</p>\n<p>b</p>tail", media, "a\nb\ntail"},
+		{"div inside text", "a<div><br></div><div>b</div>", media, "a\n\nb"},
+		{"list", "<ul><li>a</li><li>b</li></ul>", media, "- a\n- b"},
+		{"style and script", "<style>.x{}</style>text<script>alert(1)</script>", media, "text"},
+		{"image", `see <img src="dog.jpg">`, media, "see ![](/uploads/media/dog.jpg)"},
+		{"image single quotes", `<img alt=x src='a b.png'>`, media, "![](/uploads/media/a b.png)"},
+		{"image escaped name", `<img src="a&amp;b.png">`, media, "![](/uploads/media/a&b.png)"},
+		{"missing image", `<img src="missing.png">x`, media, "x"},
+		{"sound", "[sound:hund.mp3]", media, "[hund.mp3](/uploads/media/hund.mp3)"},
+		{"no media", `<img src="dog.jpg">[sound:a.mp3]text`, nil, "text"},
+		{"blank lines collapsed", "a<br><br><br><br>b", media, "a\n\nb"},
+		{"trailing spaces", "a   <br>  b  ", media, "a\n  b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlainText(tt.html, tt.media); got != tt.want {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+	media := func(url string) string {
+		if url == "/uploads/media/dog.jpg" {
+			return "dog.jpg"
+		}
+		if url == "/uploads/media/hund.mp3" {
+			return "hund.mp3"
+		}
+		return ""
+	}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"escape", "a < b & c", "a &lt; b &amp; c"},
+		{"newlines", "one\r\ntwo\nthree", "one<br>two<br>three"},
+		{"packed image", "![](/uploads/media/dog.jpg)", `<img src="dog.jpg">`},
+		{"external image", "![x](https://example.com/a.png)", `<img src="https://example.com/a.png">`},
+		{"packed sound", "[hund](/uploads/media/hund.mp3)", "[sound:hund.mp3]"},
+		{"plain link", "[site](https://example.com)", "[site](https://example.com)"},
+		{"unknown audio", "[a](https://example.com/a.mp3)", "[a](https://example.com/a.mp3)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLText(tt.text, media); got != tt.want {
+				t.Errorf("HTMLText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
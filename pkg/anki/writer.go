@@ -0,0 +1,334 @@
+package anki
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schema11 — схема коллекции Anki 2.1 «для старых версий»: её принимают все версии Anki.
+const schema11 = `
+CREATE TABLE col (id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null, conf text not null,
+	models text not null, decks text not null, dconf text not null, tags text not null);
+CREATE TABLE notes (id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null, csum integer not null,
+	flags integer not null, data text not null);
+CREATE TABLE cards (id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null, due integer not null,
+	ivl integer not null, factor integer not null, reps integer not null, lapses integer not null, left integer not null,
+	odue integer not null, odid integer not null, flags integer not null, data text not null);
+CREATE TABLE revlog (id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null, type integer not null);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);`
+
+const (
+	basicFront = "{{Front}}"
+	basicBack  = "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}"
+	basicCSS   = ".card {\n font-family: arial;\n font-size: 20px;\n text-align: center;\n color: black;\n background-color: white;\n}\n"
+)
+
+// Schedule — состояние повторений карточки на повторении (type = CardReview).
+type Schedule struct {
+	Due      time.Time
+	Interval int // дни
+	Factor   int // лёгкость ×1000
+	Reps     int
+	Lapses   int
+}
+
+// Writer собирает пакет Anki с одним типом заметки «вопрос — ответ». Коллекция пишется
+// во временный файл SQLite, а архив с медиафайлами — потоком в Finish, не собираясь в памяти.
+type Writer struct {
+	db       *sql.DB
+	tx       *sql.Tx
+	notes    *sql.Stmt
+	cards    *sql.Stmt
+	tmp      string
+	created  time.Time
+	modelID  int64
+	nextID   int64
+	position int
+	decks    map[string]int64
+	order    []string
+	tags     map[string]bool
+	media    []mediaFile
+	names    map[string]bool
+}
+
+type mediaFile struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// NewWriter создаёт пустой пакет. Close удаляет временный файл коллекции.
+func NewWriter() (*Writer, error) {
+	tmp, err := os.CreateTemp("", "anki-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	now := time.Now()
+	w := &Writer{
+		tmp: tmp.Name(),
+		// день 0 коллекции — сегодня: сроки карточек на повторении считаются от него
+		created: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+		modelID: now.UnixMilli(),
+		nextID:  now.UnixMilli(),
+		decks:   map[string]int64{"Default": 1},
+		order:   []string{"Default"},
+		tags:    make(map[string]bool),
+		names:   make(map[string]bool),
+	}
+	if err := w.init(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) init() error {
+	var err error
+	if w.db, err = sql.Open("sqlite", "file:"+w.tmp); err != nil {
+		return err
+	}
+	// одно соединение: транзакция и подготовленные запросы живут на нём
+	w.db.SetMaxOpenConns(1)
+	if _, err = w.db.Exec(schema11); err != nil {
+		return err
+	}
+	if w.tx, err = w.db.Begin(); err != nil {
+		return err
+	}
+	if w.notes, err = w.tx.Prepare(`INSERT INTO notes (id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data)
+		VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`); err != nil {
+		return err
+	}
+	w.cards, err = w.tx.Prepare(`INSERT INTO cards (id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid, flags, data)
+		VALUES (?, ?, ?, 0, ?, -1, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, '')`)
+	return err
+}
+
+// Close освобождает коллекцию и удаляет временный файл.
+func (w *Writer) Close() {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx = nil
+	}
+	if w.db != nil {
+		w.db.Close()
+		w.db = nil
+	}
+	os.Remove(w.tmp)
+}
+
+func (w *Writer) id() int64 {
+	w.nextID++
+	return w.nextID
+}
+
+// Deck возвращает ID колоды с полным именем name (Языки::Немецкий), создавая её и родительские колоды.
+func (w *Writer) Deck(name string) int64 {
+	if id, ok := w.decks[name]; ok {
+		return id
+	}
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		w.Deck(name[:i])
+	}
+	id := w.id()
+	w.decks[name] = id
+	w.order = append(w.order, name)
+	return id
+}
+
+// AddNote добавляет заметку с одной карточкой. guid — устойчивый идентификатор: повторный
+// импорт пакета в Anki обновит заметку, а не создаст копию. sched = nil — новая карточка.
+func (w *Writer) AddNote(deckID int64, guid, front, back string, tags []string, sched *Schedule) error {
+	now := time.Now().Unix()
+	noteID := w.id()
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		// пробел в Anki разделяет теги
+		names[i] = strings.ReplaceAll(t, " ", "_")
+		w.tags[names[i]] = true
+	}
+	tagField := ""
+	if len(names) > 0 {
+		tagField = " " + strings.Join(names, " ") + " "
+	}
+	sort := stripHTML(front)
+	if _, err := w.notes.Exec(noteID, guid, w.modelID, now, tagField, front+"\x1f"+back, sort, checksum(sort)); err != nil {
+		return err
+	}
+	w.position++
+	typ, queue, due, ivl, factor, reps, lapses := CardNew, 0, int64(w.position), 0, 0, 0, 0
+	if sched != nil {
+		typ, queue = CardReview, 2
+		due = int64(math.Floor(sched.Due.Sub(w.created).Hours() / 24))
+		ivl, factor, reps, lapses = max(sched.Interval, 1), sched.Factor, sched.Reps, sched.Lapses
+	}
+	_, err := w.cards.Exec(w.id(), noteID, deckID, now, typ, queue, due, ivl, factor, reps, lapses)
+	return err
+}
+
+// AddMedia добавляет медиафайл; open вызывается при записи архива. Возвращает имя файла
+// в пакете для src картинок и [sound:…]: при совпадении имён добавляется номер.
+func (w *Writer) AddMedia(name string, open func() (io.ReadCloser, error)) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		name = "media"
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; w.names[name]; i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	w.names[name] = true
+	w.media = append(w.media, mediaFile{name: name, open: open})
+	return name
+}
+
+// Finish дописывает коллекцию и выводит архив .apkg в out. После Finish писатель нужно закрыть.
+func (w *Writer) Finish(out io.Writer) error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	if err := copyToZip(zw, "collection.anki2", func() (io.ReadCloser, error) { return os.Open(w.tmp) }); err != nil {
+		return err
+	}
+	index := make(map[string]string, len(w.media))
+	for i, m := range w.media {
+		key := strconv.Itoa(i)
+		if err := copyToZip(zw, key, m.open); err != nil {
+			return err
+		}
+		index[key] = m.name
+	}
+	mw, err := zw.Create("media")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(mw).Encode(index); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func copyToZip(zw *zip.Writer, name string, open func() (io.ReadCloser, error)) error {
+	src, err := open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// finish записывает строку col (типы заметок, колоды, настройки) и закрывает базу.
+func (w *Writer) finish() error {
+	now := time.Now()
+	conf, _ := json.Marshal(map[string]interface{}{
+		"activeDecks": []int64{1}, "curDeck": 1, "newSpread": 0, "collapseTime": 1200, "timeLim": 0,
+		"estTimes": true, "dueCounts": true, "curModel": strconv.FormatInt(w.modelID, 10), "nextPos": w.position + 1,
+		"sortType": "noteFld", "sortBackwards": false, "addToCur": true,
+	})
+	models, _ := json.Marshal(map[string]interface{}{strconv.FormatInt(w.modelID, 10): w.model(now)})
+	decks := make(map[string]interface{}, len(w.order))
+	for _, name := range w.order {
+		id := w.decks[name]
+		decks[strconv.FormatInt(id, 10)] = deckJSON(id, name, now)
+	}
+	decksJSON, _ := json.Marshal(decks)
+	dconf, _ := json.Marshal(map[string]interface{}{"1": defaultDeckConf})
+	tags := make(map[string]int, len(w.tags))
+	for t := range w.tags {
+		tags[t] = 0
+	}
+	tagsJSON, _ := json.Marshal(tags)
+
+	if _, err := w.tx.Exec(`INSERT INTO col (id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags)
+		VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, ?)`,
+		w.created.Unix(), now.UnixMilli(), now.UnixMilli(), string(conf), string(models), string(decksJSON), string(dconf), string(tagsJSON)); err != nil {
+		return err
+	}
+	w.notes.Close()
+	w.cards.Close()
+	err := w.tx.Commit()
+	w.tx = nil
+	if err != nil {
+		return err
+	}
+	err = w.db.Close()
+	w.db = nil
+	return err
+}
+
+func (w *Writer) model(now time.Time) map[string]interface{} {
+	field := func(name string, ord int) map[string]interface{} {
+		return map[string]interface{}{"name": name, "ord": ord, "sticky": false, "rtl": false, "font": "Arial", "size": 20, "media": []string{}}
+	}
+	return map[string]interface{}{
+		"id": w.modelID, "name": "Mozgoemka Basic", "type": ModelStandard, "mod": now.Unix(), "usn": -1,
+		"sortf": 0, "did": 1, "tags": []string{}, "vers": []int{},
+		"flds": []interface{}{field("Front", 0), field("Back", 1)},
+		"tmpls": []interface{}{map[string]interface{}{
+			"name": "Card 1", "ord": 0, "qfmt": basicFront, "afmt": basicBack,
+			"did": nil, "bqfmt": "", "bafmt": "",
+		}},
+		"css":       basicCSS,
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"latexsvg":  false,
+		"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+	}
+}
+
+func deckJSON(id int64, name string, now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "mod": now.Unix(), "usn": -1, "desc": "", "dyn": 0, "conf": 1, "collapsed": false,
+		"browserCollapsed": false, "extendNew": 10, "extendRev": 50,
+		"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+	}
+}
+
+// defaultDeckConf — настройки колод Anki по умолчанию.
+var defaultDeckConf = map[string]interface{}{
+	"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0,
+	"replayq": true, "dyn": false,
+	"new": map[string]interface{}{
+		"delays": []float64{1, 10}, "ints": []int{1, 4, 0}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": false,
+	},
+	"rev": map[string]interface{}{
+		"perDay": 200, "ease4": 1.3, "ivlFct": 1, "maxIvl": 36500, "hardFactor": 1.2, "bury": false,
+	},
+	"lapse": map[string]interface{}{
+		"delays": []float64{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 1,
+	},
+}
+
+// checksum — поле csum заметки: первые 32 бита SHA-1 поля сортировки.
+func checksum(s string) int64 {
+	sum := sha1.Sum([]byte(s))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
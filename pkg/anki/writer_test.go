@@ -0,0 +1,116 @@
+package anki
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Пакет, собранный Writer, должен читаться Open: колоды, теги, шаблон, медиафайлы и расписание.
+func TestWriterRoundTrip(t *testing.T) {
+	w, err := NewWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	img := w.AddMedia("uploads/dog.jpg", func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("jpeg")), nil
+	})
+	dup := w.AddMedia(`C:\files\dog.jpg`, func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("other")), nil
+	})
+	deck := w.Deck("Языки::Немецкий")
+	now := time.Now()
+	// день коллекции — локальная дата создания, поэтому и срок задаём полднем локальной даты
+	due := time.Date(now.Year(), now.Month(), now.Day()+3, 12, 0, 0, 0, time.UTC)
+	notes := []struct {
+		front, back string
+		tags        []string
+		sched       *Schedule
+	}{
+		{"Hund", `собака <img src="` + img + `">`, []string{"de", "animals"}, nil},
+		{"Katze", "кошка", []string{"part of speech"}, &Schedule{Due: due, Interval: 5, Factor: 2500, Reps: 3, Lapses: 1}},
+	}
+	for i, n := range notes {
+		if err := w.AddNote(deck, "guid"+string(rune('a'+i)), n.front, n.back, n.tags, n.sched); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := w.Finish(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if img != "dog.jpg" || dup != "dog-1.jpg" {
+		t.Errorf("media names = %q, %q; want dog.jpg, dog-1.jpg", img, dup)
+	}
+	p, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	var deckNames []string
+	for _, d := range p.Decks {
+		deckNames = append(deckNames, d.Name)
+	}
+	sort.Strings(deckNames)
+	if want := []string{"Default", "Языки", "Языки::Немецкий"}; !reflect.DeepEqual(deckNames, want) {
+		t.Errorf("decks = %q, want %q", deckNames, want)
+	}
+	if len(p.Cards) != len(notes) {
+		t.Fatalf("cards = %d, want %d", len(p.Cards), len(notes))
+	}
+	sort.Slice(p.Cards, func(i, j int) bool { return p.Cards[i].ID < p.Cards[j].ID })
+	for i, c := range p.Cards {
+		q, a, ok := p.Render(c)
+		if !ok || q != notes[i].front || strings.TrimSpace(a) != notes[i].back {
+			t.Errorf("card %d = %q / %q (%v), want %q / %q", i, q, a, ok, notes[i].front, notes[i].back)
+		}
+		if c.DeckID != deck {
+			t.Errorf("card %d deck = %d, want %d", i, c.DeckID, deck)
+		}
+	}
+	if tags := p.Notes[p.Cards[1].NoteID].Tags; !reflect.DeepEqual(tags, []string{"part_of_speech"}) {
+		t.Errorf("tags = %q, want [part_of_speech]", tags)
+	}
+	if c := p.Cards[0]; c.Type != CardNew {
+		t.Errorf("new card type = %d, want %d", c.Type, CardNew)
+	}
+	c := p.Cards[1]
+	if c.Type != CardReview || c.Interval != 5 || c.Factor != 2500 || c.Reps != 3 || c.Lapses != 1 {
+		t.Errorf("review card = %+v", c)
+	}
+	if got := p.Created.UTC().AddDate(0, 0, int(c.Due)); got.Format("2006-01-02") != due.Format("2006-01-02") {
+		t.Errorf("due = %s, want %s", got.Format("2006-01-02"), due.Format("2006-01-02"))
+	}
+
+	if p.MediaCount() != 2 {
+		t.Errorf("media count = %d, want 2", p.MediaCount())
+	}
+	for name, want := range map[string]string{"dog.jpg": "jpeg", "dog-1.jpg": "other"} {
+		rc, err := p.Media(name)
+		if err != nil {
+			t.Fatalf("Media(%q): %v", name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(data) != want {
+			t.Errorf("Media(%q) = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	if _, err := p.Media("cat.jpg"); err != ErrNoMedia {
+		t.Errorf("Media(cat.jpg) error = %v, want ErrNoMedia", err)
+	}
+}
+
+func TestOpenNotPackage(t *testing.T) {
+	data := []byte("not a zip")
+	if _, err := Open(bytes.NewReader(data), int64(len(data))); err != ErrNotPackage {
+		t.Errorf("Open() error = %v, want ErrNotPackage", err)
+	}
+}
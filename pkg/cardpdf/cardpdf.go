@@ -0,0 +1,269 @@
+// Package cardpdf раскладывает карточки для печати: лицевые стороны на нечётных страницах,
+// обороты на чётных, зеркально по горизонтали, чтобы при двусторонней печати они совпали.
+package cardpdf
+
+import (
+	"errors"
+	"image"
+	_ "image/gif" // форматы картинок, которые fpdf умеет встраивать
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Наибольшая сетка карточек на странице.
+const (
+	MaxCols = 6
+	MaxRows = 10
+)
+
+var ErrLayout = errors.New("неверная сетка: допустимо от 1×1 до 6×10 карточек на странице")
+
+// Форматы бумаги.
+const (
+	PaperA4     = "A4"
+	PaperLetter = "Letter"
+)
+
+const (
+	fontFamily  = "go"
+	margin      = 10.0 // поля страницы, мм
+	padding     = 4.0  // отступ текста от линии реза, мм
+	maxFontSize = 16.0
+	minFontSize = 6.0
+	lineHeight  = 0.45 // высота строки в мм на пункт кегля
+)
+
+// Layout — раскладка страницы.
+type Layout struct {
+	Paper    string // PaperA4 или PaperLetter
+	Cols     int
+	Rows     int
+	CutLines bool // пунктирные линии реза между карточками
+	Mirror   bool // зеркалить обороты для двусторонней печати по длинному краю
+}
+
+// Side — сторона карточки: текст и пути к картинкам (JPEG, PNG, GIF).
+type Side struct {
+	Text   string
+	Images []string
+}
+
+type Card struct {
+	Front Side
+	Back  Side
+}
+
+// Document накапливает карточки по страницам; в памяти держится только PDF, а не список карточек.
+type Document struct {
+	pdf     *fpdf.Fpdf
+	layout  Layout
+	cellW   float64
+	cellH   float64
+	pending []Card
+}
+
+// New создаёт документ с раскладкой l. Шрифт Go встраивается в PDF и содержит кириллицу.
+func New(l Layout) (*Document, error) {
+	if l.Cols < 1 || l.Cols > MaxCols || l.Rows < 1 || l.Rows > MaxRows {
+		return nil, ErrLayout
+	}
+	if l.Paper != PaperLetter {
+		l.Paper = PaperA4
+	}
+	pdf := fpdf.New("P", "mm", l.Paper, "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetCellMargin(0)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pageW, pageH := pdf.GetPageSize()
+	return &Document{
+		pdf:    pdf,
+		layout: l,
+		cellW:  (pageW - 2*margin) / float64(l.Cols),
+		cellH:  (pageH - 2*margin) / float64(l.Rows),
+	}, nil
+}
+
+// Add добавляет карточку; заполненный лист (лицевая страница и оборот) выводится сразу.
+func (d *Document) Add(c Card) {
+	d.pending = append(d.pending, c)
+	if len(d.pending) == d.layout.Cols*d.layout.Rows {
+		d.flush()
+	}
+}
+
+// WriteTo выводит последний неполный лист и весь документ в w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	d.flush()
+	if d.pdf.PageCount() == 0 {
+		d.pdf.AddPage()
+	}
+	cw := &countWriter{w: w}
+	err := d.pdf.Output(cw)
+	return cw.n, err
+}
+
+func (d *Document) flush() {
+	if len(d.pending) == 0 {
+		return
+	}
+	cols := d.layout.Cols
+	d.page(func(i int) (int, *Side) { return i % cols, &d.pending[i].Front })
+	d.page(func(i int) (int, *Side) {
+		col := i % cols
+		if d.layout.Mirror {
+			col = cols - 1 - col
+		}
+		return col, &d.pending[i].Back
+	})
+	d.pending = d.pending[:0]
+}
+
+// page выводит страницу; side возвращает колонку и сторону i-й карточки листа.
+func (d *Document) page(side func(i int) (int, *Side)) {
+	d.pdf.AddPage()
+	if d.layout.CutLines {
+		d.cutLines()
+	}
+	for i := range d.pending {
+		col, s := side(i)
+		row := i / d.layout.Cols
+		d.cell(margin+float64(col)*d.cellW, margin+float64(row)*d.cellH, s)
+	}
+}
+
+func (d *Document) cutLines() {
+	d.pdf.SetDrawColor(170, 170, 170)
+	d.pdf.SetLineWidth(0.2)
+	d.pdf.SetDashPattern([]float64{2, 1.5}, 0)
+	pageW, pageH := d.pdf.GetPageSize()
+	for c := 0; c <= d.layout.Cols; c++ {
+		x := margin + float64(c)*d.cellW
+		d.pdf.Line(x, margin/2, x, pageH-margin/2)
+	}
+	for r := 0; r <= d.layout.Rows; r++ {
+		y := margin + float64(r)*d.cellH
+		d.pdf.Line(margin/2, y, pageW-margin/2, y)
+	}
+	d.pdf.SetDashPattern([]float64{}, 0)
+}
+
+// cell выводит сторону карточки в ячейку (x, y): картинки сверху, текст под ними, всё по центру.
+func (d *Document) cell(x, y float64, s *Side) {
+	w, h := d.cellW-2*padding, d.cellH-2*padding
+	x, y = x+padding, y+padding
+	text := printable(s.Text)
+
+	var images []*fpdf.ImageInfoType
+	var paths []string
+	for _, p := range s.Images {
+		if info := d.image(p); info != nil {
+			images = append(images, info)
+			paths = append(paths, p)
+		}
+	}
+	imgH := 0.0
+	if len(images) > 0 {
+		imgH = h
+		if text != "" {
+			imgH = h * 0.6
+		}
+		slotW := w / float64(len(images))
+		for i, info := range images {
+			iw, ih := fit(info.Width(), info.Height(), slotW-1, imgH)
+			ix := x + float64(i)*slotW + (slotW-iw)/2
+			d.pdf.ImageOptions(paths[i], ix, y+(imgH-ih)/2, iw, ih, false, fpdf.ImageOptions{}, 0, "")
+		}
+		h -= imgH
+		y += imgH
+	}
+	if text == "" || h <= 0 {
+		return
+	}
+	size, lines := d.fitText(text, w, h)
+	lh := size * lineHeight
+	top := y + (h-lh*float64(len(lines)))/2
+	for i, line := range lines {
+		d.pdf.SetXY(x, top+float64(i)*lh)
+		d.pdf.CellFormat(w, lh, line, "", 0, "C", false, 0, "")
+	}
+}
+
+// fitText подбирает наибольший кегль, при котором текст помещается в w×h; на минимальном
+// кегле лишние строки отбрасываются с многоточием.
+func (d *Document) fitText(text string, w, h float64) (float64, []string) {
+	size := maxFontSize
+	for {
+		d.pdf.SetFont(fontFamily, "", size)
+		lines := d.pdf.SplitText(text, w)
+		maxLines := int(h / (size * lineHeight))
+		if len(lines) <= maxLines || size <= minFontSize {
+			if len(lines) > maxLines && maxLines > 0 {
+				lines = lines[:maxLines]
+				lines[maxLines-1] += "…"
+			}
+			return size, lines
+		}
+		size -= 1
+	}
+}
+
+// image регистрирует картинку; nil, если файл не читается или формат не поддерживается.
+func (d *Document) image(path string) *fpdf.ImageInfoType {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	_, format, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+	info := d.pdf.RegisterImageOptions(path, fpdf.ImageOptions{ImageType: format})
+	if !d.pdf.Ok() {
+		// ошибка fpdf «залипает» и сорвала бы весь документ
+		d.pdf.ClearError()
+		return nil
+	}
+	return info
+}
+
+// fit вписывает размеры w×h в maxW×maxH с сохранением пропорций.
+func fit(w, h, maxW, maxH float64) (float64, float64) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	k := min(maxW/w, maxH/h)
+	return w * k, h * k
+}
+
+// printable убирает символы вне базовой плоскости Unicode (эмодзи): шрифт и fpdf их не поддерживают.
+func printable(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r > 0xFFFF {
+			return -1
+		}
+		if r == '\t' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}